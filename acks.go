@@ -1,9 +1,13 @@
 package gosocketio
 
 import (
+	"encoding/json"
 	"errors"
+	"sort"
 	"sync"
 
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
 	"github.com/mtfelian/synced"
 )
 
@@ -11,12 +15,54 @@ var (
 	ErrorAckWaiterNotFound = errors.New("ack waiter not found")
 )
 
+// PendingAck is an ack request of the server not answered when the session was suspended,
+// it's sent again to the Channel resuming the session
+type PendingAck struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	Namespace string          `json:"namespace,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+
+	waiter *ackWaiter // the caller waiting for the response on this node, nil if the session came from the store
+}
+
+// ackWaiter awaits the response to an ack request, it follows the request to the Channel resuming the session
+type ackWaiter struct {
+	ackC     chan string   // buffered, so the response is delivered without blocking
+	stoppedC chan struct{} // closed when the caller stops waiting
+	once     sync.Once
+	request  *PendingAck // kept for the session resumption, nil if it isn't kept
+}
+
+// newAckWaiter returns a new ackWaiter for the request, which may be nil
+func newAckWaiter(request *PendingAck) *ackWaiter {
+	return &ackWaiter{ackC: make(chan string, 1), stoppedC: make(chan struct{}), request: request}
+}
+
+// follow returns the waiter for the request sent again to the Channel resuming the session
+func (w *ackWaiter) follow(request *PendingAck) *ackWaiter {
+	return &ackWaiter{ackC: w.ackC, stoppedC: w.stoppedC, request: request}
+}
+
+// stop waiting for the response
+func (w *ackWaiter) stop() { w.once.Do(func() { close(w.stoppedC) }) }
+
+// stopped returns true if the caller doesn't wait for the response anymore
+func (w *ackWaiter) stopped() bool {
+	select {
+	case <-w.stoppedC:
+		return true
+	default:
+		return false
+	}
+}
+
 // acks represents chans needed for Ack messages to work
 type acks struct {
 	count synced.Counter
 
-	ackC  map[int]chan string
-	ackMu sync.RWMutex
+	waiters map[int]*ackWaiter
+	ackMu   sync.RWMutex
 }
 
 // nextId of ack waiter
@@ -26,27 +72,99 @@ func (a *acks) nextId() int {
 }
 
 // register new ack request waiter
-func (a *acks) register(id int, ackC chan string) {
+func (a *acks) register(id int, w *ackWaiter) {
 	a.ackMu.Lock()
-	a.ackC[id] = ackC
+	a.waiters[id] = w
 	a.ackMu.Unlock()
 }
 
 // unregister a waiter by ack id that is unnecessary anymore
 func (a *acks) unregister(id int) {
 	a.ackMu.Lock()
-	delete(a.ackC, id)
+	delete(a.waiters, id)
+	a.ackMu.Unlock()
+}
+
+// deliver the response to the waiter at given ack id and unregister it
+func (a *acks) deliver(id int, response string) error {
+	a.ackMu.Lock()
+	w, ok := a.waiters[id]
+	delete(a.waiters, id)
 	a.ackMu.Unlock()
+
+	if !ok {
+		return ErrorAckWaiterNotFound
+	}
+	select {
+	case w.ackC <- response:
+	default: // duplicated response
+	}
+	return nil
 }
 
-// obtain checks that waiter at given ack id exists and returns the appropriate chan
-func (a *acks) obtain(id int) (chan string, error) {
-	a.ackMu.RLock()
-	defer a.ackMu.RUnlock()
+// pending returns the kept requests awaiting the response in order, the waiters stopped are dropped
+func (a *acks) pending() []PendingAck {
+	a.ackMu.Lock()
+	defer a.ackMu.Unlock()
 
-	if ackC, ok := a.ackC[id]; ok {
-		return ackC, nil
+	var requests []PendingAck
+	for id, w := range a.waiters {
+		if w.stopped() {
+			delete(a.waiters, id)
+			continue
+		}
+		if w.request != nil {
+			request := *w.request
+			request.waiter = w
+			requests = append(requests, request)
+		}
 	}
+	sort.Slice(requests, func(i, j int) bool { return requests[i].ID < requests[j].ID })
+	return requests
+}
 
-	return nil, ErrorAckWaiterNotFound
+// pendingAck returns the request m with payload to keep for the session resumption,
+// nil if the session of the Channel can't be resumed
+func (c *Channel) pendingAck(m *protocol.Message, payload interface{}) *PendingAck {
+	if !c.resumable() {
+		return nil
+	}
+
+	request := &PendingAck{ID: m.AckID, Name: m.EventName, Namespace: m.Namespace}
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil
+		}
+		request.Payload = b
+	}
+	return request
+}
+
+// resendAcks sends again the ack requests of the resumed session, the responses reach the callers
+// still waiting on this node
+func (c *Channel) resendAcks(requests []PendingAck) {
+	for _, request := range requests {
+		request := request // the waiter follows its own copy
+		w := request.waiter
+		if w == nil {
+			w = newAckWaiter(nil)
+		} else if w.stopped() {
+			continue
+		}
+
+		request.ID, request.waiter = c.ack.nextId(), nil
+		c.ack.register(request.ID, w.follow(&request))
+
+		var payload interface{}
+		if len(request.Payload) > 0 {
+			payload = request.Payload
+		}
+		m := &protocol.Message{Type: protocol.MessageTypeAckRequest, AckID: request.ID, EventName: request.Name,
+			Namespace: request.Namespace}
+		if err := c.sendWith(m, payload, &packet{}, false); err != nil {
+			logging.Log().Warn("Channel.resendAcks() drops the pending ack requests:", err)
+			return
+		}
+	}
 }
@@ -0,0 +1,194 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+const (
+	// AdminNamespace is the namespace of the admin instrumentation, see ServeAdmin
+	AdminNamespace = "/admin"
+
+	// DefaultAdminStatsInterval is the interval of the server_stats events
+	DefaultAdminStatsInterval = 2 * time.Second
+)
+
+// AdminSocket describes the channel in the all_sockets event of the admin namespace
+type AdminSocket struct {
+	ID          string    `json:"id"`
+	Tenant      string    `json:"tenant,omitempty"`
+	Address     string    `json:"address"`
+	Rooms       []string  `json:"rooms"`
+	ConnectedAt time.Time `json:"connectedAt"`
+}
+
+// AdminStats is the payload of the server_stats event of the admin namespace
+type AdminStats struct {
+	Hostname     string `json:"hostname"`
+	Pid          int    `json:"pid"`
+	Uptime       int64  `json:"uptime"` // seconds since ServeAdmin
+	ClientsCount int    `json:"clientsCount"`
+	RoomsCount   int    `json:"roomsCount"`
+}
+
+// AdminConfig is the payload of the config event of the admin namespace
+type AdminConfig struct {
+	ReadOnly bool `json:"readonly"`
+}
+
+// AdminCommand is the payload of the commands of the admin namespace: "_join" and "_leave" the Room by the channel
+// with the Sid, "_disconnect" the channel, "_emit" the Event with the Payload to the channel, or to the Room
+// of the Tenant if Sid is empty. "_fetch" without payload asks for the all_sockets event
+type AdminCommand struct {
+	Sid     string          `json:"sid"`
+	Tenant  string          `json:"tenant"`
+	Room    string          `json:"room"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// admin holds the channels authorized in the admin namespace
+type admin struct {
+	s          *Server
+	ns         *Namespace
+	readOnly   bool
+	interval   time.Duration
+	started    time.Time
+	authorized map[*Channel]chan struct{} // closed when the channel leaves the namespace
+	mu         sync.Mutex
+}
+
+// ServeAdmin serves the admin instrumentation in AdminNamespace modeled on the events of the socket.io Admin UI,
+// so the dashboards can list the sockets and rooms, make the sockets join and leave the rooms, emit and disconnect
+// them. The channels connected to the namespace and allowed by authorize get the config and all_sockets events and
+// then server_stats every interval, DefaultAdminStatsInterval if it's zero. The commands take the single
+// AdminCommand object, they are accepted unless readOnly and audited with AuditAdmin. The commands of the channels
+// not authorized are ignored, nil authorize refuses all the channels
+func (s *Server) ServeAdmin(authorize func(c *Channel) bool, readOnly bool, interval time.Duration) *Namespace {
+	if interval <= 0 {
+		interval = DefaultAdminStatsInterval
+	}
+	a := &admin{s: s, ns: s.Of(AdminNamespace), readOnly: readOnly, interval: interval, started: s.now(),
+		authorized: make(map[*Channel]chan struct{})}
+
+	a.ns.On(OnConnection, func(c *Channel) {
+		if authorize == nil || !authorize(c) {
+			s.audit(AuditAuthFailure, c, "", "admin namespace")
+			return
+		}
+		stop := make(chan struct{})
+		a.mu.Lock()
+		a.authorized[c] = stop
+		a.mu.Unlock()
+
+		a.emit(c, "config", AdminConfig{ReadOnly: readOnly})
+		a.emit(c, "all_sockets", a.sockets())
+		go a.statsLoop(c, stop)
+	})
+	a.ns.On(OnDisconnection, func(c *Channel) {
+		a.mu.Lock()
+		if stop, ok := a.authorized[c]; ok {
+			close(stop)
+			delete(a.authorized, c)
+		}
+		a.mu.Unlock()
+	})
+
+	a.ns.On("_fetch", func(c *Channel) {
+		if a.isAuthorized(c) {
+			a.emit(c, "all_sockets", a.sockets())
+		}
+	})
+	a.command("_join", func(target *Channel, cmd AdminCommand) error { return target.Join(cmd.Room) })
+	a.command("_leave", func(target *Channel, cmd AdminCommand) error { return target.Leave(cmd.Room) })
+	a.command("_disconnect", func(target *Channel, cmd AdminCommand) error { return target.Close() })
+	a.ns.On("_emit", func(c *Channel, cmd AdminCommand) {
+		if !a.accepts(c, "_emit", cmd) {
+			return
+		}
+		var payload interface{}
+		if len(cmd.Payload) > 0 {
+			payload = cmd.Payload
+		}
+		if cmd.Sid == "" {
+			s.Tenant(cmd.Tenant).BroadcastTo(cmd.Room, cmd.Event, payload)
+			return
+		}
+		if err := s.Bus().Publish(cmd.Sid, cmd.Event, payload); err != nil {
+			logging.Log().Debug("Server.ServeAdmin() can't emit:", err)
+		}
+	})
+	return a.ns
+}
+
+// command registers the handler of the admin command applied to the channel with the command sid
+func (a *admin) command(name string, f func(target *Channel, cmd AdminCommand) error) {
+	a.ns.On(name, func(c *Channel, cmd AdminCommand) {
+		if !a.accepts(c, name, cmd) {
+			return
+		}
+		target, err := a.s.GetChannel(cmd.Sid)
+		if err == nil {
+			err = f(target, cmd)
+		}
+		if err != nil {
+			logging.Log().Debugf("Server.ServeAdmin() %s of %s failed: %v", name, cmd.Sid, err)
+		}
+	})
+}
+
+// accepts returns whether the command of the channel c is accepted, auditing it
+func (a *admin) accepts(c *Channel, name string, cmd AdminCommand) bool {
+	if !a.isAuthorized(c) || a.readOnly {
+		return false
+	}
+	a.s.audit(AuditAdmin, c, cmd.Room, "admin namespace: "+name+" "+cmd.Sid)
+	return true
+}
+
+// isAuthorized returns whether the channel c is authorized in the namespace
+func (a *admin) isAuthorized(c *Channel) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.authorized[c]
+	return ok
+}
+
+// emit the event to the admin channel c
+func (a *admin) emit(c *Channel, name string, payload interface{}) {
+	if err := a.ns.Emit(c, name, payload); err != nil {
+		logging.Log().Debugf("Server.ServeAdmin() can't send %s to %s: %v", name, c.Id(), err)
+	}
+}
+
+// sockets returns the connected channels
+func (a *admin) sockets() []AdminSocket {
+	channels := a.s.channelsList()
+	sockets := make([]AdminSocket, 0, len(channels))
+	for _, c := range channels {
+		sockets = append(sockets, AdminSocket{ID: c.Id(), Tenant: c.Tenant(), Address: c.IP(), Rooms: c.Rooms(),
+			ConnectedAt: c.ConnectedAt()})
+	}
+	return sockets
+}
+
+// statsLoop sends server_stats to the admin channel c until stop is closed or c is closed
+func (a *admin) statsLoop(c *Channel, stop chan struct{}) {
+	hostname, _ := os.Hostname()
+	for {
+		a.emit(c, "server_stats", AdminStats{Hostname: hostname, Pid: os.Getpid(),
+			Uptime: int64(a.s.since(a.started) / time.Second), ClientsCount: a.s.CountChannels(),
+			RoomsCount: a.s.CountRooms()})
+		select {
+		case <-a.s.after(a.interval):
+		case <-stop:
+			return
+		case <-c.Done():
+			return
+		}
+	}
+}
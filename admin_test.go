@@ -0,0 +1,149 @@
+package gosocketio
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestAdminNamespace(t *testing.T) {
+	s := NewServer()
+	var (
+		refused string // sid of the channel not authorized in the admin namespace
+		mu      sync.Mutex
+	)
+	s.ServeAdmin(func(c *Channel) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return c.Id() != refused
+	}, false, 10*time.Millisecond)
+	connected := make(chan string, 2)
+	s.On(OnConnection, func(c *Channel) { connected <- c.Id() })
+	disconnected := make(chan string, 2)
+	s.On(OnDisconnection, func(c *Channel) { disconnected <- c.Id() })
+	refusals := make(chan string, 1)
+	s.OnAudit(func(r AuditRecord) {
+		if r.Action == AuditAuthFailure {
+			refusals <- r.Sid
+		}
+	})
+	host, port, stop := serve(t, s)
+	defer stop()
+	addr := AddrWebsocket(host, port, false)
+
+	target, err := NewManager(addr, transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	news := make(chan string, 1)
+	target.Socket("/").On("news", func(c *Channel, m string) { news <- m })
+	targetSid := receive(t, connected, "target connection")
+	mu.Lock()
+	refused = targetSid
+	mu.Unlock()
+
+	m, err := NewManager(addr, transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	receive(t, connected, "admin connection")
+	dashboard := m.Socket(AdminNamespace)
+	configs := make(chan AdminConfig, 1)
+	dashboard.On("config", func(c *Channel, cfg AdminConfig) { configs <- cfg })
+	sockets := make(chan []AdminSocket, 4)
+	dashboard.On("all_sockets", func(c *Channel, s []AdminSocket) { sockets <- s })
+	stats := make(chan AdminStats, 64)
+	dashboard.On("server_stats", func(c *Channel, s AdminStats) {
+		select {
+		case stats <- s:
+		default:
+		}
+	})
+	if err := dashboard.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case cfg := <-configs:
+		if cfg.ReadOnly {
+			t.Fatal("admin namespace is read only")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no config")
+	}
+	// fetched waits for all_sockets returning the socket of the target
+	fetched := func() AdminSocket {
+		t.Helper()
+		select {
+		case list := <-sockets:
+			for _, socket := range list {
+				if socket.ID == targetSid {
+					return socket
+				}
+			}
+			t.Fatal("all_sockets don't list the target:", list)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no all_sockets")
+		}
+		return AdminSocket{}
+	}
+	if socket := fetched(); len(socket.Rooms) != 0 {
+		t.Fatal("rooms of the target:", socket.Rooms)
+	}
+	select {
+	case s := <-stats:
+		if s.ClientsCount != 2 {
+			t.Fatal("clients in the server_stats:", s.ClientsCount)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no server_stats")
+	}
+
+	if err := dashboard.Emit("_join", AdminCommand{Sid: targetSid, Room: "lobby"}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); s.Amount("lobby") == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("target isn't joined to the room")
+		}
+	}
+	if err := dashboard.Emit("_fetch", nil); err != nil {
+		t.Fatal(err)
+	}
+	if socket := fetched(); len(socket.Rooms) != 1 || socket.Rooms[0] != "lobby" {
+		t.Fatal("rooms of the target after the join:", socket.Rooms)
+	}
+	if err := dashboard.Emit("_emit", AdminCommand{Room: "lobby", Event: "news", Payload: []byte(`"hello"`)}); err != nil {
+		t.Fatal(err)
+	}
+	if n := receive(t, news, "emitted event"); n != "hello" {
+		t.Fatal("emitted event:", n)
+	}
+
+	intruder := target.Socket(AdminNamespace)
+	if err := intruder.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if sid := receive(t, refusals, "auth failure"); sid != targetSid {
+		t.Fatal("refused:", sid)
+	}
+	if err := intruder.Emit("_disconnect", AdminCommand{Sid: targetSid}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sid := <-disconnected:
+		t.Fatal("not authorized channel disconnected", sid)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := dashboard.Emit("_disconnect", AdminCommand{Sid: targetSid}); err != nil {
+		t.Fatal(err)
+	}
+	if sid := receive(t, disconnected, "disconnection"); sid != targetSid {
+		t.Fatal("disconnected:", sid)
+	}
+}
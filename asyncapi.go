@@ -0,0 +1,206 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AsyncAPIVersion is a version of the AsyncAPI specification of the exported documents
+const AsyncAPIVersion = "2.0.0"
+
+// internalEventPrefix starts the names of the events handled by the package itself
+const internalEventPrefix = "sio:"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// systemEvents are not sent by the peer, they aren't exported
+var systemEvents = map[string]bool{
+	OnConnection: true, OnDisconnection: true, OnError: true, OnUpgrade: true,
+	OnPong: true, OnIdleDisconnect: true, OnReconnect: true,
+}
+
+// AsyncAPI returns the AsyncAPI document describing the events handled by the server with the given title
+// and API version. Each namespace is a channel, the events are messages published by the clients,
+// payload schemas are derived from the handler arguments, the ack responses are in the x-ack extension
+func (s *Server) AsyncAPI(title, version string) ([]byte, error) {
+	channels := map[string]interface{}{"/": s.event.asyncAPIChannel()}
+
+	s.event.handlersMu.RLock()
+	namespaces := make(map[string]*event, len(s.event.namespaces))
+	for name, ns := range s.event.namespaces {
+		namespaces[name] = ns
+	}
+	s.event.handlersMu.RUnlock()
+	for name, ns := range namespaces {
+		channels[name] = ns.asyncAPIChannel()
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"asyncapi": AsyncAPIVersion,
+		"info":     map[string]string{"title": title, "version": version},
+		"channels": channels,
+	}, "", "  ")
+}
+
+// AsyncAPIHandler returns the HTTP handler serving the AsyncAPI document of the server, see AsyncAPI
+func (s *Server) AsyncAPIHandler(title, version string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, err := s.AsyncAPI(title, version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+}
+
+// asyncAPIChannel returns the AsyncAPI channel object of the events handled by e
+func (e *event) asyncAPIChannel() map[string]interface{} {
+	e.handlersMu.RLock()
+	names := make([]string, 0, len(e.handlers))
+	for name := range e.handlers {
+		if !systemEvents[name] && !strings.HasPrefix(name, internalEventPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	messages := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		messages = append(messages, e.handlers[name].asyncAPIMessage(name))
+	}
+	e.handlersMu.RUnlock()
+
+	if len(messages) == 0 {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{"publish": map[string]interface{}{"message": map[string]interface{}{"oneOf": messages}}}
+}
+
+// asyncAPIMessage returns the AsyncAPI message object of the event with the given name handled by h
+func (h *handler) asyncAPIMessage(name string) map[string]interface{} {
+	message := map[string]interface{}{"name": name}
+	if h.rpc {
+		message["payload"] = rpcSchema(reflect.TypeOf(callRequest{}), "params", h.rpcParams)
+		message["x-ack"] = rpcSchema(reflect.TypeOf(callResponse{}), "result", h.rpcResult)
+		return message
+	}
+	switch {
+	case h.hasArgs && isRaw(h.args):
+		message["payload"] = map[string]interface{}{}
+	case h.hasArgs:
+		message["payload"] = jsonSchema(h.args, make(map[reflect.Type]bool))
+	}
+	if h.out {
+		message["x-ack"] = jsonSchema(h.function.Type().Out(0), make(map[reflect.Type]bool))
+	}
+	return message
+}
+
+// rpcSchema returns the JSON schema of the call envelope type t with the property of type value, it may be nil
+func rpcSchema(t reflect.Type, property string, value reflect.Type) map[string]interface{} {
+	schema := jsonSchema(t, make(map[reflect.Type]bool))
+	properties := schema["properties"].(map[string]interface{})
+	if value == nil {
+		delete(properties, property)
+	} else {
+		properties[property] = jsonSchema(value, make(map[reflect.Type]bool))
+	}
+	return schema
+}
+
+// jsonSchema returns the JSON schema of values of type t marshaled with encoding/json,
+// seen holds the struct types being described to stop at recursive types
+func jsonSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return structSchema(t, seen)
+	}
+	return map[string]interface{}{} // interface{} and other types accept any value
+}
+
+// structSchema returns the JSON schema of the struct type t, see jsonSchema
+func structSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous { // unexported
+			continue
+		}
+
+		name, options := field.Name, ""
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if comma := strings.Index(tag, ","); comma >= 0 {
+				tag, options = tag[:comma], tag[comma:]
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		if field.Anonymous && name == field.Name {
+			embedded := jsonSchema(field.Type, seen)
+			if props, ok := embedded["properties"].(map[string]interface{}); ok {
+				for k, v := range props {
+					properties[k] = v
+				}
+				continue
+			}
+		}
+
+		properties[name] = jsonSchema(field.Type, seen)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
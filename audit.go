@@ -0,0 +1,79 @@
+package gosocketio
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// AuditAction is a kind of the security-relevant action
+type AuditAction string
+
+const (
+	AuditConnect          AuditAction = "connect"
+	AuditDisconnect       AuditAction = "disconnect"
+	AuditAuthFailure      AuditAction = "authFailure"
+	AuditForcedDisconnect AuditAction = "forcedDisconnect" // Channel.Close() called on the server side
+	AuditJoin             AuditAction = "join"
+	AuditLeave            AuditAction = "leave"
+	AuditAdmin            AuditAction = "admin" // emit API, shedding, draining
+)
+
+// AuditUserKey is a Channel store key of the user identity put into audit records
+const AuditUserKey = "user"
+
+// AuditRecord describes the security-relevant action
+type AuditRecord struct {
+	Time      time.Time   `json:"time"`
+	Action    AuditAction `json:"action"`
+	Sid       string      `json:"sid,omitempty"`
+	User      string      `json:"user,omitempty"`
+	IP        string      `json:"ip,omitempty"`
+	Namespace string      `json:"namespace"`
+	Room      string      `json:"room,omitempty"`
+	Detail    string      `json:"detail,omitempty"`
+}
+
+// OnAudit registers a handler called synchronously for each security-relevant action, e.g. to feed a SIEM
+func (s *Server) OnAudit(f func(r AuditRecord)) {
+	s.auditMu.Lock()
+	s.onAudit = f
+	s.auditMu.Unlock()
+}
+
+// emitAudit passes the record r to the OnAudit handler if it's registered
+func (s *Server) emitAudit(r AuditRecord) {
+	if s == nil {
+		return
+	}
+
+	s.auditMu.RLock()
+	f := s.onAudit
+	s.auditMu.RUnlock()
+
+	if f != nil {
+		r.Time, r.Namespace = time.Now(), defaultNamespace
+		f(r)
+	}
+}
+
+// audit the action on the channel c, c may be nil for the server-wide actions
+func (s *Server) audit(action AuditAction, c *Channel, room, detail string) {
+	r := AuditRecord{Action: action, Room: room, Detail: detail}
+	if c != nil {
+		r.Sid, r.IP = c.Id(), c.IP()
+		if user, ok := c.Get(AuditUserKey); ok {
+			r.User = fmt.Sprint(user)
+		}
+	}
+	s.emitAudit(r)
+}
+
+// auditRequest audits the action requested by HTTP request r
+func (s *Server) auditRequest(action AuditAction, r *http.Request, detail string) {
+	ip := r.Header.Get(headerForward)
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+	s.emitAudit(AuditRecord{Action: action, IP: ip, Detail: detail})
+}
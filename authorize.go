@@ -0,0 +1,85 @@
+package gosocketio
+
+import (
+	"errors"
+	"net/http"
+)
+
+// RolesKey is a Channel store key of the role claims checked by RequireRole, the value is a string or []string
+const RolesKey = "roles"
+
+var ErrorForbidden = errors.New("forbidden")
+
+// Middleware is called before the handler of the incoming event with the given name,
+// the event is rejected if it returns an error
+type Middleware func(c *Channel, name string) error
+
+// RequireRole returns a middleware rejecting the events from channels without the given role in the RolesKey store value
+func RequireRole(role string) Middleware {
+	return func(c *Channel, name string) error {
+		if !c.HasRole(role) {
+			return ErrorForbidden
+		}
+		return nil
+	}
+}
+
+// HasRole returns true if the channel has the given role in the RolesKey store value
+func (c *Channel) HasRole(role string) bool {
+	value, ok := c.Get(RolesKey)
+	if !ok {
+		return false
+	}
+
+	switch roles := value.(type) {
+	case string:
+		return roles == role
+	case []string:
+		for _, r := range roles {
+			if r == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OnWith registers the handler f for the event with the given name like On, the middlewares are called in order
+// before it. Rejected events are audited and answered with the error event of ServerError with 403 code
+func (e *event) OnWith(name string, f interface{}, middlewares ...Middleware) error {
+	h, err := newHandler(f)
+	if err != nil {
+		return err
+	}
+	h.middlewares = middlewares
+
+	e.handlersMu.Lock()
+	e.handlers[name] = h
+	e.handlersMu.Unlock()
+
+	return nil
+}
+
+// OnAuthorized registers the handler f for the event with the given name
+// accepting it only from the channels with the required role, see RequireRole
+func (e *event) OnAuthorized(name, requiredRole string, f interface{}) error {
+	return e.OnWith(name, f, RequireRole(requiredRole))
+}
+
+// authorize the event with the given name on channel c with the handler middlewares,
+// rejected event is audited and answered with the error event
+func (h *handler) authorize(c *Channel, name string) bool {
+	for _, middleware := range h.middlewares {
+		err := middleware(c, name)
+		if err == nil {
+			continue
+		}
+
+		if c.server != nil {
+			c.server.audit(AuditAuthFailure, c, "", "event "+name+": "+err.Error())
+		}
+		c.EmitError(http.StatusForbidden, err.Error(), name)
+		return false
+	}
+	return true
+}
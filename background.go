@@ -0,0 +1,132 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// BackgroundEvent is an ack request of the client entering or leaving the background state
+const BackgroundEvent = "sio:background"
+
+const (
+	DefaultBackgroundPingInterval = 2 * time.Minute
+	DefaultBackgroundPingTimeout  = time.Minute
+)
+
+// BackgroundParams is a payload of the BackgroundEvent request with the ping params wanted by the client,
+// and of its response with the ping params granted by the server, in milliseconds
+type BackgroundParams struct {
+	Background   bool `json:"background"`
+	PingInterval int  `json:"pingInterval"`
+	PingTimeout  int  `json:"pingTimeout"`
+}
+
+// SetBackground tells the server the application entered or left the background state, e.g. the mobile app
+// was minimized. In background the heartbeats interval and timeout are lengthened as negotiated with the server,
+// see Server.SetBackgroundPingLimits, and the low priority emits of both sides are held until the foreground
+func (c *Client) SetBackground(background bool) error {
+	c.Channel.setBackground(background)
+
+	params := BackgroundParams{Background: background}
+	if background {
+		params.PingInterval = int(DefaultBackgroundPingInterval / time.Millisecond)
+		params.PingTimeout = int(DefaultBackgroundPingTimeout / time.Millisecond)
+	}
+
+	_, timeout := c.PingParams()
+	result, err := c.Ack(BackgroundEvent, params, timeout)
+	if err != nil {
+		return err
+	}
+
+	var granted BackgroundParams
+	if err := json.Unmarshal([]byte(result), &granted); err != nil {
+		return err
+	}
+	c.Channel.backgroundPing(background, time.Duration(granted.PingInterval)*time.Millisecond,
+		time.Duration(granted.PingTimeout)*time.Millisecond)
+	return nil
+}
+
+// SetBackgroundPingLimits sets the maximum ping interval and timeout granted to the clients in background,
+// zero interval keeps the usual ping params. They're DefaultBackgroundPingInterval and
+// DefaultBackgroundPingTimeout by default. The read timeout of the connection is lengthened accordingly
+func (s *Server) SetBackgroundPingLimits(interval, timeout time.Duration) {
+	s.backgroundMu.Lock()
+	s.backgroundInterval, s.backgroundTimeout = interval, timeout
+	s.backgroundMu.Unlock()
+}
+
+// serveBackground registers the BackgroundEvent handler of the server
+func (s *Server) serveBackground() {
+	s.On(BackgroundEvent, func(c *Channel, p BackgroundParams) BackgroundParams {
+		interval, timeout := c.connection().PingParams()
+		if p.Background {
+			s.backgroundMu.RLock()
+			maxInterval, maxTimeout := s.backgroundInterval, s.backgroundTimeout
+			s.backgroundMu.RUnlock()
+
+			if maxInterval > 0 {
+				interval = clampDuration(time.Duration(p.PingInterval)*time.Millisecond, interval, maxInterval)
+				timeout = clampDuration(time.Duration(p.PingTimeout)*time.Millisecond, timeout, maxTimeout)
+			}
+		}
+
+		c.setBackground(p.Background)
+		c.backgroundPing(p.Background, interval, timeout)
+		return BackgroundParams{Background: p.Background,
+			PingInterval: int(interval / time.Millisecond), PingTimeout: int(timeout / time.Millisecond)}
+	})
+}
+
+// clampDuration returns d limited to the range from lo to hi
+func clampDuration(d, lo, hi time.Duration) time.Duration {
+	if d > hi {
+		d = hi
+	}
+	if d < lo {
+		d = lo
+	}
+	return d
+}
+
+// IsBackground returns whether the client of the Channel is in the background state, see Client.SetBackground
+func (c *Channel) IsBackground() bool {
+	c.backgroundMu.Lock()
+	defer c.backgroundMu.Unlock()
+	return c.background
+}
+
+// setBackground sets the background state of the Channel holding or resuming the low priority emits
+func (c *Channel) setBackground(background bool) {
+	c.backgroundMu.Lock()
+	c.background = background
+	c.backgroundMu.Unlock()
+	c.wakeLowLane()
+}
+
+// backgroundPing applies the ping params negotiated for the background state, the read timeout of the connection
+// is lengthened to the ping interval plus timeout in background and restored in foreground
+func (c *Channel) backgroundPing(background bool, interval, timeout time.Duration) {
+	c.setPingParams(interval, timeout)
+	if conn, ok := c.connection().(transport.ReceiveTimeoutSetter); ok {
+		if background {
+			conn.SetReceiveTimeout(interval + timeout)
+		} else {
+			conn.SetReceiveTimeout(0)
+		}
+	}
+}
+
+// lowLanePaused returns whether the low priority emits are held, they aren't while draining
+func (c *Channel) lowLanePaused() bool { return c.IsBackground() && !c.isDraining() }
+
+// wakeLowLane makes outLoop waiting for the packets to check again whether the low priority lane is paused
+func (c *Channel) wakeLowLane() {
+	select {
+	case c.lowWakeC <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,47 @@
+package gosocketio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestFlushInBackground(t *testing.T) {
+	s := NewServer()
+	connected := make(chan *Channel, 1)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	host, port, stop := serve(t, s)
+	defer stop()
+
+	client, err := Dial(AddrWebsocket(host, port, false), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	received := make(chan string, 2)
+	client.On("update", func(c *Channel, m string) { received <- m })
+	c := <-connected
+
+	c.setBackground(true)
+	if err := c.EmitWithPriority("update", "low", PriorityLow); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Emit("update", "normal"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal("flush in background:", err)
+	}
+	if m := receive(t, received, "normal emit"); m != "normal" {
+		t.Fatal("emit received in background:", m)
+	}
+
+	c.setBackground(false)
+	if m := receive(t, received, "held emit"); m != "low" {
+		t.Fatal("emit received in foreground:", m)
+	}
+}
@@ -0,0 +1,149 @@
+package gosocketio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// BandwidthAction is what happens when the Channel receives more data than the bandwidth quota allows
+type BandwidthAction int
+
+const (
+	BandwidthThrottle   BandwidthAction = iota // stop reading from the connection until the quota allows
+	BandwidthWarn                              // only call the OnBandwidthExceeded handler
+	BandwidthDisconnect                        // close the Channel with DisconnectBandwidthExceeded reason
+)
+
+// BandwidthQuota limits the amount of data received per connection, the zero value disables the limit
+type BandwidthQuota struct {
+	BytesPerSecond int             // sustained rate, zero disables the quota
+	Burst          int             // bytes allowed above the rate at once, BytesPerSecond if zero
+	Action         BandwidthAction // taken when the quota is exceeded
+}
+
+// bandwidth measures the received bytes of the Channel and enforces the quota with a token bucket
+type bandwidth struct {
+	tokens   float64   // bytes allowed to receive now, negative if the quota is exceeded
+	refilled time.Time // moment tokens were refilled at
+	exceeded bool      // true since the quota is exceeded until the tokens are refilled
+
+	windowStart time.Time
+	windowBytes int // bytes received since windowStart
+	rate        int // bytes received in the last complete second
+
+	mu sync.Mutex
+}
+
+// SetBandwidthQuota sets the quota of data received per connection, the OnBandwidthExceeded handler
+// is called each time the quota becomes exceeded whatever the action is
+func (e *event) SetBandwidthQuota(q BandwidthQuota) {
+	if q.Burst <= 0 {
+		q.Burst = q.BytesPerSecond
+	}
+	e.handlersMu.Lock()
+	e.bandwidthQuota = q
+	e.handlersMu.Unlock()
+}
+
+// OnBandwidthExceeded sets the handler f called when the Channel exceeds the bandwidth quota,
+// rate is the amount of bytes received since the beginning of the current second
+func (e *event) OnBandwidthExceeded(f func(c *Channel, rate int)) {
+	e.handlersMu.Lock()
+	e.onBandwidthExceeded = f
+	e.handlersMu.Unlock()
+}
+
+// bandwidthPolicy returns the bandwidth quota and the handler of exceeding it, e may be nil
+func (e *event) bandwidthPolicy() (BandwidthQuota, func(c *Channel, rate int)) {
+	if e == nil {
+		return BandwidthQuota{}, nil
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.bandwidthQuota, e.onBandwidthExceeded
+}
+
+// BytesPerSecond returns the amount of bytes received by the Channel in the last complete second
+func (c *Channel) BytesPerSecond() int {
+	c.bandwidth.mu.Lock()
+	defer c.bandwidth.mu.Unlock()
+	switch elapsed := c.events.since(c.bandwidth.windowStart); {
+	case elapsed >= 2*time.Second:
+		return 0
+	case elapsed >= time.Second:
+		return c.bandwidth.windowBytes
+	}
+	return c.bandwidth.rate
+}
+
+// measure the size bytes received at the moment now, it returns the delay the reading should be throttled for,
+// true if the quota q became exceeded and the bytes received since the beginning of the current second
+func (b *bandwidth) measure(q BandwidthQuota, now time.Time, size int) (time.Duration, bool, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.windowStart); elapsed >= time.Second {
+		if elapsed >= 2*time.Second {
+			b.windowBytes = 0
+		}
+		b.windowStart, b.rate, b.windowBytes = now, b.windowBytes, 0
+	}
+	b.windowBytes += size
+
+	if q.BytesPerSecond <= 0 {
+		return 0, false, b.windowBytes
+	}
+
+	if b.refilled.IsZero() {
+		b.tokens, b.refilled = float64(q.Burst), now
+	}
+	b.tokens += now.Sub(b.refilled).Seconds() * float64(q.BytesPerSecond)
+	if b.tokens > float64(q.Burst) {
+		b.tokens = float64(q.Burst)
+	}
+	b.refilled = now
+	b.tokens -= float64(size)
+
+	if b.tokens >= 0 {
+		b.exceeded = false
+		return 0, false, b.windowBytes
+	}
+
+	exceeded := !b.exceeded
+	b.exceeded = true
+	switch q.Action {
+	case BandwidthThrottle:
+		return time.Duration(-b.tokens / float64(q.BytesPerSecond) * float64(time.Second)), exceeded, b.windowBytes
+	case BandwidthWarn:
+		if b.tokens < -float64(q.Burst) { // don't accumulate the debt the warned peer never repays
+			b.tokens = -float64(q.Burst)
+		}
+	}
+	return 0, exceeded, b.windowBytes
+}
+
+// police the message of the given size received by the Channel against the bandwidth quota,
+// it returns false if the Channel is closed for exceeding the quota
+func (c *Channel) police(size int) bool {
+	q, f := c.events.bandwidthQuotaOf(c)
+	delay, exceeded, rate := c.bandwidth.measure(q, c.events.now(), size)
+	if exceeded {
+		logging.Log().Debugf("Channel.police() channel %s exceeded the bandwidth quota: %d bytes/s", c.Id(), rate)
+		if f != nil {
+			f(c, rate)
+		}
+	}
+
+	if !exceeded || q.Action != BandwidthDisconnect {
+		if delay > 0 {
+			c.events.sleep(delay)
+		}
+		return true
+	}
+
+	c.disconnected(DisconnectBandwidthExceeded, 0, "")
+	c.close(c.events)
+	return false
+}
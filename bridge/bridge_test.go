@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// recordingClient records the MQTT publishes and the Kafka records
+type recordingClient struct{ published chan string }
+
+func (r *recordingClient) Publish(topic string, payload []byte) error {
+	r.published <- topic + " " + string(payload)
+	return nil
+}
+
+func (r *recordingClient) Subscribe(string, func(string, []byte)) error { return nil }
+
+func (r *recordingClient) Produce(topic string, key, value []byte) error {
+	var record KafkaRecord
+	if err := json.Unmarshal(value, &record); err != nil {
+		return err
+	}
+	r.published <- topic + " " + record.Event + " " + string(record.Payload)
+	return nil
+}
+
+// receive returns the string received from ch, failing the test after the timeout
+func receive(t *testing.T, ch chan string, what string) string {
+	t.Helper()
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("not received:", what)
+		return ""
+	}
+}
+
+func TestBridgesKeepHandlers(t *testing.T) {
+	s := gosocketio.NewServer()
+	handled := make(chan string, 1)
+	s.On("chat", func(c *gosocketio.Channel, m string) { handled <- m })
+
+	recorder := &recordingClient{published: make(chan string, 4)}
+	mqtt, err := NewMQTTBridge(s, recorder, DefaultTopicTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mqtt.Forward("chat"); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewKafkaBridge(s, recorder, nil).Sink("chat", "events"); err != nil {
+		t.Fatal(err)
+	}
+
+	webhooks := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e WebhookEvent
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &e); err == nil {
+			webhooks <- e.Namespace + " " + e.Event + " " + string(e.Payload)
+		}
+	}))
+	defer hook.Close()
+	if err := NewWebhookDispatcher(s, hook.URL).Forward("chat"); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	host, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	joined := make(chan struct{})
+	s.On(gosocketio.OnConnection, func(c *gosocketio.Channel) {
+		c.Join("lobby")
+		close(joined)
+	})
+	c, err := gosocketio.Dial(gosocketio.AddrWebsocket(host, p, false), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	<-joined
+	if err := c.Emit("chat", "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := receive(t, handled, "handler call"); m != "hi" {
+		t.Fatal("handler got:", m)
+	}
+	forwarded := map[string]bool{receive(t, recorder.published, "bridge publish"): true,
+		receive(t, recorder.published, "bridge publish"): true}
+	if !forwarded[`socketio/lobby/chat "hi"`] || !forwarded[`events chat "hi"`] {
+		t.Fatal("published to the brokers:", forwarded)
+	}
+	if e := receive(t, webhooks, "webhook"); e != `/ chat "hi"` {
+		t.Fatal("webhook event:", e)
+	}
+}
@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"encoding/json"
+
+	"github.com/mtfelian/golang-socketio"
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// KafkaProducer is a minimal Kafka producer, adapt the producer of your Kafka library to it
+type KafkaProducer interface {
+	Produce(topic string, key, value []byte) error
+}
+
+// KafkaConsumer is a minimal Kafka consumer, adapt the consumer of your Kafka library to it.
+// Subscribe should call handler for each record consumed from the given topics
+type KafkaConsumer interface {
+	Subscribe(topics []string, handler func(topic string, key, value []byte)) error
+}
+
+// KafkaRecord represents a value of the record produced for the incoming event
+type KafkaRecord struct {
+	Sid     string          `json:"sid"`
+	Event   string          `json:"event"`
+	Rooms   []string        `json:"rooms"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// KafkaBridge publishes incoming events to Kafka topics and broadcasts records consumed from topics
+type KafkaBridge struct {
+	server   *gosocketio.Server
+	producer KafkaProducer
+	consumer KafkaConsumer
+}
+
+// NewKafkaBridge returns a bridge between server and Kafka, producer or consumer may be nil if not used
+func NewKafkaBridge(server *gosocketio.Server, producer KafkaProducer, consumer KafkaConsumer) *KafkaBridge {
+	return &KafkaBridge{server: server, producer: producer, consumer: consumer}
+}
+
+// Sink subscribes to the given event of the root namespace on the server bus, producing each received event
+// to the topic as KafkaRecord JSON. Records are keyed by channel sid to keep the order of events of the same
+// channel. The event handler is kept
+func (b *KafkaBridge) Sink(event, topic string) error {
+	b.server.Bus().Subscribe(event, func(e gosocketio.BusEvent) {
+		if e.Namespace != "" {
+			return
+		}
+		c, err := b.server.GetChannel(e.Sid)
+		if err != nil {
+			logging.Log().Debug("KafkaBridge.Sink() skips event of the gone channel:", e.Sid)
+			return
+		}
+		record, err := json.Marshal(KafkaRecord{Sid: e.Sid, Event: e.Name, Rooms: c.Rooms(), Payload: e.Payload})
+		if err != nil {
+			logging.Log().Warn("KafkaBridge.Sink() can't encode record:", err)
+			return
+		}
+
+		if err := b.producer.Produce(topic, []byte(e.Sid), record); err != nil {
+			logging.Log().Warn("KafkaBridge.Sink() failed to produce:", err)
+		}
+	})
+	return nil
+}
+
+// Source consumes the given topics, broadcasting each record with gosocketio.EmitAPIRequest JSON value
+// to its room, or to all channels if the room is empty
+func (b *KafkaBridge) Source(topics ...string) error {
+	return b.consumer.Subscribe(topics, func(topic string, key, value []byte) {
+		var req gosocketio.EmitAPIRequest
+		if err := json.Unmarshal(value, &req); err != nil || req.Event == "" {
+			logging.Log().Debug("KafkaBridge.Source() skips record from topic:", topic)
+			return
+		}
+
+		var payload interface{}
+		if len(req.Payload) > 0 {
+			payload = req.Payload
+		}
+
+		if req.Room == "" {
+			b.server.BroadcastToAll(req.Event, payload)
+			return
+		}
+		b.server.BroadcastTo(req.Room, req.Event, payload)
+	})
+}
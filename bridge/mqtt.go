@@ -0,0 +1,145 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/mtfelian/golang-socketio"
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+const (
+	// DefaultTopicTemplate maps topic "socketio/<room>/<event>" to the event in the room
+	DefaultTopicTemplate = "socketio/" + placeholderRoom + "/" + placeholderEvent
+
+	placeholderRoom  = "{room}"
+	placeholderEvent = "{event}"
+	topicSeparator   = "/"
+	topicWildcard    = "+"
+)
+
+var (
+	ErrorWrongTopicTemplate = errors.New("topic template should contain {room} and {event} as whole levels")
+	ErrorTopicMismatch      = errors.New("topic does not match the template")
+)
+
+// MQTTClient is a minimal MQTT client, adapt the client of your MQTT library to it
+type MQTTClient interface {
+	Publish(topic string, payload []byte) error
+	Subscribe(topic string, handler func(topic string, payload []byte)) error
+}
+
+// MQTTBridge maps MQTT topics to socket.io rooms and events and vice versa
+type MQTTBridge struct {
+	server   *gosocketio.Server
+	client   MQTTClient
+	template []string // levels of the topic template
+}
+
+// NewMQTTBridge returns a bridge between server and MQTT client with the given topic template,
+// which should contain {room} and {event} placeholders as whole topic levels
+func NewMQTTBridge(server *gosocketio.Server, client MQTTClient, topicTemplate string) (*MQTTBridge, error) {
+	template := strings.Split(topicTemplate, topicSeparator)
+
+	var rooms, events int
+	for _, level := range template {
+		switch level {
+		case placeholderRoom:
+			rooms++
+		case placeholderEvent:
+			events++
+		default:
+			if strings.Contains(level, placeholderRoom) || strings.Contains(level, placeholderEvent) ||
+				level == topicWildcard || level == "#" {
+				return nil, ErrorWrongTopicTemplate
+			}
+		}
+	}
+	if rooms != 1 || events != 1 {
+		return nil, ErrorWrongTopicTemplate
+	}
+
+	return &MQTTBridge{server: server, client: client, template: template}, nil
+}
+
+// Topic returns an MQTT topic for the given room and event
+func (b *MQTTBridge) Topic(room, event string) string {
+	levels := make([]string, len(b.template))
+	for i, level := range b.template {
+		switch level {
+		case placeholderRoom:
+			levels[i] = room
+		case placeholderEvent:
+			levels[i] = event
+		default:
+			levels[i] = level
+		}
+	}
+	return strings.Join(levels, topicSeparator)
+}
+
+// parseTopic returns room and event for the given topic
+func (b *MQTTBridge) parseTopic(topic string) (string, string, error) {
+	levels := strings.Split(topic, topicSeparator)
+	if len(levels) != len(b.template) {
+		return "", "", ErrorTopicMismatch
+	}
+
+	var room, event string
+	for i, level := range b.template {
+		switch level {
+		case placeholderRoom:
+			room = levels[i]
+		case placeholderEvent:
+			event = levels[i]
+		default:
+			if levels[i] != level {
+				return "", "", ErrorTopicMismatch
+			}
+		}
+	}
+	return room, event, nil
+}
+
+// Subscribe to all topics matching the template, broadcasting received messages to the rooms.
+// JSON payloads are sent as is, others are sent as strings
+func (b *MQTTBridge) Subscribe() error {
+	return b.client.Subscribe(b.Topic(topicWildcard, topicWildcard), func(topic string, payload []byte) {
+		room, event, err := b.parseTopic(topic)
+		if err != nil {
+			logging.Log().Debug("MQTTBridge.Subscribe() skips topic:", topic)
+			return
+		}
+
+		if json.Valid(payload) {
+			b.server.BroadcastTo(room, event, json.RawMessage(payload))
+			return
+		}
+		b.server.BroadcastTo(room, event, string(payload))
+	})
+}
+
+// Forward subscribes to the given events of the root namespace on the server bus, publishing each received
+// event to the topics of all rooms the sender channel is joined to. The event handlers are kept
+func (b *MQTTBridge) Forward(events ...string) error {
+	bus := b.server.Bus()
+	for _, event := range events {
+		bus.Subscribe(event, func(e gosocketio.BusEvent) {
+			if e.Namespace != "" {
+				return
+			}
+			c, err := b.server.GetChannel(e.Sid)
+			if err != nil {
+				logging.Log().Debug("MQTTBridge.Forward() skips event of the gone channel:", e.Sid)
+				return
+			}
+			for _, room := range c.Rooms() {
+				if err := b.client.Publish(b.Topic(room, e.Name), e.Payload); err != nil {
+					logging.Log().Warn("MQTTBridge.Forward() failed to publish:", err)
+				}
+			}
+		})
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/mtfelian/golang-socketio"
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+const (
+	DefaultWebhookRetries = 3
+	DefaultWebhookBackoff = 500 * time.Millisecond
+	DefaultWebhookTimeout = 10 * time.Second
+
+	defaultNamespace = "/"
+)
+
+var (
+	ErrorWebhookRejected = errors.New("webhook rejected the event")
+	errWebhookRetryable  = errors.New("webhook temporarily failed")
+)
+
+// WebhookEvent represents a body of the webhook request
+type WebhookEvent struct {
+	Sid       string          `json:"sid"`
+	Namespace string          `json:"namespace"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Time      time.Time       `json:"time"`
+}
+
+// WebhookDispatcher POSTs selected incoming events to the HTTP endpoint as WebhookEvent JSON.
+// Network errors, 429 and 5xx responses are retried with exponential backoff
+type WebhookDispatcher struct {
+	server *gosocketio.Server
+	url    string
+
+	Client  *http.Client
+	Headers http.Header   // added to each request, e.g. for authentication
+	Retries int           // retries after the first attempt
+	Backoff time.Duration // delay before the first retry, doubled for each next one
+}
+
+// NewWebhookDispatcher returns a dispatcher posting server events to the given url with default params
+func NewWebhookDispatcher(server *gosocketio.Server, url string) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		server:  server,
+		url:     url,
+		Client:  &http.Client{Timeout: DefaultWebhookTimeout},
+		Retries: DefaultWebhookRetries,
+		Backoff: DefaultWebhookBackoff,
+	}
+}
+
+// Forward subscribes to the given events of all the namespaces on the server bus, posting each received event
+// to the webhook. The events are dispatched in background, so the retries don't hold the channel messages.
+// The event handlers are kept
+func (d *WebhookDispatcher) Forward(events ...string) error {
+	bus := d.server.Bus()
+	for _, event := range events {
+		bus.Subscribe(event, func(e gosocketio.BusEvent) {
+			namespace := e.Namespace
+			if namespace == "" {
+				namespace = defaultNamespace
+			}
+			webhookEvent := WebhookEvent{
+				Sid:       e.Sid,
+				Namespace: namespace,
+				Event:     e.Name,
+				Payload:   e.Payload,
+				Time:      time.Now(),
+			}
+			go func() {
+				if err := d.Dispatch(webhookEvent); err != nil {
+					logging.Log().Warn("WebhookDispatcher.Forward() failed to deliver event:", webhookEvent.Event, err)
+				}
+			}()
+		})
+	}
+	return nil
+}
+
+// Dispatch the event to the webhook, retrying on temporary failures
+func (d *WebhookDispatcher) Dispatch(event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := d.Backoff
+	for attempt := 0; ; attempt++ {
+		err = d.post(body)
+		if err == nil || err == ErrorWebhookRejected || attempt >= d.Retries {
+			return err
+		}
+
+		logging.Log().Debug("WebhookDispatcher.Dispatch() retries after err:", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post the body to the webhook once
+func (d *WebhookDispatcher) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for name, values := range d.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return errWebhookRetryable
+	default:
+		return ErrorWebhookRejected
+	}
+}
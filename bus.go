@@ -0,0 +1,117 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// AllEvents subscribes to every incoming event of the Bus
+const AllEvents = "*"
+
+// BusEvent is the incoming event published on the Bus
+type BusEvent struct {
+	Sid       string          // sid of the channel the event came from
+	Tenant    string          // tenant of the channel, see SetTenantResolver
+	Namespace string          // empty for the root namespace
+	Name      string          // event name
+	Payload   json.RawMessage // may be empty
+	Ack       bool            // true for the ack requests, they are answered by the handler registered with On
+}
+
+// Bus decouples the application modules from the channels. Modules subscribe to the incoming events
+// and publish the outgoing ones addressing the channels by sid, room or tenant, so they don't need
+// references to Server or Channel
+type Bus struct {
+	s           *Server
+	subscribers map[string][]*busSubscriber
+	mu          sync.RWMutex
+}
+
+// busSubscriber is the function subscribed to the Bus events
+type busSubscriber struct{ f func(e BusEvent) }
+
+// Bus returns the event bus of the server
+func (s *Server) Bus() *Bus {
+	s.event.handlersMu.Lock()
+	defer s.event.handlersMu.Unlock()
+	if s.event.bus == nil {
+		s.event.bus = &Bus{s: s, subscribers: make(map[string][]*busSubscriber)}
+	}
+	return s.event.bus
+}
+
+// getBus returns the event bus, nil if it isn't created, e may be nil
+func (e *event) getBus() *Bus {
+	if e == nil {
+		return nil
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.bus
+}
+
+// Subscribe f to the incoming events with the given name of all the namespaces, AllEvents subscribes to every event.
+// Subscribers are called in the order of subscribing before the event handlers, independently of them.
+// The returned function unsubscribes f
+func (b *Bus) Subscribe(name string, f func(e BusEvent)) (unsubscribe func()) {
+	sub := &busSubscriber{f: f}
+	b.mu.Lock()
+	b.subscribers[name] = append(b.subscribers[name], sub)
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := b.subscribers[name]
+		for i := range subs {
+			if subs[i] == sub {
+				b.subscribers[name] = append(subs[:i:i], subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// publishIncoming passes the incoming message m of channel c to the subscribers, b may be nil
+func (b *Bus) publishIncoming(c *Channel, m *protocol.Message) {
+	if b == nil || m.Type != protocol.MessageTypeEmit && m.Type != protocol.MessageTypeAckRequest {
+		return
+	}
+
+	b.mu.RLock()
+	subs := append(append([]*busSubscriber(nil), b.subscribers[m.EventName]...), b.subscribers[AllEvents]...)
+	b.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	e := BusEvent{Sid: c.Id(), Tenant: c.tenant, Namespace: m.Namespace, Name: m.EventName,
+		Payload: json.RawMessage(m.Args), Ack: m.Type == protocol.MessageTypeAckRequest}
+	for _, sub := range subs {
+		sub.f(e)
+	}
+}
+
+// Publish an event with the given name and payload to the channel with the given sid
+func (b *Bus) Publish(sid, name string, payload interface{}) error {
+	c, err := b.s.GetChannel(sid)
+	if err != nil {
+		return err
+	}
+	return c.Emit(name, payload)
+}
+
+// PublishToRoom publishes an event with the given name and payload to the channels joined to the room
+func (b *Bus) PublishToRoom(room, name string, payload interface{}) {
+	b.s.BroadcastTo(room, name, payload)
+}
+
+// PublishToTenant publishes an event with the given name and payload to the channels of the tenant
+func (b *Bus) PublishToTenant(tenant, name string, payload interface{}) {
+	b.s.Tenant(tenant).BroadcastToAll(name, payload)
+}
+
+// PublishToAll publishes an event with the given name and payload to all the channels of the global tenant
+func (b *Bus) PublishToAll(name string, payload interface{}) { b.s.BroadcastToAll(name, payload) }
@@ -0,0 +1,355 @@
+package gosocketio
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// CaptureHeader is the first line of the capture file
+const CaptureHeader = "sio-capture 1"
+
+var ErrorNotCapture = errors.New("not a capture file")
+
+// CaptureRecord is a packet written to the capture file. The file starts with CaptureHeader line which is
+// followed by the records as JSON objects, one per line:
+//
+//	{"time":"2006-01-02T15:04:05.999999999Z","sid":"...","dir":"in","packet":"42[\"event\",1]"}
+//
+// where dir is "in" for the packets received by the recording side and "out" for the sent ones
+type CaptureRecord struct {
+	Time      time.Time
+	Sid       string
+	Direction Direction
+	Packet    string
+}
+
+// captureLine is the JSON line of the CaptureRecord
+type captureLine struct {
+	Time   time.Time `json:"time"`
+	Sid    string    `json:"sid"`
+	Dir    string    `json:"dir"`
+	Packet string    `json:"packet"`
+}
+
+const (
+	captureIn  = "in"
+	captureOut = "out"
+)
+
+// Recorder writes the packets of the selected channels to the capture, see SetRecorder
+type Recorder struct {
+	w      *bufio.Writer
+	closer io.Closer // nil if the writer isn't owned
+	filter func(c *Channel) bool
+	err    error // first write error, recording stops on it
+	mu     sync.Mutex
+}
+
+// NewRecorder returns the recorder writing to w the packets of the channels selected by filter,
+// nil filter selects all of them
+func NewRecorder(w io.Writer, filter func(c *Channel) bool) (*Recorder, error) {
+	r := &Recorder{w: bufio.NewWriter(w), filter: filter}
+	if _, err := r.w.WriteString(CaptureHeader + "\n"); err != nil {
+		return nil, err
+	}
+	return r, r.w.Flush()
+}
+
+// CreateRecorder creates the capture file at path and returns the recorder writing to it
+func CreateRecorder(path string, filter func(c *Channel) bool) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRecorder(f, filter)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// write the packet of c to the capture
+func (r *Recorder) write(c *Channel, at time.Time, d Direction, packet string) {
+	if r.filter != nil && !r.filter(c) {
+		return
+	}
+
+	line := captureLine{Time: at, Sid: c.Id(), Dir: captureIn, Packet: packet}
+	if d == DirectionOutbound {
+		line.Dir = captureOut
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	if _, err = r.w.Write(append(b, '\n')); err == nil {
+		err = r.w.Flush()
+	}
+	if err != nil {
+		logging.Log().Warn("Recorder.write() stops recording on err:", err)
+		r.err = err
+	}
+}
+
+// Err returns the write error which stopped the recording, nil if it's recording
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close the capture file created by CreateRecorder, it's no-op for NewRecorder
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+// SetRecorder makes the channels to write their packets to the recorder r for offline debugging,
+// nil disables it. It's disabled by default
+func (e *event) SetRecorder(r *Recorder) {
+	e.handlersMu.Lock()
+	e.recorder = r
+	e.handlersMu.Unlock()
+}
+
+// getRecorder returns the packets recorder, e may be nil
+func (e *event) getRecorder() *Recorder {
+	if e == nil {
+		return nil
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.recorder
+}
+
+// CaptureReader reads the records of the capture
+type CaptureReader struct {
+	scanner *bufio.Scanner
+}
+
+// NewCaptureReader returns the reader of the capture from r, it fails with ErrorNotCapture
+// if r doesn't start with CaptureHeader
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxQueueRecordSize)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrorNotCapture
+	}
+	if scanner.Text() != CaptureHeader {
+		return nil, ErrorNotCapture
+	}
+	return &CaptureReader{scanner: scanner}, nil
+}
+
+// Next returns the next record, io.EOF at the end of the capture
+func (cr *CaptureReader) Next() (CaptureRecord, error) {
+	if !cr.scanner.Scan() {
+		if err := cr.scanner.Err(); err != nil {
+			return CaptureRecord{}, err
+		}
+		return CaptureRecord{}, io.EOF
+	}
+
+	var line captureLine
+	if err := json.Unmarshal(cr.scanner.Bytes(), &line); err != nil {
+		return CaptureRecord{}, err
+	}
+	r := CaptureRecord{Time: line.Time, Sid: line.Sid, Direction: DirectionInbound, Packet: line.Packet}
+	if line.Dir == captureOut {
+		r.Direction = DirectionOutbound
+	}
+	return r, nil
+}
+
+// ReadCaptureFile returns all the records of the capture file at path
+func ReadCaptureFile(path string) ([]CaptureRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr, err := NewCaptureReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var records []CaptureRecord
+	for {
+		r, err := cr.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, r)
+	}
+}
+
+// Replay passes the records to f keeping the intervals between them divided by speed,
+// zero speed passes them without delays. It stops on the first f error and returns it
+func Replay(records []CaptureRecord, speed float64, f func(r CaptureRecord) error) error {
+	for i, r := range records {
+		if i > 0 && speed > 0 {
+			if d := r.Time.Sub(records[i-1].Time); d > 0 {
+				time.Sleep(time.Duration(float64(d) / speed))
+			}
+		}
+		if err := f(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaySettleTime is how long the replayed side is given to respond to the last replayed packet
+const ReplaySettleTime = 200 * time.Millisecond
+
+// ReplayReport compares the packets sent by the replayed side with the recorded ones,
+// the open packet, heartbeats and noops are left out of both
+type ReplayReport struct {
+	Expected []string // recorded packets
+	Actual   []string // packets sent while replaying
+}
+
+// Matches returns whether the replayed side sent the recorded packets
+func (r *ReplayReport) Matches() bool { return len(r.Diff()) == 0 }
+
+// Diff returns the differences of the actual packets from the expected ones, one per mismatched position
+func (r *ReplayReport) Diff() []string {
+	var diff []string
+	for i := 0; i < len(r.Expected) || i < len(r.Actual); i++ {
+		switch {
+		case i >= len(r.Actual):
+			diff = append(diff, fmt.Sprintf("#%d: expected %s, got nothing", i, r.Expected[i]))
+		case i >= len(r.Expected):
+			diff = append(diff, fmt.Sprintf("#%d: expected nothing, got %s", i, r.Actual[i]))
+		case r.Expected[i] != r.Actual[i]:
+			diff = append(diff, fmt.Sprintf("#%d: expected %s, got %s", i, r.Expected[i], r.Actual[i]))
+		}
+	}
+	return diff
+}
+
+// replayCompared returns whether the packet is compared by ReplayReport
+func replayCompared(packet string) bool {
+	return packet != "" && !strings.HasPrefix(packet, protocol.MessageOpen) &&
+		!strings.HasPrefix(packet, protocol.MessagePing) && !strings.HasPrefix(packet, protocol.MessagePong) &&
+		packet != protocol.MessageBlank
+}
+
+// FlipDirections returns the records captured by the other side, e.g. by the client to replay them to the server
+func FlipDirections(records []CaptureRecord) []CaptureRecord {
+	flipped := make([]CaptureRecord, len(records))
+	for i, r := range records {
+		flipped[i] = r
+		if r.Direction == DirectionInbound {
+			flipped[i].Direction = DirectionOutbound
+		} else {
+			flipped[i].Direction = DirectionInbound
+		}
+	}
+	return flipped
+}
+
+// replayTo writes the packets of the records in the direction d to the peer connection end, see Replay
+// for speed, and reports the packets read from it against the records in the other direction
+func replayTo(peer transport.Connection, records []CaptureRecord, d Direction, speed float64) (*ReplayReport, error) {
+	report := &ReplayReport{}
+	for _, r := range records {
+		if r.Direction != d && replayCompared(r.Packet) {
+			report.Expected = append(report.Expected, r.Packet)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			packet, err := peer.GetMessage()
+			if err != nil {
+				return
+			}
+			if replayCompared(packet) {
+				report.Actual = append(report.Actual, packet)
+			}
+		}
+	}()
+
+	err := Replay(records, speed, func(r CaptureRecord) error {
+		if r.Direction != d {
+			return nil // keeps the delay of the recorded response
+		}
+		return peer.WriteMessage(r.Packet)
+	})
+	time.Sleep(ReplaySettleTime)
+	peer.Close()
+	<-done
+	return report, err
+}
+
+// ReplayCapture replays the client session to the server within a new in-memory connection, see Replay
+// for speed. The records are captured by the server, inbound packets are written as if the client sent them
+// and the server responses are reported against the outbound ones. Records of a single sid should be passed,
+// FlipDirections converts the records captured by the client. The connection is closed after the last packet
+func (s *Server) ReplayCapture(records []CaptureRecord, speed float64) (*ReplayReport, error) {
+	client, server := transport.MemoryPipe(transport.PlDefaultPingInterval, transport.PlDefaultPingTimeout)
+	s.setupEventLoop(server, nil, "replay", http.Header{}, nil)
+	return replayTo(client, records, DirectionInbound, speed)
+}
+
+// ReplayCaptureToClient replays the server session to a new client connected within a new in-memory connection,
+// see Replay for speed. The records are captured by the server, outbound packets are written as if the server
+// sent them and the client responses are reported against the inbound ones. The setup function registers
+// the client handlers before replaying, the emits the client application made on its own are reported missing
+// unless setup makes them. The client is closed after the last packet
+func ReplayCaptureToClient(records []CaptureRecord, speed float64, setup func(c *Client)) (*ReplayReport, error) {
+	tr := transport.NewMemoryTransport()
+	defer tr.Close()
+	accepted := make(chan transport.Connection, 1)
+	go func() {
+		if conn, err := tr.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	c, err := Dial("replay", tr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if setup != nil {
+		setup(c)
+	}
+	return replayTo(<-accepted, records, DirectionOutbound, speed)
+}
@@ -1,8 +1,10 @@
 package gosocketio
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -18,8 +20,14 @@ const (
 )
 
 var (
-	ErrorSendTimeout     = errors.New("timeout")
-	ErrorSocketOverflood = errors.New("socket overflood")
+	ErrorSendTimeout   = errors.New("timeout")
+	ErrorNotConnected  = errors.New("channel is not connected")
+	ErrorClosed        = errors.New("channel is closed")
+	ErrorQueueFull     = errors.New("outgoing queue is full")
+	ErrorUpgradeFailed = errors.New("transport upgrade failed")
+
+	// ErrorSocketOverflood is kept for compatibility, same as ErrorQueueFull
+	ErrorSocketOverflood = ErrorQueueFull
 )
 
 // connectionHeader represents engine.io connection header
@@ -28,37 +36,164 @@ type connectionHeader struct {
 	Upgrades     []string `json:"upgrades"`
 	PingInterval int      `json:"pingInterval"`
 	PingTimeout  int      `json:"pingTimeout"`
+	Token        string   `json:"token,omitempty"`       // session resumption token
+	Compression  string   `json:"compression,omitempty"` // name of the payloads codec, see SetCompression
+}
+
+// packet represents an item of the outgoing queue
+type packet struct {
+	message     string        // encoded message, empty message without batch marks a flush point
+	batch       []string      // encoded messages to write at once instead of the single message
+	done        chan error    // receives the result of writing if not nil
+	expires     time.Time     // packet is dropped if it's not written before, zero means no expiry
+	priority    Priority      // outgoing queue lane
+	sendTimeout time.Duration // overrides the send timeout of the Channel if not zero
+}
+
+// isMark returns true if the packet p is a flush mark
+func (p *packet) isMark() bool { return p.message == "" && p.batch == nil }
+
+// finish reports the result of writing the packet
+func (p *packet) finish(err error) {
+	if p.done != nil {
+		p.done <- err
+	}
 }
 
 // Channel represents socket.io connection
 type Channel struct {
-	conn transport.Connection
+	conn   transport.Connection
+	connMu sync.RWMutex // guards conn and connHeader, locked for writing while the transport upgrade is in progress
 
-	outC       chan string
+	outC       chan *packet // normal priority lane of the outgoing queue
+	outHighC   chan *packet
+	outLowC    chan *packet
 	stubC      chan string
 	upgradedC  chan string
 	connHeader connectionHeader
 
-	alive   bool
-	aliveMu sync.Mutex
+	alive    bool
+	draining bool // new messages are not accepted while draining
+	aliveMu  sync.Mutex
+
+	migration migration
+	quality   quality
+
+	ctx    context.Context // canceled when the Channel is closed, survives the transport upgrade
+	cancel context.CancelFunc
+
+	disconnectReason DisconnectReason
+	closeCode        int
+	closeText        string
+	disconnectMu     sync.Mutex
 
 	ack *acks
 
+	pingInterval time.Duration // overrides the transport ping interval if not zero
+	pingTimeout  time.Duration // overrides the transport ping timeout if not zero
+	pingPayload  func() string // produces an application data for the ping packets, may be nil
+	pingMu       sync.RWMutex
+	pingResetC   chan struct{} // wakes up the pingLoop when ping params change
+
+	sendTimeout   time.Duration // overrides the transport send timeout if not zero
+	sendTimeoutMu sync.Mutex
+
 	server  *Server
+	events  *event // handlers of the server or client the Channel belongs to
 	address string
 	header  http.Header
+
+	connectedAt   time.Time
+	lastActivity  time.Time // moment of the last received application event
+	lastReceived  time.Time // moment of the last received message of any kind
+	handshakeDone bool      // true since the first valid packet received
+	activityMu    sync.Mutex
+
+	connectData string       // data of the connect packet received by the client side Channel
+	onConnect   func()       // if set it's called after the connect packet was received
+	hooks       *clientHooks // instrumentation of the client side Channel, nil on the server side
+	connectMu   sync.RWMutex
+
+	lost   func(conn transport.Connection) bool // if set and returns true lost connection is being replaced
+	holdC  chan struct{}                        // closed to resume writes held while reconnecting, nil if not held
+	holdMu sync.Mutex
+
+	background   bool          // low priority emits are held in the background state
+	lowWakeC     chan struct{} // wakes outLoop when the background state changes
+	backgroundMu sync.Mutex
+
+	history   history       // last packets, recorded if the history size is set
+	bandwidth bandwidth     // received bytes rate and quota
+	dispatch  dispatchQueue // incoming messages being processed, see SetReadFlowControl
+	traffic   *traffic      // shared with the Channel replacing this one at the transport upgrade
+
+	quarantined  map[string]struct{} // names of the events not dispatched to the handlers
+	quarantineMu sync.RWMutex
+
+	streams   map[string]*Stream // open streams by id
+	streamsMu sync.Mutex
+
+	namespaces   map[string]struct{} // non-root namespaces the server Channel is connected to, see Server.Of
+	namespacesMu sync.Mutex
+
+	sessionID      string           // logical session id, survives resumption
+	resumedPending []string         // packets transferred with the resumed session, see Migrate
+	resumedAcks    []PendingAck     // ack requests of the resumed session awaiting the response
+	resumedRooms   []string         // rooms restored with the session
+	ordered        *orderedSender   // ordered delivery of the server channel, nil if it's off
+	orderedIn      *orderedReceiver // ordered delivery of the client channel, nil if it's off
+	tenant         string           // assigned at handshake by the TenantResolver, rooms are scoped by it
+	cipher         Cipher           // encrypts the payloads, nil if they aren't encrypted
+	previousCipher Cipher           // cipher of the previous key epoch, nil if the key wasn't rotated
+	nextKey        Cipher           // cipher of the next key epoch, created on the first use
+	keyEpoch       int              // see RotateKey
+	cipherMu       sync.Mutex
+	key            []byte // HMAC key signing the messages, nil if they aren't signed
+	keyMu          sync.Mutex
+	replay         *replay // sequence numbers, shared with the Channel replacing this one at the transport upgrade
+	replayMu       sync.Mutex
+	store          map[string]interface{}
+	storeMu        sync.RWMutex
 }
 
 // init the Channel
 func (c *Channel) init() {
-	c.outC, c.stubC, c.upgradedC = make(chan string, queueBufferSize), make(chan string), make(chan string)
+	c.outC, c.stubC, c.upgradedC = make(chan *packet, queueBufferSize), make(chan string), make(chan string, 1)
+	c.outHighC, c.outLowC = make(chan *packet, queueBufferSize), make(chan *packet, queueBufferSize)
 	c.ack = &acks{}
-	c.ack.ackC = make(map[int]chan string)
+	c.ack.waiters = make(map[int]*ackWaiter)
+	c.pingResetC, c.lowWakeC = make(chan struct{}, 1), make(chan struct{}, 1)
+	c.store = make(map[string]interface{})
 	c.alive = true
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.traffic = &traffic{}
+	c.connectedAt = c.events.now()
+	c.lastActivity, c.lastReceived = c.connectedAt, c.connectedAt
 }
 
 // Id returns an ID of the current socket connection
-func (c *Channel) Id() string { return c.connHeader.Sid }
+func (c *Channel) Id() string {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.connHeader.Sid
+}
+
+// Context returns the context canceled when the Channel is closed, handlers pass it to the work they start
+// to stop it promptly when the peer disconnects
+func (c *Channel) Context() context.Context { return c.ctx }
+
+// Done returns a channel closed when the Channel is closed
+func (c *Channel) Done() <-chan struct{} { return c.ctx.Done() }
+
+// ConnectedAt returns the moment the Channel was connected
+func (c *Channel) ConnectedAt() time.Time { return c.connectedAt }
+
+// isDraining returns true if Channel doesn't accept new messages before closing
+func (c *Channel) isDraining() bool {
+	c.aliveMu.Lock()
+	defer c.aliveMu.Unlock()
+	return c.draining
+}
 
 // IsAlive checks that Channel is still alive
 func (c *Channel) IsAlive() bool {
@@ -67,15 +202,75 @@ func (c *Channel) IsAlive() bool {
 	return c.alive
 }
 
+// connection returns the current transport connection of the Channel
+func (c *Channel) connection() transport.Connection {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.conn
+}
+
+// upgraded returns true if the connection conn was replaced by the transport upgrade
+func (c *Channel) upgraded(conn transport.Connection) bool { return c.connection() != conn }
+
+// write message m into the current connection, waits for the transport upgrade to finish
+func (c *Channel) write(m string) error { return c.writePacket(&packet{message: m}) }
+
+// writePacket p into the current connection, batch is written at once if the connection supports it
+func (c *Channel) writePacket(p *packet) error {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+
+	if conn, ok := c.conn.(transport.SendTimeoutSetter); ok {
+		conn.SetSendTimeout(c.sendTimeoutOf(p))
+	}
+
+	if p.batch == nil {
+		c.record(DirectionOutbound, p.message)
+		if err := c.conn.WriteMessage(p.message); err != nil {
+			return err
+		}
+		c.account(DirectionOutbound, p.message)
+		return nil
+	}
+
+	for _, m := range p.batch {
+		c.record(DirectionOutbound, m)
+	}
+	if bw, ok := c.conn.(transport.BatchWriter); ok {
+		if err := bw.WriteMessages(p.batch); err != nil {
+			return err
+		}
+		for _, m := range p.batch {
+			c.account(DirectionOutbound, m)
+		}
+		return nil
+	}
+
+	for _, m := range p.batch {
+		if err := c.conn.WriteMessage(m); err != nil {
+			return err
+		}
+		c.account(DirectionOutbound, m)
+	}
+	return nil
+}
+
 // Close the client (Channel) connection
-func (c *Channel) Close() error { return c.close(c.server.event) }
+func (c *Channel) Close() error {
+	c.disconnected(DisconnectClosed, 0, "")
+	if c.server != nil && c.IsAlive() {
+		c.server.audit(AuditForcedDisconnect, c, "", "")
+	}
+	return c.close(c.events)
+}
 
 // stub closes the polling client (Channel) connection at socket.io upgrade
 func (c *Channel) stub() error { return c.close(nil) }
 
 // close channel
 func (c *Channel) close(e *event) error {
-	switch c.conn.(type) {
+	conn := c.connection()
+	switch conn.(type) {
 	case *transport.PollingConnection:
 		logging.Log().Debug("Channel.close() type: PollingConnection")
 	case *transport.WebsocketConnection:
@@ -89,19 +284,24 @@ func (c *Channel) close(e *event) error {
 		return nil
 	}
 
-	c.conn.Close()
+	conn.Close()
 	c.alive = false
 
 	// clean outloop
-	for len(c.outC) > 0 {
-		<-c.outC
+	for _, lane := range []chan *packet{c.outHighC, c.outC, c.outLowC} {
+		for len(lane) > 0 {
+			(<-lane).finish(ErrorClosed)
+		}
 	}
 
 	if e != nil { // close
-		c.outC <- protocol.MessageClose
+		c.outC <- &packet{message: protocol.MessageClose}
+		c.cancel()
+		c.wakeDispatch()
 		e.callHandler(c, OnDisconnection)
+		c.failStreams()
 	} else { // stub at transport upgrade
-		c.outC <- protocol.MessageStub
+		c.outC <- &packet{message: protocol.MessageStub}
 	}
 
 	overfloodedMu.Lock()
@@ -113,10 +313,20 @@ func (c *Channel) close(e *event) error {
 
 // inLoop is an incoming events loop
 func (c *Channel) inLoop(e *event) error {
+	conn := c.connection()
 	for {
-		message, err := c.conn.GetMessage()
+		message, err := conn.GetMessage()
 		if err != nil {
+			if c.upgraded(conn) {
+				logging.Log().Debug("Channel.inLoop(): connection upgraded")
+				return nil
+			}
 			logging.Log().Debugf("Channel.inLoop(), c.conn.GetMessage() err: %v, message: %s", err, message)
+			if c.connectionLost(conn) {
+				logging.Log().Debug("Channel.inLoop(): connection lost, reconnecting")
+				return nil
+			}
+			c.disconnected(reasonFromError(err))
 			return c.close(e)
 		}
 
@@ -124,41 +334,78 @@ func (c *Channel) inLoop(e *event) error {
 			logging.Log().Debug("Channel.inLoop(): StopMessage")
 			return nil
 		}
+		c.received()
+		c.record(DirectionInbound, message)
+		c.account(DirectionInbound, message)
+		if !c.police(len(message)) {
+			return nil
+		}
 
-		decodedMessage, err := protocol.Decode(message)
+		decodedMessage, err := e.decode(message)
 		if err != nil {
 			logging.Log().Debugf("Channel.inLoop() decoding err: %v, message: %s", err, message)
+			c.logHistory(err)
+			e.protocolError(c, message, err)
+			c.disconnected(DisconnectProtocolError, 0, "")
 			c.close(e)
 			return err
 		}
+		c.handshaken()
 
 		switch decodedMessage.Type {
 		case protocol.MessageTypeOpen:
 			logging.Log().Debugf("Channel.inLoop(), protocol.MessageTypeOpen, decodedMessage: %+v", decodedMessage)
-			if err := json.Unmarshal([]byte(decodedMessage.Source[1:]), &c.connHeader); err != nil {
+			var connHeader connectionHeader
+			if err := json.Unmarshal([]byte(decodedMessage.Source[1:]), &connHeader); err != nil {
 				c.close(e)
 			}
-			e.callHandler(c, OnConnection)
+
+			if c.Id() != "" { // repeated open packet renegotiates ping params
+				c.setPingParams(time.Duration(connHeader.PingInterval)*time.Millisecond,
+					time.Duration(connHeader.PingTimeout)*time.Millisecond)
+				continue
+			}
+
+			c.connMu.Lock()
+			c.connHeader = connHeader // OnConnection handler is called when the connect packet arrives
+			c.connMu.Unlock()
+			c.hooks.handshake(c)
+
+		case protocol.MessageTypeEmpty:
+			if decodedMessage.Namespace == "" && c.server == nil {
+				c.connectAcked(e, decodedMessage.Args)
+				break
+			}
+			c.touch()
+			c.dispatchIncoming(e, decodedMessage)
 
 		case protocol.MessageTypePing:
 			logging.Log().Debugf("Channel.inLoop(), protocol.MessageTypePing, decodedMessage: %+v", decodedMessage)
 			if decodedMessage.Source == protocol.MessagePingProbe {
 				logging.Log().Debugf("Channel.inLoop(), decodedMessage.Source: %s", decodedMessage.Source)
-				c.outC <- protocol.MessagePongProbe
+				c.outC <- &packet{message: protocol.MessagePongProbe}
 				c.upgradedC <- transport.UpgradedMessage
-			} else {
-				c.outC <- protocol.MessagePong
+			} else { // pong echoes the ping payload
+				c.pingReceived()
+				c.outC <- &packet{message: protocol.MessagePong + decodedMessage.Source[1:]}
 			}
 
+		case protocol.MessageTypePong:
+			c.pongReceived()
+			go e.callHandlerWithPayload(c, OnPong, decodedMessage.Source[1:])
+
 		case protocol.MessageTypeUpgrade:
 		case protocol.MessageTypeBlank:
-		case protocol.MessageTypePong:
 		default:
-			go e.processIncoming(c, decodedMessage)
+			c.touch()
+			c.dispatchIncoming(e, decodedMessage)
 		}
-	}
 
-	return nil
+		if c.upgraded(conn) { // the new connection is served by another inLoop
+			logging.Log().Debug("Channel.inLoop(): connection upgraded")
+			return nil
+		}
+	}
 }
 
 // outLoop is an outgoing events loop, sends messages from channel to socket
@@ -169,98 +416,480 @@ func (c *Channel) outLoop(e *event) error {
 		switch {
 		case outBufferLen >= queueBufferSize-1:
 			logging.Log().Debug("Channel.outLoop(), outBufferLen >= queueBufferSize-1")
+			c.disconnected(DisconnectQueueOverflow, 0, "")
 			return c.close(e)
 		case outBufferLen > int(queueBufferSize/2):
 			overfloodedMu.Lock()
 			overflooded[c] = struct{}{}
 			overfloodedMu.Unlock()
+			c.queueFilled(true)
 		default:
 			overfloodedMu.Lock()
 			delete(overflooded, c)
 			overfloodedMu.Unlock()
+			c.queueFilled(false)
 		}
 
-		m := <-c.outC
+		p := c.nextPacket()
 
-		if m == protocol.MessageClose || m == protocol.MessageStub {
+		if p.message == protocol.MessageClose || p.message == protocol.MessageStub {
 			return nil
 		}
 
-		if err := c.conn.WriteMessage(m); err != nil {
-			logging.Log().Debug("Channel.outLoop(), failed to c.conn.WriteMessage() with err:", err)
+		if p.isMark() {
+			p.finish(nil)
+			continue
+		}
+
+		if c.collect(p) {
+			continue
+		}
+
+		if p.expired(c.events.now()) {
+			logging.Log().Debug("Channel.outLoop() drops expired packet")
+			expiredPackets.Inc()
+			p.finish(ErrorPacketExpired)
+			continue
+		}
+
+		c.waitWrites()
+		conn := c.connection()
+		err := c.writePacket(p)
+		if err != nil && c.connectionLost(conn) { // retry on the new connection
+			c.retransmitted()
+			c.waitWrites()
+			err = c.writePacket(p)
+		}
+		if err != nil {
+			logging.Log().Debug("Channel.outLoop(), failed to c.writePacket() with err:", err)
+			if transport.IsWriteTimeout(err) {
+				err = c.writeTimedOut(e, p, err)
+			}
+			p.finish(err)
+			c.disconnected(DisconnectTransportError, 0, "")
 			return c.close(e)
 		}
+		p.finish(nil)
+	}
+}
+
+// upgrade the Channel connection to the websocket conn, performing the probe sequence.
+// Outgoing messages are held until the upgrade finishes, on failure the current connection is kept.
+func (c *Channel) upgrade(e *event, conn transport.Connection) error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if err := conn.WriteMessage(protocol.MessagePingProbe); err != nil {
+		conn.Close()
+		return err
+	}
+
+	message, err := conn.GetMessage()
+	if err != nil {
+		conn.Close()
+		return err
 	}
+
+	if message != protocol.MessagePongProbe {
+		logging.Log().Debug("Channel.upgrade(), unexpected probe answer:", message)
+		conn.Close()
+		return ErrorUpgradeFailed
+	}
+
+	if err := conn.WriteMessage(protocol.MessageUpgrade); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.conn = conn
+	go c.inLoop(e)
+	return nil
+}
+
+// reconnect replaces the Channel connection with the new session conn and closes the old one
+func (c *Channel) reconnect(e *event, conn transport.Connection) {
+	c.connMu.Lock()
+	old := c.conn
+	c.conn, c.connHeader = conn, connectionHeader{}
+	go c.inLoop(e)
+	c.connMu.Unlock()
+	c.received()
+
+	old.Close()
+}
+
+// PingParams returns the ping interval and timeout of the Channel
+func (c *Channel) PingParams() (time.Duration, time.Duration) {
+	interval, timeout := c.connection().PingParams()
+
+	c.pingMu.RLock()
+	defer c.pingMu.RUnlock()
+	if c.pingInterval != 0 {
+		interval = c.pingInterval
+	}
+	if c.pingTimeout != 0 {
+		timeout = c.pingTimeout
+	}
+	return interval, timeout
+}
+
+// setPingParams overrides the ping interval and timeout of the Channel
+func (c *Channel) setPingParams(interval, timeout time.Duration) {
+	c.pingMu.Lock()
+	c.pingInterval, c.pingTimeout = interval, timeout
+	c.pingMu.Unlock()
+
+	c.connMu.Lock()
+	c.connHeader.PingInterval, c.connHeader.PingTimeout = int(interval/time.Millisecond), int(timeout/time.Millisecond)
+	c.connMu.Unlock()
+
+	select {
+	case c.pingResetC <- struct{}{}:
+	default:
+	}
+}
+
+// SetPingParams changes the ping interval and timeout of the connected Channel.
+// The server side Channel sends the new values to the client by repeating the open packet.
+// Note that interval plus timeout should not exceed the transport ReceiveTimeout of the server
+func (c *Channel) SetPingParams(interval, timeout time.Duration) error {
+	c.setPingParams(interval, timeout)
+	if c.server == nil {
+		return nil
+	}
+
+	c.connMu.RLock()
+	connHeader := c.connHeader
+	c.connMu.RUnlock()
+	connHeader.Upgrades = []string{} // prevent the client from probing again
+
+	jsonHdr, err := json.Marshal(&connHeader)
+	if err != nil {
+		return err
+	}
+
+	c.outC <- &packet{message: protocol.MustEncode(&protocol.Message{Type: protocol.MessageTypeOpen, Args: string(jsonHdr)})}
 	return nil
 }
 
+// Flush blocks until all messages queued before the call are written to the transport or ctx is done.
+// In background the held low priority emits aren't waited for
+func (c *Channel) Flush(ctx context.Context) error {
+	// low lane is served only when other lanes are empty, so the mark is reached after all previous packets.
+	// The low lane is held in background, then the mark goes to the normal lane not waiting for the held emits
+	mark := &packet{done: make(chan error, 1), priority: PriorityLow}
+	if c.lowLanePaused() {
+		mark.priority = PriorityNormal
+	}
+	lane := c.lane(mark.priority)
+
+	// queueing under the lock ensures the mark is either finished by close() or reached by outLoop
+	c.aliveMu.Lock()
+	if !c.alive {
+		c.aliveMu.Unlock()
+		return ErrorClosed
+	}
+	select {
+	case lane <- mark:
+		c.aliveMu.Unlock()
+	default:
+		c.aliveMu.Unlock()
+		select {
+		case lane <- mark:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	select {
+	case err := <-mark.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain stops accepting new messages, waits for the queued ones to be written to the transport
+// and closes the Channel. It's intended for planned disconnects
+func (c *Channel) Drain() error { return c.drain(c.events) }
+
+// drain the Channel and close it with event e
+func (c *Channel) drain(e *event) error {
+	c.aliveMu.Lock()
+	c.draining = true
+	c.aliveMu.Unlock()
+	c.wakeLowLane()
+
+	err := c.Flush(context.Background())
+	c.disconnected(DisconnectClosed, 0, "")
+	if closeErr := c.close(e); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // pingLoop sends ping messages for keeping connection alive
 func (c *Channel) pingLoop() {
 	for {
-		interval, _ := c.conn.PingParams()
-		time.Sleep(interval)
+		interval, _ := c.PingParams()
+		select {
+		case <-c.events.after(interval):
+		case <-c.pingResetC:
+			continue
+		}
+
 		if !c.IsAlive() {
 			return
 		}
 
-		c.outC <- protocol.MessagePing
+		c.pingSent()
+		c.outC <- &packet{message: protocol.MessagePing + c.nextPingPayload()}
+	}
+}
+
+// nextPingPayload returns an application data for the next ping packet
+func (c *Channel) nextPingPayload() string {
+	c.pingMu.RLock()
+	defer c.pingMu.RUnlock()
+	if c.pingPayload == nil {
+		return ""
 	}
+	return c.pingPayload()
 }
 
-// send message packet to the given channel c with payload
+// send message packet to the given channel c with payload, waits for the space in the outgoing queue
 func (c *Channel) send(m *protocol.Message, payload interface{}) error {
+	return c.sendWith(m, payload, &packet{}, true)
+}
+
+// sendWith sends message packet to the given channel c with payload queueing it as p,
+// if block is false it fails with ErrorQueueFull instead of waiting for the space in the outgoing queue
+func (c *Channel) sendWith(m *protocol.Message, payload interface{}, p *packet, block bool) error {
+	command, err := c.encode(m, payload)
+	if err != nil {
+		return err
+	}
+
+	p.message = command
+	if err := c.push(p, block); err != nil {
+		return err
+	}
+
+	if m.EventName != "" {
+		c.events.sent(c, m.EventName, len(m.Args))
+	}
+	return nil
+}
+
+// protection transforms the payloads of the Channel messages, the zero value leaves them as is
+type protection struct {
+	codec     Codec          // compresses the payloads, may be nil
+	threshold int            // minimum size of the compressed payload
+	replay    *replay        // numbers the messages, nil if the replay protection is disabled
+	ordered   *orderedSender // numbers the ordered messages, nil if the ordered delivery is off
+	cipher    Cipher         // encrypts the payloads, may be nil
+	key       []byte         // HMAC key signing the messages, may be nil
+}
+
+// enabled returns whether any payload transformation is enabled
+func (p protection) enabled() bool {
+	return p.codec != nil || p.replay != nil || p.cipher != nil || p.key != nil || p.ordered != nil
+}
+
+// protection returns the payload transformations of the Channel
+func (c *Channel) protection() (p protection, err error) {
+	if p.cipher, err = c.payloadCipher(); err != nil {
+		return p, err
+	}
+	if p.key, err = c.signingKey(); err != nil {
+		return p, err
+	}
+	p.codec, p.threshold = c.compression()
+	p.replay = c.replayWindow()
+	p.ordered = c.ordered
+	return p, nil
+}
+
+// encode message packet m with payload protecting it with the Channel sequence, cipher and signing key
+func (c *Channel) encode(m *protocol.Message, payload interface{}) (string, error) {
+	p, err := c.protection()
+	if err != nil {
+		return "", err
+	}
+	return encodeWith(m, payload, p)
+}
+
+// encode message packet m with payload
+func encode(m *protocol.Message, payload interface{}) (string, error) {
+	return encodeWith(m, payload, protection{})
+}
+
+// encodeWith encodes message packet m with payload numbered for the ordered delivery, compressed, numbered,
+// encrypted and signed as p requires.
+// m.Args is set to the plaintext payload
+func encodeWith(m *protocol.Message, payload interface{}, p protection) (command string, err error) {
 	// preventing encoding/json "index out of range" panic
 	defer func() {
 		if r := recover(); r != nil {
-			logging.Log().Warn("Channel.send(): recovered from panic:", r)
+			logging.Log().Warn("encode(): recovered from panic:", r)
+			err = fmt.Errorf("recovered from panic: %v", r)
 		}
 	}()
 
 	if payload != nil {
 		b, err := json.Marshal(&payload)
 		if err != nil {
-			return err
+			return "", err
 		}
 		m.Args = string(b)
 	}
 
-	command, err := protocol.Encode(m)
-	if err != nil {
-		return err
+	out := m
+	if p.ordered != nil && orderable(out) {
+		if out, err = p.ordered.number(out); err != nil {
+			return "", err
+		}
 	}
+	if p.codec != nil && sealed(out) {
+		if out, err = compress(p.codec, p.threshold, out); err != nil {
+			return "", err
+		}
+	}
+	if p.replay != nil && signed(out) {
+		out = p.replay.number(out)
+	}
+	if p.cipher != nil && sealed(out) {
+		if out, err = seal(p.cipher, out); err != nil {
+			return "", err
+		}
+	}
+	if p.key != nil && signed(out) {
+		out = sign(p.key, out)
+	}
+	return protocol.Encode(out)
+}
 
-	if len(c.outC) == queueBufferSize {
-		return ErrorSocketOverflood
+// push the packet p with encoded message into the outgoing queue
+func (c *Channel) push(p *packet, block bool) error {
+	if c.connection() == nil {
+		return ErrorNotConnected
 	}
 
-	c.outC <- command
-	return nil
+	if !c.IsAlive() || c.isDraining() {
+		return ErrorClosed
+	}
+
+	lane := c.lane(p.priority)
+	if !block {
+		select {
+		case lane <- p:
+			return nil
+		default:
+			return ErrorQueueFull
+		}
+	}
+
+	select {
+	case lane <- p:
+		return nil
+	case <-c.Done():
+		return ErrorClosed
+	}
 }
 
-// Emit an asynchronous event with the given name and payload
+// Emit an asynchronous event with the given name and payload, it waits for the space in the outgoing queue.
+// It fails with ErrorNotConnected or ErrorClosed if the message can't be queued
 func (c *Channel) Emit(name string, payload interface{}) error {
 	message := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: name}
 	return c.send(message, payload)
 }
 
+// TryEmit acts like Emit but never blocks, it fails with ErrorQueueFull if the outgoing queue is full
+func (c *Channel) TryEmit(name string, payload interface{}) error {
+	message := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: name}
+	return c.sendWith(message, payload, &packet{}, false)
+}
+
+// ServerError represents a standardized payload of the error event
+type ServerError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Error makes ServerError to implement error interface
+func (e ServerError) Error() string { return fmt.Sprintf("%d: %s", e.Code, e.Message) }
+
+// EmitError sends the error event with the standardized payload built from code, message and optional data
+func (c *Channel) EmitError(code int, message string, data interface{}) error {
+	return c.Emit(OnError, ServerError{Code: code, Message: message, Data: data})
+}
+
 // Ack a synchronous event with the given name and payload and wait for/receive the response
 func (c *Channel) Ack(name string, payload interface{}, timeout time.Duration) (string, error) {
-	m := &protocol.Message{Type: protocol.MessageTypeAckRequest, AckID: c.ack.nextId(), EventName: name}
+	return c.ackWith(&protocol.Message{Type: protocol.MessageTypeAckRequest, EventName: name}, payload, timeout)
+}
+
+// ackWith sends the ack request m with payload and waits for the response
+func (c *Channel) ackWith(m *protocol.Message, payload interface{}, timeout time.Duration) (string, error) {
+	return c.ackContext(context.Background(), m, payload, timeout)
+}
+
+// ackContext sends the ack request m with payload and waits for the response until timeout or ctx is done.
+// If the Channel closes while its session can be resumed the response is awaited from the resumed Channel
+func (c *Channel) ackContext(ctx context.Context, m *protocol.Message, payload interface{}, timeout time.Duration) (string, error) {
+	m.AckID = c.ack.nextId()
 
-	ackC := make(chan string)
-	c.ack.register(m.AckID, ackC)
+	w := newAckWaiter(c.pendingAck(m, payload))
+	c.ack.register(m.AckID, w)
+	defer c.ack.unregister(m.AckID)
+	defer w.stop()
 
+	sent := c.events.now()
 	if err := c.send(m, payload); err != nil {
-		c.ack.unregister(m.AckID)
+		return "", err
 	}
 
-	select {
-	case result := <-ackC:
-		return result, nil
-	case <-time.After(timeout):
-		c.ack.unregister(m.AckID)
-		return "", ErrorSendTimeout
+	expired, done := c.events.after(timeout), c.Done()
+	for {
+		select {
+		case result := <-w.ackC:
+			if done != nil {
+				c.measuredRTT(c.events.since(sent))
+			}
+			return result, nil
+		case <-expired:
+			return "", ErrorSendTimeout
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-done:
+			if w.request == nil {
+				return "", ErrorClosed
+			}
+			done = nil // the request is sent again to the Channel resuming the session
+		}
+	}
+}
+
+// AckInto acts like Ack but decodes the elements of the ack response into the given results pointers in order.
+// Results without the corresponding response element stay untouched
+func (c *Channel) AckInto(name string, payload interface{}, timeout time.Duration, results ...interface{}) error {
+	response, err := c.Ack(name, payload, timeout)
+	if err != nil {
+		return err
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte("["+response+"]"), &elements); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(results) && i < len(elements); i++ {
+		if err := json.Unmarshal(elements[i], results[i]); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // IP returns an IP of the socket client
@@ -272,15 +901,39 @@ func (c *Channel) IP() string {
 	return c.address
 }
 
+// Subprotocol returns the negotiated websocket subprotocol, empty if none or if the transport is not websocket
+func (c *Channel) Subprotocol() string {
+	if ws, ok := c.connection().(*transport.WebsocketConnection); ok {
+		return ws.Subprotocol()
+	}
+	return ""
+}
+
 // RequestHeader returns a connection request connectionHeader
 func (c *Channel) RequestHeader() http.Header { return c.header }
 
-// Join this channel to the given room
+// Join this channel to the given room, the channel gets the shared state of the room if it has one
+// and the values of its counters and sets
 func (c *Channel) Join(room string) error {
+	if err := c.join(room); err != nil {
+		return err
+	}
+	c.server.audit(AuditJoin, c, room, "")
+	c.server.sendRoomState(c, room)
+	c.server.sendRoomCRDTs(c, room)
+	return nil
+}
+
+// join this channel to the given room without auditing
+func (c *Channel) join(room string) error {
 	if c.server == nil {
 		return ErrorServerNotSet
 	}
+	if !validRoom(room) {
+		return ErrorRoomName
+	}
 
+	room = scopedRoom(c.tenant, room)
 	c.server.channelsMu.Lock()
 	defer c.server.channelsMu.Unlock()
 
@@ -298,10 +951,23 @@ func (c *Channel) Join(room string) error {
 
 // Leave the given room (remove channel from it)
 func (c *Channel) Leave(room string) error {
+	if err := c.leave(room); err != nil {
+		return err
+	}
+	c.server.audit(AuditLeave, c, room, "")
+	return nil
+}
+
+// leave the given room without auditing
+func (c *Channel) leave(room string) error {
 	if c.server == nil {
 		return ErrorServerNotSet
 	}
+	if !validRoom(room) {
+		return ErrorRoomName
+	}
 
+	room = scopedRoom(c.tenant, room)
 	c.server.channelsMu.Lock()
 	defer c.server.channelsMu.Unlock()
 
@@ -319,26 +985,42 @@ func (c *Channel) Leave(room string) error {
 	return nil
 }
 
-// Amount returns an amount of channels joined to the given room, using channel
+// Rooms returns a list of rooms the channel is joined to
+func (c *Channel) Rooms() []string {
+	if c.server == nil {
+		return []string{}
+	}
+
+	c.server.channelsMu.RLock()
+	defer c.server.channelsMu.RUnlock()
+
+	rooms := make([]string, 0, len(c.server.rooms[c]))
+	for room := range c.server.rooms[c] {
+		rooms = append(rooms, unscopedRoom(c.tenant, room))
+	}
+	return rooms
+}
+
+// Amount returns an amount of channels joined to the given room of the channel tenant, using channel
 func (c *Channel) Amount(room string) int {
 	if c.server == nil {
 		return 0
 	}
-	return c.server.Amount(room)
+	return c.server.Tenant(c.tenant).Amount(room)
 }
 
-// List returns a list of channels joined to the given room, using channel
+// List returns a list of channels joined to the given room of the channel tenant, using channel
 func (c *Channel) List(room string) []*Channel {
 	if c.server == nil {
 		return []*Channel{}
 	}
-	return c.server.List(room)
+	return c.server.Tenant(c.tenant).List(room)
 }
 
-// BroadcastTo the the given room an event with given name and payload, using channel
+// BroadcastTo the the given room of the channel tenant an event with given name and payload, using channel
 func (c *Channel) BroadcastTo(room, name string, payload interface{}) {
 	if c.server == nil {
 		return
 	}
-	c.server.BroadcastTo(room, name, payload)
+	c.server.Tenant(c.tenant).BroadcastTo(room, name, payload)
 }
@@ -0,0 +1,55 @@
+package gosocketio
+
+import (
+	"testing"
+	"time"
+)
+
+// stubConnection is the connection of the Channel without the loops, its methods are never called in tests
+type stubConnection struct{}
+
+func (stubConnection) GetMessage() (string, error)                { select {} }
+func (stubConnection) WriteMessage(string) error                  { return nil }
+func (stubConnection) Close() error                               { return nil }
+func (stubConnection) PingParams() (time.Duration, time.Duration) { return time.Minute, time.Minute }
+
+func TestEmitWaitsForQueueSpace(t *testing.T) {
+	c := &Channel{conn: stubConnection{}}
+	c.init()
+	for i := 0; i < queueBufferSize; i++ {
+		if err := c.TryEmit("update", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.TryEmit("update", "full"); err != ErrorQueueFull {
+		t.Fatal("TryEmit to the full queue:", err)
+	}
+
+	emitted := make(chan error, 1)
+	go func() { emitted <- c.Emit("update", "waiting") }()
+	select {
+	case err := <-emitted:
+		t.Fatal("Emit to the full queue doesn't wait:", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	<-c.outC
+	select {
+	case err := <-emitted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Emit doesn't queue the message when the space is freed")
+	}
+
+	go func() { emitted <- c.Emit("update", "closed") }()
+	c.cancel()
+	select {
+	case err := <-emitted:
+		if err != ErrorClosed {
+			t.Fatal("Emit to the full queue of the closed channel:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Emit waits after the channel is closed")
+	}
+}
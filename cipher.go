@@ -0,0 +1,184 @@
+package gosocketio
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+var (
+	ErrorNotSealed   = errors.New("payload is not encrypted")
+	ErrorShortSealed = errors.New("encrypted payload is too short")
+)
+
+var decryptFailures synced.Counter
+
+// CountDecryptFailures returns an amount of incoming messages dropped because their payload can't be decrypted
+func CountDecryptFailures() int { return decryptFailures.Get() }
+
+// Cipher encrypts and decrypts the event payloads of a single channel
+type Cipher interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(ciphertext []byte) ([]byte, error)
+}
+
+// CipherProvider returns the cipher of channel c, it's called once per channel at handshake on the server side
+// and before the first encrypted message on the client side
+type CipherProvider func(c *Channel) (Cipher, error)
+
+// aesGCM is the AES-GCM Cipher, the random nonce prefixes each ciphertext
+type aesGCM struct{ aead cipher.AEAD }
+
+// NewAESGCM returns the AES-GCM Cipher with the given 16, 24 or 32 bytes key
+func NewAESGCM(key []byte) (Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return aesGCM{aead: aead}, nil
+}
+
+// Seal encrypts the plaintext
+func (g aesGCM) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, g.aead.NonceSize(), g.aead.NonceSize()+len(plaintext)+g.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return g.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts and authenticates the ciphertext
+func (g aesGCM) Open(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < g.aead.NonceSize() {
+		return nil, ErrorShortSealed
+	}
+	nonce, sealed := ciphertext[:g.aead.NonceSize()], ciphertext[g.aead.NonceSize():]
+	return g.aead.Open(nil, nonce, sealed, nil)
+}
+
+// SessionKey derives the 32 bytes key of the session with the given sid from the secret shared by
+// the server and the client, pass it to NewAESGCM to get the per-session cipher
+func SessionKey(secret []byte, sid string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(sid))
+	return mac.Sum(nil)
+}
+
+// SetCipher sets the provider of the channel ciphers encrypting the payloads of the events, acks and
+// ack responses. The packet type, namespace, event name and ack id stay readable for routing, the payload
+// is replaced with a JSON string of the base64 encoded ciphertext. Both sides must set the cipher,
+// the incoming messages without encrypted payload are dropped. nil disables the encryption of new channels.
+// The server rejects the channel with the error event of 403 code if the provider returns an error
+func (e *event) SetCipher(f CipherProvider) {
+	e.handlersMu.Lock()
+	e.cipherProvider = f
+	e.handlersMu.Unlock()
+}
+
+// getCipherProvider returns the provider of the channel ciphers, e may be nil
+func (e *event) getCipherProvider() CipherProvider {
+	if e == nil {
+		return nil
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.cipherProvider
+}
+
+// payloadCipher returns the cipher of the Channel creating it with the provider on the first use, nil if
+// the payloads aren't encrypted
+func (c *Channel) payloadCipher() (Cipher, error) {
+	c.cipherMu.Lock()
+	defer c.cipherMu.Unlock()
+	if c.cipher != nil {
+		return c.cipher, nil
+	}
+
+	f := c.events.getCipherProvider()
+	if f == nil {
+		return nil, nil
+	}
+	cph, err := f(c)
+	if err != nil {
+		return nil, err
+	}
+	c.cipher = cph
+	return cph, nil
+}
+
+// sealed returns whether the payload of the message m is encrypted
+func sealed(m *protocol.Message) bool {
+	switch m.Type {
+	case protocol.MessageTypeEmit, protocol.MessageTypeAckRequest, protocol.MessageTypeAckResponse:
+		return m.Args != ""
+	}
+	return false
+}
+
+// seal returns the copy of message m with the payload encrypted by cph
+func seal(cph Cipher, m *protocol.Message) (*protocol.Message, error) {
+	ciphertext, err := cph.Seal([]byte(m.Args))
+	if err != nil {
+		return nil, err
+	}
+	args, err := json.Marshal(base64.StdEncoding.EncodeToString(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+
+	sealedM := *m
+	sealedM.Args = string(args)
+	return &sealedM, nil
+}
+
+// open decrypts the payload of the incoming message m in place, it returns false if m must be dropped
+func (c *Channel) open(m *protocol.Message) bool {
+	if !sealed(m) {
+		return true
+	}
+	cph, err := c.payloadCipher()
+	if err == nil && cph == nil {
+		return true
+	}
+
+	if err == nil {
+		m.Args, err = c.openRotated(cph, m.Args)
+	}
+	if err != nil {
+		decryptFailures.Inc()
+		logging.Log().Warnf("Channel.open() can't decrypt %q payload on %s: %v", m.EventName, c.Id(), err)
+		return false
+	}
+	return true
+}
+
+// openArgs returns the payload decrypted by cph from the JSON string args
+func openArgs(cph Cipher, args string) (string, error) {
+	var encoded string
+	if err := json.Unmarshal([]byte(args), &encoded); err != nil {
+		return "", ErrorNotSealed
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrorNotSealed
+	}
+	plaintext, err := cph.Open(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
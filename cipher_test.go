@@ -0,0 +1,93 @@
+package gosocketio
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// dialCiphered connects the client with the given hooks to the server with the payloads encrypted by
+// the session keys of the given secrets, it returns after the handshake the client and a function to stop
+// the client and server
+func dialCiphered(t *testing.T, s *Server, serverSecret, clientSecret string, hooks ClientHooks) (*Client, func()) {
+	t.Helper()
+	s.SetCipher(func(c *Channel) (Cipher, error) { return NewAESGCM(SessionKey([]byte(serverSecret), c.Id())) })
+	host, port, stopServer := serve(t, s)
+
+	handshaken := make(chan struct{})
+	hooks.Handshake = func(*Channel) { close(handshaken) }
+	c, err := DialWithHooks(AddrWebsocket(host, port, false), transport.DefaultWebsocketTransport(), hooks)
+	if err != nil {
+		stopServer()
+		t.Fatal(err)
+	}
+	stop := func() {
+		c.Close()
+		stopServer()
+	}
+	select {
+	case <-handshaken: // the session key is derived from the sid
+	case <-time.After(5 * time.Second):
+		stop()
+		t.Fatal("no handshake")
+	}
+	c.SetCipher(func(c *Channel) (Cipher, error) { return NewAESGCM(SessionKey([]byte(clientSecret), c.Id())) })
+	return c, stop
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	s := NewServer()
+	s.On("echo", func(c *Channel, q string) string { return q + "!" })
+	var (
+		sent []string
+		mu   sync.Mutex
+	)
+	c, stop := dialCiphered(t, s, "secret", "secret", ClientHooks{PacketSent: func(_ *Channel, packet string) {
+		mu.Lock()
+		sent = append(sent, packet)
+		mu.Unlock()
+	}})
+	defer stop()
+
+	response, err := c.Ack("echo", "plaintext", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != `"plaintext!"` {
+		t.Fatal("decrypted ack response:", response)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, packet := range sent {
+		if strings.Contains(packet, "plaintext") {
+			t.Fatal("payload is written unencrypted:", packet)
+		}
+	}
+}
+
+func TestCipherWrongKey(t *testing.T) {
+	s := NewServer()
+	received := make(chan string, 1)
+	s.On("message", func(c *Channel, m string) { received <- m })
+	c, stop := dialCiphered(t, s, "secret", "another secret", ClientHooks{})
+	defer stop()
+
+	failures := CountDecryptFailures()
+	if err := c.Emit("message", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); CountDecryptFailures() == failures; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("decrypt failure isn't counted")
+		}
+	}
+	select {
+	case m := <-received:
+		t.Fatal("message encrypted with the wrong key is delivered:", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -2,9 +2,10 @@ package gosocketio
 
 import (
 	"strconv"
+	"sync"
+	"time"
 
-	_ "time"
-
+	"github.com/mtfelian/golang-socketio/logging"
 	"github.com/mtfelian/golang-socketio/transport"
 )
 
@@ -16,12 +17,37 @@ const (
 	pollingSchema       = "http://"
 	pollingSecureSchema = "https://"
 	socketioPollingURL  = "/socket.io/?EIO=3&transport=polling"
+	socketioSSEURL      = "/socket.io/?EIO=3&transport=" + transport.SSETransportName
+
+	tcpSchema = "tcp://"
+	tlsSchema = "tls://"
 )
 
 // Client represents socket.io client
 type Client struct {
 	*event
 	*Channel
+
+	addr string              // address the client is connected to
+	tr   transport.Transport // transport used to connect
+	mu   sync.Mutex          // guards addr
+
+	reconnection  *ReconnectionParams // nil if automatic reconnection is disabled
+	reconnecting  bool
+	onLost        func() // called when the connection is lost or replaced
+	onReconnected func() // called when the connection is replaced
+	reconnMu      sync.Mutex
+
+	deadTimeout  time.Duration
+	deadWatching bool // true if deadLoop is running
+	deadMu       sync.Mutex
+
+	hooks *clientHooks // shared with the Channel
+
+	offlineQueue      OfflineQueue // persists EmitDurable emits, may be nil
+	offlineFlushing   bool         // true while the queued emits are written
+	offlineFlushAgain bool         // true if the queue was changed while flushing
+	offlineMu         sync.Mutex
 }
 
 // AddrWebsocket returns an url for socket.io connection for websocket transport
@@ -42,16 +68,53 @@ func AddrPolling(host string, port int, secure bool) string {
 	return prefix + host + ":" + strconv.Itoa(port) + socketioPollingURL
 }
 
+// AddrTCP returns an url for socket.io connection for tcp transport, secure one is over TLS
+func AddrTCP(host string, port int, secure bool) string {
+	prefix := tcpSchema
+	if secure {
+		prefix = tlsSchema
+	}
+	return prefix + host + ":" + strconv.Itoa(port)
+}
+
+// AddrSSE returns an url for socket.io connection for Server-Sent Events transport
+func AddrSSE(host string, port int, secure bool) string {
+	prefix := pollingSchema
+	if secure {
+		prefix = pollingSecureSchema
+	}
+	return prefix + host + ":" + strconv.Itoa(port) + socketioSSEURL
+}
+
 // Dial connects to server and initializes socket.io protocol
 // The correct ws protocol addr example:
 // ws://myserver.com/socket.io/?EIO=3&transport=websocket
 func Dial(addr string, tr transport.Transport) (*Client, error) {
-	c := &Client{Channel: &Channel{}, event: &event{}}
+	return DialWithOpts(addr, DialTransport(tr))
+}
+
+// dial connects to server with the instrumentation hooks and the payloads compression
+func dial(addr string, tr transport.Transport, hooks *clientHooks, cmp compression) (*Client, error) {
+	c := &Client{Channel: &Channel{hooks: hooks}, event: &event{}, addr: addr, tr: tr, hooks: hooks}
 	c.Channel.init()
 	c.event.init()
+	c.event.compression = cmp
+	c.Channel.events, c.Channel.lost, c.Channel.onConnect = c.event, c.startReconnecting, c.connectAcked
+	if orderedRequested(addr) {
+		c.Channel.orderedIn = &orderedReceiver{}
+	}
+	c.event.On(ReconnectRequestEvent, func(_ *Channel, r ReconnectRequest) {
+		c.reconnectAfter(time.Duration(r.Delay)*time.Millisecond, r.URL, r.Token)
+	})
+	c.event.serveQoS()
+	c.event.On(KeyRotationEvent, func(ch *Channel, r KeyRotation) {
+		if err := ch.advanceKey(r.Epoch); err != nil {
+			logging.Log().Warn("Client can't rotate the key:", err)
+		}
+	})
 
 	var err error
-	c.conn, err = tr.Connect(addr)
+	c.conn, err = hooks.connect(tr, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -59,14 +122,78 @@ func Dial(addr string, tr transport.Transport) (*Client, error) {
 	go c.Channel.inLoop(c.event)
 	go c.Channel.outLoop(c.event)
 	go c.Channel.pingLoop()
+	c.connected()
+
+	return c, nil
+}
 
-	switch tr.(type) {
+// connected finishes connection, polling transport gets the open and connect packets on connect, so
+// the header is set and OnConnection handler is called here
+func (c *Client) connected() {
+	switch c.tr.(type) {
 	case *transport.PollingClientTransport:
-		go c.event.callHandler(c.Channel, OnConnection)
+		polling := c.connection().(*transport.PollingClientConnection)
+		c.connMu.Lock()
+		c.connHeader.Sid, c.connHeader.Token, c.connHeader.Compression = polling.Sid(), polling.Token(), polling.Compression()
+		c.connMu.Unlock()
+		c.hooks.handshake(c.Channel)
+		go c.Channel.connectAcked(c.event, polling.ConnectData())
 	}
+}
 
-	return c, nil
+// connectAcked upgrades the polling connection if it's required and writes the offline queue,
+// it's called after the connect packet was received
+func (c *Client) connectAcked() {
+	go c.flushOffline()
+	if tr, ok := c.tr.(*transport.PollingClientTransport); ok && tr.Upgrade != nil {
+		c.hooks.upgrade(c.Channel, c.upgrade(tr.Upgrade))
+	}
 }
 
+// upgrade the client connection from polling to the websocket transport tr
+func (c *Client) upgrade(tr *transport.WebsocketTransport) error {
+	polling := c.connection().(*transport.PollingClientConnection)
+	if !polling.CanUpgrade("websocket") {
+		logging.Log().Debug("Client.upgrade(): server does not allow to upgrade to websocket")
+		return ErrorUpgradeFailed
+	}
+
+	addr, err := polling.WebsocketURL()
+	if err != nil {
+		logging.Log().Debug("Client.upgrade(): can't get websocket url:", err)
+		return err
+	}
+
+	conn, err := tr.Connect(addr)
+	if err != nil {
+		logging.Log().Debug("Client.upgrade(): can't connect via websocket:", err)
+		return err
+	}
+
+	if err := c.Channel.upgrade(c.event, conn); err != nil {
+		logging.Log().Debug("Client.upgrade(): failed with err:", err)
+		return err
+	}
+	polling.Release() // the session is served by the websocket connection now
+
+	logging.Log().Debug("Client.upgrade(): upgraded to websocket")
+	c.event.callHandler(c.Channel, OnUpgrade)
+	return nil
+}
+
+// SetPingPayload sets a function producing an application data (e.g. timestamp) to send within each ping packet.
+// The peer echoes it in the pong packet which is passed to the OnPong handler
+func (c *Client) SetPingPayload(f func() string) {
+	c.pingMu.Lock()
+	c.pingPayload = f
+	c.pingMu.Unlock()
+}
+
+// OnServerError registers a handler for the error events sent by the server with Channel.EmitError()
+func (c *Client) OnServerError(f func(c *Channel, e ServerError)) error { return c.On(OnError, f) }
+
 // Close client connection
 func (c *Client) Close() { c.Channel.close(c.event) }
+
+// Drain waits for the queued messages to be written and closes client connection
+func (c *Client) Drain() error { return c.Channel.drain(c.event) }
@@ -0,0 +1,96 @@
+package gosocketio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// ClientHooks are the callbacks instrumenting the client lifecycle, nil ones are skipped.
+// They're called synchronously, packet hooks are called for every packet including heartbeats
+type ClientHooks struct {
+	DialStart        func(addr string)                                   // connecting or reconnecting to addr
+	DialDone         func(addr string, err error, elapsed time.Duration) // connection attempt finished
+	Handshake        func(c *Channel)                                    // open packet received, c.Id() is assigned
+	Upgrade          func(c *Channel, err error)                         // transport upgrade to websocket finished
+	PacketSent       func(c *Channel, packet string)                     // packet was written
+	PacketReceived   func(c *Channel, packet string)                     // packet was read
+	ReconnectAttempt func(attempt int, err error)                        // automatic reconnection attempt from 1 finished
+}
+
+// clientHooks holds the hooks shared by the Client and its Channel
+type clientHooks struct {
+	hooks ClientHooks
+	mu    sync.RWMutex
+}
+
+// DialWithHooks connects to server like Dial calling the hooks from the very dial start
+func DialWithHooks(addr string, tr transport.Transport, hooks ClientHooks) (*Client, error) {
+	return DialWithOpts(addr, DialTransport(tr), DialHooks(hooks))
+}
+
+// SetHooks replaces the client instrumentation hooks
+func (c *Client) SetHooks(hooks ClientHooks) {
+	c.hooks.mu.Lock()
+	c.hooks.hooks = hooks
+	c.hooks.mu.Unlock()
+}
+
+// get returns the hooks, h may be nil
+func (h *clientHooks) get() ClientHooks {
+	if h == nil {
+		return ClientHooks{}
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.hooks
+}
+
+// connect to addr with tr reporting the attempt to the hooks
+func (h *clientHooks) connect(tr transport.Transport, addr string) (transport.Connection, error) {
+	hooks := h.get()
+	if hooks.DialStart != nil {
+		hooks.DialStart(addr)
+	}
+	start := time.Now()
+	conn, err := tr.Connect(addr)
+	if hooks.DialDone != nil {
+		hooks.DialDone(addr, err, time.Since(start))
+	}
+	return conn, err
+}
+
+// handshake reports the open packet received by c
+func (h *clientHooks) handshake(c *Channel) {
+	if f := h.get().Handshake; f != nil {
+		f(c)
+	}
+}
+
+// upgrade reports the transport upgrade of c finished with err
+func (h *clientHooks) upgrade(c *Channel, err error) {
+	if f := h.get().Upgrade; f != nil {
+		f(c, err)
+	}
+}
+
+// packet reports the packet read or written by c in the direction d
+func (h *clientHooks) packet(c *Channel, d Direction, packet string) {
+	if h == nil {
+		return
+	}
+	hooks := h.get()
+	if d == DirectionInbound && hooks.PacketReceived != nil {
+		hooks.PacketReceived(c, packet)
+	} else if d == DirectionOutbound && hooks.PacketSent != nil {
+		hooks.PacketSent(c, packet)
+	}
+}
+
+// reconnectAttempt reports the automatic reconnection attempt finished with err
+func (h *clientHooks) reconnectAttempt(attempt int, err error) {
+	if f := h.get().ReconnectAttempt; f != nil {
+		f(attempt, err)
+	}
+}
@@ -0,0 +1,35 @@
+package gosocketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestClientUpgradeReleasesPolling(t *testing.T) {
+	s := NewServer()
+	s.On("echo", func(c *Channel, m string) string { return m })
+	host, port, stop := serve(t, s)
+	defer stop()
+
+	c, err := Dial(AddrPolling(host, port, false), transport.DefaultPollingClientTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	polling := c.connection().(*transport.PollingClientConnection)
+	if err := c.upgrade(transport.DefaultWebsocketTransport()); err != nil {
+		t.Fatal("upgrade failed:", err)
+	}
+
+	if err := polling.WriteMessage(protocol.MessageBlank); !transport.IsReleased(err) {
+		t.Fatal("polling connection is not released after the upgrade, write err:", err)
+	}
+
+	if reply, err := c.Ack("echo", "hi", 5*time.Second); err != nil || reply != `"hi"` {
+		t.Fatalf("ack over the upgraded connection: %q, %v", reply, err)
+	}
+}
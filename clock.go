@@ -0,0 +1,131 @@
+package gosocketio
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a time source used by ping loops, ack timeouts, reconnection backoff and other timing logic
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock implemented with the time package
+type systemClock struct{}
+
+// Now returns the current local time
+func (systemClock) Now() time.Time { return time.Now() }
+
+// After waits for the duration to elapse and then sends the current time on the returned channel
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the real time Clock used by default
+var SystemClock Clock = systemClock{}
+
+// SetClock sets the time source for the channels, nil sets the SystemClock.
+// The server monotonic time answered to TimeEvent counts from the moment the clock is set
+func (e *event) SetClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	e.handlersMu.Lock()
+	e.clock, e.clockSet = clock, clock.Now()
+	e.handlersMu.Unlock()
+}
+
+// clockSetAt returns the moment of the clock when it was set, zero if it wasn't
+func (e *event) clockSetAt() time.Time {
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.clockSet
+}
+
+// getClock returns the time source, e may be nil
+func (e *event) getClock() Clock {
+	if e == nil {
+		return SystemClock
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	if e.clock == nil {
+		return SystemClock
+	}
+	return e.clock
+}
+
+// now returns the current time of the event clock
+func (e *event) now() time.Time { return e.getClock().Now() }
+
+// since returns the duration elapsed since t by the event clock
+func (e *event) since(t time.Time) time.Duration { return e.getClock().Now().Sub(t) }
+
+// after returns the channel receiving the time after duration d elapsed by the event clock
+func (e *event) after(d time.Duration) <-chan time.Time { return e.getClock().After(d) }
+
+// sleep for the duration d by the event clock
+func (e *event) sleep(d time.Duration) { <-e.after(d) }
+
+// manualWaiter is a pending After call of the ManualClock
+type manualWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// ManualClock is a Clock which time is changed only by Advance, it's intended for tests
+type ManualClock struct {
+	now     time.Time
+	waiters []manualWaiter
+	mu      sync.Mutex
+}
+
+// NewManualClock returns a ManualClock starting at the given time
+func NewManualClock(start time.Time) *ManualClock { return &ManualClock{now: start} }
+
+// Now returns the current time of the clock
+func (m *ManualClock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// After returns a channel receiving the clock time when it's advanced by at least d
+func (m *ManualClock) After(d time.Duration) <-chan time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- m.now
+		return c
+	}
+	m.waiters = append(m.waiters, manualWaiter{at: m.now.Add(d), c: c})
+	return c
+}
+
+// Advance the clock by d, firing the pending After channels in the order of their deadlines
+func (m *ManualClock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.now = m.now.Add(d)
+	sort.SliceStable(m.waiters, func(i, j int) bool { return m.waiters[i].at.Before(m.waiters[j].at) })
+
+	pending := m.waiters[:0]
+	for _, w := range m.waiters {
+		if w.at.After(m.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.c <- m.now
+	}
+	m.waiters = pending
+}
+
+// Waiters returns an amount of the pending After calls, tests use it to wait for the goroutines to block
+func (m *ManualClock) Waiters() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.waiters)
+}
@@ -0,0 +1,220 @@
+// Command siogen generates typed emit and handler registration wrappers from the event schema.
+//
+// The schema is a Go struct, each field tagged with `sio:"name"` describes the event with the given name.
+// The field type is the event payload type, func(Req) Resp field describes the ack request with the
+// Req payload answered with Resp, func() Resp is the ack request without payload:
+//
+//	type ChatEvents struct {
+//		Message ChatMessage                       `sio:"message"`
+//		History func(HistoryRequest) []ChatMessage `sio:"history"`
+//	}
+//
+// For the schema above siogen generates the ChatEventsEmitter wrapping *gosocketio.Channel with
+// Message(payload) and History(req, timeout) methods, and ChatEventsHandlers wrapping *gosocketio.Server
+// or *gosocketio.Client with OnMessage(f) and OnHistory(f) methods. Usage:
+//
+//	//go:generate siogen -type ChatEvents events.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+var errNoEvents = errors.New("no events found")
+
+// event is a schema field describing the event
+type event struct {
+	Field   string // schema field name
+	Name    string // event name
+	Payload string // payload type, request type for the ack requests, empty for the ack requests without payload
+	Result  string // ack response type, empty for the events
+}
+
+// schema is a struct listing the events
+type schema struct {
+	Type   string
+	Events []event
+}
+
+// parseSchemas returns the schemas of the struct types with the given names declared in the file,
+// all the struct types with tagged fields if names are empty
+func parseSchemas(fileName string, names []string) (string, []schema, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), fileName, nil, 0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var schemas []schema
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok || len(wanted) > 0 && !wanted[spec.Name.Name] {
+			return false
+		}
+
+		s := schema{Type: spec.Name.Name}
+		for _, field := range st.Fields.List {
+			if field.Tag == nil || len(field.Names) == 0 {
+				continue
+			}
+			name, ok := reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Lookup("sio")
+			if !ok || name == "" {
+				continue
+			}
+			for _, ident := range field.Names {
+				s.Events = append(s.Events, newEvent(ident.Name, name, field.Type))
+			}
+		}
+		if len(s.Events) > 0 {
+			schemas = append(schemas, s)
+		}
+		return false
+	})
+
+	if len(schemas) == 0 {
+		return "", nil, errNoEvents
+	}
+	return f.Name.Name, schemas, nil
+}
+
+// newEvent returns the event with the given name described by the schema field of type expr
+func newEvent(field, name string, expr ast.Expr) event {
+	e := event{Field: field, Name: name, Payload: types.ExprString(expr)}
+	if fn, ok := expr.(*ast.FuncType); ok && fn.Results != nil && len(fn.Results.List) == 1 {
+		e.Payload, e.Result = "", types.ExprString(fn.Results.List[0].Type)
+		if fn.Params != nil && len(fn.Params.List) == 1 {
+			e.Payload = types.ExprString(fn.Params.List[0].Type)
+		}
+	}
+	return e
+}
+
+var code = template.Must(template.New("code").Parse(`// Code generated by siogen; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	{{- if .HasAcks}}
+	"encoding/json"
+	"time"
+	{{end}}
+	gosocketio "github.com/mtfelian/golang-socketio"
+)
+{{range .Schemas}}{{$type := .Type}}
+// {{$type}}Registrar is implemented by *gosocketio.Server and *gosocketio.Client
+type {{$type}}Registrar interface {
+	On(name string, f interface{}) error
+}
+
+// {{$type}}Emitter emits the {{$type}} events on the channel
+type {{$type}}Emitter struct{ C *gosocketio.Channel }
+
+// {{$type}}Handlers registers the {{$type}} event handlers
+type {{$type}}Handlers struct{ R {{$type}}Registrar }
+{{range .Events}}{{if .Result}}
+// {{.Field}} sends the {{printf "%q" .Name}} ack request and waits for the response within timeout
+func (e {{$type}}Emitter) {{.Field}}({{if .Payload}}req {{.Payload}}, {{end}}timeout time.Duration) ({{.Result}}, error) {
+	var resp {{.Result}}
+	result, err := e.C.Ack({{printf "%q" .Name}}, {{if .Payload}}req{{else}}nil{{end}}, timeout)
+	if err != nil {
+		return resp, err
+	}
+	err = json.Unmarshal([]byte(result), &resp)
+	return resp, err
+}
+
+// On{{.Field}} registers the handler f answering the {{printf "%q" .Name}} ack requests
+func (h {{$type}}Handlers) On{{.Field}}(f func(c *gosocketio.Channel{{if .Payload}}, req {{.Payload}}{{end}}) {{.Result}}) error {
+	return h.R.On({{printf "%q" .Name}}, f)
+}
+{{else}}
+// {{.Field}} emits the {{printf "%q" .Name}} event
+func (e {{$type}}Emitter) {{.Field}}(payload {{.Payload}}) error {
+	return e.C.Emit({{printf "%q" .Name}}, payload)
+}
+
+// On{{.Field}} registers the handler f of the {{printf "%q" .Name}} event
+func (h {{$type}}Handlers) On{{.Field}}(f func(c *gosocketio.Channel, payload {{.Payload}})) error {
+	return h.R.On({{printf "%q" .Name}}, f)
+}
+{{end}}{{end}}{{end}}`))
+
+// generate the wrappers of the schemas in the package
+func generate(pkg string, schemas []schema) ([]byte, error) {
+	hasAcks := false
+	for _, s := range schemas {
+		for _, e := range s.Events {
+			hasAcks = hasAcks || e.Result != ""
+		}
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		Package string
+		Schemas []schema
+		HasAcks bool
+	}{pkg, schemas, hasAcks}
+	if err := code.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated schema struct names, all tagged structs if empty")
+	output := flag.String("output", "", "output file name, <file>_sio.go by default")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: siogen [-type T[,T]] [-output file] schema.go")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var names []string
+	if *typeNames != "" {
+		names = strings.Split(*typeNames, ",")
+	}
+
+	input := flag.Arg(0)
+	pkg, schemas, err := parseSchemas(input, names)
+	if err != nil {
+		log.Fatalf("siogen: %s: %v", input, err)
+	}
+
+	src, err := generate(pkg, schemas)
+	if err != nil {
+		log.Fatalf("siogen: %v", err)
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(input, ".go") + "_sio.go"
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatalf("siogen: %v", err)
+	}
+}
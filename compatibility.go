@@ -0,0 +1,48 @@
+package gosocketio
+
+import (
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+// Compatibility is a mode of the incoming messages decoding
+type Compatibility int
+
+const (
+	CompatibilityStrict  Compatibility = iota // reject any deviation from the protocol
+	CompatibilityLenient                      // tolerate quirks of older or buggy clients, see protocol.Tolerate
+)
+
+var toleratedDeviations [protocol.DeviationsAmount]synced.Counter
+
+// SetCompatibility sets the mode of the incoming messages decoding, CompatibilityStrict is used by default
+func (e *event) SetCompatibility(mode Compatibility) {
+	e.handlersMu.Lock()
+	e.compatibility = mode
+	e.handlersMu.Unlock()
+}
+
+// tolerate fixes the message if lenient mode is on, counting tolerated deviations
+func (e *event) tolerate(message string) string {
+	e.handlersMu.RLock()
+	mode := e.compatibility
+	e.handlersMu.RUnlock()
+
+	if mode != CompatibilityLenient {
+		return message
+	}
+
+	message, deviations := protocol.Tolerate(message)
+	for _, d := range deviations {
+		toleratedDeviations[d].Inc()
+	}
+	return message
+}
+
+// CountToleratedDeviations returns an amount of tolerated deviations of the given kind
+func CountToleratedDeviations(d protocol.Deviation) int {
+	if d < 0 || d >= protocol.DeviationsAmount {
+		return 0
+	}
+	return toleratedDeviations[d].Get()
+}
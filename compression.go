@@ -0,0 +1,233 @@
+package gosocketio
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+const (
+	// DeflateCodecName is the name of the codec returned by NewDeflateCodec
+	DeflateCodecName = "deflate"
+
+	// MaxDecompressedSize limits the size of the decompressed payload of the codecs of this package
+	MaxDecompressedSize = 16 * 1024 * 1024
+
+	compressionParam = "compression"
+
+	// the tags of the payloads, the NUL byte doesn't start the application strings in practice
+	compressedPrefix = "\x00z" // payload is compressed
+	stringPrefix     = "\x00s" // payload is a JSON string left uncompressed
+)
+
+var (
+	ErrorNotCompressed = errors.New("payload is not compressed")
+	ErrorTooLarge      = errors.New("decompressed payload is too large")
+
+	decompressFailures synced.Counter
+)
+
+// CountDecompressFailures returns an amount of incoming messages dropped because their payload can't be decompressed
+func CountDecompressFailures() int { return decompressFailures.Get() }
+
+// Codec compresses the event payloads, e.g. zstd or snappy codec wrapped to implement it.
+// Decompress should limit the size of the output
+type Codec interface {
+	Name() string // name negotiated at handshake
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// deflateCodec is the DEFLATE Codec
+type deflateCodec struct{ level int }
+
+// NewDeflateCodec returns the DEFLATE Codec of the standard library with the given compression level,
+// see compress/flate
+func NewDeflateCodec(level int) (Codec, error) {
+	if _, err := flate.NewWriter(ioutil.Discard, level); err != nil {
+		return nil, err
+	}
+	return deflateCodec{level: level}, nil
+}
+
+// Name returns DeflateCodecName
+func (d deflateCodec) Name() string { return DeflateCodecName }
+
+// Compress the data
+func (d deflateCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, d.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress the data up to MaxDecompressedSize
+func (d deflateCodec) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	out, err := ioutil.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxDecompressedSize {
+		return nil, ErrorTooLarge
+	}
+	return out, nil
+}
+
+// compression holds the codecs and the minimum size of the payload to compress
+type compression struct {
+	codecs    []Codec // in the order of preference
+	threshold int
+}
+
+// codec returns the codec with the given name, nil if there is no such one
+func (cmp compression) codec(name string) Codec {
+	for _, codec := range cmp.codecs {
+		if codec.Name() == name {
+			return codec
+		}
+	}
+	return nil
+}
+
+// SetCompression enables the compression of the event, ack and ack response payloads not shorter than threshold
+// bytes by one of the codecs, independently of the websocket permessage-deflate extension, so it works through
+// the intermediaries stripping the extensions. The client offers the names of its codecs at handshake with the
+// "compression" query parameter, see DialCompression, the server picks the first of its codecs offered
+// and announces it in the open packet, then both sides compress with it. The payloads are compressed before
+// they are numbered, encrypted and signed. Without codecs the compression of new channels is disabled
+func (e *event) SetCompression(threshold int, codecs ...Codec) {
+	e.handlersMu.Lock()
+	e.compression = compression{codecs: codecs, threshold: threshold}
+	e.handlersMu.Unlock()
+}
+
+// getCompression returns the codecs and the threshold, e may be nil
+func (e *event) getCompression() compression {
+	if e == nil {
+		return compression{}
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.compression
+}
+
+// AddrCompression returns addr with the compression query parameter offering the codecs with the given names
+func AddrCompression(addr string, names ...string) string {
+	return addr + "&" + compressionParam + "=" + url.QueryEscape(strings.Join(names, ","))
+}
+
+// negotiateCompression picks the codec of the Channel from the comma separated names offered by the client
+func (c *Channel) negotiateCompression(offered string) {
+	cmp := c.events.getCompression()
+	for _, codec := range cmp.codecs {
+		for _, name := range strings.Split(offered, ",") {
+			if codec.Name() == name {
+				c.connHeader.Compression = name
+				return
+			}
+		}
+	}
+}
+
+// compression returns the negotiated codec of the Channel and the threshold, nil codec if the payloads
+// aren't compressed
+func (c *Channel) compression() (Codec, int) {
+	c.connMu.RLock()
+	name := c.connHeader.Compression // replaced on reconnect
+	c.connMu.RUnlock()
+	if name == "" {
+		return nil, 0
+	}
+	cmp := c.events.getCompression()
+	return cmp.codec(name), cmp.threshold
+}
+
+// compress returns the copy of message m with the payload compressed by codec if it's not shorter than threshold,
+// the payload of JSON string is marked as uncompressed otherwise
+func compress(codec Codec, threshold int, m *protocol.Message) (*protocol.Message, error) {
+	var tagged string
+	switch {
+	case len(m.Args) >= threshold:
+		compressed, err := codec.Compress([]byte(m.Args))
+		if err != nil {
+			return nil, err
+		}
+		tagged = compressedPrefix + base64.StdEncoding.EncodeToString(compressed)
+	case strings.HasPrefix(m.Args, `"`):
+		tagged = stringPrefix + m.Args
+	default:
+		return m, nil
+	}
+
+	args, err := json.Marshal(tagged)
+	if err != nil {
+		return nil, err
+	}
+	compressedM := *m
+	compressedM.Args = string(args)
+	return &compressedM, nil
+}
+
+// decompress the payload of the incoming message m in place, it returns false if m must be dropped
+func (c *Channel) decompress(m *protocol.Message) bool {
+	if !sealed(m) {
+		return true
+	}
+	codec, _ := c.compression()
+	if codec == nil || !strings.HasPrefix(m.Args, `"`) {
+		return true
+	}
+
+	args, err := decompressArgs(codec, m.Args)
+	if err != nil {
+		decompressFailures.Inc()
+		logging.Log().Warnf("Channel.decompress() can't decompress %q payload on %s: %v", m.EventName, c.Id(), err)
+		return false
+	}
+	m.Args = args
+	return true
+}
+
+// decompressArgs returns the payload decompressed by codec from the JSON string args, the untagged string
+// is returned as is, as the peer may send it before it gets the open packet
+func decompressArgs(codec Codec, args string) (string, error) {
+	var tagged string
+	if err := json.Unmarshal([]byte(args), &tagged); err != nil {
+		return "", ErrorNotCompressed
+	}
+	switch {
+	case strings.HasPrefix(tagged, stringPrefix):
+		return tagged[len(stringPrefix):], nil
+	case strings.HasPrefix(tagged, compressedPrefix):
+		compressed, err := base64.StdEncoding.DecodeString(tagged[len(compressedPrefix):])
+		if err != nil {
+			return "", ErrorNotCompressed
+		}
+		data, err := codec.Decompress(compressed)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return args, nil
+}
@@ -0,0 +1,127 @@
+package gosocketio
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dialCompressed connects the client offering the codecs to the server, it returns after the handshake
+// the client, a function to stop the client and server and the packets written and read by the client
+func dialCompressed(t *testing.T, s *Server, codecs ...Codec) (*Client, func(), func() []string) {
+	t.Helper()
+	host, port, stopServer := serve(t, s)
+
+	var (
+		packets []string
+		mu      sync.Mutex
+	)
+	record := func(_ *Channel, packet string) {
+		mu.Lock()
+		packets = append(packets, packet)
+		mu.Unlock()
+	}
+	handshaken := make(chan struct{})
+	hooks := ClientHooks{Handshake: func(*Channel) { close(handshaken) }, PacketSent: record, PacketReceived: record}
+	c, err := DialWithOpts(AddrWebsocket(host, port, false), DialHooks(hooks), DialCompression(16, codecs...))
+	if err != nil {
+		stopServer()
+		t.Fatal(err)
+	}
+	stop := func() {
+		c.Close()
+		stopServer()
+	}
+	select {
+	case <-handshaken: // the codec is announced in the open packet
+	case <-time.After(5 * time.Second):
+		stop()
+		t.Fatal("no handshake")
+	}
+	return c, stop, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), packets...)
+	}
+}
+
+// deflate returns the DEFLATE codec with the default compression level
+func deflate(t *testing.T) Codec {
+	t.Helper()
+	codec, err := NewDeflateCodec(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	s := NewServer()
+	s.SetCompression(16, deflate(t))
+	s.On("echo", func(c *Channel, q string) string { return q + "!" })
+	c, stop, packets := dialCompressed(t, s, deflate(t))
+	defer stop()
+	if codec, _ := c.Channel.compression(); codec == nil || codec.Name() != DeflateCodecName {
+		t.Fatal("negotiated codec:", codec)
+	}
+
+	payload := strings.Repeat("compressible ", 20)
+	response, err := c.Ack("echo", payload, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != `"`+payload+`!"` {
+		t.Fatal("decompressed ack response:", response)
+	}
+	short, err := c.Ack("echo", "short", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short != `"short!"` {
+		t.Fatal("ack response shorter than the threshold:", short)
+	}
+
+	for _, packet := range packets() {
+		if strings.Contains(packet, "compressible") {
+			t.Fatal("payload is transferred uncompressed:", packet)
+		}
+	}
+}
+
+func TestCompressionNotNegotiated(t *testing.T) {
+	for name, offer := range map[string]struct{ server, client []Codec }{
+		"client without codecs": {server: []Codec{deflate(t)}},
+		"server without codecs": {client: []Codec{deflate(t)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := NewServer()
+			s.SetCompression(16, offer.server...)
+			s.On("echo", func(c *Channel, q string) string { return q + "!" })
+			c, stop, packets := dialCompressed(t, s, offer.client...)
+			defer stop()
+			if codec, _ := c.Channel.compression(); codec != nil {
+				t.Fatal("codec is negotiated:", codec.Name())
+			}
+
+			payload := strings.Repeat("compressible ", 20)
+			response, err := c.Ack("echo", payload, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response != `"`+payload+`!"` {
+				t.Fatal("ack response:", response)
+			}
+
+			var plain int
+			for _, packet := range packets() {
+				if strings.Contains(packet, payload) {
+					plain++
+				}
+			}
+			if plain != 2 {
+				t.Fatalf("%d packets transfer the payload uncompressed, expected the ack request and response", plain)
+			}
+		})
+	}
+}
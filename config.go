@@ -0,0 +1,225 @@
+package gosocketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultConfigWatchInterval is how often WatchConfigFile checks the file by default
+const DefaultConfigWatchInterval = 5 * time.Second
+
+var ErrorInvalidDuration = errors.New("invalid duration")
+
+// Duration is a time.Duration decoded from the JSON string like "1m30s" or the number of nanoseconds
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		n, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return ErrorInvalidDuration
+		}
+		*d = Duration(n)
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return ErrorInvalidDuration
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) { return json.Marshal(time.Duration(d).String()) }
+
+// Config holds the server tunables changeable at runtime with ApplyConfig, nil field keeps the current value.
+// Zero values mean the same as for the corresponding setters
+type Config struct {
+	HandshakeTimeout       *Duration        `json:"handshakeTimeout,omitempty"`       // see SetHandshakeTimeout
+	IdleTimeout            *Duration        `json:"idleTimeout,omitempty"`            // see SetIdleTimeout
+	HeartbeatTimeout       *Duration        `json:"heartbeatTimeout,omitempty"`       // see SetHeartbeatTimeout
+	BackgroundPingInterval *Duration        `json:"backgroundPingInterval,omitempty"` // see SetBackgroundPingLimits
+	BackgroundPingTimeout  *Duration        `json:"backgroundPingTimeout,omitempty"`
+	MaxConnections         *int             `json:"maxConnections,omitempty"` // see SetMaxConnections
+	Bandwidth              *BandwidthQuota  `json:"bandwidth,omitempty"`      // see SetBandwidthQuota
+	ReadFlowPause          *int             `json:"readFlowPause,omitempty"`  // see SetReadFlowControl
+	ReadFlowResume         *int             `json:"readFlowResume,omitempty"`
+	HistorySize            *int             `json:"historySize,omitempty"`  // see SetHistorySize
+	DecodeLimits           *protocol.Limits `json:"decodeLimits,omitempty"` // see SetDecodeLimits
+	LogLevel               string           `json:"logLevel,omitempty"`     // logrus level name, empty keeps the current one
+}
+
+// SetMaxConnections limits the amount of the connected channels, new connections over the limit are refused
+// with 503 Service Unavailable. Zero means unlimited, it's the default
+func (s *Server) SetMaxConnections(max int) {
+	s.maxConnectionsMu.Lock()
+	s.maxConnections = max
+	s.maxConnectionsMu.Unlock()
+}
+
+// full returns whether the server has the maximum amount of the connected channels
+func (s *Server) full() bool {
+	s.maxConnectionsMu.RLock()
+	max := s.maxConnections
+	s.maxConnectionsMu.RUnlock()
+	return max > 0 && s.CountChannels() >= max
+}
+
+// refuseFull responds with 503 Service Unavailable if the server is full, it returns whether it did
+func (s *Server) refuseFull(w http.ResponseWriter) bool {
+	if !s.full() {
+		return false
+	}
+	logging.Log().Warn("Server.ServeHTTP() refused the connection: max connections reached")
+	http.Error(w, "too many connections", http.StatusServiceUnavailable)
+	return true
+}
+
+// ApplyConfig updates the server tunables with cfg at runtime. The config is validated first, so the invalid one
+// changes nothing. Concurrent calls are applied one by one. Changes affect the connected channels where
+// the tunable is read on use, e.g. timeouts and quotas of the new connections, limits of the new messages
+func (s *Server) ApplyConfig(cfg Config) error {
+	var level logrus.Level
+	if cfg.LogLevel != "" {
+		var err error
+		if level, err = logrus.ParseLevel(cfg.LogLevel); err != nil {
+			return err
+		}
+	}
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if cfg.HandshakeTimeout != nil {
+		s.SetHandshakeTimeout(time.Duration(*cfg.HandshakeTimeout))
+	}
+	if cfg.IdleTimeout != nil {
+		s.SetIdleTimeout(time.Duration(*cfg.IdleTimeout))
+	}
+	if cfg.HeartbeatTimeout != nil {
+		s.SetHeartbeatTimeout(time.Duration(*cfg.HeartbeatTimeout))
+	}
+	if cfg.BackgroundPingInterval != nil || cfg.BackgroundPingTimeout != nil {
+		s.backgroundMu.RLock()
+		interval, timeout := s.backgroundInterval, s.backgroundTimeout
+		s.backgroundMu.RUnlock()
+		if cfg.BackgroundPingInterval != nil {
+			interval = time.Duration(*cfg.BackgroundPingInterval)
+		}
+		if cfg.BackgroundPingTimeout != nil {
+			timeout = time.Duration(*cfg.BackgroundPingTimeout)
+		}
+		s.SetBackgroundPingLimits(interval, timeout)
+	}
+	if cfg.MaxConnections != nil {
+		s.SetMaxConnections(*cfg.MaxConnections)
+	}
+	if cfg.Bandwidth != nil {
+		s.SetBandwidthQuota(*cfg.Bandwidth)
+	}
+	if cfg.ReadFlowPause != nil || cfg.ReadFlowResume != nil {
+		pause, resume := s.readFlowControl()
+		if cfg.ReadFlowPause != nil {
+			pause = *cfg.ReadFlowPause
+		}
+		if cfg.ReadFlowResume != nil {
+			resume = *cfg.ReadFlowResume
+		}
+		s.SetReadFlowControl(pause, resume)
+	}
+	if cfg.HistorySize != nil {
+		s.SetHistorySize(*cfg.HistorySize)
+	}
+	if cfg.DecodeLimits != nil {
+		s.SetDecodeLimits(*cfg.DecodeLimits)
+	}
+	if cfg.LogLevel != "" {
+		logging.Log().SetLevel(level)
+	}
+	return nil
+}
+
+// LoadConfigFile reads the JSON config from the file at path
+func LoadConfigFile(path string) (Config, error) {
+	var cfg Config
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(b, &cfg)
+	return cfg, err
+}
+
+// WatchConfigFile applies the JSON config from the file at path, then checks the file modification time
+// every interval and applies it again when it's changed, until ctx is done. The error of the first load is
+// returned at once, later ones are passed to onError if it isn't nil and the previous config stays in effect.
+// Zero interval means DefaultConfigWatchInterval
+func (s *Server) WatchConfigFile(ctx context.Context, path string, interval time.Duration,
+	onError func(err error)) error {
+	if interval <= 0 {
+		interval = DefaultConfigWatchInterval
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	if err := s.ApplyConfig(cfg); err != nil {
+		return err
+	}
+
+	fail := func(err error) {
+		logging.Log().Warn("Server.WatchConfigFile() can't reload the config:", err)
+		if onError != nil {
+			onError(err)
+		}
+	}
+
+	modified, size := info.ModTime(), info.Size()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		if info.ModTime().Equal(modified) && info.Size() == size {
+			continue
+		}
+		modified, size = info.ModTime(), info.Size()
+
+		cfg, err := LoadConfigFile(path)
+		if err == nil {
+			err = s.ApplyConfig(cfg)
+		}
+		if err != nil {
+			fail(err)
+			continue
+		}
+		logging.Log().Debug("Server.WatchConfigFile() applied the changed config")
+	}
+}
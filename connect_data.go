@@ -0,0 +1,66 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+var ErrorNoConnectData = errors.New("connect packet carries no data")
+
+// SetConnectData sets a function producing the data (e.g. assigned user id, feature flags, server time)
+// attached to the connect packet of each new channel, it's called before the OnConnection handler.
+// The client reads it with Channel.ConnectData() in its OnConnection handler. nil disables the data.
+// Note that JS clients of socket.io v2 may reject the connect packet with data
+func (s *Server) SetConnectData(f func(c *Channel) interface{}) {
+	s.connectDataMu.Lock()
+	s.connectData = f
+	s.connectDataMu.Unlock()
+}
+
+// connectMessage returns the connect packet for the channel c
+func (s *Server) connectMessage(c *Channel) *protocol.Message {
+	m := &protocol.Message{Type: protocol.MessageTypeEmpty}
+
+	s.connectDataMu.RLock()
+	f := s.connectData
+	s.connectDataMu.RUnlock()
+	if f == nil {
+		return m
+	}
+
+	data, err := json.Marshal(f(c))
+	if err != nil {
+		logging.Log().Warn("Server.connectMessage() can't marshal connect data:", err)
+		return m
+	}
+	m.Args = string(data)
+	return m
+}
+
+// connectAcked stores the data of the connect packet received by the client side Channel and calls OnConnection handler
+func (c *Channel) connectAcked(e *event, data string) {
+	c.connectMu.Lock()
+	c.connectData = data
+	onConnect := c.onConnect
+	c.connectMu.Unlock()
+
+	e.callHandler(c, OnConnection)
+	if onConnect != nil {
+		go onConnect()
+	}
+}
+
+// ConnectData decodes the data attached by the server to the connect packet into v, see Server.SetConnectData()
+func (c *Channel) ConnectData(v interface{}) error {
+	c.connectMu.RLock()
+	data := c.connectData
+	c.connectMu.RUnlock()
+
+	if data == "" {
+		return ErrorNoConnectData
+	}
+	return json.Unmarshal([]byte(data), v)
+}
@@ -0,0 +1,464 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// CRDTChangeEvent carries the value of the room counter or set changed locally or by another node
+const CRDTChangeEvent = "sio:crdt"
+
+const (
+	CRDTCounter = "counter"
+	CRDTSet     = "set"
+
+	defaultCRDTNode = "local"
+)
+
+var ErrorCRDTKind = errors.New("room has the CRDT of another kind with this name")
+
+// CRDTChange is a payload of the CRDTChangeEvent
+type CRDTChange struct {
+	Room  string      `json:"room"`
+	Name  string      `json:"name"`
+	Kind  string      `json:"kind"`  // CRDTCounter or CRDTSet
+	Value interface{} `json:"value"` // int64 of the counter, sorted []string of the set members
+}
+
+// CRDTUpdate is the state of the room counter or set replicated between the nodes,
+// merging it is idempotent, commutative and associative, so the updates may be duplicated and reordered
+type CRDTUpdate struct {
+	Room  string          `json:"room"`
+	Name  string          `json:"name"`
+	Kind  string          `json:"kind"`
+	State json.RawMessage `json:"state"`
+}
+
+// CRDTReplicator carries the CRDT updates between the nodes of the cluster, e.g. over the pub/sub broker.
+// Implementation should be safe for concurrent use and shouldn't block
+type CRDTReplicator interface {
+	// Publish the update of this node to other ones
+	Publish(u CRDTUpdate) error
+	// Subscribe f to the updates of other nodes, the updates of this node may be passed too
+	Subscribe(f func(u CRDTUpdate))
+}
+
+// SetCRDTReplicator replicates the room counters and sets of the server with r under the unique node id,
+// nil r keeps them local. It should be set before the CRDTs are changed
+func (s *Server) SetCRDTReplicator(node string, r CRDTReplicator) {
+	s.crdtsMu.Lock()
+	s.crdtNode, s.crdtReplicator = node, r
+	s.crdtsMu.Unlock()
+	if r != nil {
+		r.Subscribe(s.mergeCRDT)
+	}
+}
+
+// replication returns the node id and the replicator, nil if the CRDTs are local
+func (s *Server) replication() (string, CRDTReplicator) {
+	s.crdtsMu.Lock()
+	defer s.crdtsMu.Unlock()
+	if s.crdtNode == "" {
+		return defaultCRDTNode, s.crdtReplicator
+	}
+	return s.crdtNode, s.crdtReplicator
+}
+
+// crdtKey identifies the CRDT of the room
+type crdtKey struct{ room, name string }
+
+// crdt is the room counter or set
+type crdt interface {
+	kind() string
+	merge(state json.RawMessage) (changed bool, err error) // the lock must be held
+	state() (json.RawMessage, error)                       // the lock must be held
+	value() interface{}                                    // the lock must be held
+	lock() *sync.Mutex
+}
+
+// roomCRDT returns the CRDT of the room with the given name and kind creating it with create
+func (s *Server) roomCRDT(room, name, kind string, create func() crdt) (crdt, error) {
+	s.crdtsMu.Lock()
+	defer s.crdtsMu.Unlock()
+	if s.crdts == nil {
+		s.crdts = make(map[crdtKey]crdt)
+	}
+	key := crdtKey{room: room, name: name}
+	if d, ok := s.crdts[key]; ok {
+		if d.kind() != kind {
+			return nil, ErrorCRDTKind
+		}
+		return d, nil
+	}
+	d := create()
+	s.crdts[key] = d
+	return d, nil
+}
+
+// roomCRDTs returns the CRDTs of the room by name
+func (s *Server) roomCRDTs(room string) map[string]crdt {
+	s.crdtsMu.Lock()
+	defer s.crdtsMu.Unlock()
+	crdts := make(map[string]crdt)
+	for key, d := range s.crdts {
+		if key.room == room {
+			crdts[key.name] = d
+		}
+	}
+	return crdts
+}
+
+// DeleteRoomCRDTs discards the counters and sets of the room of the global tenant on this node
+func (s *Server) DeleteRoomCRDTs(room string) {
+	if validRoom(room) {
+		s.deleteRoomCRDTs(room)
+	}
+}
+
+// deleteRoomCRDTs discards the counters and sets of the room with the given internal name on this node
+func (s *Server) deleteRoomCRDTs(room string) {
+	s.crdtsMu.Lock()
+	defer s.crdtsMu.Unlock()
+	for key := range s.crdts {
+		if key.room == room {
+			delete(s.crdts, key)
+		}
+	}
+}
+
+// changedCRDT publishes the state of the CRDT d changed on this node and broadcasts its value to the room,
+// the lock of d must be held, so the values are broadcast in order
+func (s *Server) changedCRDT(room, name string, d crdt, local bool) {
+	if local {
+		s.publishCRDT(room, name, d)
+	}
+
+	value := d.value()
+	for _, c := range s.list(room) {
+		change := CRDTChange{Room: unscopedRoom(c.tenant, room), Name: name, Kind: d.kind(), Value: value}
+		if err := c.Emit(CRDTChangeEvent, change); err != nil {
+			logging.Log().Warnf("Server.changedCRDT() can't send the change to %s: %v", c.Id(), err)
+		}
+	}
+}
+
+// publishCRDT publishes the state of the CRDT d updated on this node to other ones, the lock of d must be held
+func (s *Server) publishCRDT(room, name string, d crdt) {
+	if _, r := s.replication(); r != nil {
+		state, err := d.state()
+		if err == nil {
+			err = r.Publish(CRDTUpdate{Room: room, Name: name, Kind: d.kind(), State: state})
+		}
+		if err != nil {
+			logging.Log().Warn("Server.publishCRDT() can't publish the update:", err)
+		}
+	}
+}
+
+// mergeCRDT merges the update of another node and broadcasts the value if it's changed
+func (s *Server) mergeCRDT(u CRDTUpdate) {
+	var create func() crdt
+	switch u.Kind {
+	case CRDTCounter:
+		create = func() crdt { return newRoomCounter(s, u.Room, u.Name) }
+	case CRDTSet:
+		create = func() crdt { return newRoomSet(s, u.Room, u.Name) }
+	default:
+		logging.Log().Warn("Server.mergeCRDT() unknown kind:", u.Kind)
+		return
+	}
+	d, err := s.roomCRDT(u.Room, u.Name, u.Kind, create)
+	if err != nil {
+		logging.Log().Warn("Server.mergeCRDT() can't merge the update:", err)
+		return
+	}
+
+	d.lock().Lock()
+	defer d.lock().Unlock()
+	changed, err := d.merge(u.State)
+	if err != nil {
+		logging.Log().Warn("Server.mergeCRDT() can't merge the update:", err)
+		return
+	}
+	if changed {
+		s.changedCRDT(u.Room, u.Name, d, false)
+	}
+}
+
+// sendRoomCRDTs sends the values of the room counters and sets to the channel c joined to the room
+func (s *Server) sendRoomCRDTs(c *Channel, room string) {
+	for name, d := range s.roomCRDTs(scopedRoom(c.tenant, room)) {
+		d.lock().Lock()
+		change := CRDTChange{Room: room, Name: name, Kind: d.kind(), Value: d.value()}
+		d.lock().Unlock()
+		if err := c.Emit(CRDTChangeEvent, change); err != nil {
+			logging.Log().Warnf("Server.sendRoomCRDTs() can't send the value to %s: %v", c.Id(), err)
+		}
+	}
+}
+
+// RoomCounter is the counter of the room (PN-Counter) replicated between the nodes, e.g. of likes or viewers
+// online. The channels of the room get CRDTChangeEvent with its value on join and on each change
+type RoomCounter struct {
+	s          *Server
+	room, name string
+	inc, dec   map[string]int64 // totals by node
+	mu         sync.Mutex
+}
+
+// newRoomCounter returns the empty counter
+func newRoomCounter(s *Server, room, name string) *RoomCounter {
+	return &RoomCounter{s: s, room: room, name: name, inc: make(map[string]int64), dec: make(map[string]int64)}
+}
+
+// RoomCounter returns the counter of the room with the given name creating the zero one,
+// it fails with ErrorCRDTKind if the room has the set with this name. The room is of the global tenant,
+// see Tenant.RoomCounter. It fails with ErrorRoomName if the name contains the tenant separator
+func (s *Server) RoomCounter(room, name string) (*RoomCounter, error) {
+	if !validRoom(room) {
+		return nil, ErrorRoomName
+	}
+	return s.roomCounter(room, name)
+}
+
+// roomCounter returns the counter of the room with the given internal name creating the zero one
+func (s *Server) roomCounter(room, name string) (*RoomCounter, error) {
+	d, err := s.roomCRDT(room, name, CRDTCounter, func() crdt { return newRoomCounter(s, room, name) })
+	if err != nil {
+		return nil, err
+	}
+	return d.(*RoomCounter), nil
+}
+
+// Add delta to the counter, it may be negative
+func (rc *RoomCounter) Add(delta int64) {
+	if delta == 0 {
+		return
+	}
+	node, _ := rc.s.replication()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if delta > 0 {
+		rc.inc[node] += delta
+	} else {
+		rc.dec[node] -= delta
+	}
+	rc.s.changedCRDT(rc.room, rc.name, rc, true)
+}
+
+// Value returns the value of the counter
+func (rc *RoomCounter) Value() int64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.value().(int64)
+}
+
+// counterState is the replicated state of the RoomCounter
+type counterState struct {
+	Inc map[string]int64 `json:"inc"`
+	Dec map[string]int64 `json:"dec"`
+}
+
+func (rc *RoomCounter) kind() string      { return CRDTCounter }
+func (rc *RoomCounter) lock() *sync.Mutex { return &rc.mu }
+
+func (rc *RoomCounter) state() (json.RawMessage, error) {
+	return json.Marshal(counterState{Inc: rc.inc, Dec: rc.dec})
+}
+
+func (rc *RoomCounter) value() interface{} {
+	var v int64
+	for _, n := range rc.inc {
+		v += n
+	}
+	for _, n := range rc.dec {
+		v -= n
+	}
+	return v
+}
+
+func (rc *RoomCounter) merge(state json.RawMessage) (bool, error) {
+	var cs counterState
+	if err := json.Unmarshal(state, &cs); err != nil {
+		return false, err
+	}
+	changed := mergeMax(rc.inc, cs.Inc)
+	return mergeMax(rc.dec, cs.Dec) || changed, nil
+}
+
+// mergeMax merges the node totals taking the maximum, it returns whether to is changed
+func mergeMax(to, from map[string]int64) bool {
+	changed := false
+	for node, n := range from {
+		if n > to[node] {
+			to[node], changed = n, true
+		}
+	}
+	return changed
+}
+
+// RoomSet is the set of strings of the room (LWW-Element-Set) replicated between the nodes, e.g. of the users
+// online. The concurrent add and remove of the element are resolved by the node clocks, add wins the tie.
+// The channels of the room get CRDTChangeEvent with its members on join and on each change
+type RoomSet struct {
+	s              *Server
+	room, name     string
+	added, removed map[string]int64 // moments in nanoseconds by element
+	mu             sync.Mutex
+}
+
+// newRoomSet returns the empty set
+func newRoomSet(s *Server, room, name string) *RoomSet {
+	return &RoomSet{s: s, room: room, name: name, added: make(map[string]int64), removed: make(map[string]int64)}
+}
+
+// RoomSet returns the set of the room with the given name creating the empty one,
+// it fails with ErrorCRDTKind if the room has the counter with this name. The room is of the global tenant,
+// see Tenant.RoomSet. It fails with ErrorRoomName if the name contains the tenant separator
+func (s *Server) RoomSet(room, name string) (*RoomSet, error) {
+	if !validRoom(room) {
+		return nil, ErrorRoomName
+	}
+	return s.roomSet(room, name)
+}
+
+// roomSet returns the set of the room with the given internal name creating the empty one
+func (s *Server) roomSet(room, name string) (*RoomSet, error) {
+	d, err := s.roomCRDT(room, name, CRDTSet, func() crdt { return newRoomSet(s, room, name) })
+	if err != nil {
+		return nil, err
+	}
+	return d.(*RoomSet), nil
+}
+
+// Add the element to the set
+func (rs *RoomSet) Add(element string) { rs.update(element, rs.added) }
+
+// Remove the element from the set
+func (rs *RoomSet) Remove(element string) { rs.update(element, rs.removed) }
+
+// update the moment of the element in the added or removed ones
+func (rs *RoomSet) update(element string, moments map[string]int64) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	was := rs.contains(element)
+	now, last := rs.s.event.now().UnixNano(), rs.added[element]
+	if rs.removed[element] > last {
+		last = rs.removed[element]
+	}
+	if now <= last {
+		now = last + 1 // the later operation of this node wins
+	}
+	moments[element] = now
+	if rs.contains(element) != was {
+		rs.s.changedCRDT(rs.room, rs.name, rs, true)
+	} else { // the moment is published anyway to win over the concurrent operations of other nodes
+		rs.s.publishCRDT(rs.room, rs.name, rs)
+	}
+}
+
+// Contains returns whether the element is in the set
+func (rs *RoomSet) Contains(element string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.contains(element)
+}
+
+// contains returns whether the element is in the set, the lock must be held
+func (rs *RoomSet) contains(element string) bool {
+	added, ok := rs.added[element]
+	return ok && added >= rs.removed[element]
+}
+
+// Members returns the sorted elements of the set
+func (rs *RoomSet) Members() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.value().([]string)
+}
+
+// setState is the replicated state of the RoomSet
+type setState struct {
+	Added   map[string]int64 `json:"added"`
+	Removed map[string]int64 `json:"removed"`
+}
+
+func (rs *RoomSet) kind() string      { return CRDTSet }
+func (rs *RoomSet) lock() *sync.Mutex { return &rs.mu }
+
+func (rs *RoomSet) state() (json.RawMessage, error) {
+	return json.Marshal(setState{Added: rs.added, Removed: rs.removed})
+}
+
+func (rs *RoomSet) value() interface{} {
+	members := []string{}
+	for element := range rs.added {
+		if rs.contains(element) {
+			members = append(members, element)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+func (rs *RoomSet) merge(state json.RawMessage) (bool, error) {
+	var ss setState
+	if err := json.Unmarshal(state, &ss); err != nil {
+		return false, err
+	}
+	before := rs.value().([]string)
+	mergeMax(rs.added, ss.Added)
+	mergeMax(rs.removed, ss.Removed)
+	after := rs.value().([]string)
+	if len(before) != len(after) {
+		return true, nil
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MemoryReplication replicates the CRDTs between the servers of the current process, e.g. in tests
+type MemoryReplication struct {
+	subscribers []func(u CRDTUpdate)
+	mu          sync.RWMutex
+}
+
+// NewMemoryReplication returns a new MemoryReplication
+func NewMemoryReplication() *MemoryReplication { return &MemoryReplication{} }
+
+// Node returns the replicator of a single server
+func (mr *MemoryReplication) Node() CRDTReplicator { return &memoryReplicator{mr: mr, index: -1} }
+
+// memoryReplicator is the CRDTReplicator of the MemoryReplication node
+type memoryReplicator struct {
+	mr    *MemoryReplication
+	index int // of the subscriber, -1 if it isn't subscribed
+}
+
+// Publish the update to other nodes asynchronously
+func (r *memoryReplicator) Publish(u CRDTUpdate) error {
+	r.mr.mu.RLock()
+	defer r.mr.mu.RUnlock()
+	for i, f := range r.mr.subscribers {
+		if i != r.index {
+			go f(u)
+		}
+	}
+	return nil
+}
+
+// Subscribe f to the updates of other nodes
+func (r *memoryReplicator) Subscribe(f func(u CRDTUpdate)) {
+	r.mr.mu.Lock()
+	defer r.mr.mu.Unlock()
+	r.index = len(r.mr.subscribers)
+	r.mr.subscribers = append(r.mr.subscribers, f)
+}
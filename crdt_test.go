@@ -0,0 +1,141 @@
+package gosocketio
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRoomSetFrozenClock(t *testing.T) {
+	s := NewServer()
+	s.SetClock(NewManualClock(time.Unix(1000, 0)))
+	set, err := s.RoomSet("lobby", "online")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set.Add("a")
+	set.Remove("a")
+	if set.Contains("a") {
+		t.Fatal("element removed after the add on the same clock tick is in the set")
+	}
+	set.Add("a")
+	if !set.Contains("a") {
+		t.Fatal("element added after the remove on the same clock tick isn't in the set")
+	}
+
+	set.Remove("b")
+	set.Add("b")
+	set.Remove("b")
+	if set.Contains("b") {
+		t.Fatal("element removed last on the same clock tick is in the set")
+	}
+}
+
+// heldReplicator holds the updates published while holding until released
+type heldReplicator struct {
+	CRDTReplicator
+	held    []CRDTUpdate
+	holding bool
+	mu      sync.Mutex
+}
+
+// Publish the update or hold it
+func (r *heldReplicator) Publish(u CRDTUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.holding {
+		r.held = append(r.held, u)
+		return nil
+	}
+	return r.CRDTReplicator.Publish(u)
+}
+
+// hold the following updates
+func (r *heldReplicator) hold() {
+	r.mu.Lock()
+	r.holding = true
+	r.mu.Unlock()
+}
+
+// release publishes the held updates
+func (r *heldReplicator) release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.holding = false
+	for _, u := range r.held {
+		r.CRDTReplicator.Publish(u)
+	}
+	r.held = nil
+}
+
+// replicatedSet is the set of the node with its clock and replicator
+type replicatedSet struct {
+	*RoomSet
+	clock      *ManualClock
+	replicator *heldReplicator
+}
+
+// replicatedSets returns the sets of the room on two nodes replicated with MemoryReplication,
+// the node clocks start at the given moments
+func replicatedSets(t *testing.T, startA, startB time.Time) (replicatedSet, replicatedSet) {
+	t.Helper()
+	replication := NewMemoryReplication()
+	sets := make([]replicatedSet, 2)
+	for i, start := range []time.Time{startA, startB} {
+		s := NewServer()
+		sets[i].clock = NewManualClock(start)
+		s.SetClock(sets[i].clock)
+		sets[i].replicator = &heldReplicator{CRDTReplicator: replication.Node()}
+		s.SetCRDTReplicator(string(rune('a'+i)), sets[i].replicator)
+		set, err := s.RoomSet("lobby", "online")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sets[i].RoomSet = set
+	}
+	return sets[0], sets[1]
+}
+
+// converged waits for both sets to contain the element or not
+func converged(t *testing.T, a, b replicatedSet, element string, contains bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if a.Contains(element) == contains && b.Contains(element) == contains {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sets don't converge, contain %q: %v and %v, expected %v", element,
+				a.Contains(element), b.Contains(element), contains)
+		}
+	}
+}
+
+// concurrently runs the operations on the sets holding the replication until all of them are done
+func concurrently(a, b replicatedSet, operations ...func()) {
+	a.replicator.hold()
+	b.replicator.hold()
+	for _, operation := range operations {
+		operation()
+	}
+	a.replicator.release()
+	b.replicator.release()
+}
+
+func TestRoomSetReplicatesRemoveOfUnknownElement(t *testing.T) {
+	a, b := replicatedSets(t, time.Unix(100, 0), time.Unix(200, 0))
+
+	concurrently(a, b, func() { a.Add("x") }, func() { b.Remove("x") }) // the remove is newer
+	converged(t, a, b, "x", false)
+}
+
+func TestRoomSetReplicatesReAddOfPresentElement(t *testing.T) {
+	a, b := replicatedSets(t, time.Unix(100, 0), time.Unix(200, 0))
+
+	a.Add("x")
+	converged(t, a, b, "x", true)
+
+	a.clock.Advance(200 * time.Second)
+	concurrently(a, b, func() { a.Add("x") }, func() { b.Remove("x") }) // the add is newer
+	converged(t, a, b, "x", true)
+}
@@ -0,0 +1,63 @@
+package gosocketio
+
+import (
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// minDeadCheckInterval limits the frequency of dead connection checks
+const minDeadCheckInterval = 100 * time.Millisecond
+
+// SetDeadConnectionTimeout makes client to treat the connection as dead (half-open) if nothing,
+// including pongs, was received for the given duration. Dead connection is torn down and the client
+// reconnects, if reconnection fails the client is closed. Zero duration disables the check.
+// The timeout should be greater than the ping interval
+func (c *Client) SetDeadConnectionTimeout(timeout time.Duration) {
+	c.deadMu.Lock()
+	defer c.deadMu.Unlock()
+
+	c.deadTimeout = timeout
+	if timeout > 0 && !c.deadWatching {
+		c.deadWatching = true
+		go c.deadLoop()
+	}
+}
+
+// deadCheck returns the current dead connection timeout and the interval between checks
+func (c *Client) deadCheck() (time.Duration, time.Duration) {
+	c.deadMu.Lock()
+	defer c.deadMu.Unlock()
+
+	if c.deadTimeout <= 0 || !c.IsAlive() {
+		c.deadWatching = false
+		return 0, 0
+	}
+
+	interval := c.deadTimeout / 4
+	if interval < minDeadCheckInterval {
+		interval = minDeadCheckInterval
+	}
+	return c.deadTimeout, interval
+}
+
+// deadLoop periodically checks the connection until the check is disabled or the client is closed
+func (c *Client) deadLoop() {
+	for {
+		timeout, interval := c.deadCheck()
+		if timeout == 0 {
+			return
+		}
+
+		if c.SilentFor() >= timeout {
+			logging.Log().Debug("Client.deadLoop() detected dead connection, reconnecting")
+			if err := c.Reconnect(""); err != nil {
+				logging.Log().Debug("Client.deadLoop() failed to reconnect:", err)
+				c.disconnected(DisconnectTimeout, 0, "")
+				c.Close()
+			}
+		}
+
+		c.event.sleep(interval)
+	}
+}
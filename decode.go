@@ -0,0 +1,39 @@
+package gosocketio
+
+import "github.com/mtfelian/golang-socketio/protocol"
+
+// SetDecodeLimits sets limits for the incoming messages, the connection sending a message exceeding them
+// is closed after calling the OnProtocolError handler. protocol.DefaultLimits are used by default
+func (e *event) SetDecodeLimits(l protocol.Limits) {
+	e.handlersMu.Lock()
+	e.limits = l
+	e.handlersMu.Unlock()
+}
+
+// OnProtocolError registers a handler called when the malformed message is received,
+// err is one of the protocol package errors
+func (e *event) OnProtocolError(f func(c *Channel, message string, err error)) {
+	e.handlersMu.Lock()
+	e.onProtocolError = f
+	e.handlersMu.Unlock()
+}
+
+// decode the incoming message according to the limits
+func (e *event) decode(message string) (*protocol.Message, error) {
+	e.handlersMu.RLock()
+	l := e.limits
+	e.handlersMu.RUnlock()
+
+	return protocol.DecodeWithLimits(e.tolerate(message), l)
+}
+
+// protocolError calls OnProtocolError handler if it's registered
+func (e *event) protocolError(c *Channel, message string, err error) {
+	e.handlersMu.RLock()
+	f := e.onProtocolError
+	e.handlersMu.RUnlock()
+
+	if f != nil {
+		f(c, message, err)
+	}
+}
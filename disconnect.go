@@ -0,0 +1,129 @@
+package gosocketio
+
+import (
+	"net"
+
+	"github.com/gorilla/websocket"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// DisconnectReason describes why the Channel was disconnected
+type DisconnectReason int
+
+const (
+	DisconnectUnknown           DisconnectReason = iota
+	DisconnectClosed                             // closed by this side with Close() or CloseWith()
+	DisconnectPeerClosed                         // closed by the peer normally
+	DisconnectGoingAway                          // peer is going away, e.g. server shutdown or browser navigation
+	DisconnectProtocolError                      // malformed packet received or protocol error close code
+	DisconnectPolicyViolation                    // policy violation close code
+	DisconnectMessageTooBig                      // message too big close code
+	DisconnectInternalError                      // peer internal error close code
+	DisconnectTimeout                            // nothing received within the receive timeout
+	DisconnectTransportError                     // connection lost or failed to write
+	DisconnectQueueOverflow                      // outgoing queue overflooded
+	DisconnectIdle                               // no application events within the idle timeout
+	DisconnectBandwidthExceeded                  // received more data than the bandwidth quota allows
+	DisconnectHandshakeTimeout                   // no valid packet received within the handshake timeout
+	DisconnectSessionConflict                    // rejected because the resumed session is active on another channel
+	DisconnectSessionTakeover                    // the session was taken over by another channel
+	DisconnectRejected                           // rejected at the handshake, e.g. by the TenantResolver
+	DisconnectMigrated                           // the session was migrated to another node, see Channel.Migrate
+)
+
+var disconnectReasonNames = map[DisconnectReason]string{
+	DisconnectUnknown:           "unknown",
+	DisconnectClosed:            "closed",
+	DisconnectPeerClosed:        "peer closed",
+	DisconnectGoingAway:         "going away",
+	DisconnectProtocolError:     "protocol error",
+	DisconnectPolicyViolation:   "policy violation",
+	DisconnectMessageTooBig:     "message too big",
+	DisconnectInternalError:     "internal error",
+	DisconnectTimeout:           "timeout",
+	DisconnectTransportError:    "transport error",
+	DisconnectQueueOverflow:     "queue overflow",
+	DisconnectIdle:              "idle",
+	DisconnectBandwidthExceeded: "bandwidth exceeded",
+	DisconnectHandshakeTimeout:  "handshake timeout",
+	DisconnectSessionConflict:   "session conflict",
+	DisconnectSessionTakeover:   "session takeover",
+	DisconnectRejected:          "rejected",
+	DisconnectMigrated:          "migrated",
+}
+
+// String makes DisconnectReason to implement fmt.Stringer
+func (r DisconnectReason) String() string { return disconnectReasonNames[r] }
+
+// reasonFromCode maps the websocket close code to the DisconnectReason
+func reasonFromCode(code int) DisconnectReason {
+	switch code {
+	case websocket.CloseNormalClosure, websocket.CloseNoStatusReceived:
+		return DisconnectPeerClosed
+	case websocket.CloseGoingAway, websocket.CloseServiceRestart, websocket.CloseTryAgainLater:
+		return DisconnectGoingAway
+	case websocket.CloseProtocolError, websocket.CloseUnsupportedData, websocket.CloseInvalidFramePayloadData:
+		return DisconnectProtocolError
+	case websocket.ClosePolicyViolation:
+		return DisconnectPolicyViolation
+	case websocket.CloseMessageTooBig:
+		return DisconnectMessageTooBig
+	case websocket.CloseInternalServerErr:
+		return DisconnectInternalError
+	}
+	return DisconnectTransportError
+}
+
+// reasonFromError returns DisconnectReason, close code and text for the error err of reading from the connection
+func reasonFromError(err error) (DisconnectReason, int, string) {
+	if closeErr, ok := err.(*websocket.CloseError); ok {
+		return reasonFromCode(closeErr.Code), closeErr.Code, closeErr.Text
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return DisconnectTimeout, 0, ""
+	}
+	if transport.IsReceivedClose(err) {
+		return DisconnectPeerClosed, 0, ""
+	}
+	return DisconnectTransportError, 0, ""
+}
+
+// disconnected records why the Channel is being disconnected, only the first reason is kept
+func (c *Channel) disconnected(reason DisconnectReason, code int, text string) {
+	if !c.IsAlive() {
+		return
+	}
+
+	c.disconnectMu.Lock()
+	defer c.disconnectMu.Unlock()
+
+	if c.disconnectReason != DisconnectUnknown {
+		return
+	}
+	c.disconnectReason, c.closeCode, c.closeText = reason, code, text
+}
+
+// DisconnectReason returns why the Channel was disconnected, DisconnectUnknown while it's alive
+func (c *Channel) DisconnectReason() DisconnectReason {
+	c.disconnectMu.Lock()
+	defer c.disconnectMu.Unlock()
+	return c.disconnectReason
+}
+
+// CloseCode returns the websocket close code and text received from the peer or sent with CloseWith(),
+// zero code if there were none
+func (c *Channel) CloseCode() (int, string) {
+	c.disconnectMu.Lock()
+	defer c.disconnectMu.Unlock()
+	return c.closeCode, c.closeText
+}
+
+// CloseWith closes the Channel sending the websocket close frame with the given code and text,
+// polling connections are closed as with Close()
+func (c *Channel) CloseWith(code int, text string) error {
+	c.disconnected(DisconnectClosed, code, text)
+	if ws, ok := c.connection().(*transport.WebsocketConnection); ok {
+		ws.WriteClose(code, text)
+	}
+	return c.Close()
+}
@@ -0,0 +1,102 @@
+package gosocketio
+
+import (
+	"sync"
+
+	"github.com/mtfelian/synced"
+)
+
+var downgradedEmits synced.Counter
+
+// CountDowngradedEmits returns an amount of adaptive emits sent with the compact payload
+func CountDowngradedEmits() int { return downgradedEmits.Get() }
+
+// PayloadVariants produce the full and the compact payloads of the event from the application data,
+// see EmitAdaptive. Compact should return a smaller payload, e.g. the metadata sent less often or rounded
+type PayloadVariants struct {
+	Full    func(data interface{}) interface{}
+	Compact func(data interface{}) interface{}
+}
+
+// SetPayloadVariants registers the payload producers of the event with the given name,
+// nil producers of both variants unregister them
+func (e *event) SetPayloadVariants(name string, v PayloadVariants) {
+	e.handlersMu.Lock()
+	defer e.handlersMu.Unlock()
+	if v.Full == nil && v.Compact == nil {
+		delete(e.variants, name)
+		return
+	}
+	if e.variants == nil {
+		e.variants = make(map[string]PayloadVariants)
+	}
+	e.variants[name] = v
+}
+
+// payloadVariants returns the payload producers of the event with the given name
+func (e *event) payloadVariants(name string) (PayloadVariants, bool) {
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	v, ok := e.variants[name]
+	return v, ok
+}
+
+// Constrained returns whether the Channel is under backpressure, its outgoing queue is filled up to a half,
+// or its connection quality is poor, so the compact payloads are preferred for it
+func (c *Channel) Constrained() bool {
+	if len(c.outC)+len(c.outLowC) > queueBufferSize/2 {
+		return true
+	}
+	c.quality.mu.Lock()
+	stalled := c.quality.stalled
+	c.quality.mu.Unlock()
+	return stalled || c.Quality().Level == QualityPoor
+}
+
+// adaptivePayload produces the payloads of a single adaptive emit, each variant is produced once
+type adaptivePayload struct {
+	variants PayloadVariants
+	data     interface{}
+
+	full, compact         interface{}
+	fullOnce, compactOnce sync.Once
+}
+
+// payloadFor returns the payload variant for the channel c, the full one if there is no compact producer.
+// It returns the data as is without producers
+func (a *adaptivePayload) payloadFor(c *Channel) interface{} {
+	if a.variants.Compact != nil && (a.variants.Full == nil || c.Constrained()) {
+		downgradedEmits.Inc()
+		a.compactOnce.Do(func() { a.compact = a.variants.Compact(a.data) })
+		return a.compact
+	}
+	if a.variants.Full == nil {
+		return a.data
+	}
+	a.fullOnce.Do(func() { a.full = a.variants.Full(a.data) })
+	return a.full
+}
+
+// EmitAdaptive emits the event with the payload produced from data by the variants registered with
+// SetPayloadVariants, the compact one is picked if the Channel is constrained, see Constrained.
+// Without variants it acts like Emit
+func (c *Channel) EmitAdaptive(name string, data interface{}) error {
+	v, _ := c.events.payloadVariants(name)
+	a := &adaptivePayload{variants: v, data: data}
+	return c.Emit(name, a.payloadFor(c))
+}
+
+// BroadcastAdaptive acts like BroadcastTo picking the payload variant for each channel of the room,
+// see EmitAdaptive. Each variant is produced once
+func (s *Server) BroadcastAdaptive(room, name string, data interface{}) {
+	v, _ := s.event.payloadVariants(name)
+	a := &adaptivePayload{variants: v, data: data}
+
+	s.channelsMu.RLock()
+	defer s.channelsMu.RUnlock()
+	for cn := range s.channels[room] {
+		if cn.IsAlive() {
+			go func(cn *Channel) { cn.Emit(name, a.payloadFor(cn)) }(cn)
+		}
+	}
+}
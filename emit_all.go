@@ -0,0 +1,67 @@
+package gosocketio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// emitAllTimeout limits the time EmitAll waits for the message to be written to a single recipient
+const emitAllTimeout = 30 * time.Second
+
+// EmitAll sends an event with the given name and payload to the channels with the given sids.
+// The message is encoded once, and numbered, encrypted and signed for each recipient requiring it,
+// see SetReplayWindow, SetCipher and SetSigningKey. EmitAll blocks until it is written to every recipient or failed,
+// and returns the sids of the recipients the message was written to and of the failed ones
+func (s *Server) EmitAll(sids []string, name string, payload interface{}) (delivered []string, failed []string) {
+	m := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: name}
+	command, err := encode(m, payload)
+	if err != nil {
+		return nil, append(failed, sids...)
+	}
+
+	ok := make([]bool, len(sids))
+	var wg sync.WaitGroup
+	for i, sid := range sids {
+		c, err := s.GetChannel(sid)
+		if err != nil {
+			continue
+		}
+
+		p := &packet{message: command, done: make(chan error, 1)}
+		protected, err := c.protection()
+		if err != nil {
+			continue
+		}
+		if protected.enabled() {
+			if p.message, err = encodeWith(m, nil, protected); err != nil {
+				continue
+			}
+		}
+		if err := c.push(p, true); err != nil {
+			continue
+		}
+		s.event.sent(nil, name, len(m.Args))
+
+		wg.Add(1)
+		go func(i int, p *packet) {
+			defer wg.Done()
+			select {
+			case err := <-p.done:
+				ok[i] = err == nil
+			case <-s.event.after(emitAllTimeout):
+			}
+		}(i, p)
+	}
+	wg.Wait()
+
+	for i, sid := range sids {
+		if ok[i] {
+			delivered = append(delivered, sid)
+		} else {
+			failed = append(failed, sid)
+		}
+	}
+	return delivered, failed
+}
@@ -0,0 +1,78 @@
+package gosocketio
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+const (
+	// EmitAPIPath is a conventional path to mount the emit API handler at
+	EmitAPIPath = "/socket.io-api/emit"
+
+	defaultNamespace = "/"
+	bearerPrefix     = "Bearer "
+	maxEmitAPIBody   = 1 << 20
+)
+
+// EmitAPIRequest represents a body of the emit API request
+type EmitAPIRequest struct {
+	Room      string          `json:"room"`      // broadcast to all channels of the tenant if empty
+	Tenant    string          `json:"tenant"`    // the global tenant if empty, see SetTenantResolver
+	Namespace string          `json:"namespace"` // only the default namespace is supported
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// EmitAPIHandler returns an HTTP handler accepting POST requests with EmitAPIRequest JSON body
+// and broadcasting the event with the given payload. Requests should be authenticated
+// with "Authorization: Bearer <token>" header, where token is the given one
+func (s *Server) EmitAPIHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		presented := strings.TrimPrefix(r.Header.Get("Authorization"), bearerPrefix)
+		if token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			s.auditRequest(AuditAuthFailure, r, "emit API")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		var req EmitAPIRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEmitAPIBody)).Decode(&req); err != nil {
+			logging.Log().Debug("Server.EmitAPIHandler() can't decode request:", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if req.Event == "" {
+			http.Error(w, "event is required", http.StatusBadRequest)
+			return
+		}
+
+		if req.Namespace != "" && req.Namespace != defaultNamespace {
+			http.Error(w, "namespace is not supported", http.StatusBadRequest)
+			return
+		}
+
+		var payload interface{}
+		if len(req.Payload) > 0 {
+			payload = req.Payload
+		}
+
+		s.auditRequest(AuditAdmin, r, "emit API: event "+req.Event+" to room "+req.Room+" of tenant "+req.Tenant)
+		tenant := s.Tenant(req.Tenant)
+		if req.Room == "" {
+			tenant.BroadcastToAll(req.Event, payload)
+		} else {
+			tenant.BroadcastTo(req.Room, req.Event, payload)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
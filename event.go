@@ -1,18 +1,22 @@
 package gosocketio
 
 import (
-	"encoding/json"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/mtfelian/golang-socketio/logging"
 	"github.com/mtfelian/golang-socketio/protocol"
 )
 
 const (
-	OnConnection    = "connection"
-	OnDisconnection = "disconnection"
-	OnError         = "error"
+	OnConnection     = "connection"
+	OnDisconnection  = "disconnection"
+	OnError          = "error"
+	OnUpgrade        = "upgrade"
+	OnPong           = "pong"
+	OnIdleDisconnect = "idleDisconnect"
+	OnReconnect      = "reconnect"
 )
 
 // systemEventHandler function for internal handler processing
@@ -25,12 +29,59 @@ type event struct {
 
 	onConnection    systemEventHandler
 	onDisconnection systemEventHandler
+
+	namespaces map[string]*event // handlers of the namespace sockets sharing the connection, guarded by handlersMu
+
+	streamHandlers map[string]func(c *Channel, s *Stream) // guarded by handlersMu
+	variants       map[string]PayloadVariants             // guarded by handlersMu, see SetPayloadVariants
+
+	onDeliveryFailed func(c *Channel, name string, payload interface{})
+	onQualityChange  func(c *Channel, q Quality)
+	onProtocolError  func(c *Channel, message string, err error)
+	onWriteTimeout   func(c *Channel, err *WriteTimeoutError)
+
+	metrics       Metrics         // guarded by handlersMu, may be nil
+	limits        protocol.Limits // guarded by handlersMu
+	compatibility Compatibility   // guarded by handlersMu
+	historySize   int             // guarded by handlersMu
+	recorder      *Recorder       // guarded by handlersMu, may be nil
+	clock         Clock           // guarded by handlersMu, SystemClock if nil
+	clockSet      time.Time       // moment of the clock when it was set, guarded by handlersMu
+
+	onHandlerError RecoveryAction // guarded by handlersMu
+	onHandlerPanic RecoveryAction // guarded by handlersMu
+
+	bandwidthQuota      BandwidthQuota             // guarded by handlersMu
+	onBandwidthExceeded func(c *Channel, rate int) // guarded by handlersMu
+
+	dispatchPause  int // guarded by handlersMu, zero if the read flow control is disabled
+	dispatchResume int // guarded by handlersMu
+
+	cipherProvider   CipherProvider         // guarded by handlersMu, nil if the payloads aren't encrypted
+	rotatingProvider RotatingCipherProvider // guarded by handlersMu, nil if the keys aren't rotated
+	compression      compression            // guarded by handlersMu
+
+	signingKey         func(c *Channel) ([]byte, error) // guarded by handlersMu, nil if the messages aren't signed
+	onInvalidSignature func(c *Channel, name string)    // guarded by handlersMu
+
+	replayWindow int // guarded by handlersMu, zero if the replay protection is disabled
+
+	idempotencyCache IdempotencyCache // guarded by handlersMu, nil if the duplicates aren't suppressed
+	idempotencyTTL   time.Duration    // guarded by handlersMu
+
+	bus *Bus // guarded by handlersMu, nil until Server.Bus() is called
 }
 
 // init initializes events mapping
-func (e *event) init() { e.handlers = make(map[string]*handler) }
+func (e *event) init() {
+	e.handlers = make(map[string]*handler)
+	e.limits = protocol.DefaultLimits
+	e.onHandlerPanic = RecoveryLog
+	e.initStreams()
+}
 
-// On registers message processing function and binds it to the given event name
+// On registers message processing function and binds it to the given event name.
+// The argument of []byte, json.RawMessage or io.Reader type gets the payload JSON without decoding
 func (e *event) On(name string, f interface{}) error {
 	c, err := newHandler(f)
 	if err != nil {
@@ -73,9 +124,73 @@ func (e *event) callHandler(c *Channel, name string) {
 	f.call(c, &struct{}{})
 }
 
+// callHandlerWithPayload for the given channel c and event name passing the string payload.
+// Handler receives the payload if it has a string argument
+func (e *event) callHandlerWithPayload(c *Channel, name, payload string) {
+	f, ok := e.findHandler(name)
+	if !ok {
+		return
+	}
+
+	switch {
+	case !f.hasArgs:
+		f.call(c, &struct{}{})
+	case f.args.Kind() == reflect.String:
+		f.call(c, &payload)
+	default:
+		f.call(c, nil)
+	}
+}
+
 // processIncoming checks incoming message m on channel c
 func (e *event) processIncoming(c *Channel, m *protocol.Message) {
 	logging.Log().Debug("event.processIncoming() fired with:", m)
+	if !c.verify(e, m) || !c.open(m) || !c.unnumber(m) || !c.decompress(m) || !c.reorder(e, m) {
+		return
+	}
+	e.deliver(c, m)
+}
+
+// deliver the incoming message m with the payload restored to the handlers
+func (e *event) deliver(c *Channel, m *protocol.Message) {
+	e.getBus().publishIncoming(c, m)
+	if m.Namespace != "" {
+		e.processNamespace(c, m)
+		return
+	}
+	e.process(c, m)
+}
+
+// processNamespace passes the incoming message m of the non-root namespace to the namespace handlers,
+// the server Channel answers the connect packet, see Server.Of()
+func (e *event) processNamespace(c *Channel, m *protocol.Message) {
+	e.handlersMu.RLock()
+	ns, ok := e.namespaces[m.Namespace]
+	e.handlersMu.RUnlock()
+
+	if !ok {
+		logging.Log().Debug("event.processNamespace(): unknown namespace:", m.Namespace)
+		return
+	}
+
+	switch m.Type {
+	case protocol.MessageTypeEmpty:
+		if c.server != nil && !c.enterNamespace(m.Namespace) {
+			return
+		}
+		ns.callHandler(c, OnConnection)
+	case protocol.MessageTypeClose:
+		if c.server != nil && !c.leaveNamespace(m.Namespace) {
+			return
+		}
+		ns.callHandler(c, OnDisconnection)
+	default:
+		ns.process(c, m)
+	}
+}
+
+// process the incoming message m on channel c with the handlers of e
+func (e *event) process(c *Channel, m *protocol.Message) {
 	switch m.Type {
 	case protocol.MessageTypeEmit:
 		logging.Log().Debug("event.processIncoming() is finding handler for msg.Event:", m.EventName)
@@ -86,54 +201,82 @@ func (e *event) processIncoming(c *Channel, m *protocol.Message) {
 		}
 
 		logging.Log().Debug("event.processIncoming() found handler:", f)
+		if c.IsQuarantined(m.EventName) || !f.authorize(c, m.EventName) {
+			return
+		}
+		finish, ok := e.idempotent(c, m)
+		if !ok {
+			return
+		}
+		done := e.observe(c, m.EventName, m.Args)
 
 		if !f.hasArgs {
-			f.call(c, &struct{}{})
+			_, err := f.safeCall(c, &struct{}{})
+			done(err)
+			finish("", !panicked(err))
+			e.recover(c, m.EventName, err)
 			return
 		}
 
-		data := f.arguments()
-		logging.Log().Debug("event.processIncoming(), f.arguments() returned:", data)
-
-		if err := json.Unmarshal([]byte(m.Args), &data); err != nil {
+		data, err := f.decode(m.Args)
+		if err != nil {
 			logging.Log().Infof("event.processIncoming() failed to json.Unmaeshal(). msg.Args: %s, data: %v, err: %v",
 				m.Args, data, err)
+			done(err)
+			finish("", false)
 			return
 		}
 
-		f.call(c, data)
+		_, err = f.safeCall(c, data)
+		done(err)
+		finish("", !panicked(err))
+		e.recover(c, m.EventName, err)
 
 	case protocol.MessageTypeAckRequest:
 		logging.Log().Debug("event.processIncoming() ack request")
 		f, ok := e.findHandler(m.EventName)
-		if !ok || !f.out {
+		if !ok || !f.out || c.IsQuarantined(m.EventName) || !f.authorize(c, m.EventName) {
+			return
+		}
+		finish, ok := e.idempotent(c, m)
+		if !ok {
 			return
 		}
 
+		done := e.observe(c, m.EventName, m.Args)
 		var result []reflect.Value
+		var err error
 		if f.hasArgs {
-			// data type should be defined for Unmarshal()
-			data := f.arguments()
-			if err := json.Unmarshal([]byte(m.Args), &data); err != nil {
+			data, decodeErr := f.decode(m.Args)
+			if decodeErr != nil {
+				done(decodeErr)
+				finish("", false)
 				return
 			}
-			result = f.call(c, data)
+			result, err = f.safeCall(c, data)
 		} else {
-			result = f.call(c, &struct{}{})
+			result, err = f.safeCall(c, &struct{}{})
+		}
+		done(err)
+		e.recover(c, m.EventName, err)
+		if panicked(err) {
+			finish("", false)
+			return
 		}
 
 		ackResponse := &protocol.Message{
-			Type:  protocol.MessageTypeAckResponse,
-			AckID: m.AckID,
+			Type:      protocol.MessageTypeAckResponse,
+			AckID:     m.AckID,
+			Namespace: m.Namespace,
 		}
 
 		c.send(ackResponse, result[0].Interface())
+		finish(ackResponse.Args, true)
 
 	case protocol.MessageTypeAckResponse:
 		logging.Log().Debug("event.processIncoming() ack response")
-		ackC, err := c.ack.obtain(m.AckID)
-		if err == nil {
-			ackC <- m.Args
+		if err := c.ack.deliver(m.AckID, m.Args); err != nil {
+			logging.Log().Debug("event.processIncoming() ack response:", err)
 		}
 	}
 }
@@ -0,0 +1,108 @@
+package gosocketio
+
+import (
+	"sync"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+var readPauses synced.Counter
+
+// CountReadPauses returns an amount of times the channels stopped reading because of slow handlers
+func CountReadPauses() int { return readPauses.Get() }
+
+// dispatchQueue counts the incoming messages being processed by the Channel handlers
+type dispatchQueue struct {
+	length int
+	paused bool // true while the reading is stopped until the queue drains
+	cond   *sync.Cond
+	mu     sync.Mutex
+}
+
+// SetReadFlowControl makes the channels to stop reading from the connection when pause incoming messages
+// are being processed by the handlers, applying the transport backpressure to the peer,
+// and to resume reading when the queue drains to resume messages. Zero pause disables flow control,
+// it's disabled by default. Handlers waiting for the acks from the same peer may hold reading paused
+// until the ack timeout, keep pause above the amount of such handlers running at once
+func (e *event) SetReadFlowControl(pause, resume int) {
+	if resume >= pause {
+		resume = pause - 1
+	}
+	if resume < 0 {
+		resume = 0
+	}
+	e.handlersMu.Lock()
+	e.dispatchPause, e.dispatchResume = pause, resume
+	e.handlersMu.Unlock()
+}
+
+// readFlowControl returns the dispatch queue thresholds to pause and resume reading, e may be nil
+func (e *event) readFlowControl() (int, int) {
+	if e == nil {
+		return 0, 0
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.dispatchPause, e.dispatchResume
+}
+
+// Dispatching returns an amount of the incoming messages being processed by the Channel handlers
+func (c *Channel) Dispatching() int {
+	c.dispatch.mu.Lock()
+	defer c.dispatch.mu.Unlock()
+	return c.dispatch.length
+}
+
+// IsReadPaused returns true if the Channel stopped reading until the handlers process the queued messages
+func (c *Channel) IsReadPaused() bool {
+	c.dispatch.mu.Lock()
+	defer c.dispatch.mu.Unlock()
+	return c.dispatch.paused
+}
+
+// dispatchIncoming processes the incoming message m with the handlers of e in a separate goroutine,
+// it blocks while the dispatch queue is above the flow control thresholds
+func (c *Channel) dispatchIncoming(e *event, m *protocol.Message) {
+	pause, resume := c.events.readFlowControl()
+
+	c.dispatch.mu.Lock()
+	if c.dispatch.cond == nil {
+		c.dispatch.cond = sync.NewCond(&c.dispatch.mu)
+	}
+	if pause > 0 && c.dispatch.length >= pause {
+		logging.Log().Debugf("Channel.dispatchIncoming() channel %s paused reading, %d messages queued",
+			c.Id(), c.dispatch.length)
+		readPauses.Inc()
+		c.dispatch.paused = true
+		for c.dispatch.length > resume && c.ctx.Err() == nil {
+			c.dispatch.cond.Wait()
+		}
+		c.dispatch.paused = false
+	}
+	c.dispatch.length++
+	c.dispatch.mu.Unlock()
+
+	go func() {
+		defer c.dispatched()
+		e.processIncoming(c, m)
+	}()
+}
+
+// dispatched removes the processed message from the dispatch queue
+func (c *Channel) dispatched() {
+	c.dispatch.mu.Lock()
+	c.dispatch.length--
+	c.dispatch.cond.Broadcast()
+	c.dispatch.mu.Unlock()
+}
+
+// wakeDispatch resumes reading paused by the flow control, it's called when the Channel is closed
+func (c *Channel) wakeDispatch() {
+	c.dispatch.mu.Lock()
+	if c.dispatch.cond != nil {
+		c.dispatch.cond.Broadcast()
+	}
+	c.dispatch.mu.Unlock()
+}
@@ -0,0 +1,113 @@
+// Package grpcbridge exposes the socket.io server to the backend services over gRPC, see socketio.proto.
+// It's a separate module keeping the gRPC dependencies away from the core package
+package grpcbridge
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative socketio.proto
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mtfelian/golang-socketio"
+	"github.com/mtfelian/golang-socketio/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Service is the SocketServer gRPC service driving the socket.io server. The requests aren't authenticated,
+// protect the gRPC server with the transport credentials or an interceptor
+type Service struct {
+	UnimplementedSocketServerServer
+	server *gosocketio.Server
+}
+
+// NewService returns the service driving the server
+func NewService(server *gosocketio.Server) *Service { return &Service{server: server} }
+
+// Register the service driving the server on the gRPC server
+func Register(g *grpc.Server, server *gosocketio.Server) {
+	RegisterSocketServerServer(g, NewService(server))
+}
+
+// payloadOf returns the event payload of the JSON data, nil if it's empty
+func payloadOf(event string, data []byte) (interface{}, error) {
+	if event == "" {
+		return nil, status.Error(codes.InvalidArgument, "event is required")
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(data) {
+		return nil, status.Error(codes.InvalidArgument, "payload is not JSON")
+	}
+	return json.RawMessage(data), nil
+}
+
+// channel returns the channel with the given sid
+func (s *Service) channel(sid string) (*gosocketio.Channel, error) {
+	c, err := s.server.GetChannel(sid)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return c, nil
+}
+
+// Emit an event to the channel with the given sid
+func (s *Service) Emit(ctx context.Context, req *EmitRequest) (*EmitResponse, error) {
+	payload, err := payloadOf(req.Event, req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.channel(req.Sid)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Emit(req.Event, payload); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &EmitResponse{}, nil
+}
+
+// BroadcastToRoom emits an event to the channels of the tenant joined to the room
+func (s *Service) BroadcastToRoom(ctx context.Context, req *BroadcastToRoomRequest) (*BroadcastToRoomResponse, error) {
+	payload, err := payloadOf(req.Event, req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	tenant := s.server.Tenant(req.Tenant)
+	if req.Room == "" {
+		tenant.BroadcastToAll(req.Event, payload)
+	} else {
+		tenant.BroadcastTo(req.Room, req.Event, payload)
+	}
+	return &BroadcastToRoomResponse{}, nil
+}
+
+// FetchSockets returns the channels of the tenant joined to the room
+func (s *Service) FetchSockets(ctx context.Context, req *FetchSocketsRequest) (*FetchSocketsResponse, error) {
+	tenant := s.server.Tenant(req.Tenant)
+	channels := tenant.Channels()
+	if req.Room != "" {
+		channels = tenant.List(req.Room)
+	}
+
+	resp := &FetchSocketsResponse{Sockets: make([]*Socket, 0, len(channels))}
+	for _, c := range channels {
+		resp.Sockets = append(resp.Sockets, &Socket{Sid: c.Id(), Tenant: c.Tenant(), Ip: c.IP(), Rooms: c.Rooms()})
+	}
+	return resp, nil
+}
+
+// DisconnectSocket closes the channel with the given sid
+func (s *Service) DisconnectSocket(ctx context.Context, req *DisconnectSocketRequest) (*DisconnectSocketResponse, error) {
+	c, err := s.channel(req.Sid)
+	if err != nil {
+		return nil, err
+	}
+	logging.Log().Debug("grpcbridge.Service.DisconnectSocket() closes channel:", req.Sid)
+	if err := c.Close(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &DisconnectSocketResponse{}, nil
+}
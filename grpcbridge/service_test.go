@@ -0,0 +1,136 @@
+package grpcbridge
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio"
+	"github.com/mtfelian/golang-socketio/transport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialService returns the client of the service driving s over the in-memory connection
+func dialService(t *testing.T, s *gosocketio.Server) (SocketServerClient, func()) {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	Register(g, s)
+	go g.Serve(l)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }))
+	if err != nil {
+		g.Stop()
+		t.Fatal(err)
+	}
+	return NewSocketServerClient(conn), func() {
+		conn.Close()
+		g.Stop()
+	}
+}
+
+// receive waits for the value from c
+func receive(t *testing.T, c <-chan string, what string) string {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for", what)
+		return ""
+	}
+}
+
+func TestService(t *testing.T) {
+	s := gosocketio.NewServer()
+	connected := make(chan string, 1)
+	s.On(gosocketio.OnConnection, func(c *gosocketio.Channel) {
+		c.Join("lobby")
+		connected <- c.Id()
+	})
+	disconnected := make(chan string, 1)
+	s.On(gosocketio.OnDisconnection, func(c *gosocketio.Channel) { disconnected <- c.Id() })
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	host, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := gosocketio.Dial(gosocketio.AddrWebsocket(host, p, false), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	received := make(chan string, 2)
+	if err := c.On("news", func(c *gosocketio.Channel, m string) { received <- m }); err != nil {
+		t.Fatal(err)
+	}
+	sid := receive(t, connected, "connection")
+
+	client, stop := dialService(t, s)
+	defer stop()
+	ctx := context.Background()
+
+	fetched, err := client.FetchSockets(ctx, &FetchSocketsRequest{Room: "lobby"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched.Sockets) != 1 || fetched.Sockets[0].Sid != sid || len(fetched.Sockets[0].Rooms) != 1 ||
+		fetched.Sockets[0].Rooms[0] != "lobby" {
+		t.Fatal("fetched sockets:", fetched.Sockets)
+	}
+	if fetched, err := client.FetchSockets(ctx, &FetchSocketsRequest{Room: "empty"}); err != nil ||
+		len(fetched.Sockets) != 0 {
+		t.Fatal("fetched sockets of the empty room:", fetched, err)
+	}
+
+	if _, err := client.BroadcastToRoom(ctx, &BroadcastToRoomRequest{Room: "lobby", Event: "news",
+		Payload: []byte(`"broadcast"`)}); err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, received, "broadcast"); m != "broadcast" {
+		t.Fatal("broadcast:", m)
+	}
+	if _, err := client.Emit(ctx, &EmitRequest{Sid: sid, Event: "news", Payload: []byte(`"emitted"`)}); err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, received, "emit"); m != "emitted" {
+		t.Fatal("emit:", m)
+	}
+
+	for _, invalid := range []struct {
+		req  *EmitRequest
+		code codes.Code
+	}{
+		{req: &EmitRequest{Sid: sid, Payload: []byte(`"no event"`)}, code: codes.InvalidArgument},
+		{req: &EmitRequest{Sid: sid, Event: "news", Payload: []byte(`not JSON`)}, code: codes.InvalidArgument},
+		{req: &EmitRequest{Sid: "unknown", Event: "news"}, code: codes.NotFound},
+	} {
+		if _, err := client.Emit(ctx, invalid.req); status.Code(err) != invalid.code {
+			t.Fatalf("emit %v: %v, expected %v", invalid.req, err, invalid.code)
+		}
+	}
+
+	if _, err := client.DisconnectSocket(ctx, &DisconnectSocketRequest{Sid: sid}); err != nil {
+		t.Fatal(err)
+	}
+	if disconnectedSid := receive(t, disconnected, "disconnection"); disconnectedSid != sid {
+		t.Fatal("disconnected:", disconnectedSid)
+	}
+	if _, err := client.DisconnectSocket(ctx, &DisconnectSocketRequest{Sid: sid}); status.Code(err) != codes.NotFound {
+		t.Fatal("disconnect of the gone socket:", err)
+	}
+}
@@ -0,0 +1,228 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: socketio.proto
+
+package grpcbridge
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SocketServer_Emit_FullMethodName             = "/socketio.bridge.SocketServer/Emit"
+	SocketServer_BroadcastToRoom_FullMethodName  = "/socketio.bridge.SocketServer/BroadcastToRoom"
+	SocketServer_FetchSockets_FullMethodName     = "/socketio.bridge.SocketServer/FetchSockets"
+	SocketServer_DisconnectSocket_FullMethodName = "/socketio.bridge.SocketServer/DisconnectSocket"
+)
+
+// SocketServerClient is the client API for SocketServer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SocketServerClient interface {
+	// Emit an event to the channel with the given sid
+	Emit(ctx context.Context, in *EmitRequest, opts ...grpc.CallOption) (*EmitResponse, error)
+	// BroadcastToRoom emits an event to the channels of the tenant joined to the room
+	BroadcastToRoom(ctx context.Context, in *BroadcastToRoomRequest, opts ...grpc.CallOption) (*BroadcastToRoomResponse, error)
+	// FetchSockets returns the channels of the tenant joined to the room
+	FetchSockets(ctx context.Context, in *FetchSocketsRequest, opts ...grpc.CallOption) (*FetchSocketsResponse, error)
+	// DisconnectSocket closes the channel with the given sid
+	DisconnectSocket(ctx context.Context, in *DisconnectSocketRequest, opts ...grpc.CallOption) (*DisconnectSocketResponse, error)
+}
+
+type socketServerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSocketServerClient(cc grpc.ClientConnInterface) SocketServerClient {
+	return &socketServerClient{cc}
+}
+
+func (c *socketServerClient) Emit(ctx context.Context, in *EmitRequest, opts ...grpc.CallOption) (*EmitResponse, error) {
+	out := new(EmitResponse)
+	err := c.cc.Invoke(ctx, SocketServer_Emit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socketServerClient) BroadcastToRoom(ctx context.Context, in *BroadcastToRoomRequest, opts ...grpc.CallOption) (*BroadcastToRoomResponse, error) {
+	out := new(BroadcastToRoomResponse)
+	err := c.cc.Invoke(ctx, SocketServer_BroadcastToRoom_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socketServerClient) FetchSockets(ctx context.Context, in *FetchSocketsRequest, opts ...grpc.CallOption) (*FetchSocketsResponse, error) {
+	out := new(FetchSocketsResponse)
+	err := c.cc.Invoke(ctx, SocketServer_FetchSockets_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socketServerClient) DisconnectSocket(ctx context.Context, in *DisconnectSocketRequest, opts ...grpc.CallOption) (*DisconnectSocketResponse, error) {
+	out := new(DisconnectSocketResponse)
+	err := c.cc.Invoke(ctx, SocketServer_DisconnectSocket_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SocketServerServer is the server API for SocketServer service.
+// All implementations must embed UnimplementedSocketServerServer
+// for forward compatibility
+type SocketServerServer interface {
+	// Emit an event to the channel with the given sid
+	Emit(context.Context, *EmitRequest) (*EmitResponse, error)
+	// BroadcastToRoom emits an event to the channels of the tenant joined to the room
+	BroadcastToRoom(context.Context, *BroadcastToRoomRequest) (*BroadcastToRoomResponse, error)
+	// FetchSockets returns the channels of the tenant joined to the room
+	FetchSockets(context.Context, *FetchSocketsRequest) (*FetchSocketsResponse, error)
+	// DisconnectSocket closes the channel with the given sid
+	DisconnectSocket(context.Context, *DisconnectSocketRequest) (*DisconnectSocketResponse, error)
+	mustEmbedUnimplementedSocketServerServer()
+}
+
+// UnimplementedSocketServerServer must be embedded to have forward compatible implementations.
+type UnimplementedSocketServerServer struct {
+}
+
+func (UnimplementedSocketServerServer) Emit(context.Context, *EmitRequest) (*EmitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Emit not implemented")
+}
+func (UnimplementedSocketServerServer) BroadcastToRoom(context.Context, *BroadcastToRoomRequest) (*BroadcastToRoomResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BroadcastToRoom not implemented")
+}
+func (UnimplementedSocketServerServer) FetchSockets(context.Context, *FetchSocketsRequest) (*FetchSocketsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchSockets not implemented")
+}
+func (UnimplementedSocketServerServer) DisconnectSocket(context.Context, *DisconnectSocketRequest) (*DisconnectSocketResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisconnectSocket not implemented")
+}
+func (UnimplementedSocketServerServer) mustEmbedUnimplementedSocketServerServer() {}
+
+// UnsafeSocketServerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SocketServerServer will
+// result in compilation errors.
+type UnsafeSocketServerServer interface {
+	mustEmbedUnimplementedSocketServerServer()
+}
+
+func RegisterSocketServerServer(s grpc.ServiceRegistrar, srv SocketServerServer) {
+	s.RegisterService(&SocketServer_ServiceDesc, srv)
+}
+
+func _SocketServer_Emit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocketServerServer).Emit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SocketServer_Emit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocketServerServer).Emit(ctx, req.(*EmitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocketServer_BroadcastToRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BroadcastToRoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocketServerServer).BroadcastToRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SocketServer_BroadcastToRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocketServerServer).BroadcastToRoom(ctx, req.(*BroadcastToRoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocketServer_FetchSockets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchSocketsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocketServerServer).FetchSockets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SocketServer_FetchSockets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocketServerServer).FetchSockets(ctx, req.(*FetchSocketsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocketServer_DisconnectSocket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisconnectSocketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocketServerServer).DisconnectSocket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SocketServer_DisconnectSocket_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocketServerServer).DisconnectSocket(ctx, req.(*DisconnectSocketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SocketServer_ServiceDesc is the grpc.ServiceDesc for SocketServer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SocketServer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "socketio.bridge.SocketServer",
+	HandlerType: (*SocketServerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Emit",
+			Handler:    _SocketServer_Emit_Handler,
+		},
+		{
+			MethodName: "BroadcastToRoom",
+			Handler:    _SocketServer_BroadcastToRoom_Handler,
+		},
+		{
+			MethodName: "FetchSockets",
+			Handler:    _SocketServer_FetchSockets_Handler,
+		},
+		{
+			MethodName: "DisconnectSocket",
+			Handler:    _SocketServer_DisconnectSocket_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "socketio.proto",
+}
@@ -1,8 +1,11 @@
 package gosocketio
 
 import (
+	"encoding/json"
 	"errors"
+	"io"
 	"reflect"
+	"strings"
 )
 
 // handler is an event handler representation
@@ -11,8 +14,24 @@ type handler struct {
 	args     reflect.Type
 	hasArgs  bool
 	out      bool
+
+	// fast calls the handler of the common signature without reflection, nil for other signatures
+	fast func(c *Channel, arguments interface{}) []reflect.Value
+	// newArgs allocates the argument for decoding, it avoids reflection for the common types
+	newArgs func() interface{}
+
+	middlewares []Middleware // called before the function, see OnWith
+
+	rpc       bool         // true for the OnCall handlers
+	rpcParams reflect.Type // request params type of the OnCall handler, nil if there are none
+	rpcResult reflect.Type // result type of the OnCall handler
 }
 
+var (
+	bytesType  = reflect.TypeOf([]byte(nil))
+	readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
 var (
 	ErrorHandlerIsNotFunc   = errors.New("f is not a function")
 	ErrorHandlerHasNot2Args = errors.New("f should have 1 or 2 arguments")
@@ -47,18 +66,96 @@ func newHandler(f interface{}) (*handler, error) {
 		return nil, ErrorHandlerHasNot2Args
 	}
 
+	curCaller.fast = fastCall(f)
+	curCaller.newArgs = argumentsAllocator(curCaller.args)
 	return curCaller, nil
 }
 
-// arguments returns function parameter as it is present in it using reflection
-func (h *handler) arguments() interface{} { return reflect.New(h.args).Interface() }
+// noResults is returned by the fast calls of the functions without results
+var noResults = []reflect.Value{}
+
+// fastCall returns the caller of the handler f of the common signature without reflection,
+// nil if f has another signature
+func fastCall(f interface{}) func(c *Channel, arguments interface{}) []reflect.Value {
+	switch fn := f.(type) {
+	case func(c *Channel):
+		return func(c *Channel, _ interface{}) []reflect.Value { fn(c); return noResults }
+	case func(c *Channel, s string):
+		return func(c *Channel, a interface{}) []reflect.Value { fn(c, *a.(*string)); return noResults }
+	case func(c *Channel, v interface{}):
+		return func(c *Channel, a interface{}) []reflect.Value { fn(c, *a.(*interface{})); return noResults }
+	case func(c *Channel, m map[string]interface{}):
+		return func(c *Channel, a interface{}) []reflect.Value {
+			fn(c, *a.(*map[string]interface{}))
+			return noResults
+		}
+	case func(c *Channel, raw json.RawMessage):
+		return func(c *Channel, a interface{}) []reflect.Value { fn(c, *a.(*json.RawMessage)); return noResults }
+	case func(c *Channel, b []byte):
+		return func(c *Channel, a interface{}) []reflect.Value { fn(c, *a.(*[]byte)); return noResults }
+	case func(c *Channel, r io.Reader):
+		return func(c *Channel, a interface{}) []reflect.Value { fn(c, *a.(*io.Reader)); return noResults }
+	}
+	return nil
+}
+
+// argumentsAllocator returns the allocator of the handler argument of type t, nil if the handler has no argument
+func argumentsAllocator(t reflect.Type) func() interface{} {
+	if t == nil {
+		return nil
+	}
+
+	switch t {
+	case reflect.TypeOf(""):
+		return func() interface{} { return new(string) }
+	case reflect.TypeOf((*interface{})(nil)).Elem():
+		return func() interface{} { return new(interface{}) }
+	case reflect.TypeOf(map[string]interface{}{}):
+		return func() interface{} { return new(map[string]interface{}) }
+	case rawMessageType:
+		return func() interface{} { return new(json.RawMessage) }
+	case bytesType:
+		return func() interface{} { return new([]byte) }
+	case readerType:
+		return func() interface{} { r := io.Reader(strings.NewReader("")); return &r }
+	}
+	return func() interface{} { return reflect.New(t).Interface() }
+}
+
+// arguments returns a pointer to the new function parameter
+func (h *handler) arguments() interface{} { return h.newArgs() }
+
+// isRaw returns true if the handler argument of type t is passed the payload JSON without decoding
+func isRaw(t reflect.Type) bool { return t == bytesType || t == rawMessageType || t == readerType }
+
+// decode the JSON encoded args into the new function parameter, the raw types get args as is
+func (h *handler) decode(args string) (interface{}, error) {
+	switch h.args {
+	case bytesType:
+		b := []byte(args)
+		return &b, nil
+	case rawMessageType:
+		raw := json.RawMessage(args)
+		return &raw, nil
+	case readerType:
+		r := io.Reader(strings.NewReader(args))
+		return &r, nil
+	}
+
+	data := h.newArgs()
+	err := json.Unmarshal([]byte(args), data)
+	return data, err
+}
 
 // call func with given arguments from its representation using reflection
 func (h *handler) call(c *Channel, arguments interface{}) []reflect.Value {
 	// nil is untyped, so use the default empty value of correct type
-	if arguments == nil {
+	if arguments == nil && h.hasArgs {
 		arguments = h.arguments()
 	}
+	if h.fast != nil {
+		return h.fast(c, arguments)
+	}
 
 	a := []reflect.Value{reflect.ValueOf(c), reflect.ValueOf(arguments).Elem()}
 	if !h.hasArgs {
@@ -0,0 +1,99 @@
+package gosocketio
+
+import (
+	"reflect"
+	"testing"
+)
+
+// reflectHandler returns the handler of f dispatched with reflection only, as the handlers of uncommon signatures
+func reflectHandler(tb testing.TB, f interface{}) *handler {
+	tb.Helper()
+	h, err := newHandler(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	h.fast = nil
+	if h.hasArgs {
+		h.newArgs = func() interface{} { return reflect.New(h.args).Interface() }
+	}
+	return h
+}
+
+// fastHandler returns the handler of f, which should have the common signature dispatched without reflection
+func fastHandler(tb testing.TB, f interface{}) *handler {
+	tb.Helper()
+	h, err := newHandler(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if h.fast == nil {
+		tb.Fatalf("%T has no fast call", f)
+	}
+	return h
+}
+
+func TestFastCallMatchesReflect(t *testing.T) {
+	var got []interface{}
+	handlers := []interface{}{
+		func(c *Channel) { got = append(got, nil) },
+		func(c *Channel, s string) { got = append(got, s) },
+		func(c *Channel, m map[string]interface{}) { got = append(got, m["k"]) },
+		func(c *Channel, v interface{}) { got = append(got, v) },
+	}
+	args := []string{"", `"s"`, `{"k":"v"}`, `[1]`}
+
+	for i, f := range handlers {
+		got = nil
+		for _, h := range []*handler{fastHandler(t, f), reflectHandler(t, f)} {
+			var data interface{} = &struct{}{}
+			if h.hasArgs {
+				var err error
+				if data, err = h.decode(args[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h.call(&Channel{}, data)
+		}
+		if len(got) != 2 || !reflect.DeepEqual(got[0], got[1]) {
+			t.Errorf("%T: fast and reflect calls got %v", f, got)
+		}
+	}
+}
+
+// benchmarkDispatch decodes args and calls the handler h as the incoming event does
+func benchmarkDispatch(b *testing.B, h *handler, args string) {
+	c := &Channel{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var data interface{} = &struct{}{}
+		if h.hasArgs {
+			var err error
+			if data, err = h.decode(args); err != nil {
+				b.Fatal(err)
+			}
+		}
+		h.call(c, data)
+	}
+}
+
+func BenchmarkCallDispatch(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		f    interface{}
+		args string
+	}{
+		{"string", func(c *Channel, s string) {}, `"payload"`},
+		{"map", func(c *Channel, m map[string]interface{}) {}, `{"key":"value","n":1}`},
+		{"interface", func(c *Channel, v interface{}) {}, `[1,"two",{"three":3}]`},
+	} {
+		b.Run(bc.name+"/fast", func(b *testing.B) { benchmarkDispatch(b, fastHandler(b, bc.f), bc.args) })
+		b.Run(bc.name+"/reflect", func(b *testing.B) { benchmarkDispatch(b, reflectHandler(b, bc.f), bc.args) })
+	}
+}
+
+func BenchmarkCallDispatchNoArgs(b *testing.B) {
+	f := func(c *Channel) {}
+	b.Run("fast", func(b *testing.B) { benchmarkDispatch(b, fastHandler(b, f), "") })
+	b.Run("reflect", func(b *testing.B) { benchmarkDispatch(b, reflectHandler(b, f), "") })
+}
@@ -0,0 +1,82 @@
+package gosocketio
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+)
+
+// InheritedListenerEnv is an environment variable set for the successor process started by StartSuccessor
+const InheritedListenerEnv = "GOSOCKETIO_INHERITED_LISTENER"
+
+// inheritedListenerFd is a descriptor of the listener passed to the successor process, after stdin, stdout and stderr
+const inheritedListenerFd = 3
+
+var (
+	ErrorNotTCPListener        = errors.New("listener is not a TCP listener")
+	ErrorReusePortNotSupported = errors.New("SO_REUSEPORT is not supported on this platform")
+)
+
+// InheritListener returns the listener inherited from the predecessor process if it's started by StartSuccessor,
+// otherwise it listens on the TCP network address addr
+func InheritListener(addr string) (net.Listener, error) {
+	if os.Getenv(InheritedListenerEnv) == "" {
+		return net.Listen("tcp", addr)
+	}
+
+	f := os.NewFile(inheritedListenerFd, "inherited listener")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// StartSuccessor starts a new instance of the current executable with the same arguments passing the listener l
+// to it. The successor gets it with InheritListener and starts accepting, after that the current process
+// should stop accepting and call Server.DrainAll() to let clients reconnect to the successor
+func StartSuccessor(l net.Listener) (*os.Process, error) {
+	tl, ok := l.(*net.TCPListener)
+	if !ok {
+		return nil, ErrorNotTCPListener
+	}
+
+	f, err := tl.File()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := os.StartProcess(executable, os.Args, &os.ProcAttr{
+		Env:   append(os.Environ(), InheritedListenerEnv+"=1"),
+		Files: []*os.File{os.Stdin, os.Stdout, os.Stderr, f},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// passing the descriptor switches the shared socket into blocking mode,
+	// restore it to keep the listener l closable
+	if err := setNonblock(tl); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// DrainAll drains all the server channels, see Channel.Drain(), and calls the OnShutdown hooks of the plugins
+func (s *Server) DrainAll() {
+	s.audit(AuditAdmin, nil, "", "drain all channels")
+	var wg sync.WaitGroup
+	for _, c := range s.channelsList() {
+		wg.Add(1)
+		go func(c *Channel) {
+			defer wg.Done()
+			c.Drain()
+		}(c)
+	}
+	wg.Wait()
+	s.pluginsShutdown()
+}
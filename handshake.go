@@ -0,0 +1,87 @@
+package gosocketio
+
+import (
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/synced"
+)
+
+var handshakeTimeouts synced.Counter
+
+// CountHandshakeTimeouts returns an amount of connections closed because they didn't complete the handshake in time
+func CountHandshakeTimeouts() int { return handshakeTimeouts.Get() }
+
+// SetHandshakeTimeout sets the time allowed between accepting the connection and the first valid packet from it,
+// and between the websocket upgrade request and the probe packet. Connections which don't complete
+// the handshake in time are closed. Clients may send nothing before the first ping, so the timeout
+// should be longer than the ping interval. Zero disables the deadline, it's disabled by default.
+// Slow HTTP requests should be limited by the http.Server ReadHeaderTimeout
+func (s *Server) SetHandshakeTimeout(timeout time.Duration) {
+	s.handshakeMu.Lock()
+	s.handshakeTimeout = timeout
+	s.handshakeMu.Unlock()
+}
+
+// handshakeDeadline returns the handshake timeout
+func (s *Server) handshakeDeadline() time.Duration {
+	s.handshakeMu.RLock()
+	defer s.handshakeMu.RUnlock()
+	return s.handshakeTimeout
+}
+
+// handshaken marks the Channel as received the first valid packet
+func (c *Channel) handshaken() {
+	c.activityMu.Lock()
+	c.handshakeDone = true
+	c.activityMu.Unlock()
+}
+
+// isHandshaken returns true if the Channel received a valid packet
+func (c *Channel) isHandshaken() bool {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	return c.handshakeDone
+}
+
+// watchHandshake closes the Channel if it doesn't receive a valid packet within the handshake timeout
+func (s *Server) watchHandshake(c *Channel) {
+	timeout := s.handshakeDeadline()
+	if timeout <= 0 {
+		return
+	}
+
+	go func() {
+		select {
+		case <-s.event.after(timeout):
+		case <-c.Done():
+			return
+		}
+		if c.isHandshaken() {
+			return
+		}
+
+		logging.Log().Debug("Server.watchHandshake() closes channel not completed the handshake:", c.Id())
+		handshakeTimeouts.Inc()
+		c.disconnected(DisconnectHandshakeTimeout, 0, "")
+		c.Close()
+	}()
+}
+
+// awaitProbe waits for the probe packet on the upgraded Channel c within the handshake timeout,
+// it returns false if the timeout expired
+func (s *Server) awaitProbe(c *Channel) bool {
+	timeout := s.handshakeDeadline()
+	if timeout <= 0 {
+		<-c.upgradedC
+		return true
+	}
+
+	select {
+	case <-c.upgradedC:
+		return true
+	case <-s.event.after(timeout):
+		handshakeTimeouts.Inc()
+		return false
+	}
+}
@@ -0,0 +1,79 @@
+package gosocketio
+
+import (
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// HeartbeatTimeoutNegotiated makes SetHeartbeatTimeout to use the ping interval plus timeout of each channel
+const HeartbeatTimeoutNegotiated time.Duration = -1
+
+// minHeartbeatCheckInterval limits the frequency of the heartbeat checks
+const minHeartbeatCheckInterval = 100 * time.Millisecond
+
+// SetHeartbeatTimeout makes server to disconnect channels which haven't sent anything, including heartbeats,
+// for the given duration, HeartbeatTimeoutNegotiated uses the ping params of each channel. Zero duration
+// disables the check. Unlike the transport ReceiveTimeout which limits every single read, it judges
+// the connection liveness by heartbeats, so the per-read deadline may be disabled by zero ReceiveTimeout
+// of the websocket and tcp transports letting the long gaps between application messages
+func (s *Server) SetHeartbeatTimeout(timeout time.Duration) {
+	s.heartbeatMu.Lock()
+	defer s.heartbeatMu.Unlock()
+
+	s.heartbeatTimeout = timeout
+	if timeout != 0 && !s.heartbeatWatching {
+		s.heartbeatWatching = true
+		go s.heartbeatLoop()
+	}
+}
+
+// heartbeatCheck returns the current heartbeat timeout and the interval between checks
+func (s *Server) heartbeatCheck() (time.Duration, time.Duration) {
+	s.heartbeatMu.Lock()
+	defer s.heartbeatMu.Unlock()
+
+	if s.heartbeatTimeout == 0 {
+		s.heartbeatWatching = false
+		return 0, 0
+	}
+
+	interval := s.heartbeatTimeout / 4
+	if s.heartbeatTimeout == HeartbeatTimeoutNegotiated {
+		interval = time.Second
+	}
+	if interval < minHeartbeatCheckInterval {
+		interval = minHeartbeatCheckInterval
+	}
+	return s.heartbeatTimeout, interval
+}
+
+// heartbeatTimeoutOf returns the heartbeat timeout of c for the server timeout
+func (c *Channel) heartbeatTimeoutOf(timeout time.Duration) time.Duration {
+	if timeout != HeartbeatTimeoutNegotiated {
+		return timeout
+	}
+	interval, pingTimeout := c.PingParams()
+	return interval + pingTimeout
+}
+
+// heartbeatLoop periodically disconnects silent channels until the heartbeat timeout is disabled
+func (s *Server) heartbeatLoop() {
+	for {
+		timeout, interval := s.heartbeatCheck()
+		if timeout == 0 {
+			return
+		}
+
+		for _, c := range s.channelsList() {
+			if c.SilentFor() < c.heartbeatTimeoutOf(timeout) {
+				continue
+			}
+			logging.Log().Debug("Server.heartbeatLoop() disconnects silent channel:", c.Id())
+			c.disconnected(DisconnectTimeout, 0, "")
+			c.Close()
+		}
+
+		s.event.sleep(interval)
+	}
+}
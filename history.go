@@ -0,0 +1,145 @@
+package gosocketio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// Direction of the recorded packet
+type Direction int
+
+const (
+	DirectionInbound Direction = iota
+	DirectionOutbound
+)
+
+// String returns a direction arrow as it's printed in the history dump
+func (d Direction) String() string {
+	if d == DirectionInbound {
+		return "<-"
+	}
+	return "->"
+}
+
+// HistoryEntry represents an encoded packet received or sent by the channel
+type HistoryEntry struct {
+	Time      time.Time
+	Direction Direction
+	Packet    string
+}
+
+// String returns the entry as a history dump line
+func (h HistoryEntry) String() string {
+	return h.Time.Format("15:04:05.000000") + " " + h.Direction.String() + " " + h.Packet
+}
+
+// history is a ring buffer of the last packets of the channel
+type history struct {
+	entries []HistoryEntry
+	next    int  // index of the entry to overwrite
+	full    bool // true if the buffer wrapped around
+	mu      sync.Mutex
+}
+
+// add the packet to the history of the given size, it's resized if the size was changed
+func (h *history) add(size int, at time.Time, d Direction, packet string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.entries) != size {
+		h.entries, h.next, h.full = h.list(size), 0, false
+		if len(h.entries) == size {
+			h.full = true
+		} else {
+			h.next = len(h.entries)
+			h.entries = append(h.entries, make([]HistoryEntry, size-len(h.entries))...)
+		}
+	}
+
+	h.entries[h.next] = HistoryEntry{Time: at, Direction: d, Packet: packet}
+	h.next++
+	if h.next == size {
+		h.next, h.full = 0, true
+	}
+}
+
+// list returns up to the last n entries from the oldest to the newest, h.mu should be locked
+func (h *history) list(n int) []HistoryEntry {
+	var entries []HistoryEntry
+	if h.full {
+		entries = append(entries, h.entries[h.next:]...)
+	}
+	entries = append(entries, h.entries[:h.next]...)
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries
+}
+
+// SetHistorySize makes the channels to record the last size packets received or sent, they are returned
+// by Channel.History() and dumped into the log when the channel is closed due to the protocol error.
+// Zero size disables recording, it's disabled by default
+func (e *event) SetHistorySize(size int) {
+	e.handlersMu.Lock()
+	e.historySize = size
+	e.handlersMu.Unlock()
+}
+
+// historySizeValue returns the size of channels history, e may be nil
+func (e *event) historySizeValue() int {
+	if e == nil {
+		return 0
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.historySize
+}
+
+// record the packet into the channel history and the capture if they're enabled
+func (c *Channel) record(d Direction, packet string) {
+	r, size := c.events.getRecorder(), c.events.historySizeValue()
+	if r == nil && size <= 0 {
+		return
+	}
+	at := c.events.now()
+	if r != nil {
+		r.write(c, at, d, packet)
+	}
+	if size > 0 {
+		c.history.add(size, at, d, packet)
+	}
+}
+
+// History returns the recorded packets from the oldest to the newest, see SetHistorySize()
+func (c *Channel) History() []HistoryEntry {
+	c.history.mu.Lock()
+	defer c.history.mu.Unlock()
+	return c.history.list(len(c.history.entries))
+}
+
+// DumpHistory writes the recorded packets into w, one per line
+func (c *Channel) DumpHistory(w io.Writer) error {
+	for _, entry := range c.History() {
+		if _, err := fmt.Fprintln(w, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logHistory dumps the recorded packets into the log on error
+func (c *Channel) logHistory(err error) {
+	entries := c.History()
+	if len(entries) == 0 {
+		return
+	}
+
+	logging.Log().Warnf("Channel %s failed with err: %v, last %d packets:", c.Id(), err, len(entries))
+	for _, entry := range entries {
+		logging.Log().Warn(entry)
+	}
+}
@@ -0,0 +1,185 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+// DefaultIdempotencyTTL is a time the processed idempotency keys are remembered by default
+const DefaultIdempotencyTTL = 10 * time.Minute
+
+// idempotencyPrefix starts the payload of the message carrying the idempotency key
+const idempotencyPrefix = `{"sio:idem":`
+
+var suppressedDuplicates synced.Counter
+
+// CountSuppressedDuplicates returns an amount of incoming messages not passed to the handlers
+// because their idempotency key was already processed or is being processed
+func CountSuppressedDuplicates() int { return suppressedDuplicates.Get() }
+
+// IdempotencyCache remembers the processed idempotency keys and their ack responses, e.g. MemoryIdempotencyCache.
+// Redis backed one reserves the key with SET NX
+type IdempotencyCache interface {
+	// Reserve the key for processing, reserved is false if it's already reserved,
+	// processed is true and ack is the ack response payload if it's processed
+	Reserve(key string, ttl time.Duration) (reserved, processed bool, ack string, err error)
+	// Complete the key processing with the ack response payload, empty if there are none
+	Complete(key, ack string, ttl time.Duration) error
+	// Release the key reserved by the failed processing, so the retry is processed again
+	Release(key string) error
+}
+
+// idempotentPayload is the payload of the message carrying the idempotency key
+type idempotentPayload struct {
+	Key  string      `json:"sio:idem"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// idempotentArgs are the decoded arguments of the message carrying the idempotency key
+type idempotentArgs struct {
+	Key  string          `json:"sio:idem"`
+	Data json.RawMessage `json:"data"`
+}
+
+// idempotencyEntry is the key remembered by MemoryIdempotencyCache
+type idempotencyEntry struct {
+	processed bool
+	ack       string
+	expires   time.Time
+}
+
+// MemoryIdempotencyCache is the IdempotencyCache of a single server, expired keys are swept on reservation
+type MemoryIdempotencyCache struct {
+	entries map[string]idempotencyEntry
+	swept   time.Time
+	mu      sync.Mutex
+}
+
+// NewMemoryIdempotencyCache returns a new empty MemoryIdempotencyCache
+func NewMemoryIdempotencyCache() *MemoryIdempotencyCache {
+	return &MemoryIdempotencyCache{entries: make(map[string]idempotencyEntry)}
+}
+
+// Reserve the key for processing
+func (m *MemoryIdempotencyCache) Reserve(key string, ttl time.Duration) (bool, bool, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.swept) > ttl {
+		for k, entry := range m.entries {
+			if now.After(entry.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.swept = now
+	}
+
+	if entry, ok := m.entries[key]; ok && !now.After(entry.expires) {
+		return false, entry.processed, entry.ack, nil
+	}
+	m.entries[key] = idempotencyEntry{expires: now.Add(ttl)}
+	return true, false, "", nil
+}
+
+// Complete the key processing with the ack response payload
+func (m *MemoryIdempotencyCache) Complete(key, ack string, ttl time.Duration) error {
+	m.mu.Lock()
+	m.entries[key] = idempotencyEntry{processed: true, ack: ack, expires: time.Now().Add(ttl)}
+	m.mu.Unlock()
+	return nil
+}
+
+// Release the reserved key
+func (m *MemoryIdempotencyCache) Release(key string) error {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// SetIdempotency enables suppressing the duplicate events and ack requests emitted with the same idempotency key,
+// see Channel.EmitIdempotent, within ttl after processing. The duplicate ack request is answered with the cached
+// ack response, the duplicate of the message being processed is dropped. Keys are scoped by the tenant and
+// the event name. nil cache disables it
+func (e *event) SetIdempotency(cache IdempotencyCache, ttl time.Duration) {
+	e.handlersMu.Lock()
+	e.idempotencyCache, e.idempotencyTTL = cache, ttl
+	e.handlersMu.Unlock()
+}
+
+// getIdempotency returns the cache of the idempotency keys and their ttl
+func (e *event) getIdempotency() (IdempotencyCache, time.Duration) {
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.idempotencyCache, e.idempotencyTTL
+}
+
+// EmitIdempotent acts like Emit but the event carries the idempotency key, so the peer processes it once
+// for all the retries with the same key, see SetIdempotency. The payload is wrapped into
+// {"sio:idem": key, "data": payload} object, the peer without the idempotency enabled gets it as is
+func (c *Channel) EmitIdempotent(key, name string, payload interface{}) error {
+	return c.Emit(name, idempotentPayload{Key: key, Data: payload})
+}
+
+// AckIdempotent acts like Ack but the request carries the idempotency key, the retries with the same key
+// get the response cached by the peer, see EmitIdempotent
+func (c *Channel) AckIdempotent(key, name string, payload interface{}, timeout time.Duration) (string, error) {
+	return c.Ack(name, idempotentPayload{Key: key, Data: payload}, timeout)
+}
+
+// idempotent unwraps the idempotency key of the incoming message m and reserves it. It returns false
+// if m is the duplicate to suppress answering the duplicate ack request with the cached response, otherwise
+// finish must be called with the ack response payload and whether the message was processed
+func (e *event) idempotent(c *Channel, m *protocol.Message) (finish func(ack string, processed bool), proceed bool) {
+	noop := func(string, bool) {}
+	if !strings.HasPrefix(m.Args, idempotencyPrefix) {
+		return noop, true
+	}
+	cache, ttl := e.getIdempotency()
+	if cache == nil {
+		return noop, true
+	}
+
+	var args idempotentArgs
+	if err := json.Unmarshal([]byte(m.Args), &args); err != nil || args.Key == "" {
+		return noop, true
+	}
+	m.Args = string(args.Data)
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	key := scopedRoom(c.tenant, m.EventName+"\x00"+args.Key)
+	reserved, processed, ack, err := cache.Reserve(key, ttl)
+	if err != nil {
+		logging.Log().Warn("event.idempotent() can't reserve the key, processing anyway:", err)
+		return noop, true
+	}
+	if !reserved {
+		suppressedDuplicates.Inc()
+		logging.Log().Debugf("event.idempotent() suppressed duplicate %q with key %s", m.EventName, args.Key)
+		if processed && m.Type == protocol.MessageTypeAckRequest {
+			c.send(&protocol.Message{Type: protocol.MessageTypeAckResponse, AckID: m.AckID,
+				Namespace: m.Namespace, Args: ack}, nil)
+		}
+		return noop, false
+	}
+
+	return func(ack string, processed bool) {
+		if processed {
+			err = cache.Complete(key, ack, ttl)
+		} else {
+			err = cache.Release(key)
+		}
+		if err != nil {
+			logging.Log().Warn("event.idempotent() can't store the key:", err)
+		}
+	}, true
+}
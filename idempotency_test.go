@@ -0,0 +1,85 @@
+package gosocketio
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestIdempotencySuppressesDuplicates(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+	s := NewServer()
+	s.SetIdempotency(NewMemoryIdempotencyCache(), ttl)
+	var calls int32
+	s.On("inc", func(c *Channel, p string) string { return p + strconv.Itoa(int(atomic.AddInt32(&calls, 1))) })
+	emitted := make(chan string, 4)
+	s.On("note", func(c *Channel, p string) { emitted <- p })
+	host, port, stop := serve(t, s)
+	defer stop()
+	c, err := Dial(AddrWebsocket(host, port, false), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// ack returns the response to the ack request with the key
+	ack := func(key string) string {
+		response, err := c.AckIdempotent(key, "inc", "n", 5*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+	if first, retry := ack("1"), ack("1"); first != `"n1"` || retry != first {
+		t.Fatalf("responses to the request %s and its retry %s, expected the cached one", first, retry)
+	}
+	if another := ack("2"); another != `"n2"` {
+		t.Fatal("response to the request with another key:", another)
+	}
+	time.Sleep(ttl + 50*time.Millisecond)
+	if expired := ack("1"); expired != `"n3"` {
+		t.Fatal("response to the request with the expired key:", expired)
+	}
+
+	suppressed := CountSuppressedDuplicates()
+	for i := 0; i < 2; i++ {
+		if err := c.EmitIdempotent("3", "note", "once"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p := receive(t, emitted, "idempotent emit"); p != "once" {
+		t.Fatal("payload of the idempotent emit:", p)
+	}
+	for deadline := time.Now().Add(5 * time.Second); CountSuppressedDuplicates() == suppressed; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("duplicate emit isn't suppressed")
+		}
+	}
+	select {
+	case p := <-emitted:
+		t.Fatal("duplicate emit is delivered:", p)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMemoryIdempotencyCache(t *testing.T) {
+	m := NewMemoryIdempotencyCache()
+	if reserved, _, _, _ := m.Reserve("k", time.Minute); !reserved {
+		t.Fatal("new key isn't reserved")
+	}
+	if reserved, processed, _, _ := m.Reserve("k", time.Minute); reserved || processed {
+		t.Fatal("key being processed is reserved again or processed")
+	}
+
+	m.Release("k")
+	if reserved, _, _, _ := m.Reserve("k", time.Minute); !reserved {
+		t.Fatal("released key isn't reserved")
+	}
+	m.Complete("k", `"done"`, time.Minute)
+	if reserved, processed, ack, _ := m.Reserve("k", time.Minute); reserved || !processed || ack != `"done"` {
+		t.Fatalf("processed key: reserved %v, processed %v, ack %s", reserved, processed, ack)
+	}
+}
@@ -0,0 +1,91 @@
+package gosocketio
+
+import (
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// minIdleCheckInterval limits the frequency of idle channels checks
+const minIdleCheckInterval = time.Second
+
+// touch marks the channel as active at the current moment
+func (c *Channel) touch() {
+	c.activityMu.Lock()
+	c.lastActivity = c.events.now()
+	c.activityMu.Unlock()
+}
+
+// received marks the moment the channel received a message of any kind, including heartbeats
+func (c *Channel) received() {
+	c.activityMu.Lock()
+	c.lastReceived = c.events.now()
+	c.activityMu.Unlock()
+}
+
+// SilentFor returns the duration since the last message of any kind received on the channel
+func (c *Channel) SilentFor() time.Duration {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	return c.events.since(c.lastReceived)
+}
+
+// IdleFor returns the duration since the last application event received on the channel
+func (c *Channel) IdleFor() time.Duration {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	return c.events.since(c.lastActivity)
+}
+
+// SetIdleTimeout makes server to disconnect channels which haven't sent any application event
+// (heartbeats are not counted) for the given duration. OnIdleDisconnect handler fires before disconnecting.
+// Zero duration disables the check
+func (s *Server) SetIdleTimeout(timeout time.Duration) {
+	s.idleMu.Lock()
+	defer s.idleMu.Unlock()
+
+	s.idleTimeout = timeout
+	if timeout > 0 && !s.idleReaping {
+		s.idleReaping = true
+		go s.idleLoop()
+	}
+}
+
+// idleCheck returns the current idle timeout and the interval between checks
+func (s *Server) idleCheck() (time.Duration, time.Duration) {
+	s.idleMu.Lock()
+	defer s.idleMu.Unlock()
+
+	if s.idleTimeout <= 0 {
+		s.idleReaping = false
+		return 0, 0
+	}
+
+	interval := s.idleTimeout / 2
+	if interval < minIdleCheckInterval {
+		interval = minIdleCheckInterval
+	}
+	return s.idleTimeout, interval
+}
+
+// idleLoop periodically disconnects idle channels until the idle timeout is disabled
+func (s *Server) idleLoop() {
+	for {
+		timeout, interval := s.idleCheck()
+		if timeout == 0 {
+			return
+		}
+
+		for _, c := range s.channelsList() {
+			if c.IdleFor() < timeout {
+				continue
+			}
+			logging.Log().Debug("Server.idleLoop() disconnects idle channel:", c.Id())
+			s.callHandler(c, OnIdleDisconnect)
+			c.disconnected(DisconnectIdle, 0, "")
+			c.Close()
+		}
+
+		s.event.sleep(interval)
+	}
+}
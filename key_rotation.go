@@ -0,0 +1,161 @@
+package gosocketio
+
+import (
+	"errors"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// KeyRotationEvent is a control event announcing the next epoch of the payload encryption key
+const KeyRotationEvent = "sio:keys"
+
+var ErrorKeyRotationDisabled = errors.New("key rotation is disabled")
+
+// KeyRotation is a payload of the KeyRotationEvent
+type KeyRotation struct {
+	Epoch int `json:"epoch"` // epoch of the key used from now on
+}
+
+// RotatingCipherProvider returns the cipher of channel c for the given key epoch, it's called with zero epoch
+// at handshake on the server side and before the first encrypted message on the client side, and with the next
+// epoch on rotation. Both sides must derive the same key for the epoch, e.g. with SessionKey from the secret
+// and the sid followed by the epoch
+type RotatingCipherProvider func(c *Channel, epoch int) (Cipher, error)
+
+// SetCipherRotation sets the provider of the channel ciphers like SetCipher and enables the key rotation,
+// see Channel.RotateKey. nil disables the encryption of new channels
+func (e *event) SetCipherRotation(f RotatingCipherProvider) {
+	e.handlersMu.Lock()
+	defer e.handlersMu.Unlock()
+	e.rotatingProvider = f
+	if f == nil {
+		e.cipherProvider = nil
+		return
+	}
+	e.cipherProvider = func(c *Channel) (Cipher, error) { return f(c, 0) }
+}
+
+// getRotatingProvider returns the provider of the channel ciphers for the key epochs, e may be nil
+func (e *event) getRotatingProvider() RotatingCipherProvider {
+	if e == nil {
+		return nil
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.rotatingProvider
+}
+
+// KeyEpoch returns the epoch of the key encrypting the payloads of the Channel
+func (c *Channel) KeyEpoch() int {
+	c.cipherMu.Lock()
+	defer c.cipherMu.Unlock()
+	return c.keyEpoch
+}
+
+// RotateKey switches the Channel to the key of the next epoch without reconnecting. The peer is notified with
+// the KeyRotationEvent encrypted with the current key and switches too, the Go client does it natively.
+// Until the next rotation the payloads encrypted with the previous key are still accepted, and the ones
+// encrypted with the next key are accepted switching to it, so the messages in flight aren't dropped.
+// It fails with ErrorKeyRotationDisabled unless the cipher is set with SetCipherRotation
+func (c *Channel) RotateKey() error {
+	f := c.events.getRotatingProvider()
+	if f == nil {
+		return ErrorKeyRotationDisabled
+	}
+	cph, err := c.payloadCipher()
+	if err != nil {
+		return err
+	}
+	if cph == nil {
+		return ErrorKeyRotationDisabled
+	}
+
+	epoch := c.KeyEpoch() + 1
+	m := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: KeyRotationEvent}
+	if err := c.sendWith(m, KeyRotation{Epoch: epoch}, &packet{priority: PriorityHigh}, true); err != nil {
+		return err
+	}
+	return c.advanceKey(epoch)
+}
+
+// advanceKey switches the Channel to the key of the given epoch if it's the next one, the current key
+// becomes the previous one
+func (c *Channel) advanceKey(epoch int) error {
+	next, err := c.nextCipher(epoch)
+	if err != nil || next == nil {
+		return err
+	}
+
+	c.cipherMu.Lock()
+	defer c.cipherMu.Unlock()
+	if c.keyEpoch+1 != epoch {
+		return nil // already switched by the message encrypted with the next key
+	}
+	c.previousCipher, c.cipher, c.nextKey = c.cipher, next, nil
+	c.keyEpoch = epoch
+	logging.Log().Debugf("Channel.advanceKey() channel %s switched to key epoch %d", c.Id(), epoch)
+	return nil
+}
+
+// nextCipher returns the cipher of the given epoch if it's the next one, it's created once.
+// It returns nil if the key rotation is disabled
+func (c *Channel) nextCipher(epoch int) (Cipher, error) {
+	f := c.events.getRotatingProvider()
+	if f == nil {
+		return nil, nil
+	}
+
+	c.cipherMu.Lock()
+	defer c.cipherMu.Unlock()
+	if c.keyEpoch+1 != epoch {
+		return nil, nil
+	}
+	if c.nextKey == nil {
+		next, err := f(c, epoch)
+		if err != nil {
+			return nil, err
+		}
+		c.nextKey = next
+	}
+	return c.nextKey, nil
+}
+
+// openRotated returns the payload decrypted from the JSON string args by the current cipher cph, by the previous
+// one or by the next one switching to it
+func (c *Channel) openRotated(cph Cipher, args string) (string, error) {
+	plaintext, err := openArgs(cph, args)
+	if err == nil || err == ErrorNotSealed {
+		return plaintext, err
+	}
+
+	c.cipherMu.Lock()
+	previous, epoch := c.previousCipher, c.keyEpoch
+	c.cipherMu.Unlock()
+	if previous != nil {
+		if plaintext, prevErr := openArgs(previous, args); prevErr == nil {
+			return plaintext, nil
+		}
+	}
+
+	next, nextErr := c.nextCipher(epoch + 1)
+	if nextErr != nil || next == nil {
+		return "", err
+	}
+	plaintext, nextErr = openArgs(next, args)
+	if nextErr != nil {
+		return "", err
+	}
+	c.advanceKey(epoch + 1) // can't fail, the next cipher is created
+	return plaintext, nil
+}
+
+// RotateKeys rotates the payload encryption keys of all the server channels, see Channel.RotateKey
+func (s *Server) RotateKeys() {
+	s.audit(AuditAdmin, nil, "", "rotate keys")
+	for _, c := range s.channelsList() {
+		if err := c.RotateKey(); err != nil {
+			logging.Log().Warnf("Server.RotateKeys() can't rotate the key of %s: %v", c.Id(), err)
+		}
+	}
+}
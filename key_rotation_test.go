@@ -0,0 +1,85 @@
+package gosocketio
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// rotatingChannel returns the Channel with the stub connection encrypting the payloads with the rotating keys
+// derived from the secret
+func rotatingChannel(t *testing.T, secret string) *Channel {
+	t.Helper()
+	e := &event{}
+	e.init()
+	e.SetCipherRotation(func(c *Channel, epoch int) (Cipher, error) {
+		return NewAESGCM(SessionKey([]byte(secret), "sid "+strconv.Itoa(epoch)))
+	})
+	c := &Channel{conn: stubConnection{}, events: e}
+	c.init()
+	return c
+}
+
+// sealedBy returns the message with the payload encrypted by the current key of c
+func sealedBy(t *testing.T, c *Channel, payload string) *protocol.Message {
+	t.Helper()
+	cph, err := c.payloadCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := seal(cph, &protocol.Message{Type: protocol.MessageTypeEmit, EventName: "m", Args: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// opens checks whether c decrypts the message m to the payload
+func opens(c *Channel, m *protocol.Message, payload string) bool {
+	opened := *m
+	return c.open(&opened) && opened.Args == payload
+}
+
+func TestKeyRotation(t *testing.T) {
+	server, client := rotatingChannel(t, "secret"), rotatingChannel(t, "secret")
+	inFlight := sealedBy(t, client, `"epoch 0"`)
+
+	if err := server.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	if server.KeyEpoch() != 1 {
+		t.Fatal("key epoch after the rotation:", server.KeyEpoch())
+	}
+	notice := <-server.outHighC
+	decoded, err := protocol.Decode(notice.message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.EventName != KeyRotationEvent || !opens(client, decoded, `{"epoch":1}`) {
+		t.Fatalf("rotation notice isn't encrypted with the current key: %+v", decoded)
+	}
+	if !opens(server, inFlight, `"epoch 0"`) {
+		t.Fatal("message in flight encrypted with the previous key is dropped")
+	}
+
+	if err := client.advanceKey(1); err != nil {
+		t.Fatal(err)
+	}
+	if !opens(server, sealedBy(t, client, `"epoch 1"`), `"epoch 1"`) {
+		t.Fatal("message encrypted with the current key is dropped")
+	}
+
+	if err := client.RotateKey(); err != nil { // the client rotates before the server knows
+		t.Fatal(err)
+	}
+	if !opens(server, sealedBy(t, client, `"epoch 2"`), `"epoch 2"`) {
+		t.Fatal("message encrypted with the next key is dropped")
+	}
+	if server.KeyEpoch() != 2 {
+		t.Fatal("server doesn't switch to the next key:", server.KeyEpoch())
+	}
+	if opens(server, inFlight, `"epoch 0"`) {
+		t.Fatal("message encrypted with the key before the previous one is accepted")
+	}
+}
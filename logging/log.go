@@ -11,6 +11,9 @@ var log *logrus.Logger
 // Log returns the logger object
 func Log() *logrus.Logger { return log }
 
+// SetLog replaces the logger object, it should be called before serving or connecting
+func SetLog(l *logrus.Logger) { log = l }
+
 // initLogger mainly for debug purposes
 func initLogger() {
 	logLevel, err := logrus.ParseLevel(os.Getenv("SIO_LL"))
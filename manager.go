@@ -0,0 +1,160 @@
+package gosocketio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// Manager shares a single client connection between several namespace sockets.
+// Reconnection and heartbeats are handled once for the connection, see Client.SetReconnection()
+type Manager struct {
+	*Client
+
+	sockets map[string]*Socket
+	up      bool // false after the connection was lost until it's restored
+	mu      sync.Mutex
+}
+
+// Socket is a client of a single namespace, multiplexed over the Manager connection.
+// Handlers registered with On() receive the Manager connection Channel
+type Socket struct {
+	*event
+	manager   *Manager
+	namespace string // empty for the root namespace
+}
+
+// NewManager connects to the server at addr with transport tr, see Dial()
+func NewManager(addr string, tr transport.Transport) (*Manager, error) {
+	c, err := Dial(addr, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{Client: c, sockets: make(map[string]*Socket), up: true}
+	c.reconnMu.Lock()
+	c.onLost, c.onReconnected = m.down, m.reconnected
+	c.reconnMu.Unlock()
+	c.event.onDisconnection = func(*Channel) { m.down() }
+	return m, nil
+}
+
+// down fires OnDisconnection for all the namespace sockets once the connection is lost or closed
+func (m *Manager) down() {
+	m.mu.Lock()
+	up := m.up
+	m.up = false
+	sockets := m.socketsList()
+	m.mu.Unlock()
+
+	if !up {
+		return
+	}
+	for _, s := range sockets {
+		s.event.callHandler(m.Channel, OnDisconnection)
+	}
+}
+
+// reconnected connects all the namespace sockets over the new connection,
+// connect packets are written before the messages held while reconnecting
+func (m *Manager) reconnected() {
+	m.mu.Lock()
+	m.up = true
+	sockets := m.socketsList()
+	m.mu.Unlock()
+
+	for _, s := range sockets {
+		command, err := protocol.Encode(&protocol.Message{Type: protocol.MessageTypeEmpty, Namespace: s.namespace})
+		if err == nil {
+			err = m.write(command)
+		}
+		if err != nil {
+			logging.Log().Debug("Manager.reconnected() can't connect namespace:", s.namespace, err)
+		}
+	}
+}
+
+// socketsList returns the namespace sockets, m.mu should be locked
+func (m *Manager) socketsList() []*Socket {
+	sockets := make([]*Socket, 0, len(m.sockets))
+	for _, s := range m.sockets {
+		sockets = append(sockets, s)
+	}
+	return sockets
+}
+
+// Socket returns the socket of the given namespace, Connect() it after registering the handlers.
+// The root namespace socket ("/" or "") shares handlers with the Manager client and is always connected
+func (m *Manager) Socket(namespace string) *Socket {
+	if namespace == "" || namespace == defaultNamespace {
+		return &Socket{event: m.Client.event, manager: m}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sockets[namespace]; ok {
+		return s
+	}
+
+	s := &Socket{event: &event{}, manager: m, namespace: namespace}
+	s.event.init()
+	m.sockets[namespace] = s
+
+	m.Client.event.handlersMu.Lock()
+	if m.Client.event.namespaces == nil {
+		m.Client.event.namespaces = make(map[string]*event)
+	}
+	m.Client.event.namespaces[namespace] = s.event
+	m.Client.event.handlersMu.Unlock()
+
+	return s
+}
+
+// Namespace returns the socket namespace, empty for the root one
+func (s *Socket) Namespace() string { return s.namespace }
+
+// Connect sends the namespace connect packet, the server answers with the same one firing OnConnection
+func (s *Socket) Connect() error {
+	if s.namespace == "" {
+		return nil
+	}
+	return s.manager.send(&protocol.Message{Type: protocol.MessageTypeEmpty, Namespace: s.namespace}, nil)
+}
+
+// Emit an asynchronous event with the given name and payload to the socket namespace
+func (s *Socket) Emit(name string, payload interface{}) error {
+	return s.manager.send(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: name, Namespace: s.namespace},
+		payload)
+}
+
+// Ack a synchronous event with the given name and payload to the socket namespace and wait for the response
+func (s *Socket) Ack(name string, payload interface{}, timeout time.Duration) (string, error) {
+	m := &protocol.Message{Type: protocol.MessageTypeAckRequest, EventName: name, Namespace: s.namespace}
+	return s.manager.ackWith(m, payload, timeout)
+}
+
+// Close disconnects the socket from it's namespace keeping the Manager connection and fires OnDisconnection,
+// closing the root namespace socket closes the connection
+func (s *Socket) Close() error {
+	if s.namespace == "" {
+		s.manager.Close()
+		return nil
+	}
+
+	m := s.manager
+	m.mu.Lock()
+	delete(m.sockets, s.namespace)
+	m.mu.Unlock()
+
+	m.Client.event.handlersMu.Lock()
+	delete(m.Client.event.namespaces, s.namespace)
+	m.Client.event.handlersMu.Unlock()
+
+	err := m.send(&protocol.Message{Type: protocol.MessageTypeClose, Namespace: s.namespace}, nil)
+	s.event.callHandler(m.Channel, OnDisconnection)
+	return err
+}
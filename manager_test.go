@@ -0,0 +1,74 @@
+package gosocketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// receive returns the value from c or fails the test after the timeout
+func receive(t *testing.T, c <-chan string, what string) string {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for", what)
+		return ""
+	}
+}
+
+func TestManagerNamespace(t *testing.T) {
+	s := NewServer()
+	chat := s.Of("/chat")
+	serverEvents := make(chan string, 4)
+	chat.On(OnConnection, func(c *Channel) {
+		serverEvents <- "connected"
+		chat.Emit(c, "welcome", "hello")
+	})
+	chat.On(OnDisconnection, func(c *Channel) { serverEvents <- "disconnected" })
+	chat.On("say", func(c *Channel, m string) string { return "chat:" + m })
+	s.On("say", func(c *Channel, m string) string { return "root:" + m })
+	host, port, stop := serve(t, s)
+	defer stop()
+
+	m, err := NewManager(AddrWebsocket(host, port, false), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	socket := m.Socket("/chat")
+	clientEvents := make(chan string, 4)
+	socket.On(OnConnection, func(c *Channel) { clientEvents <- "connected" })
+	socket.On("welcome", func(c *Channel, m string) { clientEvents <- m })
+	if err := socket.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if e := receive(t, serverEvents, "server namespace connection"); e != "connected" {
+		t.Fatal("server namespace event:", e)
+	}
+	welcomed := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		welcomed[receive(t, clientEvents, "client namespace events")] = true
+	}
+	if !welcomed["connected"] || !welcomed["hello"] {
+		t.Fatal("client namespace events:", welcomed)
+	}
+
+	if r, err := socket.Ack("say", "hi", 5*time.Second); err != nil || r != `"chat:hi"` {
+		t.Fatalf("namespace ack: %q, %v", r, err)
+	}
+	if r, err := m.Socket("/").Ack("say", "hi", 5*time.Second); err != nil || r != `"root:hi"` {
+		t.Fatalf("root namespace ack: %q, %v", r, err)
+	}
+
+	if err := socket.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if e := receive(t, serverEvents, "server namespace disconnection"); e != "disconnected" {
+		t.Fatal("server namespace event:", e)
+	}
+}
@@ -0,0 +1,166 @@
+package gosocketio
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metrics receives per-event observations, it's methods are called concurrently
+type Metrics interface {
+	EventReceived(name string, payloadSize int)                 // incoming event with the given name arrived
+	HandlerDone(name string, duration time.Duration, err error) // handler completed, err is not nil if it failed
+	EventSent(name string, payloadSize int)                     // outgoing event with the given name queued
+}
+
+// SetMetrics sets metrics receiving observations of the events, nil disables them
+func (e *event) SetMetrics(m Metrics) {
+	e.handlersMu.Lock()
+	e.metrics = m
+	e.handlersMu.Unlock()
+}
+
+// getMetrics returns the metrics or nil
+func (e *event) getMetrics() Metrics {
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.metrics
+}
+
+// observe the incoming event of channel c, returned func should be called when the handler completes
+func (e *event) observe(c *Channel, name, args string) func(err error) {
+	m := e.metricsOf(c)
+	if m == nil {
+		return func(error) {}
+	}
+
+	m.EventReceived(name, len(args))
+	start := e.now()
+	return func(err error) { m.HandlerDone(name, e.since(start), err) }
+}
+
+// sent observes the outgoing event of channel c, it's nil for the events sent to many channels at once
+func (e *event) sent(c *Channel, name string, payloadSize int) {
+	if e == nil {
+		return
+	}
+	if m := e.metricsOf(c); m != nil {
+		m.EventSent(name, payloadSize)
+	}
+}
+
+// resultError returns the handler result error if it returned non-nil error
+func resultError(result []reflect.Value) error {
+	if len(result) == 0 {
+		return nil
+	}
+	err, _ := result[0].Interface().(error)
+	return err
+}
+
+// DefaultLatencyBuckets are upper bounds of the handler durations histogram used by NewEventMetrics
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second,
+}
+
+// EventStats are accumulated observations of a single event name
+type EventStats struct {
+	Received      int
+	ReceivedBytes int
+	Sent          int
+	SentBytes     int
+	Errors        int
+
+	// Durations counts handler durations not greater than the corresponding bucket of EventMetrics,
+	// the last element counts durations greater than all buckets
+	Durations []int
+}
+
+// ErrorRate returns a share of the failed handler calls
+func (s EventStats) ErrorRate() float64 {
+	calls := 0
+	for _, n := range s.Durations {
+		calls += n
+	}
+	if calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(calls)
+}
+
+// EventMetrics is an in-memory Metrics implementation
+type EventMetrics struct {
+	buckets []time.Duration
+	stats   map[string]*EventStats
+	mu      sync.Mutex
+}
+
+// NewEventMetrics returns new in-memory metrics with the given histogram buckets, or DefaultLatencyBuckets
+func NewEventMetrics(buckets ...time.Duration) *EventMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	return &EventMetrics{buckets: buckets, stats: make(map[string]*EventStats)}
+}
+
+// get returns stats of the given event name, m.mu should be locked
+func (m *EventMetrics) get(name string) *EventStats {
+	s, ok := m.stats[name]
+	if !ok {
+		s = &EventStats{Durations: make([]int, len(m.buckets)+1)}
+		m.stats[name] = s
+	}
+	return s
+}
+
+// EventReceived implements Metrics
+func (m *EventMetrics) EventReceived(name string, payloadSize int) {
+	m.mu.Lock()
+	s := m.get(name)
+	s.Received++
+	s.ReceivedBytes += payloadSize
+	m.mu.Unlock()
+}
+
+// HandlerDone implements Metrics
+func (m *EventMetrics) HandlerDone(name string, duration time.Duration, err error) {
+	i := sort.Search(len(m.buckets), func(i int) bool { return duration <= m.buckets[i] })
+
+	m.mu.Lock()
+	s := m.get(name)
+	s.Durations[i]++
+	if err != nil {
+		s.Errors++
+	}
+	m.mu.Unlock()
+}
+
+// EventSent implements Metrics
+func (m *EventMetrics) EventSent(name string, payloadSize int) {
+	m.mu.Lock()
+	s := m.get(name)
+	s.Sent++
+	s.SentBytes += payloadSize
+	m.mu.Unlock()
+}
+
+// Buckets returns upper bounds of the handler durations histogram
+func (m *EventMetrics) Buckets() []time.Duration { return append([]time.Duration(nil), m.buckets...) }
+
+// Stats returns a copy of the accumulated observations by event name
+func (m *EventMetrics) Stats() map[string]EventStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[string]EventStats, len(m.stats))
+	for name, s := range m.stats {
+		copied := *s
+		copied.Durations = append([]int(nil), s.Durations...)
+		stats[name] = copied
+	}
+	return stats
+}
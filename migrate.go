@@ -0,0 +1,153 @@
+package gosocketio
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+var ErrorResumptionDisabled = errors.New("session resumption is disabled")
+
+// migration holds the state of the Channel being migrated to another node
+type migration struct {
+	collecting bool     // true if outLoop collects the packets instead of writing them
+	pending    []string // collected packets
+	saved      bool     // true if the session is saved, so it isn't suspended again on disconnection
+	mu         sync.Mutex
+}
+
+// Migrate transfers the session of the Channel to the node serving targetURL, e.g. while draining the node
+// during the rolling deploy. The queued packets are taken out of the queue, the session with the rooms,
+// the store and the packets is saved to the session store shared by the nodes, see SetResumption, then
+// the client is asked to reconnect to targetURL, or to its current address if it's empty, with the resumption
+// token. The Channel is closed when the client leaves it or ctx is done. The node resuming the session writes
+// the packets after the open sequence and sends again the ack requests not answered, their responses reach only
+// the Ack calls of the same node. Session resumption must be enabled. The packets protected with the keys
+// of the sid may be rejected by the client
+func (c *Channel) Migrate(ctx context.Context, targetURL string) error {
+	if c.server == nil {
+		return ErrorServerNotSet
+	}
+	if c.server.getResumption() == nil || c.sessionID == "" {
+		return ErrorResumptionDisabled
+	}
+
+	c.migration.mu.Lock()
+	c.migration.collecting = true
+	c.migration.mu.Unlock()
+	c.aliveMu.Lock()
+	c.draining = true
+	c.aliveMu.Unlock()
+	c.wakeLowLane()
+
+	if err := c.Flush(ctx); err != nil { // the queued packets are collected up to the mark
+		return err
+	}
+
+	c.server.suspend(c)
+	c.migration.mu.Lock()
+	c.migration.saved = true
+	c.migration.mu.Unlock()
+
+	// outLoop doesn't write while collecting, so the request is written here
+	request, err := c.encode(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: ReconnectRequestEvent},
+		ReconnectRequest{URL: targetURL, Token: c.resumeToken()})
+	if err == nil {
+		err = c.write(request)
+	}
+	c.disconnected(DisconnectMigrated, 0, "")
+	if err != nil {
+		logging.Log().Warn("Channel.Migrate() can't ask the client to reconnect:", err)
+	} else {
+		select { // the client closes the connection once it's reconnected
+		case <-c.ctx.Done():
+		case <-ctx.Done():
+		}
+	}
+
+	if closeErr := c.close(c.events); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// collect the packet p instead of writing it if the Channel is being migrated, it returns whether it's collected.
+// Heartbeats aren't collected
+func (c *Channel) collect(p *packet) bool {
+	c.migration.mu.Lock()
+	defer c.migration.mu.Unlock()
+	if !c.migration.collecting {
+		return false
+	}
+
+	messages := p.batch
+	if messages == nil {
+		messages = []string{p.message}
+	}
+	for _, m := range messages {
+		if !strings.HasPrefix(m, protocol.MessagePing) && !strings.HasPrefix(m, protocol.MessagePong) {
+			c.migration.pending = append(c.migration.pending, m)
+		}
+	}
+	p.finish(nil)
+	return true
+}
+
+// migratedPending returns the packets collected while migrating
+func (c *Channel) migratedPending() []string {
+	c.migration.mu.Lock()
+	defer c.migration.mu.Unlock()
+	return append([]string(nil), c.migration.pending...)
+}
+
+// migrationSaved returns whether the session is already saved by Migrate
+func (c *Channel) migrationSaved() bool {
+	c.migration.mu.Lock()
+	defer c.migration.mu.Unlock()
+	return c.migration.saved
+}
+
+// writeResumed queues the packets transferred with the resumed session
+func (c *Channel) writeResumed(pending []string) {
+	for _, m := range pending {
+		if err := c.push(&packet{message: m}, false); err != nil {
+			logging.Log().Warn("Channel.writeResumed() drops the transferred packets:", err)
+			return
+		}
+	}
+}
+
+// MigrateAll migrates all the server channels to the node serving targetURL, see Channel.Migrate(),
+// and calls the OnShutdown hooks of the plugins
+func (s *Server) MigrateAll(ctx context.Context, targetURL string) {
+	s.audit(AuditAdmin, nil, "", "migrate all channels")
+	var wg sync.WaitGroup
+	for _, c := range s.channelsList() {
+		wg.Add(1)
+		go func(c *Channel) {
+			defer wg.Done()
+			if err := c.Migrate(ctx, targetURL); err != nil {
+				logging.Log().Warn("Server.MigrateAll() can't migrate the channel:", err)
+			}
+		}(c)
+	}
+	wg.Wait()
+	s.pluginsShutdown()
+}
+
+// withResumeToken returns addr with the resumption token query parameter replaced by token
+func withResumeToken(addr, token string) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return AddrResume(addr, token)
+	}
+	q := u.Query()
+	q.Set(tokenParam, token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,83 @@
+package gosocketio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestMigrate(t *testing.T) {
+	store := NewMemorySessionStore()
+	var (
+		connected [2]chan *Channel
+		addrs     [2]string
+	)
+	for i := range connected {
+		s := NewServer()
+		s.SetResumption([]byte("secret"), time.Minute, store)
+		connected[i] = make(chan *Channel, 1)
+		s.On(OnConnection, func(ch chan *Channel) func(c *Channel) {
+			return func(c *Channel) { ch <- c }
+		}(connected[i]))
+		host, port, stop := serve(t, s)
+		defer stop()
+		addrs[i] = AddrWebsocket(host, port, false)
+	}
+
+	c, err := Dial(addrs[0], transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	received := make(chan string, 4)
+	if err := c.On("news", func(c *Channel, m string) { received <- m }); err != nil {
+		t.Fatal(err)
+	}
+	source := <-connected[0]
+	if err := source.Join("lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Emit("news", "queued"); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		migrated <- source.Migrate(ctx, addrs[1])
+	}()
+	var target *Channel
+	select {
+	case target = <-connected[1]:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client isn't reconnected to the target node")
+	}
+	if err := <-migrated; err != nil {
+		t.Fatal("migrate:", err)
+	}
+	if source.IsAlive() {
+		t.Fatal("source channel isn't closed")
+	}
+	if rooms := target.Rooms(); len(rooms) != 1 || rooms[0] != "lobby" {
+		t.Fatal("rooms of the migrated session:", rooms)
+	}
+
+	if err := target.Emit("news", "migrated"); err != nil {
+		t.Fatal(err)
+	}
+	events := map[string]bool{} // the handlers are called concurrently
+	for i := 0; i < 2; i++ {
+		events[receive(t, received, "event")] = true
+	}
+	if !events["queued"] || !events["migrated"] {
+		t.Fatal("received:", events)
+	}
+	select {
+	case m := <-received:
+		t.Fatal("event is received twice:", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,112 @@
+package gosocketio
+
+import (
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// Namespace is a server namespace the clients connect to over their connection, e.g. with Manager.Socket().
+// Handlers registered with On() receive the connection Channel, OnConnection and OnDisconnection fire when
+// the client connects to the namespace and leaves it or closes the connection
+type Namespace struct {
+	*event
+	server *Server
+	name   string // empty for the root namespace
+}
+
+// Of returns the namespace with the given name, creating it on the first call.
+// The root namespace ("/" or "") shares handlers with the server
+func (s *Server) Of(name string) *Namespace {
+	if name == "" || name == defaultNamespace {
+		return &Namespace{event: s.event, server: s}
+	}
+
+	s.event.handlersMu.Lock()
+	defer s.event.handlersMu.Unlock()
+
+	if s.event.namespaces == nil {
+		s.event.namespaces = make(map[string]*event)
+	}
+	ns, ok := s.event.namespaces[name]
+	if !ok {
+		ns = &event{}
+		ns.init()
+		s.event.namespaces[name] = ns
+	}
+	return &Namespace{event: ns, server: s, name: name}
+}
+
+// Name returns the namespace name, empty for the root one
+func (n *Namespace) Name() string { return n.name }
+
+// Emit an asynchronous event with the given name and payload to the namespace on the connection Channel c
+func (n *Namespace) Emit(c *Channel, name string, payload interface{}) error {
+	return c.send(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: name, Namespace: n.name}, payload)
+}
+
+// enterNamespace marks the server Channel connected to the namespace and answers with the connect packet,
+// it returns false if the Channel is already connected
+func (c *Channel) enterNamespace(namespace string) bool {
+	c.namespacesMu.Lock()
+	if _, ok := c.namespaces[namespace]; ok {
+		c.namespacesMu.Unlock()
+		return false
+	}
+	if c.namespaces == nil {
+		c.namespaces = make(map[string]struct{})
+	}
+	c.namespaces[namespace] = struct{}{}
+	c.namespacesMu.Unlock()
+
+	if err := c.send(&protocol.Message{Type: protocol.MessageTypeEmpty, Namespace: namespace}, nil); err != nil {
+		logging.Log().Debug("Channel.enterNamespace() can't answer the connect packet:", err)
+	}
+	return true
+}
+
+// leaveNamespace marks the server Channel disconnected from the namespace,
+// it returns false if the Channel isn't connected
+func (c *Channel) leaveNamespace(namespace string) bool {
+	c.namespacesMu.Lock()
+	defer c.namespacesMu.Unlock()
+	if _, ok := c.namespaces[namespace]; !ok {
+		return false
+	}
+	delete(c.namespaces, namespace)
+	return true
+}
+
+// connectedNamespaces returns the namespaces the server Channel is connected to
+func (c *Channel) connectedNamespaces() []string {
+	c.namespacesMu.Lock()
+	defer c.namespacesMu.Unlock()
+	namespaces := make([]string, 0, len(c.namespaces))
+	for namespace := range c.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces
+}
+
+// moveNamespaces connects the Channel to to the namespaces of c at the transport upgrade
+func (c *Channel) moveNamespaces(to *Channel) {
+	for _, namespace := range c.connectedNamespaces() {
+		to.namespacesMu.Lock()
+		if to.namespaces == nil {
+			to.namespaces = make(map[string]struct{})
+		}
+		to.namespaces[namespace] = struct{}{}
+		to.namespacesMu.Unlock()
+	}
+}
+
+// namespacesDisconnected fires OnDisconnection of the namespaces the closed Channel c was connected to
+func (s *Server) namespacesDisconnected(c *Channel) {
+	for _, namespace := range c.connectedNamespaces() {
+		s.event.handlersMu.RLock()
+		ns, ok := s.event.namespaces[namespace]
+		s.event.handlersMu.RUnlock()
+		if ok && c.leaveNamespace(namespace) {
+			ns.callHandler(c, OnDisconnection)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package gosocketio
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// networkChangeDebounce is a time WatchNetworkChanges waits for the burst of the interface changes to settle
+const networkChangeDebounce = 500 * time.Millisecond
+
+var ErrorNetworkWatchNotSupported = errors.New("network changes watching is not supported on this platform")
+
+// NotifyNetworkChange tells the client the network has changed, e.g. switched from Wi-Fi to LTE.
+// The connection bound to the previous interface is likely dead, so instead of waiting for the read timeout
+// the client re-dials immediately keeping the queued messages, see Reconnect. If re-dialing fails
+// the old connection is torn down and the automatic reconnection takes over if it's enabled
+func (c *Client) NotifyNetworkChange() error {
+	c.reconnMu.Lock()
+	reconnecting := c.reconnecting
+	c.reconnMu.Unlock()
+	if reconnecting || !c.IsAlive() {
+		return nil
+	}
+
+	conn := c.connection()
+	err := c.Reconnect("")
+	if err != nil {
+		logging.Log().Debug("Client.NotifyNetworkChange() can't reconnect:", err)
+		conn.Close()
+	}
+	return err
+}
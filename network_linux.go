@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package gosocketio
+
+import (
+	"context"
+	"syscall"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// netlink multicast groups of the links and addresses changes, missing in syscall package
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv6IfAddr = 0x100
+)
+
+// WatchNetworkChanges listens to the netlink notifications of the network interfaces and addresses changes
+// calling NotifyNetworkChange when they settle, until ctx is done or the client is closed
+func (c *Client) WatchNetworkChanges(ctx context.Context) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	groups := uint32(rtmgrpLink | rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr)
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		return err
+	}
+	// the read timeout lets to check ctx and to fire the settled change
+	tv := syscall.NsecToTimeval(int64(networkChangeDebounce / 2))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return err
+	}
+
+	var changed time.Time // moment of the last change not notified yet
+	buf := make([]byte, syscall.Getpagesize())
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.Done():
+			return nil
+		default:
+		}
+
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		switch {
+		case err == syscall.EAGAIN || err == syscall.EINTR:
+		case err != nil:
+			return err
+		case networkChanged(buf[:n]):
+			changed = time.Now()
+		}
+
+		if !changed.IsZero() && time.Since(changed) >= networkChangeDebounce {
+			changed = time.Time{}
+			logging.Log().Debug("Client.WatchNetworkChanges() network changed")
+			c.NotifyNetworkChange()
+		}
+	}
+}
+
+// networkChanged returns whether netlink messages in data report the interface or address change
+func networkChanged(data []byte) bool {
+	messages, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return false
+	}
+	for _, m := range messages {
+		switch m.Header.Type {
+		case syscall.RTM_NEWLINK, syscall.RTM_DELLINK, syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+			return true
+		}
+	}
+	return false
+}
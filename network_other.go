@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package gosocketio
+
+import "context"
+
+// WatchNetworkChanges is not supported on this platform, call NotifyNetworkChange on the OS hints instead
+func (c *Client) WatchNetworkChanges(ctx context.Context) error { return ErrorNetworkWatchNotSupported }
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package gosocketio
+
+import (
+	"net"
+	"syscall"
+)
+
+// setNonblock switches the listener l socket into non-blocking mode
+func setNonblock(l *net.TCPListener) error {
+	rc, err := l.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var nbErr error
+	if err := rc.Control(func(fd uintptr) { nbErr = syscall.SetNonblock(int(fd), true) }); err != nil {
+		return err
+	}
+	return nbErr
+}
@@ -0,0 +1,6 @@
+package gosocketio
+
+import "net"
+
+// setNonblock is a no-op on windows, listeners are not passed to the successor there
+func setNonblock(l *net.TCPListener) error { return nil }
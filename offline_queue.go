@@ -0,0 +1,331 @@
+package gosocketio
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// maxQueueRecordSize limits the size of a single record of the FileQueue
+const maxQueueRecordSize = 16 * 1024 * 1024
+
+var (
+	ErrorOfflineQueueFull = errors.New("offline queue is full")
+	ErrorNoOfflineQueue   = errors.New("offline queue is not set")
+)
+
+// QueuedEmit is an emit persisted in the OfflineQueue until it's written to the server
+type QueuedEmit struct {
+	Key     string          `json:"key"` // dedupe key, the emit with the key already queued is dropped
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Queued  time.Time       `json:"queued"`
+}
+
+// OfflineQueue persists the client emits made while offline, e.g. FileQueue
+type OfflineQueue interface {
+	Put(e QueuedEmit) error      // it's no-op if the emit with the same key is queued
+	List() ([]QueuedEmit, error) // queued emits in the order of putting them
+	Delete(key string) error     // it's no-op if there are no emit with the key
+}
+
+// queueRecord is a line of the FileQueue file
+type queueRecord struct {
+	Put    *QueuedEmit `json:"put,omitempty"`
+	Delete string      `json:"delete,omitempty"`
+}
+
+// FileQueue is the OfflineQueue appending the records to a file synced on each change, it's compacted when opened
+// and when the deleted records outnumber the queued ones
+type FileQueue struct {
+	path    string
+	maxSize int
+	f       *os.File
+	emits   []QueuedEmit
+	deleted int // records of the deleted emits in the file
+	mu      sync.Mutex
+}
+
+// OpenFileQueue opens or creates the FileQueue at path holding at most maxSize emits, zero means unlimited
+func OpenFileQueue(path string, maxSize int) (*FileQueue, error) {
+	q := &FileQueue{path: path, maxSize: maxSize}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load the queued emits from the file, a truncated last record is skipped
+func (q *FileQueue) load() error {
+	f, err := os.Open(q.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxQueueRecordSize)
+	for scanner.Scan() {
+		var r queueRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			logging.Log().Warn("FileQueue.load() skips the broken record:", err)
+			continue
+		}
+		switch {
+		case r.Put != nil:
+			q.emits = append(q.emits, *r.Put)
+		case r.Delete != "":
+			q.remove(r.Delete)
+		}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites the file with the queued emits only and reopens it for appending
+func (q *FileQueue) compact() error {
+	tmp := q.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for i := range q.emits {
+		if err := writeRecord(w, queueRecord{Put: &q.emits[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if q.f != nil {
+		q.f.Close()
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	if dir, err := os.Open(filepath.Dir(q.path)); err == nil { // persist the rename
+		dir.Sync()
+		dir.Close()
+	}
+
+	q.f, err = os.OpenFile(q.path, os.O_APPEND|os.O_WRONLY, 0600)
+	q.deleted = 0
+	return err
+}
+
+// writeRecord writes the record r as a line to w
+func writeRecord(w *bufio.Writer, r queueRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+// append the record r to the file
+func (q *FileQueue) append(r queueRecord) error {
+	w := bufio.NewWriter(q.f)
+	if err := writeRecord(w, r); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return q.f.Sync()
+}
+
+// find returns the index of the emit with the key, -1 if there are none
+func (q *FileQueue) find(key string) int {
+	for i := range q.emits {
+		if q.emits[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove the emit with the key from the memory, it returns false if there are none
+func (q *FileQueue) remove(key string) bool {
+	i := q.find(key)
+	if i < 0 {
+		return false
+	}
+	q.emits = append(q.emits[:i], q.emits[i+1:]...)
+	return true
+}
+
+// Put the emit e into the queue, it fails with ErrorOfflineQueueFull if the queue holds maxSize emits
+func (q *FileQueue) Put(e QueuedEmit) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.find(e.Key) >= 0 {
+		return nil
+	}
+	if q.maxSize > 0 && len(q.emits) >= q.maxSize {
+		return ErrorOfflineQueueFull
+	}
+	if err := q.append(queueRecord{Put: &e}); err != nil {
+		return err
+	}
+	q.emits = append(q.emits, e)
+	return nil
+}
+
+// List returns the queued emits
+func (q *FileQueue) List() ([]QueuedEmit, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]QueuedEmit(nil), q.emits...), nil
+}
+
+// Delete the emit with the key from the queue
+func (q *FileQueue) Delete(key string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.find(key) < 0 {
+		return nil
+	}
+	if err := q.append(queueRecord{Delete: key}); err != nil {
+		return err
+	}
+	q.remove(key)
+
+	if q.deleted++; q.deleted > len(q.emits) {
+		return q.compact()
+	}
+	return nil
+}
+
+// Close the queue file
+func (q *FileQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.f.Close()
+}
+
+// SetOfflineQueue sets the queue persisting the emits made with EmitDurable, nil disables it.
+// The queued emits are written when the client connects or reconnects, including the emits left
+// by the previous process
+func (c *Client) SetOfflineQueue(q OfflineQueue) {
+	c.offlineMu.Lock()
+	c.offlineQueue = q
+	c.offlineMu.Unlock()
+	go c.flushOffline()
+}
+
+// EmitDurable acts like Emit but the event is persisted in the offline queue until it's written to the server,
+// so it survives the connection loss and the process restart. The event with the key already queued is dropped,
+// empty key never matches. It fails with ErrorNoOfflineQueue if the queue isn't set
+func (c *Client) EmitDurable(key, name string, payload interface{}) error {
+	c.offlineMu.Lock()
+	q := c.offlineQueue
+	c.offlineMu.Unlock()
+	if q == nil {
+		return ErrorNoOfflineQueue
+	}
+
+	var data json.RawMessage
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	if key == "" {
+		key = newSessionID()
+	}
+	if err := q.Put(QueuedEmit{Key: key, Name: name, Payload: data, Queued: c.event.now()}); err != nil {
+		return err
+	}
+
+	go c.flushOffline()
+	return nil
+}
+
+// flushOffline writes the queued emits deleting them from the queue once written, only one flush runs at a time
+func (c *Client) flushOffline() {
+	c.offlineMu.Lock()
+	q := c.offlineQueue
+	if q == nil || c.offlineFlushing {
+		c.offlineFlushAgain = q != nil
+		c.offlineMu.Unlock()
+		return
+	}
+	c.offlineFlushing = true
+	c.offlineMu.Unlock()
+
+	for {
+		c.writeOffline(q)
+
+		c.offlineMu.Lock()
+		if !c.offlineFlushAgain {
+			c.offlineFlushing = false
+			c.offlineMu.Unlock()
+			return
+		}
+		c.offlineFlushAgain = false
+		c.offlineMu.Unlock()
+	}
+}
+
+// writeOffline writes the emits queued in q until the first failure
+func (c *Client) writeOffline(q OfflineQueue) {
+	emits, err := q.List()
+	if err != nil {
+		logging.Log().Warn("Client.writeOffline() can't list the offline queue:", err)
+		return
+	}
+
+	for _, e := range emits {
+		if !c.IsAlive() {
+			return
+		}
+
+		p := &packet{done: make(chan error, 1)}
+		m := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: e.Name}
+		var payload interface{}
+		if len(e.Payload) > 0 {
+			payload = e.Payload
+		}
+		if err := c.sendWith(m, payload, p, true); err != nil {
+			return
+		}
+		if err := <-p.done; err != nil {
+			return
+		}
+
+		if err := q.Delete(e.Key); err != nil {
+			logging.Log().Warn("Client.writeOffline() can't delete the written emit:", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package gosocketio
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOfflineQueueDeliveredOnReconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "queue")
+	q, err := OpenFileQueue(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	s := NewServer()
+	connected := make(chan *Channel, 2)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	received := make(chan string, 4)
+	s.On("durable", func(c *Channel, p string) { received <- p })
+	var offline int32 // the server refuses the connections while set
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&offline) == 1 {
+			http.Error(w, "offline", http.StatusServiceUnavailable)
+			return
+		}
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	host, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts := make(chan error, 16)
+	c, err := DialWithOpts(AddrWebsocket(host, p, false), DialOfflineQueue(q),
+		DialReconnection(ReconnectionParams{MinDelay: 20 * time.Millisecond, MaxDelay: 20 * time.Millisecond}),
+		DialHooks(ClientHooks{ReconnectAttempt: func(_ int, err error) { attempts <- err }}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	atomic.StoreInt32(&offline, 1)
+	(<-connected).Close()
+	select {
+	case err := <-attempts:
+		if err == nil {
+			t.Fatal("reconnected to the offline server")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconnection attempt")
+	}
+	for _, e := range []struct{ key, payload string }{{"1", "a"}, {"1", "a"}, {"2", "b"}} {
+		if err := c.EmitDurable(e.key, "durable", e.payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	atomic.StoreInt32(&offline, 0)
+	delivered := map[string]bool{} // the handlers are called concurrently
+	for i := 0; i < 2; i++ {
+		delivered[receive(t, received, "queued emit")] = true
+	}
+	if !delivered["a"] || !delivered["b"] {
+		t.Fatal("delivered:", delivered)
+	}
+	select {
+	case p := <-received:
+		t.Fatal("emit with the queued key is delivered:", p)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if emits, _ := q.List(); len(emits) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("delivered emits are left in the queue")
+		}
+	}
+	reopened, err := OpenFileQueue(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if emits, _ := reopened.List(); len(emits) != 0 {
+		t.Fatal("delivered emits are left in the file:", emits)
+	}
+}
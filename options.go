@@ -0,0 +1,203 @@
+package gosocketio
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/transport"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrorUnsupportedTransport = errors.New("unsupported transport")
+
+// serverOptions are collected from the ServerOption list before creating the Server
+type serverOptions struct {
+	websocket    *transport.WebsocketTransport
+	polling      *transport.PollingTransport
+	pingInterval time.Duration // zero keeps the ping params of the transports
+	pingTimeout  time.Duration
+	logger       *logrus.Logger
+	configs      []Config
+	plugins      []Plugin
+}
+
+// ServerOption configures the Server created with NewServerWithOpts
+type ServerOption func(o *serverOptions) error
+
+// WithTransport sets the websocket (*transport.WebsocketTransport) or the XHR polling (*transport.PollingTransport)
+// transport of the server, other ones fail with ErrorUnsupportedTransport
+func WithTransport(tr transport.Transport) ServerOption {
+	return func(o *serverOptions) error {
+		switch t := tr.(type) {
+		case *transport.WebsocketTransport:
+			o.websocket = t
+		case *transport.PollingTransport:
+			o.polling = t
+		default:
+			return ErrorUnsupportedTransport
+		}
+		return nil
+	}
+}
+
+// WithPingInterval sets the ping interval and timeout of all the server transports
+func WithPingInterval(interval, timeout time.Duration) ServerOption {
+	return func(o *serverOptions) error {
+		o.pingInterval, o.pingTimeout = interval, timeout
+		return nil
+	}
+}
+
+// WithLogger replaces the package logger, it's shared by all the servers and clients
+func WithLogger(l *logrus.Logger) ServerOption {
+	return func(o *serverOptions) error {
+		o.logger = l
+		return nil
+	}
+}
+
+// WithConfig applies the tunables of cfg to the server, see Server.ApplyConfig
+func WithConfig(cfg Config) ServerOption {
+	return func(o *serverOptions) error {
+		o.configs = append(o.configs, cfg)
+		return nil
+	}
+}
+
+// WithPlugins registers the plugins, see Server.Use
+func WithPlugins(ps ...Plugin) ServerOption {
+	return func(o *serverOptions) error {
+		o.plugins = append(o.plugins, ps...)
+		return nil
+	}
+}
+
+// NewServerWithOpts creates new socket.io server configured with opts, they're applied in order
+func NewServerWithOpts(opts ...ServerOption) (*Server, error) {
+	o := serverOptions{
+		websocket: transport.DefaultWebsocketTransport(),
+		polling:   transport.DefaultPollingTransport(),
+	}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.pingInterval > 0 {
+		o.websocket.PingInterval, o.websocket.PingTimeout = o.pingInterval, o.pingTimeout
+		o.polling.PingInterval, o.polling.PingTimeout = o.pingInterval, o.pingTimeout
+	}
+	if o.logger != nil {
+		logging.SetLog(o.logger)
+	}
+
+	s := newServer(o.websocket, o.polling)
+	for _, cfg := range o.configs {
+		if err := s.ApplyConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+	s.Use(o.plugins...)
+	return s, nil
+}
+
+// dialOptions are collected from the DialOption list before connecting the Client
+type dialOptions struct {
+	tr           transport.Transport
+	hooks        ClientHooks
+	reconnection *ReconnectionParams
+	deadTimeout  time.Duration
+	offlineQueue OfflineQueue
+	compression  compression
+}
+
+// DialOption configures the Client connected with DialWithOpts
+type DialOption func(o *dialOptions) error
+
+// DialTransport sets the transport to connect with, it's transport.DefaultWebsocketTransport() by default
+func DialTransport(tr transport.Transport) DialOption {
+	return func(o *dialOptions) error {
+		o.tr = tr
+		return nil
+	}
+}
+
+// DialHooks sets the instrumentation hooks called from the very dial start, see DialWithHooks
+func DialHooks(hooks ClientHooks) DialOption {
+	return func(o *dialOptions) error {
+		o.hooks = hooks
+		return nil
+	}
+}
+
+// DialReconnection enables automatic reconnection, see Client.SetReconnection
+func DialReconnection(p ReconnectionParams) DialOption {
+	return func(o *dialOptions) error {
+		o.reconnection = &p
+		return nil
+	}
+}
+
+// DialDeadConnectionTimeout enables the dead connection check, see Client.SetDeadConnectionTimeout
+func DialDeadConnectionTimeout(timeout time.Duration) DialOption {
+	return func(o *dialOptions) error {
+		o.deadTimeout = timeout
+		return nil
+	}
+}
+
+// DialOfflineQueue sets the queue of the durable emits, see Client.SetOfflineQueue
+func DialOfflineQueue(q OfflineQueue) DialOption {
+	return func(o *dialOptions) error {
+		o.offlineQueue = q
+		return nil
+	}
+}
+
+// DialCompression enables the compression of the payloads offering the codecs to the server, see SetCompression.
+// The codecs are offered with the query parameter of addr, so the transports without query don't negotiate it
+func DialCompression(threshold int, codecs ...Codec) DialOption {
+	return func(o *dialOptions) error {
+		o.compression = compression{codecs: codecs, threshold: threshold}
+		return nil
+	}
+}
+
+// DialWithOpts connects to server like Dial configuring the client with opts, they're applied in order
+func DialWithOpts(addr string, opts ...DialOption) (*Client, error) {
+	var o dialOptions
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+	if o.tr == nil {
+		o.tr = transport.DefaultWebsocketTransport()
+	}
+
+	if len(o.compression.codecs) > 0 && strings.Contains(addr, "?") {
+		names := make([]string, len(o.compression.codecs))
+		for i, codec := range o.compression.codecs {
+			names[i] = codec.Name()
+		}
+		addr = AddrCompression(addr, names...)
+	}
+
+	c, err := dial(addr, o.tr, &clientHooks{hooks: o.hooks}, o.compression)
+	if err != nil {
+		return nil, err
+	}
+	if o.reconnection != nil {
+		c.SetReconnection(*o.reconnection)
+	}
+	if o.deadTimeout > 0 {
+		c.SetDeadConnectionTimeout(o.deadTimeout)
+	}
+	if o.offlineQueue != nil {
+		c.SetOfflineQueue(o.offlineQueue)
+	}
+	return c, nil
+}
@@ -0,0 +1,393 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+// OrderedAckEvent carries the highest contiguous sequence number of the ordered messages received by the client
+const OrderedAckEvent = "sio:ordered:ack"
+
+const (
+	orderedParam      = "ordered"
+	orderedPrefix     = `{"oseq":`
+	orderedAckDelay   = 100 * time.Millisecond // the acks of the messages received within it are sent at once
+	orderedGapTimeout = time.Second            // the client asks to resend the messages missed for it
+)
+
+var ErrorOrderedWindowFull = errors.New("ordered delivery window is full")
+
+var orderedResends synced.Counter
+
+// CountOrderedResends returns an amount of the ordered messages sent again after reconnecting or on the request
+func CountOrderedResends() int { return orderedResends.Get() }
+
+// OrderedAck is a payload of the OrderedAckEvent
+type OrderedAck struct {
+	Seq    uint64 `json:"seq"`              // all the messages up to it are received
+	Resend bool   `json:"resend,omitempty"` // the client misses the messages after Seq, so they are sent again
+	Until  uint64 `json:"until,omitempty"`  // the missed messages are before it, all after Seq are missed if zero
+}
+
+// OrderedMessage is the ordered message not acknowledged by the client yet
+type OrderedMessage struct {
+	Seq  uint64 `json:"seq"`
+	Name string `json:"name"`
+	Args string `json:"args,omitempty"` // JSON payload
+}
+
+// OrderedBuffer is the state of the ordered delivery saved with the session, see Server.SetOrderedDelivery
+type OrderedBuffer struct {
+	Sent    uint64           `json:"sent"` // sequence number of the last message
+	Pending []OrderedMessage `json:"pending,omitempty"`
+}
+
+// orderedPayload is the payload of the ordered message
+type orderedPayload struct {
+	Seq  uint64          `json:"oseq"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// SetOrderedDelivery enables the ordered delivery of the events emitted to the clients connected with
+// AddrOrdered, window is the maximum amount of the messages not acknowledged by the client, zero disables it.
+// The payload of each event is replaced with {"oseq":..., "data": payload} object numbering the messages
+// of the session and the messages are kept until the client acknowledges them with the OrderedAckEvent.
+// The Go client does it natively handling the events one by one in order without gaps and duplicates.
+// The messages not acknowledged are sent again after the session is resumed, see SetResumption, or when
+// the client misses them, e.g. the ones expired or not queued. Emit fails with ErrorOrderedWindowFull
+// if the window is full. The ack requests and the control events aren't ordered
+func (s *Server) SetOrderedDelivery(window int) {
+	s.orderedMu.Lock()
+	defer s.orderedMu.Unlock()
+	s.orderedWindow = window
+	if window > 0 && !s.orderedProbing {
+		s.orderedProbing = true
+		go s.probeOrderedLoop()
+	}
+}
+
+// getOrderedWindow returns the size of the ordered delivery window
+func (s *Server) getOrderedWindow() int {
+	s.orderedMu.RLock()
+	defer s.orderedMu.RUnlock()
+	return s.orderedWindow
+}
+
+// probeOrderedLoop periodically probes the ordered delivery of the channels until it's disabled
+func (s *Server) probeOrderedLoop() {
+	for {
+		s.event.sleep(orderedGapTimeout)
+		s.orderedMu.Lock()
+		if s.orderedWindow <= 0 {
+			s.orderedProbing = false
+			s.orderedMu.Unlock()
+			return
+		}
+		s.orderedMu.Unlock()
+
+		for _, c := range s.channelsList() {
+			c.probeOrdered()
+		}
+	}
+}
+
+// AddrOrdered returns the given socket.io connection url asking for the ordered delivery,
+// see Server.SetOrderedDelivery
+func AddrOrdered(addr string) string { return addr + "&" + orderedParam + "=1" }
+
+// orderedRequested returns whether the connection url addr asks for the ordered delivery
+func orderedRequested(addr string) bool {
+	u, err := url.Parse(addr)
+	return err == nil && u.Query().Get(orderedParam) != ""
+}
+
+// orderable returns whether the message m is delivered in order
+func orderable(m *protocol.Message) bool {
+	return m.Type == protocol.MessageTypeEmit && m.Namespace == "" &&
+		m.EventName != ReconnectRequestEvent && m.EventName != KeyRotationEvent
+}
+
+// orderedMessage returns the event with the given name and the JSON payload args numbered by seq
+func orderedMessage(m *protocol.Message, seq uint64, args string) *protocol.Message {
+	numbered := *m
+	numbered.Args = orderedPrefix + strconv.FormatUint(seq, 10) + `}`
+	if args != "" {
+		numbered.Args = orderedPrefix + strconv.FormatUint(seq, 10) + `,"data":` + args + `}`
+	}
+	return &numbered
+}
+
+// orderedSender numbers the ordered messages of the server channel and keeps them until they're acknowledged
+type orderedSender struct {
+	window  int
+	sent    uint64
+	pending []OrderedMessage
+	oldest  uint64 // sequence number of the oldest message pending at the previous probe
+	mu      sync.Mutex
+}
+
+// negotiateOrdered enables the ordered delivery of the server channel if the client asks for it
+func (c *Channel) negotiateOrdered(requested string) {
+	if window := c.server.getOrderedWindow(); requested != "" && window > 0 {
+		c.ordered = &orderedSender{window: window}
+	}
+}
+
+// number returns the copy of message m numbered by the next sequence number keeping it until it's acknowledged
+func (o *orderedSender) number(m *protocol.Message) (*protocol.Message, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) >= o.window {
+		return nil, ErrorOrderedWindowFull
+	}
+	o.sent++
+	o.pending = append(o.pending, OrderedMessage{Seq: o.sent, Name: m.EventName, Args: m.Args})
+	return orderedMessage(m, o.sent, m.Args), nil
+}
+
+// acked discards the messages up to seq
+func (o *orderedSender) acked(seq uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	i := 0
+	for i < len(o.pending) && o.pending[i].Seq <= seq {
+		i++
+	}
+	o.pending = append(o.pending[:0], o.pending[i:]...)
+}
+
+// between returns the messages after seq before until, all after seq if until is zero
+func (o *orderedSender) between(seq, until uint64) []OrderedMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var messages []OrderedMessage
+	for _, m := range o.pending {
+		if m.Seq > seq && (until == 0 || m.Seq < until) {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// snapshot returns the state to save with the session, o may be nil
+func (o *orderedSender) snapshot() *OrderedBuffer {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return &OrderedBuffer{Sent: o.sent, Pending: append([]OrderedMessage(nil), o.pending...)}
+}
+
+// restore the state saved with the session, o and b may be nil
+func (o *orderedSender) restore(b *OrderedBuffer) {
+	if o == nil || b == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent, o.pending = b.Sent, append([]OrderedMessage(nil), b.Pending...)
+}
+
+// orderedAcked handles the OrderedAckEvent of the client
+func (c *Channel) orderedAcked(a OrderedAck) {
+	if c.ordered == nil {
+		return
+	}
+	c.ordered.acked(a.Seq)
+	if a.Resend {
+		c.resendOrdered(a.Seq, a.Until)
+	}
+}
+
+// resendOrdered sends the ordered messages after seq before until again, all after seq if until is zero
+func (c *Channel) resendOrdered(seq, until uint64) {
+	if c.ordered == nil {
+		return
+	}
+	messages := c.ordered.between(seq, until)
+	if len(messages) == 0 {
+		return
+	}
+	p, err := c.protection()
+	if err != nil {
+		logging.Log().Warn("Channel.resendOrdered() can't protect the messages:", err)
+		return
+	}
+	p.ordered = nil // already numbered
+
+	logging.Log().Debugf("Channel.resendOrdered() sends %d messages after #%d to %s", len(messages), seq, c.Id())
+	for _, om := range messages {
+		m := orderedMessage(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: om.Name}, om.Seq, om.Args)
+		command, err := encodeWith(m, nil, p)
+		if err == nil {
+			err = c.push(&packet{message: command}, true)
+		}
+		if err != nil {
+			logging.Log().Warn("Channel.resendOrdered() can't send the message:", err)
+			return
+		}
+		orderedResends.Inc()
+	}
+}
+
+// probeOrdered sends the last ordered message again if the oldest one isn't acknowledged since the previous probe,
+// so the client acknowledges it again or finds the missed messages before it. Otherwise the loss of the last
+// messages isn't noticed
+func (c *Channel) probeOrdered() {
+	if c.ordered == nil || !c.IsAlive() {
+		return
+	}
+	o := c.ordered
+	o.mu.Lock()
+	var last uint64
+	if len(o.pending) > 0 && o.pending[0].Seq == o.oldest {
+		last = o.pending[len(o.pending)-1].Seq
+	}
+	o.oldest = 0
+	if len(o.pending) > 0 {
+		o.oldest = o.pending[0].Seq
+	}
+	o.mu.Unlock()
+
+	if last > 0 {
+		c.resendOrdered(last-1, last+1)
+	}
+}
+
+// serveOrderedAcks handles the OrderedAckEvent of the clients
+func (s *Server) serveOrderedAcks() {
+	s.On(OrderedAckEvent, func(c *Channel, a OrderedAck) { c.orderedAcked(a) })
+}
+
+// orderedReceiver delivers the ordered messages of the client channel one by one in order
+type orderedReceiver struct {
+	stream     string                       // resumption token or sid of the numbered messages
+	delivered  uint64                       // highest contiguous sequence number delivered
+	acked      uint64                       // highest sequence number acknowledged
+	held       map[uint64]*protocol.Message // received out of order
+	delivering bool                         // true while some goroutine delivers the messages
+	acking     bool                         // true while the ack is scheduled
+	checking   bool                         // true while the gap check is scheduled
+	mu         sync.Mutex
+}
+
+// orderedStream returns the resumption token identifying the numbered messages, or the sid without resumption
+func (c *Channel) orderedStream() string {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	if c.connHeader.Token != "" {
+		return c.connHeader.Token
+	}
+	return c.connHeader.Sid
+}
+
+// resumeToken returns the resumption token while the connection may be replaced
+func (c *Channel) resumeToken() string {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.connHeader.Token
+}
+
+// reorder takes the ordered message m delivering it with the preceding ones in order, it returns false if
+// m is taken. Duplicated messages are dropped
+func (c *Channel) reorder(e *event, m *protocol.Message) bool {
+	r := c.orderedIn
+	if r == nil || m.Type != protocol.MessageTypeEmit || !strings.HasPrefix(m.Args, orderedPrefix) {
+		return true
+	}
+	var payload orderedPayload
+	if err := json.Unmarshal([]byte(m.Args), &payload); err != nil || payload.Seq == 0 {
+		logging.Log().Warnf("Channel.reorder() drops %q with invalid sequence number: %v", m.EventName, err)
+		return false
+	}
+	m.Args = string(payload.Data)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stream := c.orderedStream(); stream != r.stream { // new session starts the numbering over
+		r.stream, r.delivered, r.acked, r.held = stream, 0, 0, make(map[uint64]*protocol.Message)
+	}
+	if _, ok := r.held[payload.Seq]; ok || payload.Seq <= r.delivered {
+		logging.Log().Debugf("Channel.reorder() drops duplicated %q #%d", m.EventName, payload.Seq)
+		if !r.acking && payload.Seq <= r.delivered { // the ack may be lost, so it's resent
+			r.acking = true
+			go c.ackOrdered(r)
+		}
+		return false
+	}
+	r.held[payload.Seq] = m
+	if r.delivering {
+		return false
+	}
+
+	r.delivering = true
+	for next, ok := r.held[r.delivered+1]; ok; next, ok = r.held[r.delivered+1] {
+		delete(r.held, r.delivered+1)
+		r.delivered++
+		r.mu.Unlock()
+		e.deliver(c, next)
+		r.mu.Lock()
+	}
+	r.delivering = false
+
+	if !r.acking && r.delivered > r.acked {
+		r.acking = true
+		go c.ackOrdered(r)
+	}
+	if !r.checking && len(r.held) > 0 {
+		r.checking = true
+		go c.checkOrderedGap(r, r.delivered)
+	}
+	return false
+}
+
+// ackOrdered acknowledges the delivered messages after a delay, so the acks are sent at once
+func (c *Channel) ackOrdered(r *orderedReceiver) {
+	c.events.sleep(orderedAckDelay)
+	r.mu.Lock()
+	seq := r.delivered
+	r.acked, r.acking = seq, false
+	r.mu.Unlock()
+
+	if err := c.Emit(OrderedAckEvent, OrderedAck{Seq: seq}); err != nil {
+		logging.Log().Debug("Channel.ackOrdered() can't send the ack:", err)
+	}
+}
+
+// checkOrderedGap asks to resend the missed messages if the messages after the gap are still held
+// and nothing is delivered since the given sequence number
+func (c *Channel) checkOrderedGap(r *orderedReceiver, delivered uint64) {
+	c.events.sleep(orderedGapTimeout)
+	r.mu.Lock()
+	r.checking = false
+	if len(r.held) == 0 || !c.IsAlive() {
+		r.mu.Unlock()
+		return
+	}
+	r.checking = true
+	missed, seq, until := r.delivered == delivered, r.delivered, uint64(0)
+	for held := range r.held {
+		if until == 0 || held < until {
+			until = held
+		}
+	}
+	r.mu.Unlock()
+	go c.checkOrderedGap(r, seq)
+
+	if missed {
+		logging.Log().Debugf("Channel.checkOrderedGap() misses the messages after #%d before #%d", seq, until)
+		if err := c.Emit(OrderedAckEvent, OrderedAck{Seq: seq, Resend: true, Until: until}); err != nil {
+			logging.Log().Debug("Channel.checkOrderedGap() can't ask to resend:", err)
+		}
+	}
+}
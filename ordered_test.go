@@ -0,0 +1,154 @@
+package gosocketio
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestOrderedSender(t *testing.T) {
+	o := &orderedSender{window: 3}
+	for i := 1; i <= 3; i++ {
+		m, err := o.number(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: "m", Args: `"p"`})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := `{"oseq":` + string(rune('0'+i)) + `,"data":"p"}`; m.Args != expected {
+			t.Fatalf("payload of the ordered message: %s, expected %s", m.Args, expected)
+		}
+	}
+	if _, err := o.number(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: "m"}); err != ErrorOrderedWindowFull {
+		t.Fatal("number with the full window:", err)
+	}
+
+	o.acked(2)
+	if pending := o.between(0, 0); len(pending) != 1 || pending[0].Seq != 3 {
+		t.Fatalf("pending after the ack: %+v", pending)
+	}
+	m, err := o.number(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: "m"})
+	if err != nil || m.Args != `{"oseq":4}` {
+		t.Fatalf("ordered message without payload: %+v, %v", m, err)
+	}
+
+	restored := &orderedSender{window: 3}
+	restored.restore(o.snapshot())
+	if pending := restored.between(3, 5); len(pending) != 1 || pending[0].Seq != 4 || restored.sent != 4 {
+		t.Fatalf("restored pending: %+v, sent %d", pending, restored.sent)
+	}
+}
+
+func TestReorder(t *testing.T) {
+	e := &event{}
+	e.init()
+	delivered := make(chan string, 8)
+	e.On("m", func(c *Channel, p string) { delivered <- p })
+	c := &Channel{conn: stubConnection{}, events: e, orderedIn: &orderedReceiver{}}
+	c.init()
+	c.connHeader.Token = "first session"
+
+	// take passes the message numbered by seq to the receiver
+	take := func(seq uint64, payload string) {
+		m := orderedMessage(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: "m"}, seq, `"`+payload+`"`)
+		if c.reorder(e, m) {
+			t.Fatalf("ordered message #%d isn't taken", seq)
+		}
+	}
+	take(2, "b")
+	take(3, "c")
+	take(1, "a")
+	take(2, "b") // duplicated
+	take(5, "e") // held until 4 is received
+	for _, expected := range []string{"a", "b", "c"} {
+		if p := receive(t, delivered, "ordered message"); p != expected {
+			t.Fatalf("delivered %q, expected %q", p, expected)
+		}
+	}
+	select {
+	case p := <-delivered:
+		t.Fatal("delivered after the gap or duplicated:", p)
+	default:
+	}
+	take(4, "d")
+	for _, expected := range []string{"d", "e"} {
+		if p := receive(t, delivered, "ordered message after the gap"); p != expected {
+			t.Fatalf("delivered %q, expected %q", p, expected)
+		}
+	}
+
+	c.connHeader.Token = "second session" // the numbering starts over
+	take(1, "new")
+	if p := receive(t, delivered, "first message of the new session"); p != "new" {
+		t.Fatal("delivered:", p)
+	}
+}
+
+func TestOrderedResendAfterResume(t *testing.T) {
+	store := NewMemorySessionStore()
+	s := NewServer()
+	s.SetResumption([]byte("secret"), time.Minute, store)
+	s.SetOrderedDelivery(8)
+	connected := make(chan *Channel, 2)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	host, port, stop := serve(t, s)
+	defer stop()
+	addr := AddrOrdered(AddrWebsocket(host, port, false))
+
+	// connect returns the connection not acknowledging the ordered messages after the open sequence
+	connect := func(addr string) transport.Connection {
+		conn, err := transport.DefaultWebsocketTransport().Connect(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := conn.GetMessage(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return conn
+	}
+	// read returns the next emitted messages of the connection
+	read := func(conn transport.Connection, n int) []string {
+		var messages []string
+		for len(messages) < n {
+			m, err := conn.GetMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(m, "42") {
+				messages = append(messages, m)
+			}
+		}
+		return messages
+	}
+
+	first := connect(addr)
+	c := <-connected
+	for _, p := range []string{"a", "b"} {
+		if err := c.Emit("m", p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := read(first, 2)
+	first.Close()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if session, _ := store.Load(c.SessionID()); session != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session is not suspended")
+		}
+	}
+
+	second := connect(AddrResume(addr, c.ResumeToken()))
+	defer second.Close()
+	<-connected
+	resent := read(second, 2)
+	for i, expected := range []string{`42["m",{"oseq":1,"data":"a"}]`, `42["m",{"oseq":2,"data":"b"}]`} {
+		if sent[i] != expected || resent[i] != expected {
+			t.Fatalf("ordered message #%d sent %s, resent %s, expected %s", i+1, sent[i], resent[i], expected)
+		}
+	}
+}
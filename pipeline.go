@@ -0,0 +1,67 @@
+package gosocketio
+
+import (
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// Pipeline accumulates several emits to write them at once
+type Pipeline struct {
+	c        *Channel
+	messages []string
+	names    []string // event names of the messages
+	sizes    []int    // payload sizes of the messages
+	err      error
+}
+
+// Pipeline returns a new emits pipeline for the Channel
+func (c *Channel) Pipeline() *Pipeline { return &Pipeline{c: c} }
+
+// Emit adds an event with the given name and payload to the pipeline.
+// Encoding error is kept and returned by Flush()
+func (p *Pipeline) Emit(name string, payload interface{}) *Pipeline {
+	if p.err != nil {
+		return p
+	}
+
+	m := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: name}
+	command, err := p.c.encode(m, payload)
+	if err != nil {
+		p.err = err
+		return p
+	}
+
+	p.messages = append(p.messages, command)
+	p.names, p.sizes = append(p.names, name), append(p.sizes, len(m.Args))
+	return p
+}
+
+// Len returns an amount of events in the pipeline
+func (p *Pipeline) Len() int { return len(p.messages) }
+
+// Flush queues accumulated events to be written as a single payload (polling) or one after another
+// without interleaving with other messages (websocket), and resets the pipeline
+func (p *Pipeline) Flush() error {
+	if p.err != nil {
+		err := p.err
+		p.reset()
+		return err
+	}
+
+	if len(p.messages) == 0 {
+		return nil
+	}
+
+	batch, names, sizes := p.messages, p.names, p.sizes
+	p.reset()
+	if err := p.c.push(&packet{batch: batch}, true); err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		p.c.events.sent(p.c, name, sizes[i])
+	}
+	return nil
+}
+
+// reset the pipeline
+func (p *Pipeline) reset() { p.messages, p.names, p.sizes, p.err = nil, nil, nil, nil }
@@ -0,0 +1,105 @@
+package gosocketio
+
+import (
+	"sync"
+)
+
+// Plugin packages a cross-cutting feature (metrics, audit, rate limiting) hooked into the server lifecycle,
+// it's registered with Server.Use. Hooks are called synchronously, packet hooks are called for every packet
+// including heartbeats
+type Plugin interface {
+	OnServerStart(s *Server)                          // server starts serving, or the plugin is registered later
+	OnConnection(c *Channel)                          // channel is connected, before the OnConnection handler
+	OnPacketIn(c *Channel, packet string)             // packet was read by the channel
+	OnPacketOut(c *Channel, packet string)            // packet was written by the channel
+	OnDisconnect(c *Channel, reason DisconnectReason) // channel is disconnected
+	OnShutdown(s *Server)                             // all the channels are drained, see DrainAll
+}
+
+// NopPlugin implements Plugin doing nothing, embed it to implement only the needed hooks
+type NopPlugin struct{}
+
+func (NopPlugin) OnServerStart(s *Server)                          {}
+func (NopPlugin) OnConnection(c *Channel)                          {}
+func (NopPlugin) OnPacketIn(c *Channel, packet string)             {}
+func (NopPlugin) OnPacketOut(c *Channel, packet string)            {}
+func (NopPlugin) OnDisconnect(c *Channel, reason DisconnectReason) {}
+func (NopPlugin) OnShutdown(s *Server)                             {}
+
+// plugins registered on the server
+type plugins struct {
+	list    []Plugin // replaced on registration, never modified in place
+	started bool
+	once    sync.Once
+	mu      sync.RWMutex
+}
+
+// Use registers the plugins in the order of calling their hooks
+func (s *Server) Use(ps ...Plugin) {
+	s.plugins.mu.Lock()
+	s.plugins.list = append(append([]Plugin(nil), s.plugins.list...), ps...)
+	started := s.plugins.started
+	s.plugins.mu.Unlock()
+
+	if started {
+		for _, p := range ps {
+			p.OnServerStart(s)
+		}
+	}
+}
+
+// registeredPlugins returns the plugins of the server, s may be nil
+func (s *Server) registeredPlugins() []Plugin {
+	if s == nil {
+		return nil
+	}
+	s.plugins.mu.RLock()
+	defer s.plugins.mu.RUnlock()
+	return s.plugins.list
+}
+
+// start calls the OnServerStart hooks once
+func (s *Server) start() {
+	s.plugins.once.Do(func() {
+		s.plugins.mu.Lock()
+		s.plugins.started = true
+		list := s.plugins.list
+		s.plugins.mu.Unlock()
+
+		for _, p := range list {
+			p.OnServerStart(s)
+		}
+	})
+}
+
+// pluginsConnected calls the OnConnection hooks for c
+func (s *Server) pluginsConnected(c *Channel) {
+	for _, p := range s.registeredPlugins() {
+		p.OnConnection(c)
+	}
+}
+
+// pluginsDisconnected calls the OnDisconnect hooks for c
+func (s *Server) pluginsDisconnected(c *Channel) {
+	for _, p := range s.registeredPlugins() {
+		p.OnDisconnect(c, c.DisconnectReason())
+	}
+}
+
+// pluginsPacket calls the packet hooks for the packet read or written by c in the direction d
+func (s *Server) pluginsPacket(c *Channel, d Direction, packet string) {
+	for _, p := range s.registeredPlugins() {
+		if d == DirectionInbound {
+			p.OnPacketIn(c, packet)
+		} else {
+			p.OnPacketOut(c, packet)
+		}
+	}
+}
+
+// pluginsShutdown calls the OnShutdown hooks
+func (s *Server) pluginsShutdown() {
+	for _, p := range s.registeredPlugins() {
+		p.OnShutdown(s)
+	}
+}
@@ -0,0 +1,66 @@
+package gosocketio
+
+import (
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// Priority of the outgoing message, higher priority messages are written first
+type Priority int
+
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+	PriorityLow
+)
+
+// lane returns the outgoing queue lane for the given priority
+func (c *Channel) lane(priority Priority) chan *packet {
+	switch priority {
+	case PriorityHigh:
+		return c.outHighC
+	case PriorityLow:
+		return c.outLowC
+	default:
+		return c.outC
+	}
+}
+
+// nextPacket waits for the next packet from the outgoing queue, preferring the higher priority lanes.
+// The low priority lane isn't served in the background state
+func (c *Channel) nextPacket() *packet {
+	select {
+	case p := <-c.outHighC:
+		return p
+	default:
+	}
+
+	select {
+	case p := <-c.outC:
+		return p
+	default:
+	}
+
+	for {
+		low := c.outLowC
+		if c.lowLanePaused() {
+			low = nil
+		}
+
+		select {
+		case p := <-c.outHighC:
+			return p
+		case p := <-c.outC:
+			return p
+		case p := <-low:
+			return p
+		case <-c.lowWakeC:
+		}
+	}
+}
+
+// EmitWithPriority acts like Emit but queues the message into the lane of the given priority,
+// so e.g. control messages are not stuck behind a backlog of bulky updates
+func (c *Channel) EmitWithPriority(name string, payload interface{}, priority Priority) error {
+	message := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: name}
+	return c.sendWith(message, payload, &packet{priority: priority}, true)
+}
@@ -0,0 +1,20 @@
+//go:build gofuzz
+// +build gofuzz
+
+package protocol
+
+// Fuzz is a go-fuzz target for the decoder, decoded messages should be encoded back without errors
+func Fuzz(data []byte) int {
+	m, err := Decode(string(data))
+	if err != nil {
+		return 0
+	}
+
+	switch m.Type {
+	case MessageTypeEmit, MessageTypeAckRequest, MessageTypeAckResponse:
+		if _, err := Encode(m); err != nil {
+			panic(err)
+		}
+	}
+	return 1
+}
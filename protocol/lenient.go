@@ -0,0 +1,39 @@
+package protocol
+
+import "strings"
+
+// Deviation is a kind of the tolerated protocol deviation
+type Deviation int
+
+const (
+	DeviationWhitespace         Deviation = iota // stray whitespace around the packet
+	DeviationRootNamespace                       // explicit root namespace "/," before the data
+	DeviationNamespaceSeparator                  // root namespace without the "," separator
+	DeviationsAmount                             // amount of the deviation kinds
+)
+
+// Tolerate fixes quirks of older or buggy clients in the data string,
+// it returns the fixed string and the deviations found
+func Tolerate(data string) (string, []Deviation) {
+	var deviations []Deviation
+
+	if trimmed := strings.TrimSpace(data); trimmed != data {
+		data = trimmed
+		deviations = append(deviations, DeviationWhitespace)
+	}
+
+	if len(data) < 3 || data[0:1] != messageMSG || data[2] != '/' {
+		return data, deviations
+	}
+
+	switch rest := data[3:]; {
+	case strings.HasPrefix(rest, ","):
+		data = data[:2] + rest[1:]
+		deviations = append(deviations, DeviationRootNamespace)
+	case len(rest) > 0 && (rest[0] == '[' || (rest[0] >= '0' && rest[0] <= '9')):
+		data = data[:2] + rest
+		deviations = append(deviations, DeviationNamespaceSeparator)
+	}
+
+	return data, deviations
+}
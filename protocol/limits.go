@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrorEventNameTooLong = errors.New("event name too long")
+	ErrorTooManyArgs      = errors.New("too many arguments")
+	ErrorNestingTooDeep   = errors.New("arguments nesting too deep")
+	ErrorMalformedArgs    = errors.New("malformed arguments")
+)
+
+// Limits restricts decoded messages, zero field means no limit
+type Limits struct {
+	MaxEventNameLength int // maximum length of the event name in bytes
+	MaxArgs            int // maximum amount of the top-level arguments
+	MaxDepth           int // maximum nesting depth of the arguments
+}
+
+// DefaultLimits are used by Decode
+var DefaultLimits = Limits{MaxEventNameLength: 256, MaxArgs: 64, MaxDepth: 32}
+
+// checkEventName against the limits l
+func (l Limits) checkEventName(name string) error {
+	if l.MaxEventNameLength > 0 && len(name) > l.MaxEventNameLength {
+		return ErrorEventNameTooLong
+	}
+	return nil
+}
+
+// checkArgs validates comma-separated JSON arguments against the limits l
+func (l Limits) checkArgs(args string) error {
+	d := json.NewDecoder(strings.NewReader("[" + args + "]"))
+	depth, count := 0, 0
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return ErrorMalformedArgs
+		}
+
+		switch t {
+		case json.Delim('['), json.Delim('{'):
+			if depth == 1 {
+				count++
+			}
+			depth++
+			if l.MaxDepth > 0 && depth > l.MaxDepth+1 { // +1 for the enclosing array
+				return ErrorNestingTooDeep
+			}
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		default:
+			if depth == 1 {
+				count++
+			}
+		}
+
+		if l.MaxArgs > 0 && count > l.MaxArgs {
+			return ErrorTooManyArgs
+		}
+
+		if depth == 0 {
+			break
+		}
+	}
+
+	if _, err := d.Token(); err != io.EOF {
+		return ErrorMalformedArgs
+	}
+	return nil
+}
@@ -20,4 +20,5 @@ type Message struct {
 	EventName string
 	Args      string
 	Source    string
+	Namespace string // empty for the root namespace
 }
@@ -55,9 +55,25 @@ func Encode(m *Message) (string, error) {
 		return "", err
 	}
 
+	if m.Namespace != "" {
+		switch m.Type {
+		case MessageTypeEmpty:
+			if m.Args != "" {
+				return result + m.Namespace + "," + m.Args, nil
+			}
+			return result + m.Namespace, nil
+		case MessageTypeClose:
+			return messageCloseClient + m.Namespace, nil
+		case MessageTypeEmit, MessageTypeAckRequest, MessageTypeAckResponse:
+			result += m.Namespace + ","
+		}
+	}
+
 	switch m.Type {
-	case MessageTypeEmpty, MessageTypePing, MessageTypePong:
+	case MessageTypePing, MessageTypePong:
 		return result, nil
+	case MessageTypeEmpty: // connect packet may carry the data
+		return result + m.Args, nil
 	case MessageTypeAckRequest:
 		result += strconv.Itoa(m.AckID)
 	case MessageTypeAckResponse:
@@ -119,6 +135,20 @@ func getMessageType(data string) (int, error) {
 	return 0, ErrorWrongMessageType
 }
 
+// getNamespace extracts a namespace of the socket.io packet if present,
+// it returns the packet data without the namespace
+func getNamespace(data string) (namespace, restData string) {
+	if len(data) < 3 || data[0:1] != messageMSG || data[2] != '/' {
+		return "", data
+	}
+
+	end := strings.IndexByte(data, ',')
+	if end == -1 {
+		return data[2:], data[:2]
+	}
+	return data[2:end], data[:2] + data[end+1:]
+}
+
 // getAck extracts an id of the current packet if present
 func getAck(text string) (ackId int, restText string, err error) {
 	if len(text) < 4 {
@@ -171,8 +201,14 @@ func getMethod(text string) (event, restText string, err error) {
 	return text[start:end], text[rest : len(text)-1], nil
 }
 
-// Decode the given data string into a Message
-func Decode(data string) (*Message, error) {
+// Decode the given data string into a Message, using DefaultLimits
+func Decode(data string) (*Message, error) { return DecodeWithLimits(data, DefaultLimits) }
+
+// isArray returns true if text looks like a JSON array
+func isArray(text string) bool { return len(text) >= 2 && text[0] == '[' && text[len(text)-1] == ']' }
+
+// DecodeWithLimits decodes the given data string into a Message rejecting messages exceeding limits l
+func DecodeWithLimits(data string, l Limits) (*Message, error) {
 	var err error
 	m := &Message{Source: data}
 
@@ -181,8 +217,24 @@ func Decode(data string) (*Message, error) {
 		return nil, err
 	}
 
+	m.Namespace, data = getNamespace(data)
+	if m.Namespace == "/" { // root namespace is implied
+		return nil, ErrorWrongPacket
+	}
+	if err := l.checkEventName(m.Namespace); err != nil {
+		return nil, err
+	}
+
 	switch m.Type {
-	case MessageTypeUpgrade, MessageTypeClose, MessageTypePing, MessageTypePong, MessageTypeEmpty, MessageTypeBlank:
+	case MessageTypeUpgrade, MessageTypeClose, MessageTypePing, MessageTypePong, MessageTypeBlank:
+		return m, nil
+	case MessageTypeEmpty:
+		if len(data) > 2 {
+			m.Args = data[2:]
+			if err := l.checkArgs(m.Args); err != nil {
+				return nil, err
+			}
+		}
 		return m, nil
 	case MessageTypeOpen:
 		m.Args = data[1:]
@@ -195,7 +247,13 @@ func Decode(data string) (*Message, error) {
 		if err != nil {
 			return nil, err
 		}
+		if !isArray(rest) {
+			return nil, ErrorWrongPacket
+		}
 		m.Args = rest[1 : len(rest)-1]
+		if err := l.checkArgs(m.Args); err != nil {
+			return nil, err
+		}
 		return m, nil
 	}
 
@@ -204,10 +262,21 @@ func Decode(data string) (*Message, error) {
 		rest = data[2:]
 	}
 
+	if !isArray(rest) {
+		return nil, ErrorWrongPacket
+	}
+
 	m.EventName, m.Args, err = getMethod(rest)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := l.checkEventName(m.EventName); err != nil {
+		return nil, err
+	}
+	if err := l.checkArgs(m.Args); err != nil {
+		return nil, err
+	}
+
 	return m, nil
 }
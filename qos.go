@@ -0,0 +1,136 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+const (
+	DefaultQoSRetries  = 3
+	DefaultQoSInterval = 5 * time.Second
+
+	QoSEvent        = "sio:qos"         // carries the QoS1 emit, see EmitQoS
+	QoSReceiptEvent = "sio:qos:receipt" // confirms the QoS1 emit was received
+)
+
+var failedDeliveries synced.Counter
+
+// QoSParams represents delivery params of the QoS1 emits
+type QoSParams struct {
+	Retries  int           // retries after the first attempt
+	Interval time.Duration // time to wait for the receipt before retrying
+}
+
+// QoSMessage is a payload of the QoSEvent, the retries of the emit have the same ID
+type QoSMessage struct {
+	ID      int             `json:"id"`
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// QoSReceipt is a payload of the QoSReceiptEvent
+type QoSReceipt struct {
+	ID int `json:"id"`
+}
+
+// DefaultQoSParams returns QoS1 delivery params with default values
+func DefaultQoSParams() QoSParams {
+	return QoSParams{Retries: DefaultQoSRetries, Interval: DefaultQoSInterval}
+}
+
+// EmitQoS emits an event with the given name and payload with at least once delivery (QoS1).
+// The event is sent within the QoSEvent and retried with the given params until the peer answers with
+// the QoSReceiptEvent. This package sends receipts automatically on receiving, before calling the event handler,
+// other peers should do it themselves. Delivery is performed asynchronously, OnDeliveryFailed handler fires
+// if all attempts fail
+func (c *Channel) EmitQoS(name string, payload interface{}, params QoSParams) error {
+	if !c.IsAlive() || c.isDraining() {
+		return ErrorClosed
+	}
+
+	m := QoSMessage{ID: c.ack.nextId(), Name: name}
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		m.Payload = b
+	}
+
+	go func() {
+		for attempt := 0; attempt <= params.Retries; attempt++ {
+			if attempt > 0 {
+				c.retransmitted()
+			}
+			err := c.emitQoS(m, params.Interval)
+			if err == nil {
+				return
+			}
+
+			logging.Log().Debug("Channel.EmitQoS() attempt failed with err:", err)
+			if err != ErrorSendTimeout && err != ErrorQueueFull {
+				break
+			}
+		}
+
+		failedDeliveries.Inc()
+		c.events.deliveryFailed(c, name, payload)
+	}()
+	return nil
+}
+
+// emitQoS sends the QoS1 emit m once and waits for the receipt for the given interval
+func (c *Channel) emitQoS(m QoSMessage, interval time.Duration) error {
+	w := newAckWaiter(nil)
+	c.ack.register(m.ID, w)
+	defer c.ack.unregister(m.ID)
+
+	if err := c.Emit(QoSEvent, m); err != nil {
+		return err
+	}
+
+	select {
+	case <-w.ackC:
+		return nil
+	case <-c.events.after(interval):
+		return ErrorSendTimeout
+	case <-c.Done():
+		return ErrorClosed
+	}
+}
+
+// serveQoS registers the handlers sending the receipts of the QoS1 emits and receiving them
+func (e *event) serveQoS() {
+	e.On(QoSEvent, func(c *Channel, m QoSMessage) {
+		if err := c.Emit(QoSReceiptEvent, QoSReceipt{ID: m.ID}); err != nil {
+			logging.Log().Debug("event.serveQoS() can't send the receipt:", err)
+		}
+		e.process(c, &protocol.Message{Type: protocol.MessageTypeEmit, EventName: m.Name, Args: string(m.Payload)})
+	})
+	e.On(QoSReceiptEvent, func(c *Channel, r QoSReceipt) { c.ack.deliver(r.ID, "") })
+}
+
+// OnDeliveryFailed registers a handler called when a QoS1 emit was not confirmed by the peer
+func (e *event) OnDeliveryFailed(f func(c *Channel, name string, payload interface{})) {
+	e.handlersMu.Lock()
+	e.onDeliveryFailed = f
+	e.handlersMu.Unlock()
+}
+
+// deliveryFailed calls OnDeliveryFailed handler if it's registered
+func (e *event) deliveryFailed(c *Channel, name string, payload interface{}) {
+	e.handlersMu.RLock()
+	f := e.onDeliveryFailed
+	e.handlersMu.RUnlock()
+
+	if f != nil {
+		f(c, name, payload)
+	}
+}
+
+// CountFailedDeliveries returns an amount of QoS1 emits not confirmed by the peer
+func CountFailedDeliveries() int { return failedDeliveries.Get() }
@@ -0,0 +1,48 @@
+package gosocketio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestEmitQoSReceipt(t *testing.T) {
+	s := NewServer()
+	connected := make(chan *Channel, 1)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	failed := make(chan string, 1)
+	s.OnDeliveryFailed(func(c *Channel, name string, payload interface{}) { failed <- name })
+	host, port, stop := serve(t, s)
+	defer stop()
+
+	c, err := Dial(AddrWebsocket(host, port, false), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	received := make(chan string, 1)
+	c.On("notify", func(c *Channel, m string) { received <- m })
+	ch := <-connected
+
+	if err := ch.EmitQoS("notify", "hello", QoSParams{Retries: 1, Interval: 300 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-received:
+		if m != "hello" {
+			t.Fatalf("received %q", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("QoS1 emit is not received")
+	}
+	select {
+	case name := <-failed:
+		t.Fatal("delivery failed for", name)
+	case <-time.After(time.Second):
+	}
+
+	if _, err := ch.Ack("notify", "hello", 500*time.Millisecond); err != ErrorSendTimeout {
+		t.Fatal("handler without return value answered the ack request, err:", err)
+	}
+}
@@ -0,0 +1,191 @@
+package gosocketio
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// QualityLevel is a coarse grade of the connection quality
+type QualityLevel int
+
+const (
+	QualityGood QualityLevel = iota
+	QualityFair
+	QualityPoor
+)
+
+// String returns the name of the level
+func (l QualityLevel) String() string {
+	switch l {
+	case QualityGood:
+		return "good"
+	case QualityFair:
+		return "fair"
+	default:
+		return "poor"
+	}
+}
+
+const (
+	// QualityHalfLife is the time the penalty of a single missed heartbeat, retransmit or stall halves in
+	QualityHalfLife = 30 * time.Second
+
+	qualityGoodScore = 70 // minimum score of QualityGood
+	qualityFairScore = 40 // minimum score of QualityFair
+
+	rttPenaltyPerMs       = 0.1 // penalty of the smoothed RTT per millisecond
+	maxRTTPenalty         = 50
+	missedPenalty         = 20 // penalty of the missed heartbeat
+	retransmitPenalty     = 5
+	stallPenalty          = 10
+	rttSmoothing          = 0.125 // weight of the new RTT sample, as in TCP
+	missedHeartbeatFactor = 1.5   // the heartbeat is missed if it's later than the ping interval times the factor
+)
+
+// Quality is the connection quality of the Channel
+type Quality struct {
+	Score            float64 // from 0 (worst) to 100 (best)
+	Level            QualityLevel
+	RTT              time.Duration // smoothed round trip time of the heartbeats and acks, zero if not measured
+	MissedHeartbeats int           // pongs not received before the next ping, or late pings of the peer
+	Retransmits      int           // repeated QoS emits, RPC calls and packets rewritten after reconnecting
+	QueueStalls      int           // times the outgoing queue was filled up to a half
+}
+
+// quality collects the signals of the connection quality
+type quality struct {
+	Quality
+	penalty   float64   // decaying penalty of the missed heartbeats, retransmits and stalls
+	penaltyAt time.Time // moment the penalty was taken at
+	pingAt    time.Time // moment the unanswered ping was sent, or the last ping of the peer was received
+	stalled   bool      // true while the outgoing queue is filled up to a half
+	mu        sync.Mutex
+}
+
+// OnQualityChange registers a handler called when the quality level of the channel changes,
+// so e.g. the payload richness is adapted to the client. The score is updated on heartbeats and acks
+func (e *event) OnQualityChange(f func(c *Channel, q Quality)) {
+	e.handlersMu.Lock()
+	e.onQualityChange = f
+	e.handlersMu.Unlock()
+}
+
+// Quality returns the connection quality of the Channel
+func (c *Channel) Quality() Quality {
+	c.quality.mu.Lock()
+	defer c.quality.mu.Unlock()
+	c.scoreQuality()
+	return c.quality.Quality
+}
+
+// scoreQuality updates the score decaying the penalty, c.quality.mu must be held
+func (c *Channel) scoreQuality() {
+	q := &c.quality
+	now := c.events.now()
+	if !q.penaltyAt.IsZero() {
+		q.penalty *= math.Pow(0.5, float64(now.Sub(q.penaltyAt))/float64(QualityHalfLife))
+	}
+	q.penaltyAt = now
+
+	rttPenalty := math.Min(float64(q.RTT/time.Millisecond)*rttPenaltyPerMs, maxRTTPenalty)
+	q.Score = math.Max(0, 100-rttPenalty-q.penalty)
+	switch {
+	case q.Score >= qualityGoodScore:
+		q.Level = QualityGood
+	case q.Score >= qualityFairScore:
+		q.Level = QualityFair
+	default:
+		q.Level = QualityPoor
+	}
+}
+
+// updateQuality applies f to the quality signals, rescores them and calls the OnQualityChange handler
+// if the level changed
+func (c *Channel) updateQuality(f func(q *quality)) {
+	c.quality.mu.Lock()
+	level := c.quality.Level
+	f(&c.quality)
+	c.scoreQuality()
+	q := c.quality.Quality
+	c.quality.mu.Unlock()
+
+	if q.Level == level {
+		return
+	}
+	c.events.handlersMu.RLock()
+	handler := c.events.onQualityChange
+	c.events.handlersMu.RUnlock()
+	if handler != nil {
+		go handler(c, q)
+	}
+}
+
+// measuredRTT adds the round trip time sample
+func (c *Channel) measuredRTT(rtt time.Duration) {
+	c.updateQuality(func(q *quality) {
+		if q.RTT == 0 {
+			q.RTT = rtt
+			return
+		}
+		q.RTT += time.Duration(rttSmoothing * float64(rtt-q.RTT))
+	})
+}
+
+// pingSent marks the ping sent by the client side, the previous one is missed if it's not answered yet
+func (c *Channel) pingSent() {
+	c.updateQuality(func(q *quality) {
+		if !q.pingAt.IsZero() {
+			q.MissedHeartbeats++
+			q.penalty += missedPenalty
+		}
+		q.pingAt = c.events.now()
+	})
+}
+
+// pongReceived measures the round trip time of the ping answered on the client side
+func (c *Channel) pongReceived() {
+	c.quality.mu.Lock()
+	sent := c.quality.pingAt
+	c.quality.pingAt = time.Time{}
+	c.quality.mu.Unlock()
+	if !sent.IsZero() {
+		c.measuredRTT(c.events.since(sent))
+	}
+}
+
+// pingReceived checks the ping of the peer on the server side, it's missed if it's late
+func (c *Channel) pingReceived() {
+	interval, _ := c.PingParams()
+	c.updateQuality(func(q *quality) {
+		now := c.events.now()
+		if !q.pingAt.IsZero() && now.Sub(q.pingAt) > time.Duration(missedHeartbeatFactor*float64(interval)) {
+			q.MissedHeartbeats++
+			q.penalty += missedPenalty
+		}
+		q.pingAt = now
+	})
+}
+
+// retransmitted counts the repeated packet
+func (c *Channel) retransmitted() {
+	c.updateQuality(func(q *quality) {
+		q.Retransmits++
+		q.penalty += retransmitPenalty
+	})
+}
+
+// queueFilled tracks the state of the outgoing queue, the stall is counted when it gets filled up to a half
+func (c *Channel) queueFilled(stalled bool) {
+	c.quality.mu.Lock()
+	changed := c.quality.stalled != stalled
+	c.quality.stalled = stalled
+	c.quality.mu.Unlock()
+	if !changed || !stalled {
+		return
+	}
+	c.updateQuality(func(q *quality) {
+		q.QueueStalls++
+		q.penalty += stallPenalty
+	})
+}
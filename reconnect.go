@@ -0,0 +1,216 @@
+package gosocketio
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// ReconnectRequestEvent is a control event asking the client to reconnect
+const ReconnectRequestEvent = "reconnectRequest"
+
+// ReconnectRequest is a payload of the ReconnectRequestEvent
+type ReconnectRequest struct {
+	Delay int64  `json:"delay"`           // milliseconds to wait before reconnecting
+	URL   string `json:"url,omitempty"`   // address to reconnect to, the current one if empty
+	Token string `json:"token,omitempty"` // resumption token to reconnect with, see Channel.Migrate
+}
+
+// RequestReconnect asks the client to reconnect after delay to the targetURL, or to the same address if it's empty.
+// The Go client does it natively, other clients should handle the ReconnectRequestEvent
+func (c *Channel) RequestReconnect(delay time.Duration, targetURL string) error {
+	return c.Emit(ReconnectRequestEvent, ReconnectRequest{Delay: int64(delay / time.Millisecond), URL: targetURL})
+}
+
+// reconnectAfter delay to the addr, or to the current address if it's empty, with the resumption token if it's set
+func (c *Client) reconnectAfter(delay time.Duration, addr, token string) {
+	c.event.sleep(delay)
+	if token != "" {
+		if addr == "" {
+			c.mu.Lock()
+			addr = c.addr
+			c.mu.Unlock()
+		}
+		addr = withResumeToken(addr, token)
+	}
+	if err := c.Reconnect(addr); err != nil {
+		logging.Log().Debug("Client.reconnectAfter(): failed to reconnect:", err)
+	}
+}
+
+// Reconnect the client to the addr, or to the current address if it's empty.
+// The Channel keeps its queued messages, the old connection is closed after the new one is established.
+// The client asking for the ordered delivery resumes the session reconnecting to the current address.
+// OnConnection and OnReconnect handlers are called
+func (c *Client) Reconnect(addr string) error {
+	c.mu.Lock()
+	if addr == "" {
+		addr = c.addr
+		if token := c.resumeToken(); c.orderedIn != nil && token != "" {
+			addr = withResumeToken(addr, token) // the session keeps the messages not acknowledged
+		}
+	}
+	c.addr = addr
+	c.mu.Unlock()
+
+	conn, err := c.hooks.connect(c.tr, addr)
+	if err != nil {
+		return err
+	}
+
+	if !c.IsAlive() {
+		conn.Close()
+		return ErrorClosed
+	}
+
+	c.reconnMu.Lock()
+	onLost, onReconnected := c.onLost, c.onReconnected
+	manual := !c.reconnecting
+	c.reconnMu.Unlock()
+
+	if manual {
+		if onLost != nil { // reconnectLoop has already reported it
+			onLost()
+		}
+		c.holdWrites() // onReconnected writes into the new connection before the queued messages
+		defer c.resumeWrites()
+	}
+
+	c.Channel.reconnect(c.event, conn)
+	c.connected()
+	c.event.callHandler(c.Channel, OnReconnect)
+	if onReconnected != nil {
+		onReconnected()
+	}
+	return nil
+}
+
+// ReconnectionParams configures automatic client reconnection when the connection is lost
+type ReconnectionParams struct {
+	Attempts int           // maximum amount of attempts, zero for unlimited
+	MinDelay time.Duration // delay before the first attempt, doubled for each next one
+	MaxDelay time.Duration // maximum delay between attempts
+	Jitter   float64       // randomization factor of the delay from 0 to 1
+}
+
+// DefaultReconnectionParams returns the reconnection params matching socket.io JS client defaults
+func DefaultReconnectionParams() ReconnectionParams {
+	return ReconnectionParams{MinDelay: time.Second, MaxDelay: 5 * time.Second, Jitter: 0.5}
+}
+
+// delay before the given attempt starting from zero
+func (p ReconnectionParams) delay(attempt int) time.Duration {
+	d := p.MinDelay
+	for i := 0; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// SetReconnection enables automatic reconnection with the given params, outgoing messages are held
+// until the connection is restored. If all attempts fail the client is closed
+func (c *Client) SetReconnection(p ReconnectionParams) {
+	c.reconnMu.Lock()
+	c.reconnection = &p
+	c.reconnMu.Unlock()
+}
+
+// startReconnecting if it's enabled, it returns true if the lost connection conn is being replaced
+func (c *Client) startReconnecting(conn transport.Connection) bool {
+	c.reconnMu.Lock()
+	defer c.reconnMu.Unlock()
+
+	if c.reconnecting || c.connection() != conn {
+		return true
+	}
+	if c.reconnection == nil || !c.IsAlive() {
+		return false
+	}
+
+	c.reconnecting = true
+	c.holdWrites()
+	go c.reconnectLoop(*c.reconnection)
+	return true
+}
+
+// reconnectLoop tries to reconnect with the params p, closes the client if all attempts failed
+func (c *Client) reconnectLoop(p ReconnectionParams) {
+	defer func() {
+		c.reconnMu.Lock()
+		c.reconnecting = false
+		c.reconnMu.Unlock()
+		c.resumeWrites()
+	}()
+
+	c.lostConnection()
+	for attempt := 0; p.Attempts == 0 || attempt < p.Attempts; attempt++ {
+		c.event.sleep(p.delay(attempt))
+		if !c.IsAlive() {
+			return
+		}
+
+		err := c.Reconnect("")
+		c.hooks.reconnectAttempt(attempt+1, err)
+		if err == nil {
+			return
+		}
+		logging.Log().Debug("Client.reconnectLoop() attempt failed:", err)
+	}
+
+	c.disconnected(DisconnectTransportError, 0, "")
+	c.Close()
+}
+
+// lostConnection calls the connection lost hook if it's set
+func (c *Client) lostConnection() {
+	c.reconnMu.Lock()
+	f := c.onLost
+	c.reconnMu.Unlock()
+
+	if f != nil {
+		f()
+	}
+}
+
+// holdWrites makes outLoop to wait for resumeWrites
+func (c *Channel) holdWrites() {
+	c.holdMu.Lock()
+	if c.holdC == nil {
+		c.holdC = make(chan struct{})
+	}
+	c.holdMu.Unlock()
+}
+
+// resumeWrites held by holdWrites
+func (c *Channel) resumeWrites() {
+	c.holdMu.Lock()
+	if c.holdC != nil {
+		close(c.holdC)
+		c.holdC = nil
+	}
+	c.holdMu.Unlock()
+}
+
+// waitWrites blocks while writes are held
+func (c *Channel) waitWrites() {
+	c.holdMu.Lock()
+	holdC := c.holdC
+	c.holdMu.Unlock()
+
+	if holdC != nil {
+		<-holdC
+	}
+}
+
+// connectionLost returns true if the lost connection conn is being replaced
+func (c *Channel) connectionLost(conn transport.Connection) bool {
+	return c.lost != nil && c.lost(conn)
+}
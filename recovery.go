@@ -0,0 +1,126 @@
+package gosocketio
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/synced"
+)
+
+// RecoveryAction is what happens after the event handler returned an error or panicked
+type RecoveryAction int
+
+const (
+	RecoveryIgnore     RecoveryAction = iota // nothing, the default for handler errors
+	RecoveryLog                              // log the error, the default for handler panics
+	RecoveryEmitError                        // log and send the error event with ServerError of 500 code to the peer
+	RecoveryDisconnect                       // log and close the Channel
+	RecoveryQuarantine                       // log and stop dispatching the event to the handler for this Channel
+	recoveryActionsAmount
+)
+
+var recoveries [recoveryActionsAmount]synced.Counter
+
+// CountRecoveries returns an amount of the handler errors and panics the given action was taken for
+func CountRecoveries(action RecoveryAction) int {
+	if action < 0 || action >= recoveryActionsAmount {
+		return 0
+	}
+	return recoveries[action].Get()
+}
+
+// panicked returns whether err is the recovered handler panic
+func panicked(err error) bool {
+	_, ok := err.(*HandlerPanic)
+	return ok
+}
+
+// HandlerPanic is an error of the recovered event handler panic
+type HandlerPanic struct {
+	Value interface{} // value passed to panic()
+	Stack []byte      // stack trace of the panic
+}
+
+// Error makes HandlerPanic to implement error interface
+func (p *HandlerPanic) Error() string { return fmt.Sprintf("handler panic: %v", p.Value) }
+
+// SetRecoveryPolicy sets the actions taken after the event handler returned an error (onError)
+// or panicked (onPanic). Panics are always recovered, by default errors are ignored and panics are logged
+func (e *event) SetRecoveryPolicy(onError, onPanic RecoveryAction) {
+	e.handlersMu.Lock()
+	e.onHandlerError, e.onHandlerPanic = onError, onPanic
+	e.handlersMu.Unlock()
+}
+
+// safeCall calls the handler recovering from panic, err is the panic or the error returned by the handler
+func (h *handler) safeCall(c *Channel, arguments interface{}) (result []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &HandlerPanic{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	result = h.call(c, arguments)
+	return result, resultError(result)
+}
+
+// recover takes the policy action after the handler of the event with the given name failed with err on channel c
+func (e *event) recover(c *Channel, name string, err error) {
+	if err == nil {
+		return
+	}
+
+	e.handlersMu.RLock()
+	action := e.onHandlerError
+	if _, ok := err.(*HandlerPanic); ok {
+		action = e.onHandlerPanic
+	}
+	e.handlersMu.RUnlock()
+
+	recoveries[action].Inc()
+	if action == RecoveryIgnore {
+		return
+	}
+
+	logging.Log().Warnf("event.recover(): handler of %q failed on channel %s: %v", name, c.Id(), err)
+	switch action {
+	case RecoveryEmitError:
+		c.EmitError(http.StatusInternalServerError, err.Error(), name)
+	case RecoveryDisconnect:
+		c.disconnected(DisconnectInternalError, 0, "")
+		c.Close()
+	case RecoveryQuarantine:
+		c.quarantine(name)
+	}
+}
+
+// quarantine the event with the given name, it's not dispatched to the handler for the Channel anymore
+func (c *Channel) quarantine(name string) {
+	c.quarantineMu.Lock()
+	if c.quarantined == nil {
+		c.quarantined = make(map[string]struct{})
+	}
+	c.quarantined[name] = struct{}{}
+	c.quarantineMu.Unlock()
+}
+
+// copyQuarantine quarantines the events of c on the Channel to replacing it at the transport upgrade
+func (c *Channel) copyQuarantine(to *Channel) {
+	c.quarantineMu.RLock()
+	defer c.quarantineMu.RUnlock()
+	for name := range c.quarantined {
+		to.quarantine(name)
+	}
+}
+
+// IsQuarantined returns true if the event with the given name is not dispatched to the handler for the Channel
+// because of the RecoveryQuarantine action
+func (c *Channel) IsQuarantined(name string) bool {
+	c.quarantineMu.RLock()
+	defer c.quarantineMu.RUnlock()
+	_, ok := c.quarantined[name]
+	return ok
+}
@@ -0,0 +1,148 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+var rejectedReplays synced.Counter
+
+// CountRejectedReplays returns an amount of incoming messages dropped as replayed, too old or not numbered
+func CountRejectedReplays() int { return rejectedReplays.Get() }
+
+// numberedPayload is the payload of the numbered message
+type numberedPayload struct {
+	Seq  uint64          `json:"seq"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// replay numbers the outgoing messages and tracks the sequence numbers of the incoming ones
+type replay struct {
+	sent     uint64 // sequence number of the last outgoing message
+	top      uint64 // highest sequence number received
+	seen     []bool // received sequence numbers within the window, indexed by seq modulo its size
+	rejected int
+	mu       sync.Mutex
+}
+
+// SetReplayWindow enables the replay protection of new channels with the window of the given size, zero disables it.
+// The payload of each event, ack and ack response is replaced with {"seq":..., "data": payload} object numbering
+// the messages of the channel. The incoming message is dropped if its number was already received, or it's older
+// than the size latest numbers, or it isn't numbered. Both sides must set the window. The window protects
+// against the replays by the intermediaries only with the messages signed with the per-session key, see
+// SetSigningKey, out of order delivery of the prioritized messages requires the window of the queue size
+func (e *event) SetReplayWindow(size int) {
+	e.handlersMu.Lock()
+	e.replayWindow = size
+	e.handlersMu.Unlock()
+}
+
+// getReplayWindow returns the size of the replay window, e may be nil
+func (e *event) getReplayWindow() int {
+	if e == nil {
+		return 0
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.replayWindow
+}
+
+// RejectedReplays returns an amount of incoming messages dropped by the replay protection of the Channel
+func (c *Channel) RejectedReplays() int {
+	r := c.replayWindow()
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rejected
+}
+
+// replayWindow returns the sequence numbers of the Channel creating them on the first use,
+// nil if the replay protection is disabled
+func (c *Channel) replayWindow() *replay {
+	c.replayMu.Lock()
+	defer c.replayMu.Unlock()
+	if c.replay != nil {
+		return c.replay
+	}
+
+	size := c.events.getReplayWindow()
+	if size <= 0 {
+		return nil
+	}
+	c.replay = &replay{seen: make([]bool, size)}
+	return c.replay
+}
+
+// number returns the copy of message m with the payload numbered by the next sequence number
+func (r *replay) number(m *protocol.Message) *protocol.Message {
+	r.mu.Lock()
+	r.sent++
+	seq := strconv.FormatUint(r.sent, 10)
+	r.mu.Unlock()
+
+	args := `{"seq":` + seq + `}`
+	if m.Args != "" {
+		args = `{"seq":` + seq + `,"data":` + m.Args + `}`
+	}
+
+	numberedM := *m
+	numberedM.Args = args
+	return &numberedM
+}
+
+// accept the incoming sequence number seq, it returns false if it's replayed or too old
+func (r *replay) accept(seq uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := uint64(len(r.seen))
+	switch {
+	case seq == 0 || seq+size <= r.top:
+		r.rejected++
+		return false
+	case seq > r.top:
+		from := r.top + 1
+		if seq-from >= size {
+			from = seq - size + 1
+		}
+		for s := from; s < seq; s++ {
+			r.seen[s%size] = false
+		}
+		r.top = seq
+	case r.seen[seq%size]:
+		r.rejected++
+		return false
+	}
+	r.seen[seq%size] = true
+	return true
+}
+
+// unnumber checks the sequence number of the incoming message m replacing its payload with the numbered one,
+// it returns false if m must be dropped
+func (c *Channel) unnumber(m *protocol.Message) bool {
+	r := c.replayWindow()
+	if r == nil || !signed(m) {
+		return true
+	}
+
+	var payload numberedPayload
+	if err := json.Unmarshal([]byte(m.Args), &payload); err != nil {
+		r.mu.Lock()
+		r.rejected++
+		r.mu.Unlock()
+	} else if r.accept(payload.Seq) {
+		m.Args = string(payload.Data)
+		return true
+	}
+
+	rejectedReplays.Inc()
+	logging.Log().Warnf("Channel.unnumber() rejected %q #%d on %s", m.EventName, payload.Seq, c.Id())
+	return false
+}
@@ -0,0 +1,63 @@
+package gosocketio
+
+import (
+	"testing"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+func TestReplayWindow(t *testing.T) {
+	r := &replay{seen: make([]bool, 4)}
+	for _, step := range []struct {
+		seq      uint64
+		accepted bool
+	}{
+		{0, false}, // not numbered
+		{1, true},
+		{1, false}, // replayed
+		{3, true},
+		{2, true}, // reordered within the window
+		{3, false},
+		{6, true},  // wraps around the window, 3 is its oldest number
+		{2, false}, // older than the window
+		{5, true},
+		{5, false},
+		{4, true},
+		{3, false}, // replayed within the window
+		{7, true},  // reuses the slot of 3
+		{3, false}, // older than the window now
+		{100, true},
+		{96, false},
+		{97, true},
+	} {
+		if accepted := r.accept(step.seq); accepted != step.accepted {
+			t.Fatalf("seq %d accepted: %v, expected %v", step.seq, accepted, step.accepted)
+		}
+	}
+}
+
+func TestUnnumberDropsReplays(t *testing.T) {
+	e := &event{}
+	e.init()
+	e.SetReplayWindow(8)
+	c := &Channel{conn: stubConnection{}, events: e}
+	c.init()
+
+	numbered := c.replayWindow().number(&protocol.Message{Type: protocol.MessageTypeEmit, EventName: "m", Args: `"a"`})
+	for i, accepted := range []bool{true, false} {
+		m := *numbered
+		if c.unnumber(&m) != accepted {
+			t.Fatalf("delivery %d of the numbered message accepted: %v", i, !accepted)
+		}
+		if accepted && m.Args != `"a"` {
+			t.Fatal("payload of the numbered message:", m.Args)
+		}
+	}
+	unnumbered := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: "m", Args: `"b"`}
+	if c.unnumber(unnumbered) {
+		t.Fatal("message without the number is accepted")
+	}
+	if c.RejectedReplays() != 2 {
+		t.Fatal("rejected replays:", c.RejectedReplays())
+	}
+}
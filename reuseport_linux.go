@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package gosocketio
+
+import (
+	"context"
+	"net"
+	"syscall"
+)
+
+// soReusePort is SO_REUSEPORT socket option, missing in syscall package
+const soReusePort = 0xf
+
+// ListenReusePort listens on the TCP network address addr with SO_REUSEPORT set,
+// so several processes can accept on the same port during restart
+func ListenReusePort(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) {
+				sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+			}); err != nil {
+				return err
+			}
+			return sockErr
+		},
+	}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
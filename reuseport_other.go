@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package gosocketio
+
+import "net"
+
+// ListenReusePort is not supported on this platform, use InheritListener instead
+func ListenReusePort(addr string) (net.Listener, error) { return nil, ErrorReusePortNotSupported }
@@ -0,0 +1,310 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+const (
+	// RoomStateEvent carries the full state of the room, see Server.RoomState
+	RoomStateEvent = "sio:state"
+	// RoomStatePatchEvent carries the JSON merge patch (RFC 7396) of the room state
+	RoomStatePatchEvent = "sio:state:patch"
+	// RoomStateSyncEvent asks the server to send the full state of the room again, e.g. after a missed patch
+	RoomStateSyncEvent = "sio:state:sync"
+)
+
+var ErrorNotRoomMember = errors.New("channel is not joined to the room")
+
+// RoomStateUpdate is a payload of the RoomStateEvent and RoomStatePatchEvent
+type RoomStateUpdate struct {
+	Room    string          `json:"room"`
+	Version int             `json:"version"`         // incremented by each change
+	State   json.RawMessage `json:"state,omitempty"` // full state of the RoomStateEvent
+	Patch   json.RawMessage `json:"patch,omitempty"` // merge patch of the RoomStatePatchEvent to the previous version
+}
+
+// RoomStateSync is a payload of the RoomStateSyncEvent
+type RoomStateSync struct {
+	Room string `json:"room"`
+}
+
+// RoomState is the JSON document shared by the channels of the room, e.g. the presence list or the lobby
+// state. The channels get the full state on join and the merge patches on each change. The null values
+// can't be told from the deleted ones in the merge patch, so they shouldn't be used in the state
+type RoomState struct {
+	server  *Server
+	room    string
+	state   interface{} // decoded JSON
+	version int
+	mu      sync.Mutex
+}
+
+// RoomState returns the shared state of the room creating the empty one, see RoomState type.
+// The room is of the global tenant, see Tenant.RoomState. It fails with ErrorRoomName if the name
+// contains the tenant separator
+func (s *Server) RoomState(room string) (*RoomState, error) {
+	if !validRoom(room) {
+		return nil, ErrorRoomName
+	}
+	return s.roomStateOf(room), nil
+}
+
+// roomStateOf returns the shared state of the room with the given internal name creating the empty one
+func (s *Server) roomStateOf(room string) *RoomState {
+	s.roomStatesMu.Lock()
+	defer s.roomStatesMu.Unlock()
+	if s.roomStates == nil {
+		s.roomStates = make(map[string]*RoomState)
+	}
+	rs, ok := s.roomStates[room]
+	if !ok {
+		rs = &RoomState{server: s, room: room, state: map[string]interface{}{}}
+		s.roomStates[room] = rs
+	}
+	return rs
+}
+
+// DeleteRoomState discards the shared state of the room of the global tenant, the channels keep their copies
+func (s *Server) DeleteRoomState(room string) {
+	if validRoom(room) {
+		s.deleteRoomState(room)
+	}
+}
+
+// deleteRoomState discards the shared state of the room with the given internal name
+func (s *Server) deleteRoomState(room string) {
+	s.roomStatesMu.Lock()
+	delete(s.roomStates, room)
+	s.roomStatesMu.Unlock()
+}
+
+// roomState returns the shared state of the room, nil if there is none
+func (s *Server) roomState(room string) *RoomState {
+	s.roomStatesMu.Lock()
+	defer s.roomStatesMu.Unlock()
+	return s.roomStates[room]
+}
+
+// State returns the current state and its version
+func (rs *RoomState) State() (json.RawMessage, int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	b, _ := json.Marshal(rs.state)
+	return b, rs.version
+}
+
+// UpdateState replaces the state with v and sends the merge patch to the channels of the room,
+// nothing is sent if the state isn't changed
+func (rs *RoomState) UpdateState(v interface{}) error {
+	state, err := toJSONValue(v)
+	if err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	patch := mergeDiff(rs.state, state)
+	if patch == nil {
+		return nil
+	}
+	rs.state = state
+	return rs.changed(patch)
+}
+
+// Patch applies the JSON merge patch to the state and sends the change to the channels of the room,
+// nothing is sent if the state isn't changed
+func (rs *RoomState) Patch(patch json.RawMessage) error {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return err
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	previous, err := toJSONValue(rs.state) // mergePatch changes the objects in place
+	if err != nil {
+		return err
+	}
+	patched := mergePatch(previous, p)
+	diff := mergeDiff(rs.state, patched)
+	if diff == nil {
+		return nil
+	}
+	rs.state = patched
+	return rs.changed(diff)
+}
+
+// changed increments the version and sends the patch to the channels of the room, rs.mu must be held
+func (rs *RoomState) changed(patch interface{}) error {
+	b, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	rs.version++
+	for _, c := range rs.server.list(rs.room) {
+		update := RoomStateUpdate{Room: unscopedRoom(c.tenant, rs.room), Version: rs.version, Patch: b}
+		if err := c.Emit(RoomStatePatchEvent, update); err != nil {
+			logging.Log().Warnf("RoomState.changed() can't send the patch to %s: %v", c.Id(), err)
+		}
+	}
+	return nil
+}
+
+// sendTo sends the full state to the channel c with the given room name
+func (rs *RoomState) sendTo(c *Channel, room string) error {
+	state, version := rs.State()
+	return c.Emit(RoomStateEvent, RoomStateUpdate{Room: room, Version: version, State: state})
+}
+
+// sendRoomState sends the full state of the room to the channel c if the room has the state
+func (s *Server) sendRoomState(c *Channel, room string) {
+	if rs := s.roomState(scopedRoom(c.tenant, room)); rs != nil {
+		if err := rs.sendTo(c, room); err != nil {
+			logging.Log().Warnf("Server.sendRoomState() can't send the state to %s: %v", c.Id(), err)
+		}
+	}
+}
+
+// serveRoomState answers the RoomStateSyncEvent of the room members with the full state
+func (s *Server) serveRoomState() {
+	s.On(RoomStateSyncEvent, func(c *Channel, r RoomStateSync) {
+		for _, room := range c.Rooms() {
+			if room == r.Room {
+				s.sendRoomState(c, room)
+				return
+			}
+		}
+		logging.Log().Debug("Server.serveRoomState() sync of the room not joined:", r.Room)
+	})
+}
+
+// OnRoomState registers a handler called with the full state of the room each time it's received
+// or patched. The client keeps the states of the rooms and asks the server for the full state
+// if it misses a patch
+func (c *Client) OnRoomState(f func(c *Channel, room string, state json.RawMessage)) {
+	var (
+		states   = make(map[string]interface{})
+		versions = make(map[string]int)
+		mu       sync.Mutex
+	)
+
+	c.On(RoomStateEvent, func(ch *Channel, u RoomStateUpdate) {
+		var state interface{}
+		if err := json.Unmarshal(u.State, &state); err != nil {
+			logging.Log().Warn("Client.OnRoomState() can't decode the state:", err)
+			return
+		}
+		mu.Lock()
+		if u.Version < versions[u.Room] {
+			mu.Unlock()
+			return
+		}
+		states[u.Room], versions[u.Room] = state, u.Version
+		mu.Unlock()
+		f(ch, u.Room, u.State)
+	})
+
+	c.On(RoomStatePatchEvent, func(ch *Channel, u RoomStateUpdate) {
+		var patch interface{}
+		if err := json.Unmarshal(u.Patch, &patch); err != nil {
+			logging.Log().Warn("Client.OnRoomState() can't decode the patch:", err)
+			return
+		}
+		mu.Lock()
+		state, ok := states[u.Room]
+		if !ok || u.Version != versions[u.Room]+1 {
+			mu.Unlock()
+			if !ok || u.Version > versions[u.Room] {
+				ch.Emit(RoomStateSyncEvent, RoomStateSync{Room: u.Room})
+			}
+			return
+		}
+		state = mergePatch(state, patch)
+		states[u.Room], versions[u.Room] = state, u.Version
+		b, err := json.Marshal(state)
+		mu.Unlock()
+		if err == nil {
+			f(ch, u.Room, b)
+		}
+	})
+}
+
+// toJSONValue returns v decoded from its JSON
+func toJSONValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	err = json.Unmarshal(b, &decoded)
+	return decoded, err
+}
+
+// ApplyMergePatch returns the JSON document doc patched with the JSON merge patch (RFC 7396)
+func ApplyMergePatch(doc, patch json.RawMessage) (json.RawMessage, error) {
+	var d, p interface{}
+	if len(doc) > 0 {
+		if err := json.Unmarshal(doc, &d); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(d, p))
+}
+
+// mergePatch returns the target patched as RFC 7396 defines, the target objects are modified
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// mergeDiff returns the merge patch turning the from value into the to one, nil if they're equal
+func mergeDiff(from, to interface{}) interface{} {
+	f, fromObject := from.(map[string]interface{})
+	t, toObject := to.(map[string]interface{})
+	if !fromObject || !toObject {
+		if reflect.DeepEqual(from, to) {
+			return nil
+		}
+		return to
+	}
+
+	patch := make(map[string]interface{})
+	for k := range f {
+		if _, ok := t[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, v := range t {
+		if old, ok := f[k]; !ok {
+			patch[k] = v
+		} else if d := mergeDiff(old, v); d != nil {
+			patch[k] = d
+		}
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	return patch
+}
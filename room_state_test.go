@@ -0,0 +1,31 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRoomStatePatchWithoutChange(t *testing.T) {
+	rs, err := NewServer().RoomState("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		patch, state string
+		version      int
+	}{
+		{patch: `{"a":{"b":1}}`, state: `{"a":{"b":1}}`, version: 1},
+		{patch: `{"a":{"b":1}}`, state: `{"a":{"b":1}}`, version: 1},
+		{patch: `{"c":null}`, state: `{"a":{"b":1}}`, version: 1}, // removes the absent member
+		{patch: `{"a":{"b":2}}`, state: `{"a":{"b":2}}`, version: 2},
+		{patch: `{"a":null}`, state: `{}`, version: 3},
+	} {
+		if err := rs.Patch(json.RawMessage(step.patch)); err != nil {
+			t.Fatal(err)
+		}
+		if state, version := rs.State(); string(state) != step.state || version != step.version {
+			t.Fatalf("after the patch %s: state %s version %d, expected %s version %d",
+				step.patch, state, version, step.state, step.version)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package gosocketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+const (
+	DefaultCallTimeout = 10 * time.Second
+	DefaultCallBackoff = 500 * time.Millisecond
+)
+
+var (
+	ErrorCallHandlerSignature = errors.New("f should be func(c *Channel[, req T]) (R, error)")
+	ErrorMalformedCallResult  = errors.New("malformed call result")
+
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	channelType = reflect.TypeOf((*Channel)(nil))
+	callIDs     uint64
+)
+
+// CallError is a typed error returned by the remote method, handlers return it to set the code and data,
+// other errors are passed with the http.StatusInternalServerError code
+type CallError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error makes CallError to implement error interface
+func (e *CallError) Error() string { return strconv.Itoa(e.Code) + ": " + e.Message }
+
+// NewCallError returns the CallError with data marshaled to JSON, data may be nil
+func NewCallError(code int, message string, data interface{}) *CallError {
+	e := &CallError{Code: code, Message: message}
+	if data != nil {
+		e.Data, _ = json.Marshal(data)
+	}
+	return e
+}
+
+// callRequest is a payload of the call ack request
+type callRequest struct {
+	ID     string          `json:"id"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// callResponse is a payload of the call ack response
+type callResponse struct {
+	ID     string          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *CallError      `json:"error,omitempty"`
+}
+
+// CallOptions configures the remote method call
+type CallOptions struct {
+	Timeout time.Duration // timeout of each attempt, DefaultCallTimeout if zero
+	Retries int           // attempts after the first one timed out, the method should be idempotent
+	Backoff time.Duration // delay before the first retry, doubled for each next one
+}
+
+// DefaultCallOptions are used by Call
+var DefaultCallOptions = CallOptions{Timeout: DefaultCallTimeout, Backoff: DefaultCallBackoff}
+
+// OnCall registers the remote method handler f, it should be func(c *Channel, req T) (R, error)
+// or func(c *Channel) (R, error). Request params are decoded into T, the result R is returned to the caller,
+// returned error is passed to the caller as CallError
+func (e *event) OnCall(method string, f interface{}) error {
+	fVal := reflect.ValueOf(f)
+	if fVal.Kind() != reflect.Func || fVal.IsNil() {
+		return ErrorHandlerIsNotFunc
+	}
+
+	fType := fVal.Type()
+	if fType.NumIn() < 1 || fType.NumIn() > 2 || fType.In(0) != channelType ||
+		fType.NumOut() != 2 || fType.Out(1) != errorType {
+		return ErrorCallHandlerSignature
+	}
+
+	err := e.On(method, func(c *Channel, req callRequest) callResponse {
+		args := []reflect.Value{reflect.ValueOf(c)}
+		if fType.NumIn() == 2 {
+			params := reflect.New(fType.In(1))
+			if len(req.Params) > 0 {
+				if err := json.Unmarshal(req.Params, params.Interface()); err != nil {
+					return callResponse{ID: req.ID, Error: NewCallError(http.StatusBadRequest, err.Error(), nil)}
+				}
+			}
+			args = append(args, params.Elem())
+		}
+
+		out := fVal.Call(args)
+		if err, _ := out[1].Interface().(error); err != nil {
+			callErr, ok := err.(*CallError)
+			if !ok {
+				callErr = NewCallError(http.StatusInternalServerError, err.Error(), nil)
+			}
+			return callResponse{ID: req.ID, Error: callErr}
+		}
+
+		result, err := json.Marshal(out[0].Interface())
+		if err != nil {
+			return callResponse{ID: req.ID, Error: NewCallError(http.StatusInternalServerError, err.Error(), nil)}
+		}
+		return callResponse{ID: req.ID, Result: result}
+	})
+	if err != nil {
+		return err
+	}
+
+	e.handlersMu.Lock()
+	if h, ok := e.handlers[method]; ok {
+		h.rpc, h.rpcResult = true, fType.Out(0)
+		if fType.NumIn() == 2 {
+			h.rpcParams = fType.In(1)
+		}
+	}
+	e.handlersMu.Unlock()
+	return nil
+}
+
+// Call the remote method registered with OnCall on the other side, passing req and decoding the result into resp
+// (it may be nil to ignore the result). The method error is returned as *CallError, see CallWith for the options
+func (c *Channel) Call(ctx context.Context, method string, req, resp interface{}) error {
+	return c.CallWith(ctx, DefaultCallOptions, method, req, resp)
+}
+
+// CallWith acts like Call with the given options, the attempts timed out are retried with the same correlation id
+func (c *Channel) CallWith(ctx context.Context, opts CallOptions, method string, req, resp interface{}) error {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultCallTimeout
+	}
+
+	request := callRequest{ID: c.Id() + "-" + strconv.FormatUint(atomic.AddUint64(&callIDs, 1), 10)}
+	if req != nil {
+		params, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		request.Params = params
+	}
+
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		m := &protocol.Message{Type: protocol.MessageTypeAckRequest, EventName: method}
+		result, err := c.ackContext(ctx, m, request, opts.Timeout)
+		if err == ErrorSendTimeout && attempt < opts.Retries {
+			select {
+			case <-c.events.after(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			backoff *= 2
+			c.retransmitted()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return decodeCallResponse(request.ID, result, resp)
+	}
+}
+
+// decodeCallResponse of the request with the given correlation id into resp
+func decodeCallResponse(id, result string, resp interface{}) error {
+	var response callResponse
+	if err := json.Unmarshal([]byte(result), &response); err != nil || response.ID != id {
+		return ErrorMalformedCallResult
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if resp == nil || len(response.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response.Result, resp)
+}
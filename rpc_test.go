@@ -0,0 +1,27 @@
+package gosocketio
+
+import "testing"
+
+func TestOnCallSignature(t *testing.T) {
+	var nilHandler func(c *Channel) (int, error)
+	for _, tc := range []struct {
+		name string
+		f    interface{}
+		err  error
+	}{
+		{"nil", nil, ErrorHandlerIsNotFunc},
+		{"nil func", nilHandler, ErrorHandlerIsNotFunc},
+		{"not func", 42, ErrorHandlerIsNotFunc},
+		{"no channel", func(req string) (int, error) { return 0, nil }, ErrorCallHandlerSignature},
+		{"client first", func(c *Client, req string) (int, error) { return 0, nil }, ErrorCallHandlerSignature},
+		{"no error", func(c *Channel) (int, int) { return 0, 0 }, ErrorCallHandlerSignature},
+		{"params", func(c *Channel, req string) (int, error) { return 0, nil }, nil},
+		{"no params", func(c *Channel) (int, error) { return 0, nil }, nil},
+	} {
+		e := &event{}
+		e.init()
+		if err := e.OnCall("method", tc.f); err != tc.err {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/mtfelian/golang-socketio/logging"
 	"github.com/mtfelian/golang-socketio/protocol"
 	"github.com/mtfelian/golang-socketio/transport"
@@ -20,6 +22,7 @@ import (
 var (
 	ErrorServerNotSet       = errors.New("server was not set")
 	ErrorConnectionNotFound = errors.New("connection not found")
+	ErrorNotWebsocket       = errors.New("connection transport is not websocket")
 )
 
 // Server represents a socket.io server instance
@@ -34,27 +37,99 @@ type Server struct {
 	sids   map[string]*Channel // maps channel id to channel
 	sidsMu sync.RWMutex
 
+	tags   tagIndex
+	tagsMu sync.RWMutex
+
+	roomStates   map[string]*RoomState // shared states by room name, see RoomState
+	roomStatesMu sync.Mutex
+
+	crdts          map[crdtKey]crdt // room counters and sets, see RoomCounter and RoomSet
+	crdtNode       string
+	crdtReplicator CRDTReplicator
+	crdtsMu        sync.Mutex
+
 	websocket *transport.WebsocketTransport
 	polling   *transport.PollingTransport
+
+	idleTimeout time.Duration
+	idleReaping bool // true if idleLoop is running
+	idleMu      sync.Mutex
+
+	heartbeatTimeout  time.Duration
+	heartbeatWatching bool // true if heartbeatLoop is running
+	heartbeatMu       sync.Mutex
+
+	orderedWindow  int  // see SetOrderedDelivery
+	orderedProbing bool // true if probeOrderedLoop is running
+	orderedMu      sync.RWMutex
+
+	resumption        *resumption // nil if session resumption is disabled
+	conflictPolicy    SessionConflictPolicy
+	onSessionConflict func(active, c *Channel)
+	resumptionMu      sync.RWMutex
+
+	onAudit func(r AuditRecord)
+	auditMu sync.RWMutex
+
+	connectData   func(c *Channel) interface{}
+	connectDataMu sync.RWMutex
+
+	handshakeTimeout time.Duration
+	handshakeMu      sync.RWMutex
+
+	tenantResolver TenantResolver
+	tenantPolicies map[string]TenantPolicy
+	tenantsMu      sync.RWMutex
+
+	backgroundInterval time.Duration // maximum ping interval granted in background, zero if it isn't lengthened
+	backgroundTimeout  time.Duration
+	backgroundMu       sync.RWMutex
+
+	plugins plugins
+
+	maxConnections   int // zero if unlimited
+	maxConnectionsMu sync.RWMutex
+
+	configMu sync.Mutex // serializes ApplyConfig
+
+	registered   map[string]transport.Transport // instances of the transports from the transport registry
+	registeredMu sync.Mutex
 }
 
-// NewServer creates new socket.io server
+// NewServer creates new socket.io server with the default transports, see NewServerWithOpts
 func NewServer() *Server {
+	return newServer(transport.DefaultWebsocketTransport(), transport.DefaultPollingTransport())
+}
+
+// newServer creates new socket.io server with the given transports
+func newServer(websocket *transport.WebsocketTransport, polling *transport.PollingTransport) *Server {
 	s := &Server{
-		websocket: transport.DefaultWebsocketTransport(),
-		polling:   transport.DefaultPollingTransport(),
+		websocket: websocket,
+		polling:   polling,
 		channels:  make(map[string]map[*Channel]struct{}),
 		rooms:     make(map[*Channel]map[string]struct{}),
 		sids:      make(map[string]*Channel),
+		tags:      newTagIndex(),
+
+		backgroundInterval: DefaultBackgroundPingInterval,
+		backgroundTimeout:  DefaultBackgroundPingTimeout,
 		event: &event{
 			onConnection:    onConnection,
 			onDisconnection: onDisconnection,
 		},
 	}
 	s.event.init()
+	s.serveTime(s.event.now())
+	s.serveBackground()
+	s.serveRoomState()
+	s.serveOrderedAcks()
+	s.serveQoS()
 	return s
 }
 
+// PollingTransport returns the XHR polling transport of the server to tune it's params before serving
+func (s *Server) PollingTransport() *transport.PollingTransport { return s.polling }
+
 // GetChannel by it's sid
 func (s *Server) GetChannel(sid string) (*Channel, error) {
 	s.sidsMu.RLock()
@@ -68,16 +143,47 @@ func (s *Server) GetChannel(sid string) (*Channel, error) {
 	return c, nil
 }
 
-// Get amount of channels, joined to given room, using server
+// UnderlyingConn returns the underlying websocket connection of the channel with the given sid
+func (s *Server) UnderlyingConn(sid string) (*websocket.Conn, error) {
+	c, err := s.GetChannel(sid)
+	if err != nil {
+		return nil, err
+	}
+
+	ws, ok := c.connection().(*transport.WebsocketConnection)
+	if !ok {
+		return nil, ErrorNotWebsocket
+	}
+
+	return ws.Underlying(), nil
+}
+
+// Get amount of channels, joined to given room of the global tenant, using server
 func (s *Server) Amount(room string) int {
+	if !validRoom(room) {
+		return 0
+	}
+	return s.amount(room)
+}
+
+// amount returns an amount of channels joined to the room with the given internal name
+func (s *Server) amount(room string) int {
 	s.channelsMu.RLock()
 	defer s.channelsMu.RUnlock()
 	roomChannels, _ := s.channels[room]
 	return len(roomChannels)
 }
 
-// List returns a list of channels joined to the given room, using server
+// List returns a list of channels joined to the given room of the global tenant, using server
 func (s *Server) List(room string) []*Channel {
+	if !validRoom(room) {
+		return []*Channel{}
+	}
+	return s.list(room)
+}
+
+// list returns the channels joined to the room with the given internal name
+func (s *Server) list(room string) []*Channel {
 	s.channelsMu.RLock()
 	defer s.channelsMu.RUnlock()
 
@@ -96,8 +202,15 @@ func (s *Server) List(room string) []*Channel {
 	return roomChannelsCopy
 }
 
-// BroadcastTo the the given room an handler with payload, using server
+// BroadcastTo the the given room of the global tenant an handler with payload, using server
 func (s *Server) BroadcastTo(room, name string, payload interface{}) {
+	if validRoom(room) {
+		s.broadcastTo(room, name, payload)
+	}
+}
+
+// broadcastTo the room with the given internal name an event with the given name and payload
+func (s *Server) broadcastTo(room, name string, payload interface{}) {
 	s.channelsMu.RLock()
 	defer s.channelsMu.RUnlock()
 
@@ -113,16 +226,22 @@ func (s *Server) BroadcastTo(room, name string, payload interface{}) {
 	}
 }
 
-// Broadcast to all clients
+// Broadcast to all clients of the global tenant, which are all the clients if tenancy is disabled,
+// see Tenant.BroadcastToAll
 func (s *Server) BroadcastToAll(method string, payload interface{}) {
+	s.Tenant("").BroadcastToAll(method, payload)
+}
+
+// channelsList returns a list of all connected channels
+func (s *Server) channelsList() []*Channel {
 	s.sidsMu.RLock()
 	defer s.sidsMu.RUnlock()
 
-	for _, cn := range s.sids {
-		if cn.IsAlive() {
-			go cn.Emit(method, payload)
-		}
+	channels := make([]*Channel, 0, len(s.sids))
+	for _, c := range s.sids {
+		channels = append(channels, c)
 	}
+	return channels
 }
 
 // onConnection fires on connection and on connection upgrade
@@ -134,6 +253,12 @@ func onConnection(c *Channel) {
 
 // onDisconnection fires on disconnection
 func onDisconnection(c *Channel) {
+	c.server.audit(AuditDisconnect, c, "", "")
+	c.server.pluginsDisconnected(c)
+	c.server.suspend(c)
+	c.server.untagAll(c)
+	c.server.namespacesDisconnected(c)
+
 	c.server.channelsMu.Lock()
 	defer c.server.channelsMu.Unlock()
 
@@ -165,12 +290,14 @@ func (s *Server) sendOpenSequence(c *Channel) {
 	if err != nil {
 		panic(err)
 	}
-	c.outC <- protocol.MustEncode(&protocol.Message{Type: protocol.MessageTypeOpen, Args: string(jsonHdr)})
-	c.outC <- protocol.MustEncode(&protocol.Message{Type: protocol.MessageTypeEmpty})
+	c.outC <- &packet{message: protocol.MustEncode(&protocol.Message{Type: protocol.MessageTypeOpen, Args: string(jsonHdr)})}
+	c.outC <- &packet{message: protocol.MustEncode(s.connectMessage(c))}
 }
 
-// setupEventLoop for the given connection conn on the given address with HTTP header
-func (s *Server) setupEventLoop(conn transport.Connection, address string, header http.Header) {
+// setupEventLoop for the given connection conn on the given address with HTTP header and query parameters,
+// the resumption token and the compression codecs offered are taken from the query
+func (s *Server) setupEventLoop(conn transport.Connection, tr transport.Transport, address string, header http.Header,
+	query url.Values) {
 	interval, timeout := conn.PingParams()
 	connHeader := connectionHeader{
 		Sid: func(s string) string {
@@ -186,12 +313,24 @@ func (s *Server) setupEventLoop(conn transport.Connection, address string, heade
 		PingTimeout:  int(timeout / time.Millisecond),
 	}
 
-	c := &Channel{conn: conn, address: address, header: header, server: s, connHeader: connHeader}
+	c := &Channel{conn: conn, address: address, header: header, server: s, events: s.event, connHeader: connHeader}
 	c.init()
+	rejectErr := s.resolveTenant(c)
+	if rejectErr == nil {
+		_, rejectErr = c.payloadCipher()
+	}
+	if rejectErr == nil {
+		_, rejectErr = c.signingKey()
+	}
+	c.negotiateOrdered(query.Get(orderedParam))
+	var active *Channel
+	if rejectErr == nil {
+		active = s.resume(c, query.Get(tokenParam))
+	}
+	c.negotiateCompression(query.Get(compressionParam))
 
-	switch conn.(type) {
-	case *transport.PollingConnection:
-		conn.(*transport.PollingConnection).Transport.SetSid(connHeader.Sid, conn)
+	if tr != nil {
+		tr.SetSid(connHeader.Sid, conn)
 	}
 
 	s.sendOpenSequence(c)
@@ -199,9 +338,34 @@ func (s *Server) setupEventLoop(conn transport.Connection, address string, heade
 	go c.inLoop(s.event)
 	go c.outLoop(s.event)
 
+	if rejectErr != nil {
+		s.reject(c, http.StatusForbidden, rejectErr, DisconnectRejected)
+		return
+	}
+	if active != nil && !s.resolveSessionConflict(active, c) {
+		return
+	}
+	c.writeResumed(c.resumedPending)
+	s.sendResumedRooms(c)
+	c.resendAcks(c.resumedAcks)
+	c.resendOrdered(0, 0)
+	s.audit(AuditConnect, c, "", "")
+	s.watchHandshake(c)
+	s.pluginsConnected(c)
 	s.callHandler(c, OnConnection)
 }
 
+// reject the new channel c at handshake sending it the error event with the given code and err message,
+// and closing it for the given reason
+func (s *Server) reject(c *Channel, code int, err error, reason DisconnectReason) {
+	s.audit(AuditAuthFailure, c, "", err.Error())
+	go func() {
+		c.EmitError(code, err.Error(), nil)
+		c.disconnected(reason, 0, "")
+		c.Drain()
+	}()
+}
+
 // upgradeEventLoop at transport upgrade
 func (s *Server) upgradeEventLoop(conn transport.Connection, remoteAddr string, header http.Header, sid string) {
 	logging.Log().Debug("Server.upgradeEventLoop() fired")
@@ -214,6 +378,15 @@ func (s *Server) upgradeEventLoop(conn transport.Connection, remoteAddr string,
 
 	logging.Log().Debug("Server.upgradeEventLoop() obtained a polling channel")
 	interval, timeout := conn.PingParams()
+	pollingChannel.pingMu.RLock()
+	pingInterval, pingTimeout := pollingChannel.pingInterval, pollingChannel.pingTimeout
+	pollingChannel.pingMu.RUnlock()
+	if pingInterval != 0 {
+		interval = pingInterval
+	}
+	if pingTimeout != 0 {
+		timeout = pingTimeout
+	}
 	connHeader := connectionHeader{
 		Sid:          sid,
 		Upgrades:     []string{},
@@ -221,8 +394,28 @@ func (s *Server) upgradeEventLoop(conn transport.Connection, remoteAddr string,
 		PingTimeout:  int(timeout / time.Millisecond),
 	}
 
-	c := &Channel{conn: conn, address: remoteAddr, header: header, server: s, connHeader: connHeader}
+	pollingChannel.connMu.RLock()
+	connHeader.Token, connHeader.Compression = pollingChannel.connHeader.Token, pollingChannel.connHeader.Compression
+	pollingChannel.connMu.RUnlock()
+	c := &Channel{conn: conn, address: remoteAddr, header: header, server: s, events: s.event, connHeader: connHeader}
 	c.init()
+	c.sessionID = pollingChannel.sessionID
+	c.connectedAt = pollingChannel.connectedAt
+	c.ctx, c.cancel = pollingChannel.ctx, pollingChannel.cancel
+	c.tenant = pollingChannel.tenant
+	pollingChannel.keyMu.Lock()
+	c.key = pollingChannel.key
+	pollingChannel.keyMu.Unlock()
+	pollingChannel.cipherMu.Lock()
+	c.cipher, c.previousCipher, c.keyEpoch = pollingChannel.cipher, pollingChannel.previousCipher, pollingChannel.keyEpoch
+	pollingChannel.cipherMu.Unlock()
+	c.replay = pollingChannel.replayWindow()
+	c.ordered = pollingChannel.ordered
+	c.traffic = pollingChannel.traffic
+	c.sendTimeout = pollingChannel.getSendTimeout()
+	c.quality.Quality = pollingChannel.Quality()
+	c.pingInterval, c.pingTimeout = pingInterval, pingTimeout
+	pollingChannel.copyQuarantine(c)
 	logging.Log().Debug("Server.upgradeEventLoop() initialized a new channel")
 
 	go c.inLoop(s.event)
@@ -232,12 +425,37 @@ func (s *Server) upgradeEventLoop(conn transport.Connection, remoteAddr string,
 	onConnection(c)
 
 	// synchronize stubbing polling channel with receiving "2probe" message
-	<-c.upgradedC
+	if !s.awaitProbe(c) {
+		logging.Log().Debug("Server.upgradeEventLoop() probe timed out for session:", sid)
+		c.stub()
+		if pollingChannel.IsAlive() {
+			onConnection(pollingChannel)
+		}
+		return
+	}
+	c.setStore(pollingChannel.storeCopy())
+	s.moveRooms(pollingChannel, c)
+	s.moveTags(pollingChannel, c)
+	pollingChannel.moveNamespaces(c)
+	pollingChannel.moveStreams(c)
 	pollingChannel.stub()
 }
 
+// moveRooms makes channel to to join all rooms of channel from, and from to leave them
+func (s *Server) moveRooms(from, to *Channel) {
+	for _, room := range from.Rooms() {
+		to.join(room)
+		from.leave(room)
+	}
+
+	s.channelsMu.Lock()
+	delete(s.rooms, from)
+	s.channelsMu.Unlock()
+}
+
 // ServeHTTP makes Server to implement http.Handler
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.start()
 	session, transportName := r.URL.Query().Get("sid"), r.URL.Query().Get("transport")
 
 	switch transportName {
@@ -247,13 +465,16 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			s.polling.Serve(w, r)
 			return
 		}
+		if s.refuseFull(w) {
+			return
+		}
 
 		conn, err := s.polling.HandleConnection(w, r)
 		if err != nil {
 			return
 		}
 
-		s.setupEventLoop(conn, r.RemoteAddr, r.Header)
+		s.setupEventLoop(conn, s.polling, r.RemoteAddr, r.Header, r.URL.Query())
 		logging.Log().Debug("Server.ServeHTTP() created a PollingConnection")
 		conn.(*transport.PollingConnection).PollingWriter(w, r)
 
@@ -269,14 +490,90 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			logging.Log().Debug("Server.ServeHTTP() upgraded to a WebsocketConnection")
 			return
 		}
+		if s.refuseFull(w) {
+			return
+		}
 
 		conn, err := s.websocket.HandleConnection(w, r)
 		if err != nil {
 			return
 		}
 
-		s.setupEventLoop(conn, r.RemoteAddr, r.Header)
+		s.setupEventLoop(conn, s.websocket, r.RemoteAddr, r.Header, r.URL.Query())
 		logging.Log().Debug("Server.ServeHTTP() created a WebsocketConnection")
+
+	default:
+		s.serveRegistered(w, r, transportName, session)
+	}
+}
+
+// Serve accepts connections from the acceptor (e.g. transport.MemoryTransport or transport.TCPListener)
+// until it fails, it returns the acceptor error. Connections over the SetMaxConnections limit are closed
+func (s *Server) Serve(a transport.Acceptor) error {
+	s.start()
+	for {
+		conn, err := a.Accept()
+		if err != nil {
+			return err
+		}
+		if s.full() {
+			logging.Log().Warn("Server.Serve() refused the connection: max connections reached")
+			conn.Close()
+			continue
+		}
+
+		var address string
+		if remote, ok := conn.(interface{ RemoteAddr() string }); ok {
+			address = remote.RemoteAddr()
+		}
+		s.setupEventLoop(conn, nil, address, http.Header{}, nil)
+		logging.Log().Debug("Server.Serve() accepted a connection")
+	}
+}
+
+// registeredTransport returns the instance of the transport registered with the name, nil if there are none
+func (s *Server) registeredTransport(name string) transport.Transport {
+	s.registeredMu.Lock()
+	defer s.registeredMu.Unlock()
+
+	if tr, ok := s.registered[name]; ok {
+		return tr
+	}
+	factory, ok := transport.Lookup(name)
+	if !ok {
+		return nil
+	}
+	if s.registered == nil {
+		s.registered = make(map[string]transport.Transport)
+	}
+	tr := factory()
+	s.registered[name] = tr
+	return tr
+}
+
+// serveRegistered serves the request with the transport from the transport registry, see transport.Register
+func (s *Server) serveRegistered(w http.ResponseWriter, r *http.Request, name, session string) {
+	tr := s.registeredTransport(name)
+	if tr == nil {
+		http.Error(w, "unknown transport", http.StatusBadRequest)
+		return
+	}
+	if session != "" {
+		tr.Serve(w, r)
+		return
+	}
+	if s.refuseFull(w) {
+		return
+	}
+
+	conn, err := tr.HandleConnection(w, r)
+	if err != nil {
+		return
+	}
+	s.setupEventLoop(conn, tr, r.RemoteAddr, r.Header, r.URL.Query())
+	logging.Log().Debugf("Server.ServeHTTP() created a %s connection", name)
+	if hw, ok := tr.(transport.HandshakeWriter); ok {
+		hw.WriteHandshake(conn, w, r)
 	}
 }
 
@@ -0,0 +1,159 @@
+package gosocketio
+
+import (
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+// serve starts an HTTP server for s, returns its host, port and a function to stop it
+func serve(t *testing.T, s *Server) (string, int, func()) {
+	t.Helper()
+	ts := httptest.NewServer(s)
+	host, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, p, ts.Close
+}
+
+// dialUpgraded connects a polling client to s, calls prepare with the server polling Channel and upgrades
+// the client to websocket, returns the server websocket Channel and a function to stop the client and server
+func dialUpgraded(t *testing.T, s *Server, prepare func(*Channel)) (*Channel, func()) {
+	t.Helper()
+	connected := make(chan *Channel, 1)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	host, port, stopServer := serve(t, s)
+
+	c, err := Dial(AddrPolling(host, port, false), transport.DefaultPollingClientTransport())
+	if err != nil {
+		stopServer()
+		t.Fatal(err)
+	}
+	stop := func() {
+		c.Close()
+		stopServer()
+	}
+
+	var polling *Channel
+	select {
+	case polling = <-connected:
+	case <-time.After(5 * time.Second):
+		stop()
+		t.Fatal("no connection on the server")
+	}
+	prepare(polling)
+
+	if err := c.upgrade(transport.DefaultWebsocketTransport()); err != nil {
+		stop()
+		t.Fatal("upgrade failed:", err)
+	}
+	upgraded, err := s.GetChannel(polling.Id())
+	if err != nil || upgraded == polling {
+		stop()
+		t.Fatal("no upgraded channel on the server:", err)
+	}
+	return upgraded, stop
+}
+
+func TestUpgradeKeepsPingParams(t *testing.T) {
+	c, stop := dialUpgraded(t, NewServer(), func(c *Channel) {
+		if err := c.SetPingParams(7*time.Second, 3*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	})
+	defer stop()
+
+	if interval, timeout := c.PingParams(); interval != 7*time.Second || timeout != 3*time.Second {
+		t.Fatalf("ping params after the upgrade: %v, %v", interval, timeout)
+	}
+}
+
+func TestUpgradeKeepsQuarantine(t *testing.T) {
+	c, stop := dialUpgraded(t, NewServer(), func(c *Channel) { c.quarantine("crash") })
+	defer stop()
+
+	if !c.IsQuarantined("crash") {
+		t.Fatal("event quarantined on the polling channel is dispatched after the upgrade")
+	}
+}
+
+func TestSSEHandshakeAndMessage(t *testing.T) {
+	s := NewServer()
+	s.On("echo", func(c *Channel, m string) string { return m })
+	connected := make(chan *Channel, 1)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	host, port, stop := serve(t, s)
+	defer stop()
+
+	c, err := Dial(AddrSSE(host, port, false), transport.DefaultSSEClientTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	received := make(chan string, 1)
+	if err := c.On("news", func(c *Channel, m string) { received <- m }); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := c.Ack("echo", "hi", 5*time.Second); err != nil || reply != `"hi"` {
+		t.Fatalf("ack over the SSE connection: %q, %v", reply, err)
+	}
+	sc := <-connected
+	if sc.Id() == "" || sc.Id() != c.Id() {
+		t.Fatalf("sid of the server channel %q, of the client %q", sc.Id(), c.Id())
+	}
+	if err := sc.Emit("news", "streamed"); err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, received, "streamed event"); m != "streamed" {
+		t.Fatal("streamed event:", m)
+	}
+}
+
+func TestTCPHandshakeAndMessage(t *testing.T) {
+	s := NewServer()
+	s.On("echo", func(c *Channel, m string) string { return m })
+	connected := make(chan *Channel, 1)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	tr := transport.DefaultTCPTransport()
+	l, err := tr.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go s.Serve(l)
+
+	addr := l.Addr().(*net.TCPAddr)
+	c, err := Dial(AddrTCP(addr.IP.String(), addr.Port, false), tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	received := make(chan string, 1)
+	if err := c.On("news", func(c *Channel, m string) { received <- m }); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := c.Ack("echo", "hi", 5*time.Second); err != nil || reply != `"hi"` {
+		t.Fatalf("ack over the TCP connection: %q, %v", reply, err)
+	}
+	sc := <-connected
+	if sc.Id() == "" || sc.Id() != c.Id() {
+		t.Fatalf("sid of the server channel %q, of the client %q", sc.Id(), c.Id())
+	}
+	if err := sc.Emit("news", "framed"); err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, received, "framed event"); m != "framed" {
+		t.Fatal("framed event:", m)
+	}
+}
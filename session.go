@@ -0,0 +1,178 @@
+package gosocketio
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+const (
+	// DefaultResumptionTTL is a default duration to keep a disconnected session for resumption
+	DefaultResumptionTTL = 2 * time.Minute
+
+	sessionIDLength = 16
+	tokenSeparator  = "."
+	tokenParam      = "token"
+)
+
+// resumption holds the session resumption params
+type resumption struct {
+	key   []byte
+	ttl   time.Duration
+	store SessionStore
+}
+
+// newSessionID returns a new random session id
+func newSessionID() string {
+	b := make([]byte, sessionIDLength)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sign the session id with HMAC
+func (r *resumption) sign(id string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue a token for the session id
+func (r *resumption) issue(id string) string { return id + tokenSeparator + r.sign(id) }
+
+// validate the token and return the session id from it, the second value is true if the token is valid
+func (r *resumption) validate(token string) (string, bool) {
+	parts := strings.Split(token, tokenSeparator)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", false
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(r.sign(parts[0]))) {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// SetResumption enables session resumption. At handshake every client receives a token signed with HMAC
+// using the given key. Presenting the token on reconnect within ttl restores rooms membership and
+// the store of the previous session, the ack requests not answered are sent again and the Ack calls
+// still waiting on this node get the responses. Sessions are kept in the given store, or in memory if it is nil.
+// Servers sharing the store should use the same key. Empty key disables resumption
+func (s *Server) SetResumption(key []byte, ttl time.Duration, store SessionStore) {
+	s.resumptionMu.Lock()
+	defer s.resumptionMu.Unlock()
+
+	if len(key) == 0 {
+		s.resumption = nil
+		return
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultResumptionTTL
+	}
+	if store == nil {
+		store = NewMemorySessionStore()
+	}
+	s.resumption = &resumption{key: key, ttl: ttl, store: store}
+}
+
+// getResumption returns the resumption params, nil if resumption is disabled
+func (s *Server) getResumption() *resumption {
+	s.resumptionMu.RLock()
+	defer s.resumptionMu.RUnlock()
+	return s.resumption
+}
+
+// resume the session for the channel c by the given token, or start a new one.
+// It returns the active channel of the resumed session, nil if there are none
+func (s *Server) resume(c *Channel, token string) *Channel {
+	r := s.getResumption()
+	if r == nil {
+		return nil
+	}
+
+	if id, ok := r.validate(token); ok {
+		if active := s.activeSession(c.tenant, id); active != nil {
+			c.sessionID, c.connHeader.Token = id, token
+			return active
+		}
+
+		session, err := r.store.Load(id)
+		switch {
+		case err != nil:
+			logging.Log().Debug("Server.resume() can't load session:", err)
+		case session.Tenant != c.tenant:
+			logging.Log().Warn("Server.resume() refuses the session of another tenant:", id)
+			s.audit(AuditAuthFailure, c, "", "resume token of another tenant")
+		default:
+			logging.Log().Debug("Server.resume() resumes session:", id)
+			if err := r.store.Delete(id); err != nil {
+				logging.Log().Warn("Server.resume() can't delete session from store:", err)
+			}
+
+			c.sessionID, c.connHeader.Token = id, token
+			if session.Store != nil {
+				c.setStore(session.Store)
+			}
+			for _, room := range session.Rooms { // the room states and CRDTs are sent after the open sequence
+				if c.join(room) == nil {
+					s.audit(AuditJoin, c, room, "")
+					c.resumedRooms = append(c.resumedRooms, room)
+				}
+			}
+			c.resumedPending, c.resumedAcks = session.Pending, session.Acks
+			c.ordered.restore(session.Ordered)
+			return nil
+		}
+	} else if token != "" {
+		s.audit(AuditAuthFailure, c, "", "invalid resume token")
+	}
+
+	c.sessionID = newSessionID()
+	c.connHeader.Token = r.issue(c.sessionID)
+	return nil
+}
+
+// sendResumedRooms sends the channel c the shared states, counters and sets of the rooms restored
+// with the session, it's called after the open sequence
+func (s *Server) sendResumedRooms(c *Channel) {
+	for _, room := range c.resumedRooms {
+		s.sendRoomState(c, room)
+		s.sendRoomCRDTs(c, room)
+	}
+}
+
+// suspend the session of the disconnected channel c for the further resumption
+func (s *Server) suspend(c *Channel) {
+	r := s.getResumption()
+	if r == nil || c.sessionID == "" || c.migrationSaved() {
+		return
+	}
+
+	session := &Session{Tenant: c.tenant, Rooms: c.Rooms(), Store: c.storeCopy(), Pending: c.migratedPending(),
+		Ordered: c.ordered.snapshot(), Acks: c.ack.pending()}
+	if err := r.store.Save(c.sessionID, session, r.ttl); err != nil {
+		logging.Log().Warn("Server.suspend() can't save session to store:", err)
+	}
+}
+
+// resumable returns true if the session of the server Channel is suspended for resumption on disconnection
+func (c *Channel) resumable() bool {
+	return c.server != nil && c.sessionID != "" && c.server.getResumption() != nil
+}
+
+// SessionID returns an id of the logical session which stays the same on resumption, unlike the sid.
+// It is empty if resumption is disabled
+func (c *Channel) SessionID() string { return c.sessionID }
+
+// ResumeToken returns the session resumption token received from the server at handshake
+func (c *Channel) ResumeToken() string { return c.resumeToken() }
+
+// AddrResume returns the given socket.io connection url with the resumption token
+func AddrResume(addr, token string) string { return addr + "&" + tokenParam + "=" + token }
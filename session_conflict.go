@@ -0,0 +1,93 @@
+package gosocketio
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// SessionConflictPolicy is what happens when the client resumes the session which is still active
+type SessionConflictPolicy int
+
+const (
+	SessionConflictAllow    SessionConflictPolicy = iota // keep both channels in the session, it's the default
+	SessionConflictReject                                // reject the new channel with the error event of 409 code
+	SessionConflictTakeover                              // close the active channel, the new one takes over its session
+)
+
+var ErrorSessionConflict = errors.New("session is already active")
+
+// SetSessionConflictPolicy sets the policy applied when the client presents the resumption token
+// of the session with an active channel, see SetResumption. The rejected channel isn't passed
+// to the OnConnection handler, but OnDisconnection fires for it with DisconnectSessionConflict reason
+func (s *Server) SetSessionConflictPolicy(policy SessionConflictPolicy) {
+	s.resumptionMu.Lock()
+	s.conflictPolicy = policy
+	s.resumptionMu.Unlock()
+}
+
+// OnSessionConflict sets the handler f called with the active channel and the new one
+// resuming the same session before the conflict policy is applied
+func (s *Server) OnSessionConflict(f func(active, c *Channel)) {
+	s.resumptionMu.Lock()
+	s.onSessionConflict = f
+	s.resumptionMu.Unlock()
+}
+
+// sessionConflict returns the policy and the handler of the session conflicts
+func (s *Server) sessionConflict() (SessionConflictPolicy, func(active, c *Channel)) {
+	s.resumptionMu.RLock()
+	defer s.resumptionMu.RUnlock()
+	return s.conflictPolicy, s.onSessionConflict
+}
+
+// activeSession returns the connected channel of the given tenant session with the given id, nil if there are none
+func (s *Server) activeSession(tenant, id string) *Channel {
+	for _, c := range s.channelsList() {
+		if c.sessionID == id && c.tenant == tenant && c.IsAlive() {
+			return c
+		}
+	}
+	return nil
+}
+
+// resolveSessionConflict applies the conflict policy to the new channel c resuming the session of the active one,
+// it returns false if c is rejected
+func (s *Server) resolveSessionConflict(active, c *Channel) bool {
+	policy, f := s.sessionConflict()
+	logging.Log().Debugf("Server.resolveSessionConflict() session %s is active on %s, policy %d",
+		c.sessionID, active.Id(), policy)
+	if f != nil {
+		f(active, c)
+	}
+
+	switch policy {
+	case SessionConflictReject:
+		c.sessionID = "" // the session stays with the active channel
+		s.reject(c, http.StatusConflict, ErrorSessionConflict, DisconnectSessionConflict)
+		return false
+
+	case SessionConflictTakeover:
+		c.setStore(active.storeCopy())
+		c.ordered.restore(active.ordered.snapshot())
+		c.resumedAcks = active.ack.pending()
+		for _, room := range active.Rooms() {
+			c.Join(room)
+		}
+		active.disconnected(DisconnectSessionTakeover, 0, "")
+		active.Close()
+		if r := s.getResumption(); r != nil { // closed channel suspended the session taken over
+			if err := r.store.Delete(c.sessionID); err != nil {
+				logging.Log().Warn("Server.resolveSessionConflict() can't delete session from store:", err)
+			}
+		}
+
+	default:
+		c.setStore(active.storeCopy())
+		for _, room := range active.Rooms() {
+			c.Join(room)
+		}
+	}
+	return true
+}
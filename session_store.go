@@ -0,0 +1,150 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const redisSessionPrefix = "gosocketio:session:"
+
+var (
+	ErrorSessionNotFound = errors.New("session not found")
+)
+
+// Session represents a persisted state of the logical session
+type Session struct {
+	Tenant  string                 `json:"tenant,omitempty"` // the session is resumed by the channels of this tenant only
+	Rooms   []string               `json:"rooms"`
+	Store   map[string]interface{} `json:"store"`
+	Pending []string               `json:"pending,omitempty"` // encoded packets transferred by Channel.Migrate
+	Ordered *OrderedBuffer         `json:"ordered,omitempty"` // messages not acknowledged, see SetOrderedDelivery
+	Acks    []PendingAck           `json:"acks,omitempty"`    // ack requests of the server not answered
+}
+
+// SessionStore persists sessions of disconnected channels for resumption.
+// Implementation should be safe for concurrent use
+type SessionStore interface {
+	// Save the session with the given id for ttl
+	Save(id string, session *Session, ttl time.Duration) error
+	// Load the session with the given id, should return ErrorSessionNotFound if there is no such session
+	Load(id string) (*Session, error)
+	// Delete the session with the given id
+	Delete(id string) error
+}
+
+// memorySession is an item of MemorySessionStore
+type memorySession struct {
+	session *Session
+	expires time.Time
+}
+
+// MemorySessionStore keeps sessions in memory of the current process
+type MemorySessionStore struct {
+	sessions map[string]memorySession
+	mu       sync.Mutex
+}
+
+// NewMemorySessionStore returns a new MemorySessionStore
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession)}
+}
+
+// Save the session with the given id, also removing the expired sessions
+func (s *MemorySessionStore) Save(id string, session *Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for savedID, saved := range s.sessions {
+		if now.After(saved.expires) {
+			delete(s.sessions, savedID)
+		}
+	}
+
+	s.sessions[id] = memorySession{session: session, expires: now.Add(ttl)}
+	return nil
+}
+
+// Load the session with the given id
+func (s *MemorySessionStore) Load(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved, ok := s.sessions[id]
+	if !ok || time.Now().After(saved.expires) {
+		return nil, ErrorSessionNotFound
+	}
+	return saved.session, nil
+}
+
+// Delete the session with the given id
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// RedisDoer executes redis commands. It is satisfied by redigo's redis.Conn,
+// for concurrent use wrap a connection pool
+type RedisDoer interface {
+	Do(commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+// RedisSessionStore keeps sessions in redis, so they can be resumed on other nodes or after restart.
+// Store values are encoded to JSON, so they are restored as decoded JSON values
+type RedisSessionStore struct {
+	redis RedisDoer
+}
+
+// NewRedisSessionStore returns a new RedisSessionStore using the given redis commands executor
+func NewRedisSessionStore(redis RedisDoer) *RedisSessionStore {
+	return &RedisSessionStore{redis: redis}
+}
+
+// Save the session with the given id
+func (s *RedisSessionStore) Save(id string, session *Session, ttl time.Duration) error {
+	b, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	ttlMs := strconv.FormatInt(int64(ttl/time.Millisecond), 10)
+	_, err = s.redis.Do("SET", redisSessionPrefix+id, b, "PX", ttlMs)
+	return err
+}
+
+// Load the session with the given id
+func (s *RedisSessionStore) Load(id string) (*Session, error) {
+	reply, err := s.redis.Do("GET", redisSessionPrefix+id)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	switch reply := reply.(type) {
+	case nil:
+		return nil, ErrorSessionNotFound
+	case []byte:
+		b = reply
+	case string:
+		b = []byte(reply)
+	default:
+		return nil, ErrorSessionNotFound
+	}
+
+	var session Session
+	if err := json.Unmarshal(b, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Delete the session with the given id
+func (s *RedisSessionStore) Delete(id string) error {
+	_, err := s.redis.Do("DEL", redisSessionPrefix+id)
+	return err
+}
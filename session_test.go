@@ -0,0 +1,238 @@
+package gosocketio
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestResumeResendsPendingAcks(t *testing.T) {
+	store := NewMemorySessionStore()
+	s := NewServer()
+	s.SetResumption([]byte("secret"), time.Minute, store)
+	connected := make(chan *Channel, 2)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	host, port, stop := serve(t, s)
+	defer stop()
+	addr := AddrWebsocket(host, port, false)
+
+	first, err := Dial(addr, transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	asked, block := make(chan struct{}), make(chan struct{})
+	defer close(block)
+	first.On("ask", func(c *Channel, q string) string {
+		close(asked)
+		<-block // the response is lost with the connection
+		return q
+	})
+	c := <-connected
+
+	type result struct {
+		response string
+		err      error
+	}
+	resultC := make(chan result, 1)
+	go func() {
+		response, err := c.Ack("ask", "q", 10*time.Second)
+		resultC <- result{response, err}
+	}()
+	<-asked
+	token := first.ResumeToken()
+	first.Close()
+
+	var session *Session
+	for deadline := time.Now().Add(5 * time.Second); session == nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		session, _ = store.Load(c.SessionID())
+	}
+	if session == nil {
+		t.Fatal("session is not suspended")
+	}
+	if len(session.Acks) != 1 || session.Acks[0].Name != "ask" || string(session.Acks[0].Payload) != `"q"` {
+		t.Fatalf("pending acks of the suspended session: %+v", session.Acks)
+	}
+
+	second, err := Dial(AddrResume(addr, token), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.On("ask", func(c *Channel, q string) string { return q + "!" })
+
+	select {
+	case r := <-resultC:
+		if r.err != nil || r.response != `"q!"` {
+			t.Fatalf("ack response after the resumption: %q, %v", r.response, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ack response after the resumption")
+	}
+}
+
+func TestResumeSendsRoomsAfterOpenSequence(t *testing.T) {
+	store := NewMemorySessionStore()
+	s := NewServer()
+	s.SetResumption([]byte("secret"), time.Minute, store)
+	connected := make(chan *Channel, 2)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	host, port, stop := serve(t, s)
+	defer stop()
+	addr := AddrWebsocket(host, port, false)
+
+	first, err := Dial(addr, transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := <-connected
+	if err := c.Join("lobby"); err != nil {
+		t.Fatal(err)
+	}
+	state, err := s.RoomState("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.UpdateState(map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	counter, err := s.RoomCounter("lobby", "likes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(1)
+	token := c.ResumeToken() // the client may not have read the open packet yet
+	first.Close()
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if session, _ := store.Load(c.SessionID()); session != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session is not suspended")
+		}
+	}
+
+	conn, err := transport.DefaultWebsocketTransport().Connect(AddrResume(addr, token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var messages []string
+	for len(messages) < 4 {
+		m, err := conn.GetMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, m)
+	}
+	if !strings.HasPrefix(messages[0], "0{") || messages[1] != "40" ||
+		!strings.HasPrefix(messages[2], `42["`+RoomStateEvent+`"`) ||
+		!strings.HasPrefix(messages[3], `42["`+CRDTChangeEvent+`"`) {
+		t.Fatalf("first messages of the resumed session: %q", messages)
+	}
+}
+
+func TestResumeTwiceResendsPendingAcks(t *testing.T) {
+	store := NewMemorySessionStore()
+	s := NewServer()
+	s.SetResumption([]byte("secret"), time.Minute, store)
+	connected := make(chan *Channel, 3)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	host, port, stop := serve(t, s)
+	defer stop()
+	addr := AddrWebsocket(host, port, false)
+
+	block := make(chan struct{})
+	defer close(block)
+	// dialBlocked connects the client which doesn't answer the asks, it returns when all of them are received
+	dialBlocked := func(addr string, asks int) *Client {
+		client, err := Dial(addr, transport.DefaultWebsocketTransport())
+		if err != nil {
+			t.Fatal(err)
+		}
+		asked := make(chan struct{}, asks)
+		client.On("ask", func(c *Channel, q string) string {
+			asked <- struct{}{}
+			<-block // the response is lost with the connection
+			return q
+		})
+		for i := 0; i < asks; i++ {
+			select {
+			case <-asked:
+			case <-time.After(5 * time.Second):
+				t.Fatal("asks are not received")
+			}
+		}
+		return client
+	}
+	// suspended waits for the session of c to be suspended with the given number of pending acks
+	suspended := func(c *Channel, acks int) {
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if session, _ := store.Load(c.SessionID()); session != nil {
+				if len(session.Acks) != acks {
+					t.Fatalf("pending acks of the suspended session: %+v", session.Acks)
+				}
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("session is not suspended")
+			}
+		}
+	}
+
+	first, err := Dial(addr, transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	asked := make(chan struct{}, 3)
+	first.On("ask", func(c *Channel, q string) string {
+		asked <- struct{}{}
+		<-block
+		return q
+	})
+	c := <-connected
+
+	questions := []string{"a", "b", "c"}
+	results := make(map[string]chan string)
+	for _, q := range questions {
+		resultC := make(chan string, 1)
+		results[q] = resultC
+		go func(q string) {
+			response, err := c.Ack("ask", q, 20*time.Second)
+			if err != nil {
+				response = err.Error()
+			}
+			resultC <- response
+		}(q)
+		<-asked
+	}
+	token := c.ResumeToken()
+	first.Close()
+	suspended(c, len(questions))
+
+	second := dialBlocked(AddrResume(addr, token), len(questions))
+	c = <-connected
+	second.Close()
+	suspended(c, len(questions))
+
+	third, err := Dial(AddrResume(addr, token), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Close()
+	third.On("ask", func(c *Channel, q string) string { return q + "!" })
+
+	for _, q := range questions {
+		select {
+		case response := <-results[q]:
+			if response != `"`+q+`!"` {
+				t.Fatalf("ack response to %q after the second resumption: %s", q, response)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no ack response to %q after the second resumption", q)
+		}
+	}
+}
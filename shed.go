@@ -0,0 +1,52 @@
+package gosocketio
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// ShedStrategy selects the channels to shed, it returns candidates in the order they should be shed
+type ShedStrategy func(s *Server) []*Channel
+
+// ShedOldest sheds the channels connected earlier first
+func ShedOldest() ShedStrategy {
+	return func(s *Server) []*Channel {
+		channels := s.channelsList()
+		sort.Slice(channels, func(i, j int) bool { return channels[i].connectedAt.Before(channels[j].connectedAt) })
+		return channels
+	}
+}
+
+// ShedRandom sheds the random channels
+func ShedRandom() ShedStrategy {
+	return func(s *Server) []*Channel {
+		channels := s.channelsList()
+		rand.Shuffle(len(channels), func(i, j int) { channels[i], channels[j] = channels[j], channels[i] })
+		return channels
+	}
+}
+
+// ShedRoom sheds the channels joined to the given room
+func ShedRoom(room string) ShedStrategy {
+	return func(s *Server) []*Channel { return s.List(room) }
+}
+
+// Shed asks up to n channels selected by the strategy to reconnect, so the load balancer can route them
+// to another node. It returns an amount of channels asked
+func (s *Server) Shed(n int, strategy ShedStrategy) int {
+	s.audit(AuditAdmin, nil, "", "shed "+strconv.Itoa(n)+" channels")
+	asked := 0
+	for _, c := range strategy(s) {
+		if asked >= n {
+			break
+		}
+		if !c.IsAlive() {
+			continue
+		}
+		if err := c.RequestReconnect(0, ""); err == nil {
+			asked++
+		}
+	}
+	return asked
+}
@@ -0,0 +1,137 @@
+package gosocketio
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+var invalidSignatures synced.Counter
+
+// CountInvalidSignatures returns an amount of incoming messages dropped because of the missing or invalid signature
+func CountInvalidSignatures() int { return invalidSignatures.Get() }
+
+// signedPayload is the payload of the signed message
+type signedPayload struct {
+	Sig  string          `json:"sig"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// SetSigningKey sets the function returning the HMAC-SHA256 key of the channel, e.g. derived from the session
+// or tenant secret with SessionKey. The payload of each event, ack and ack response is replaced with
+// {"sig":..., "data": payload} object, the signature covers the packet type, namespace, ack id, event name
+// and payload. The payload is signed after the encryption, see SetCipher. Both sides must set the key,
+// the incoming messages with the missing or invalid signature are dropped. nil disables the signing of
+// new channels. The server rejects the channel with the error event of 403 code if f returns an error
+func (e *event) SetSigningKey(f func(c *Channel) ([]byte, error)) {
+	e.handlersMu.Lock()
+	e.signingKey = f
+	e.handlersMu.Unlock()
+}
+
+// OnInvalidSignature sets the handler f called with the event name of each dropped incoming message
+// with the missing or invalid signature, name is empty for the ack responses
+func (e *event) OnInvalidSignature(f func(c *Channel, name string)) {
+	e.handlersMu.Lock()
+	e.onInvalidSignature = f
+	e.handlersMu.Unlock()
+}
+
+// signing returns the function returning the channel keys and the invalid signature handler, e may be nil
+func (e *event) signing() (func(c *Channel) ([]byte, error), func(c *Channel, name string)) {
+	if e == nil {
+		return nil, nil
+	}
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	return e.signingKey, e.onInvalidSignature
+}
+
+// signingKey returns the HMAC key of the Channel obtaining it on the first use, nil if the messages aren't signed
+func (c *Channel) signingKey() ([]byte, error) {
+	c.keyMu.Lock()
+	defer c.keyMu.Unlock()
+	if c.key != nil {
+		return c.key, nil
+	}
+
+	f, _ := c.events.signing()
+	if f == nil {
+		return nil, nil
+	}
+	key, err := f(c)
+	if err != nil {
+		return nil, err
+	}
+	c.key = key
+	return key, nil
+}
+
+// signed returns whether the message m carries the signature
+func signed(m *protocol.Message) bool {
+	switch m.Type {
+	case protocol.MessageTypeEmit, protocol.MessageTypeAckRequest, protocol.MessageTypeAckResponse:
+		return true
+	}
+	return false
+}
+
+// signature returns the HMAC of the message m with the payload args
+func signature(key []byte, m *protocol.Message, args string) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, part := range []string{strconv.Itoa(m.Type), m.Namespace, strconv.Itoa(m.AckID), m.EventName, args} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	return mac.Sum(nil)
+}
+
+// sign returns the copy of message m with the payload signed with the key
+func sign(key []byte, m *protocol.Message) *protocol.Message {
+	sig := base64.StdEncoding.EncodeToString(signature(key, m, m.Args))
+	args := `{"sig":"` + sig + `"}`
+	if m.Args != "" {
+		args = `{"sig":"` + sig + `","data":` + m.Args + `}`
+	}
+
+	signedM := *m
+	signedM.Args = args
+	return &signedM
+}
+
+// verify the signature of the incoming message m replacing its payload with the signed one,
+// it returns false if m must be dropped
+func (c *Channel) verify(e *event, m *protocol.Message) bool {
+	if !signed(m) {
+		return true
+	}
+	key, err := c.signingKey()
+	if err == nil && key == nil {
+		return true
+	}
+
+	var payload signedPayload
+	if err == nil {
+		err = json.Unmarshal([]byte(m.Args), &payload)
+	}
+	if err == nil {
+		sig, decodeErr := base64.StdEncoding.DecodeString(payload.Sig)
+		if decodeErr == nil && hmac.Equal(sig, signature(key, m, string(payload.Data))) {
+			m.Args = string(payload.Data)
+			return true
+		}
+	}
+
+	invalidSignatures.Inc()
+	logging.Log().Warnf("Channel.verify() invalid signature of %q on %s", m.EventName, c.Id())
+	if _, f := e.signing(); f != nil {
+		f(c, m.EventName)
+	}
+	return false
+}
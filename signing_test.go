@@ -0,0 +1,59 @@
+package gosocketio
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestSigningRejectsInvalidMessages(t *testing.T) {
+	key := []byte("key")
+	s := NewServer()
+	s.SetSigningKey(func(c *Channel) ([]byte, error) { return key, nil })
+	received := make(chan string, 4)
+	s.On("message", func(c *Channel, m string) { received <- m })
+	invalid := make(chan string, 4)
+	s.OnInvalidSignature(func(c *Channel, name string) { invalid <- name })
+	host, port, stop := serve(t, s)
+	defer stop()
+
+	conn, err := transport.DefaultWebsocketTransport().Connect(AddrWebsocket(host, port, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for i := 0; i < 2; i++ { // open and connect packets
+		if _, err := conn.GetMessage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	emit := func(args string) *protocol.Message {
+		return &protocol.Message{Type: protocol.MessageTypeEmit, EventName: "message", Args: args}
+	}
+	tampered := protocol.MustEncode(sign(key, emit(`"original"`)))
+	tampered = strings.Replace(tampered, "original", "tampered", 1)
+	wrongKey := protocol.MustEncode(sign([]byte("another key"), emit(`"wrong key"`)))
+	for _, m := range []string{protocol.MustEncode(emit(`"unsigned"`)), tampered, wrongKey,
+		protocol.MustEncode(sign(key, emit(`"signed"`)))} {
+		if err := conn.WriteMessage(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if m := receive(t, received, "signed message"); m != "signed" {
+		t.Fatal("message delivered:", m)
+	}
+	for i := 0; i < 3; i++ {
+		if name := receive(t, invalid, "invalid signature"); name != "message" {
+			t.Fatal("invalid signature of:", name)
+		}
+	}
+	select {
+	case m := <-received:
+		t.Fatal("message with the invalid signature is delivered:", m)
+	default:
+	}
+}
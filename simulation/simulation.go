@@ -0,0 +1,303 @@
+package simulation
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio"
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+const (
+	DefaultQuiet        = 5 * time.Millisecond
+	DefaultPingInterval = 30 * time.Second
+	DefaultPingTimeout  = 60 * time.Second
+
+	simulationAddr = "memory://simulation"
+)
+
+var (
+	ErrorLinkBroken = errors.New("simulated link is broken")
+	ErrorClosed     = errors.New("simulation is closed")
+	errNotHTTP      = errors.New("simulation does not serve HTTP requests")
+)
+
+// Direction of the frame on the link
+type Direction int
+
+const (
+	ToServer Direction = iota
+	ToClient
+)
+
+// frame is a message written into the link and not delivered yet
+type frame struct {
+	message string
+	at      time.Time // frame is not delivered before this moment of the simulation clock
+}
+
+// Simulation runs the server and many fake clients in one process connected with in-memory links.
+// Frames are delivered only by Step, Settle or Advance, in the order chosen by the seeded random source,
+// frames of the single link direction are delivered in order they were written.
+// Timers of the server and the clients use the simulation clock, so timeouts fire only by Advance
+type Simulation struct {
+	Server *gosocketio.Server
+	Clock  *gosocketio.ManualClock
+
+	// Quiet is a real time to wait for the goroutines to write new frames before the simulation is settled
+	Quiet time.Duration
+
+	// ping params of the links connected from now on
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+
+	rand    *rand.Rand
+	links   []*Link
+	acceptC chan transport.Connection
+	closedC chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+}
+
+// New returns a simulation of the new server with the random source seeded by seed,
+// simulations with the same seed and the same actions deliver frames in the same order
+func New(seed int64) *Simulation {
+	s := &Simulation{
+		Server:       gosocketio.NewServer(),
+		Clock:        gosocketio.NewManualClock(time.Unix(0, 0)),
+		Quiet:        DefaultQuiet,
+		PingInterval: DefaultPingInterval,
+		PingTimeout:  DefaultPingTimeout,
+		rand:         rand.New(rand.NewSource(seed)),
+		acceptC:      make(chan transport.Connection),
+		closedC:      make(chan struct{}),
+	}
+	s.Server.SetClock(s.Clock)
+	go s.Server.Serve((*acceptor)(s))
+	return s
+}
+
+// Connect a new fake client to the server, its OnConnection handler fires after the open sequence is delivered
+func (s *Simulation) Connect() (*Link, error) {
+	tr := &clientTransport{sim: s}
+	client, err := gosocketio.Dial(simulationAddr, tr)
+	if err != nil {
+		return nil, err
+	}
+	client.SetClock(s.Clock)
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.client, tr.last.client = client, client
+	return tr.last, nil
+}
+
+// Links returns all the links connected to the simulated server in order they were connected
+func (s *Simulation) Links() []*Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Link(nil), s.links...)
+}
+
+// Step delivers a single frame ready for delivery, it returns false if there are no such frames
+func (s *Simulation) Step() bool {
+	s.mu.Lock()
+	now := s.Clock.Now()
+
+	type candidate struct {
+		link *Link
+		d    Direction
+	}
+	var ready []candidate
+	for _, link := range s.links {
+		for _, d := range []Direction{ToServer, ToClient} {
+			if q := link.queues[d]; len(q) > 0 && !q[0].at.After(now) {
+				ready = append(ready, candidate{link, d})
+			}
+		}
+	}
+
+	if len(ready) == 0 {
+		s.mu.Unlock()
+		return false
+	}
+
+	chosen := ready[s.rand.Intn(len(ready))]
+	f := chosen.link.queues[chosen.d][0]
+	chosen.link.queues[chosen.d] = chosen.link.queues[chosen.d][1:]
+	s.mu.Unlock()
+
+	chosen.link.ends[chosen.d].MemoryConnection.WriteMessage(f.message) // peer end receives it
+	return true
+}
+
+// Settle delivers frames until nothing is written within the Quiet period, it returns an amount of delivered frames
+func (s *Simulation) Settle() int {
+	delivered := 0
+	for {
+		for s.Step() {
+			delivered++
+		}
+		time.Sleep(s.Quiet)
+		if !s.Step() {
+			return delivered
+		}
+		delivered++
+	}
+}
+
+// Advance the simulation clock by d in the given amount of steps settling the simulation after each one,
+// it returns an amount of delivered frames
+func (s *Simulation) Advance(d time.Duration, steps int) int {
+	if steps < 1 {
+		steps = 1
+	}
+
+	delivered := s.Settle()
+	for i := 0; i < steps; i++ {
+		s.Clock.Advance(d / time.Duration(steps))
+		delivered += s.Settle()
+	}
+	return delivered
+}
+
+// Close the simulation, clients and server channels are closed
+func (s *Simulation) Close() {
+	s.once.Do(func() { close(s.closedC) })
+	for _, link := range s.Links() {
+		if link.client != nil {
+			link.client.Close()
+		}
+	}
+}
+
+// Link is a simulated network connection between the fake client and the server
+type Link struct {
+	sim    *Simulation
+	client *gosocketio.Client
+	ends   [2]*endpoint // indexed by direction of the frames written into the end
+
+	queues [2][]frame
+	drops  [2]int
+	delays [2]time.Duration
+	broken bool
+}
+
+// Client returns the fake client of the link
+func (l *Link) Client() *gosocketio.Client { return l.client }
+
+// Drop the next n frames written in the direction d
+func (l *Link) Drop(d Direction, n int) {
+	l.sim.mu.Lock()
+	l.drops[d] += n
+	l.sim.mu.Unlock()
+}
+
+// Delay the frames written in the direction d from now on by the simulation time delay, zero disables delaying
+func (l *Link) Delay(d Direction, delay time.Duration) {
+	l.sim.mu.Lock()
+	l.delays[d] = delay
+	l.sim.mu.Unlock()
+}
+
+// Pending returns an amount of frames written in the direction d and not delivered yet
+func (l *Link) Pending(d Direction) int {
+	l.sim.mu.Lock()
+	defer l.sim.mu.Unlock()
+	return len(l.queues[d])
+}
+
+// Disconnect the link as if the network failed, undelivered frames are lost
+func (l *Link) Disconnect() {
+	l.sim.mu.Lock()
+	l.broken = true
+	l.queues = [2][]frame{}
+	l.sim.mu.Unlock()
+
+	l.ends[ToServer].Break()
+}
+
+// write the frame in the direction d
+func (l *Link) write(d Direction, message string) error {
+	l.sim.mu.Lock()
+	defer l.sim.mu.Unlock()
+
+	if l.broken {
+		return ErrorLinkBroken
+	}
+	if l.drops[d] > 0 {
+		l.drops[d]--
+		return nil
+	}
+	l.queues[d] = append(l.queues[d], frame{message: message, at: l.sim.Clock.Now().Add(l.delays[d])})
+	return nil
+}
+
+// endpoint is a link end, frames written into it are queued in the simulation until delivered
+type endpoint struct {
+	*transport.MemoryConnection
+	link *Link
+	d    Direction
+}
+
+// WriteMessage queues the frame for delivery to the peer end
+func (e *endpoint) WriteMessage(message string) error { return e.link.write(e.d, message) }
+
+// clientTransport connects the fake client to the simulated server, each connection creates a new link
+type clientTransport struct {
+	sim    *Simulation
+	client *gosocketio.Client // set after dialing
+	last   *Link              // link created by the last Connect call
+	mu     sync.Mutex
+}
+
+// Connect creates a new link and returns it's client end, the server end is accepted by the server
+func (t *clientTransport) Connect(string) (transport.Connection, error) {
+	s := t.sim
+	clientEnd, serverEnd := transport.MemoryPipe(s.PingInterval, s.PingTimeout)
+	link := &Link{sim: s}
+	link.ends[ToServer] = &endpoint{MemoryConnection: clientEnd, link: link, d: ToServer}
+	link.ends[ToClient] = &endpoint{MemoryConnection: serverEnd, link: link, d: ToClient}
+
+	t.mu.Lock()
+	link.client, t.last = t.client, link
+	t.mu.Unlock()
+
+	select {
+	case s.acceptC <- link.ends[ToClient]:
+	case <-s.closedC:
+		return nil, ErrorClosed
+	}
+
+	s.mu.Lock()
+	s.links = append(s.links, link)
+	s.mu.Unlock()
+	return link.ends[ToServer], nil
+}
+
+// HandleConnection fails, the simulation does not serve HTTP requests
+func (t *clientTransport) HandleConnection(w http.ResponseWriter, r *http.Request) (transport.Connection, error) {
+	return nil, errNotHTTP
+}
+
+// Serve does nothing, the simulation does not serve HTTP requests
+func (t *clientTransport) Serve(w http.ResponseWriter, r *http.Request) {}
+
+// SetSid does nothing for the simulation
+func (t *clientTransport) SetSid(string, transport.Connection) {}
+
+// acceptor passes the server ends of the links to the simulated server
+type acceptor Simulation
+
+// Accept waits for the fake client to connect
+func (a *acceptor) Accept() (transport.Connection, error) {
+	select {
+	case conn := <-a.acceptC:
+		return conn, nil
+	case <-a.closedC:
+		return nil, ErrorClosed
+	}
+}
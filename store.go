@@ -0,0 +1,43 @@
+package gosocketio
+
+// Set the value with the given key in the Channel store
+func (c *Channel) Set(key string, value interface{}) {
+	c.storeMu.Lock()
+	c.store[key] = value
+	c.storeMu.Unlock()
+}
+
+// Get returns the value stored with the given key in the Channel store,
+// the second value is true if such key exists
+func (c *Channel) Get(key string) (interface{}, bool) {
+	c.storeMu.RLock()
+	defer c.storeMu.RUnlock()
+	value, ok := c.store[key]
+	return value, ok
+}
+
+// Delete the value with the given key from the Channel store
+func (c *Channel) Delete(key string) {
+	c.storeMu.Lock()
+	delete(c.store, key)
+	c.storeMu.Unlock()
+}
+
+// storeCopy returns a copy of the Channel store
+func (c *Channel) storeCopy() map[string]interface{} {
+	c.storeMu.RLock()
+	defer c.storeMu.RUnlock()
+
+	store := make(map[string]interface{}, len(c.store))
+	for key, value := range c.store {
+		store[key] = value
+	}
+	return store
+}
+
+// setStore replaces the Channel store with the given one
+func (c *Channel) setStore(store map[string]interface{}) {
+	c.storeMu.Lock()
+	c.store = store
+	c.storeMu.Unlock()
+}
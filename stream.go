@@ -0,0 +1,384 @@
+package gosocketio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+const (
+	streamOpenEvent   = "sio:stream:open"
+	streamDataEvent   = "sio:stream:data"
+	streamCreditEvent = "sio:stream:credit"
+	streamCloseEvent  = "sio:stream:close"
+
+	// StreamWindow is an amount of chunks the stream writer may send before the reader consumes them
+	StreamWindow = 16
+)
+
+var (
+	ErrorStreamClosed    = errors.New("stream is closed")
+	ErrorNoStreamHandler = errors.New("no stream handler")
+	ErrorStreamOverflow  = errors.New("stream window exceeded by the peer")
+
+	streamIDs uint64
+)
+
+// streamOpen is a payload of the stream opening call
+type streamOpen struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// streamChunk is a payload of the stream data event
+type streamChunk struct {
+	ID   string          `json:"id"`
+	Seq  int             `json:"seq"`
+	Data json.RawMessage `json:"data"`
+}
+
+// streamCredit is a payload of the stream credit event, the reader allows the writer to send more chunks
+type streamCredit struct {
+	ID      string `json:"id"`
+	Credits int    `json:"credits"`
+}
+
+// streamClose is a payload of the stream close event, Seq is an amount of chunks written before closing
+type streamClose struct {
+	ID  string `json:"id"`
+	Seq int    `json:"seq"`
+}
+
+// Stream is a bidirectional ordered sequence of chunks between the Channel sides, opened with Channel.OpenStream()
+// and accepted by the handler registered with OnStream. Each direction is closed independently,
+// the writer is blocked while the reader has StreamWindow chunks not consumed
+type Stream struct {
+	id, name string
+	c        *Channel
+
+	credits  int // chunks the writer may send
+	written  int // seq of the next written chunk
+	closed   bool
+	failed   error // set if the Channel is closed
+	read     int   // seq of the next chunk to read
+	consumed int   // chunks read and not credited back
+	received map[int]json.RawMessage
+	total    int // amount of chunks written by the peer, -1 until the peer closes the stream
+	mu       sync.Mutex
+	cond     *sync.Cond
+}
+
+// newStream returns the stream with the given id and name on channel c
+func newStream(c *Channel, id, name string) *Stream {
+	s := &Stream{id: id, name: name, c: c, credits: StreamWindow, received: make(map[int]json.RawMessage), total: -1}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// ID returns the stream id
+func (s *Stream) ID() string { return s.id }
+
+// Name returns the stream name
+func (s *Stream) Name() string { return s.name }
+
+// wakeOnDone wakes up the waiting reader or writer when ctx is done, returned func stops watching ctx
+func (s *Stream) wakeOnDone(ctx context.Context) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	stopC := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.cond.Broadcast()
+			s.mu.Unlock()
+		case <-stopC:
+		}
+	}()
+	return func() { close(stopC) }
+}
+
+// Write the chunk v to the peer, it blocks while the peer has StreamWindow chunks not consumed
+func (s *Stream) Write(v interface{}) error { return s.WriteContext(context.Background(), v) }
+
+// WriteContext acts like Write but gives up waiting for the peer when ctx is done
+func (s *Stream) WriteContext(ctx context.Context, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	defer s.wakeOnDone(ctx)()
+
+	s.mu.Lock()
+	for s.credits == 0 && !s.closed && s.failed == nil && ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	switch {
+	case s.failed != nil:
+		s.mu.Unlock()
+		return s.failed
+	case s.closed:
+		s.mu.Unlock()
+		return ErrorStreamClosed
+	case ctx.Err() != nil:
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+	s.credits--
+	chunk := streamChunk{ID: s.id, Seq: s.written, Data: data}
+	s.written++
+	c := s.c
+	s.mu.Unlock()
+
+	return c.Emit(streamDataEvent, chunk)
+}
+
+// Read the next chunk from the peer into v, it returns io.EOF when the peer closed the stream
+func (s *Stream) Read(v interface{}) error { return s.ReadContext(context.Background(), v) }
+
+// ReadContext acts like Read but gives up waiting for the chunk when ctx is done
+func (s *Stream) ReadContext(ctx context.Context, v interface{}) error {
+	defer s.wakeOnDone(ctx)()
+
+	s.mu.Lock()
+	for {
+		if data, ok := s.received[s.read]; ok {
+			delete(s.received, s.read)
+			s.read++
+			s.consumed++
+			credits, c := 0, s.c
+			if s.consumed >= StreamWindow/2 {
+				credits, s.consumed = s.consumed, 0
+			}
+			s.mu.Unlock()
+
+			if credits > 0 {
+				c.Emit(streamCreditEvent, streamCredit{ID: s.id, Credits: credits})
+			}
+			return json.Unmarshal(data, v)
+		}
+
+		switch {
+		case s.total >= 0 && s.read >= s.total:
+			c := s.c
+			s.mu.Unlock()
+			c.releaseStream(s)
+			return io.EOF
+		case s.failed != nil:
+			s.mu.Unlock()
+			return s.failed
+		case ctx.Err() != nil:
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+		s.cond.Wait()
+	}
+}
+
+// Close the writing direction of the stream, the peer reads io.EOF after the chunks written before.
+// The stream is released when both directions are closed and the peer chunks are read
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	message, c := streamClose{ID: s.id, Seq: s.written}, s.c
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	c.releaseStream(s)
+	return c.Emit(streamCloseEvent, message)
+}
+
+// done returns true if both directions of the stream are closed and all the peer chunks are read,
+// s.mu should be locked
+func (s *Stream) done() bool { return s.closed && s.total >= 0 && s.read >= s.total }
+
+// receive the chunk from the peer, the stream fails with ErrorStreamOverflow if the peer
+// sends the chunk beyond StreamWindow not consumed ones
+func (s *Stream) receive(chunk streamChunk) {
+	s.mu.Lock()
+	overflow := false
+	switch {
+	case s.failed != nil:
+	case chunk.Seq >= s.read+StreamWindow:
+		overflow = true
+		s.failed, s.received = ErrorStreamOverflow, nil
+	case chunk.Seq >= s.read:
+		s.received[chunk.Seq] = chunk.Data
+	}
+	s.cond.Broadcast()
+	c := s.c
+	s.mu.Unlock()
+
+	if overflow {
+		logging.Log().Warnf("Stream.receive() stream %s failed, chunk %d is beyond the window", s.id, chunk.Seq)
+		c.streamsMu.Lock()
+		delete(c.streams, s.id)
+		c.streamsMu.Unlock()
+	}
+}
+
+// credit the writer with chunks consumed by the peer
+func (s *Stream) credit(credits int) {
+	s.mu.Lock()
+	s.credits += credits
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// remoteClose marks the peer writing direction closed after total chunks
+func (s *Stream) remoteClose(total int) {
+	s.mu.Lock()
+	s.total = total
+	s.cond.Broadcast()
+	c := s.c
+	s.mu.Unlock()
+
+	c.releaseStream(s)
+}
+
+// fail the stream with err, it's called when the Channel is closed
+func (s *Stream) fail(err error) {
+	s.mu.Lock()
+	s.failed = err
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// OnStream registers the handler f of the streams with the given name opened by the peer,
+// it's called in a separate goroutine for each stream
+func (e *event) OnStream(name string, f func(c *Channel, s *Stream)) {
+	e.handlersMu.Lock()
+	if e.streamHandlers == nil {
+		e.streamHandlers = make(map[string]func(c *Channel, s *Stream))
+	}
+	e.streamHandlers[name] = f
+	e.handlersMu.Unlock()
+}
+
+// streamHandler returns the handler of the streams with the given name
+func (e *event) streamHandler(name string) (func(c *Channel, s *Stream), bool) {
+	e.handlersMu.RLock()
+	defer e.handlersMu.RUnlock()
+	f, ok := e.streamHandlers[name]
+	return f, ok
+}
+
+// initStreams registers the handlers of the stream events
+func (e *event) initStreams() {
+	e.OnCall(streamOpenEvent, func(c *Channel, open streamOpen) (bool, error) {
+		f, ok := e.streamHandler(open.Name)
+		if !ok {
+			return false, NewCallError(http.StatusNotFound, ErrorNoStreamHandler.Error(), open.Name)
+		}
+		s := newStream(c, open.ID, open.Name)
+		c.registerStream(s)
+		go f(c, s)
+		return true, nil
+	})
+	e.On(streamDataEvent, func(c *Channel, chunk streamChunk) {
+		if s, ok := c.stream(chunk.ID); ok {
+			s.receive(chunk)
+		}
+	})
+	e.On(streamCreditEvent, func(c *Channel, credit streamCredit) {
+		if s, ok := c.stream(credit.ID); ok {
+			s.credit(credit.Credits)
+		}
+	})
+	e.On(streamCloseEvent, func(c *Channel, message streamClose) {
+		if s, ok := c.stream(message.ID); ok {
+			s.remoteClose(message.Seq)
+		}
+	})
+}
+
+// OpenStream opens the stream with the given name accepted by the peer OnStream handler
+func (c *Channel) OpenStream(name string) (*Stream, error) {
+	side := "c"
+	if c.server != nil {
+		side = "s"
+	}
+	s := newStream(c, side+strconv.FormatUint(atomic.AddUint64(&streamIDs, 1), 10), name)
+	c.registerStream(s)
+
+	if err := c.Call(context.Background(), streamOpenEvent, streamOpen{ID: s.id, Name: name}, nil); err != nil {
+		c.streamsMu.Lock()
+		delete(c.streams, s.id)
+		c.streamsMu.Unlock()
+		return nil, err
+	}
+	return s, nil
+}
+
+// registerStream s on the Channel
+func (c *Channel) registerStream(s *Stream) {
+	c.streamsMu.Lock()
+	if c.streams == nil {
+		c.streams = make(map[string]*Stream)
+	}
+	c.streams[s.id] = s
+	c.streamsMu.Unlock()
+}
+
+// stream returns the stream with the given id
+func (c *Channel) stream(id string) (*Stream, bool) {
+	c.streamsMu.Lock()
+	defer c.streamsMu.Unlock()
+	s, ok := c.streams[id]
+	return s, ok
+}
+
+// releaseStream s if both its directions are closed
+func (c *Channel) releaseStream(s *Stream) {
+	s.mu.Lock()
+	done := s.done()
+	s.mu.Unlock()
+	if !done {
+		return
+	}
+
+	c.streamsMu.Lock()
+	delete(c.streams, s.id)
+	c.streamsMu.Unlock()
+}
+
+// failStreams fails all the streams of the closed Channel
+func (c *Channel) failStreams() {
+	c.streamsMu.Lock()
+	streams := c.streams
+	c.streams = nil
+	c.streamsMu.Unlock()
+
+	for _, s := range streams {
+		s.fail(ErrorClosed)
+	}
+}
+
+// moveStreams of channel from to channel to at transport upgrade
+func (c *Channel) moveStreams(to *Channel) {
+	c.streamsMu.Lock()
+	streams := c.streams
+	c.streams = nil
+	c.streamsMu.Unlock()
+
+	for _, s := range streams {
+		s.mu.Lock()
+		s.c = to
+		s.mu.Unlock()
+		to.registerStream(s)
+	}
+}
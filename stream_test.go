@@ -0,0 +1,29 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStreamReceiveWindow(t *testing.T) {
+	c := &Channel{}
+	c.init()
+	s := newStream(c, "id", "name")
+	c.streams = map[string]*Stream{s.id: s}
+
+	for seq := 1; seq < StreamWindow; seq++ { // out of order within the window
+		s.receive(streamChunk{ID: s.id, Seq: seq, Data: json.RawMessage(`1`)})
+	}
+	if len(s.received) != StreamWindow-1 || s.failed != nil {
+		t.Fatalf("chunks within the window: %d received, failed: %v", len(s.received), s.failed)
+	}
+
+	s.receive(streamChunk{ID: s.id, Seq: StreamWindow, Data: json.RawMessage(`1`)})
+	var v int
+	if err := s.Read(&v); err != ErrorStreamOverflow {
+		t.Fatal("chunk beyond the window, read err:", err)
+	}
+	if len(s.received) != 0 || len(c.streams) != 0 {
+		t.Fatalf("failed stream keeps %d chunks, %d streams registered", len(s.received), len(c.streams))
+	}
+}
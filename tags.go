@@ -0,0 +1,149 @@
+package gosocketio
+
+// tagIndex maps tags to channels and channels to tags, tags are not a part of the socket.io protocol,
+// they are known only to the server
+type tagIndex struct {
+	channels map[string]map[*Channel]struct{} // maps tag to map of channels to an empty struct
+	tags     map[*Channel]map[string]struct{} // maps channel to map of tags to an empty struct
+}
+
+// newTagIndex returns an empty tag index
+func newTagIndex() tagIndex {
+	return tagIndex{
+		channels: make(map[string]map[*Channel]struct{}),
+		tags:     make(map[*Channel]map[string]struct{}),
+	}
+}
+
+// add tag to channel c
+func (t tagIndex) add(c *Channel, tag string) {
+	if _, ok := t.channels[tag]; !ok {
+		t.channels[tag] = make(map[*Channel]struct{})
+	}
+	if _, ok := t.tags[c]; !ok {
+		t.tags[c] = make(map[string]struct{})
+	}
+	t.channels[tag][c], t.tags[c][tag] = struct{}{}, struct{}{}
+}
+
+// remove tag from channel c
+func (t tagIndex) remove(c *Channel, tag string) {
+	if tagged, ok := t.channels[tag]; ok {
+		delete(tagged, c)
+		if len(tagged) == 0 {
+			delete(t.channels, tag)
+		}
+	}
+	if tags, ok := t.tags[c]; ok {
+		delete(tags, tag)
+		if len(tags) == 0 {
+			delete(t.tags, c)
+		}
+	}
+}
+
+// removeAll tags from channel c
+func (t tagIndex) removeAll(c *Channel) {
+	for tag := range t.tags[c] {
+		t.remove(c, tag)
+	}
+}
+
+// Tag attaches the given tags (e.g. "platform:ios" or "version:1.2") to the channel,
+// tagged channels can be found with Server.Tagged() and targeted with Server.BroadcastToTagged()
+func (c *Channel) Tag(tags ...string) error {
+	if c.server == nil {
+		return ErrorServerNotSet
+	}
+
+	c.server.tagsMu.Lock()
+	defer c.server.tagsMu.Unlock()
+
+	for _, tag := range tags {
+		c.server.tags.add(c, tag)
+	}
+	return nil
+}
+
+// Untag detaches the given tags from the channel
+func (c *Channel) Untag(tags ...string) error {
+	if c.server == nil {
+		return ErrorServerNotSet
+	}
+
+	c.server.tagsMu.Lock()
+	defer c.server.tagsMu.Unlock()
+
+	for _, tag := range tags {
+		c.server.tags.remove(c, tag)
+	}
+	return nil
+}
+
+// HasTag returns true if the given tag is attached to the channel
+func (c *Channel) HasTag(tag string) bool {
+	if c.server == nil {
+		return false
+	}
+
+	c.server.tagsMu.RLock()
+	defer c.server.tagsMu.RUnlock()
+
+	_, ok := c.server.tags.tags[c][tag]
+	return ok
+}
+
+// Tags returns a list of tags attached to the channel
+func (c *Channel) Tags() []string {
+	if c.server == nil {
+		return []string{}
+	}
+
+	c.server.tagsMu.RLock()
+	defer c.server.tagsMu.RUnlock()
+
+	tags := make([]string, 0, len(c.server.tags.tags[c]))
+	for tag := range c.server.tags.tags[c] {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Tagged returns a list of channels with the given tag attached
+func (s *Server) Tagged(tag string) []*Channel {
+	s.tagsMu.RLock()
+	defer s.tagsMu.RUnlock()
+
+	channels := make([]*Channel, 0, len(s.tags.channels[tag]))
+	for c := range s.tags.channels[tag] {
+		channels = append(channels, c)
+	}
+	return channels
+}
+
+// BroadcastToTagged emits an event with given name and payload to all the channels with the given tag attached
+func (s *Server) BroadcastToTagged(tag, name string, payload interface{}) {
+	for _, c := range s.Tagged(tag) {
+		if c.IsAlive() {
+			go c.Emit(name, payload)
+		}
+	}
+}
+
+// moveTags attaches all tags of channel from to channel to, and detaches them from channel from
+func (s *Server) moveTags(from, to *Channel) {
+	s.tagsMu.Lock()
+	defer s.tagsMu.Unlock()
+
+	for tag := range s.tags.tags[from] {
+		s.tags.add(to, tag)
+	}
+	s.tags.removeAll(from)
+}
+
+// untagAll detaches all tags from channel c
+func (s *Server) untagAll(c *Channel) {
+	s.tagsMu.Lock()
+	s.tags.removeAll(c)
+	s.tagsMu.Unlock()
+}
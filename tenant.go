@@ -0,0 +1,202 @@
+package gosocketio
+
+import (
+	"errors"
+	"strings"
+)
+
+// tenantSeparator separates the tenant id from the room name in the scoped room names
+const tenantSeparator = "\x00"
+
+// ErrorRoomName means the room name contains the tenant separator, such a name could reach the rooms
+// of another tenant
+var ErrorRoomName = errors.New("room name contains the tenant separator")
+
+// TenantResolver returns the tenant of the channel at handshake, the connection is rejected
+// with the error event of 403 code if it returns an error. Empty tenant is the global one
+type TenantResolver func(c *Channel) (string, error)
+
+// TenantPolicy overrides the server settings for the channels of the tenant
+type TenantPolicy struct {
+	Metrics   Metrics         // observations of the tenant events, the server metrics are used if nil
+	Bandwidth *BandwidthQuota // bandwidth quota of the tenant connections, the server quota is used if nil
+}
+
+// Tenant is a view of the server restricted to the channels of a single tenant
+type Tenant struct {
+	ID string
+	s  *Server
+}
+
+// SetTenantResolver sets the resolver assigning the tenant to each channel at handshake, nil disables tenancy.
+// Room names of the channels are scoped by the tenant, so the tenants never share rooms
+func (s *Server) SetTenantResolver(r TenantResolver) {
+	s.tenantsMu.Lock()
+	s.tenantResolver = r
+	s.tenantsMu.Unlock()
+}
+
+// SetTenantPolicy sets the policy of the tenant with the given id
+func (s *Server) SetTenantPolicy(id string, p TenantPolicy) {
+	s.tenantsMu.Lock()
+	if s.tenantPolicies == nil {
+		s.tenantPolicies = make(map[string]TenantPolicy)
+	}
+	s.tenantPolicies[id] = p
+	s.tenantsMu.Unlock()
+}
+
+// tenantPolicy returns the policy of the tenant with the given id
+func (s *Server) tenantPolicy(id string) (TenantPolicy, bool) {
+	s.tenantsMu.RLock()
+	defer s.tenantsMu.RUnlock()
+	p, ok := s.tenantPolicies[id]
+	return p, ok
+}
+
+// resolveTenant assigns the tenant to the new channel c
+func (s *Server) resolveTenant(c *Channel) error {
+	s.tenantsMu.RLock()
+	r := s.tenantResolver
+	s.tenantsMu.RUnlock()
+	if r == nil {
+		return nil
+	}
+
+	tenant, err := r(c)
+	if err != nil {
+		return err
+	}
+	c.tenant = tenant
+	return nil
+}
+
+// Tenant returns the view of the server restricted to the tenant with the given id
+func (s *Server) Tenant(id string) Tenant { return Tenant{ID: id, s: s} }
+
+// Tenant returns the tenant id of the Channel, empty for the global tenant
+func (c *Channel) Tenant() string { return c.tenant }
+
+// scopedRoom returns the internal name of the room of the given tenant
+func scopedRoom(tenant, room string) string {
+	if tenant == "" {
+		return room
+	}
+	return tenant + tenantSeparator + room
+}
+
+// validRoom returns whether the room name given by the application may be scoped
+func validRoom(room string) bool { return !strings.Contains(room, tenantSeparator) }
+
+// unscopedRoom returns the room name of the given tenant by its internal name
+func unscopedRoom(tenant, room string) string {
+	if tenant == "" {
+		return room
+	}
+	return strings.TrimPrefix(room, tenant+tenantSeparator)
+}
+
+// metricsOf returns the metrics observing the events of channel c, it may be nil
+func (e *event) metricsOf(c *Channel) Metrics {
+	if c != nil && c.tenant != "" && c.server != nil {
+		if p, ok := c.server.tenantPolicy(c.tenant); ok && p.Metrics != nil {
+			return p.Metrics
+		}
+	}
+	return e.getMetrics()
+}
+
+// bandwidthQuotaOf returns the bandwidth quota of channel c and the handler of exceeding it, e may be nil
+func (e *event) bandwidthQuotaOf(c *Channel) (BandwidthQuota, func(c *Channel, rate int)) {
+	q, f := e.bandwidthPolicy()
+	if c.tenant != "" && c.server != nil {
+		if p, ok := c.server.tenantPolicy(c.tenant); ok && p.Bandwidth != nil {
+			q = *p.Bandwidth
+			if q.Burst <= 0 {
+				q.Burst = q.BytesPerSecond
+			}
+		}
+	}
+	return q, f
+}
+
+// Channels returns the connected channels of the tenant
+func (t Tenant) Channels() []*Channel {
+	var channels []*Channel
+	for _, c := range t.s.channelsList() {
+		if c.tenant == t.ID {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
+
+// Amount returns an amount of the tenant channels joined to the given room
+func (t Tenant) Amount(room string) int {
+	if !validRoom(room) {
+		return 0
+	}
+	return t.s.amount(scopedRoom(t.ID, room))
+}
+
+// List returns the tenant channels joined to the given room
+func (t Tenant) List(room string) []*Channel {
+	if !validRoom(room) {
+		return []*Channel{}
+	}
+	return t.s.list(scopedRoom(t.ID, room))
+}
+
+// BroadcastTo the tenant channels joined to the given room an event with the given name and payload
+func (t Tenant) BroadcastTo(room, name string, payload interface{}) {
+	if validRoom(room) {
+		t.s.broadcastTo(scopedRoom(t.ID, room), name, payload)
+	}
+}
+
+// RoomState returns the shared state of the tenant room, see Server.RoomState
+func (t Tenant) RoomState(room string) (*RoomState, error) {
+	if !validRoom(room) {
+		return nil, ErrorRoomName
+	}
+	return t.s.roomStateOf(scopedRoom(t.ID, room)), nil
+}
+
+// DeleteRoomState removes the shared state of the tenant room
+func (t Tenant) DeleteRoomState(room string) {
+	if validRoom(room) {
+		t.s.deleteRoomState(scopedRoom(t.ID, room))
+	}
+}
+
+// RoomCounter returns the replicated counter of the tenant room, see Server.RoomCounter
+func (t Tenant) RoomCounter(room, name string) (*RoomCounter, error) {
+	if !validRoom(room) {
+		return nil, ErrorRoomName
+	}
+	return t.s.roomCounter(scopedRoom(t.ID, room), name)
+}
+
+// RoomSet returns the replicated set of the tenant room, see Server.RoomSet
+func (t Tenant) RoomSet(room, name string) (*RoomSet, error) {
+	if !validRoom(room) {
+		return nil, ErrorRoomName
+	}
+	return t.s.roomSet(scopedRoom(t.ID, room), name)
+}
+
+// DeleteRoomCRDTs removes the counters and sets of the tenant room
+func (t Tenant) DeleteRoomCRDTs(room string) {
+	if validRoom(room) {
+		t.s.deleteRoomCRDTs(scopedRoom(t.ID, room))
+	}
+}
+
+// BroadcastToAll the tenant channels an event with the given name and payload
+func (t Tenant) BroadcastToAll(name string, payload interface{}) {
+	for _, c := range t.Channels() {
+		if c.IsAlive() {
+			go c.Emit(name, payload)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestTenantScoping(t *testing.T) {
+	s := NewServer()
+	s.SetTenantResolver(func(c *Channel) (string, error) { return c.RequestHeader().Get("X-Tenant"), nil })
+	connected := make(chan *Channel, 2)
+	s.On(OnConnection, func(c *Channel) {
+		c.Join("lobby")
+		connected <- c
+	})
+	host, port, stop := serve(t, s)
+	defer stop()
+
+	events := map[string]chan string{}
+	for _, tenant := range []string{"a", ""} {
+		tr := transport.DefaultWebsocketTransport()
+		tr.Headers = http.Header{"X-Tenant": []string{tenant}}
+		c, err := Dial(AddrWebsocket(host, port, false), tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+
+		received := make(chan string, 4)
+		events[tenant] = received
+		c.OnRoomState(func(ch *Channel, room string, state json.RawMessage) { received <- room + " " + string(state) })
+		c.On("notice", func(ch *Channel, m string) { received <- m })
+		<-connected
+	}
+
+	state, err := s.Tenant("a").RoomState("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.UpdateState(map[string]string{"k": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if e := receive(t, events["a"], "tenant room state"); e != `lobby {"k":"a"}` {
+		t.Fatal("tenant room state:", e)
+	}
+
+	s.BroadcastToAll("notice", "global")
+	if e := receive(t, events[""], "global broadcast"); e != "global" {
+		t.Fatal("global tenant event:", e)
+	}
+	s.Tenant("a").BroadcastToAll("notice", "tenant")
+	if e := receive(t, events["a"], "tenant broadcast"); e != "tenant" {
+		t.Fatal("tenant event:", e)
+	}
+
+	select {
+	case e := <-events[""]:
+		t.Fatal("global tenant got the event of the tenant:", e)
+	case e := <-events["a"]:
+		t.Fatal("tenant got the event of the global tenant:", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestTenantRoomNameSeparator(t *testing.T) {
+	s := NewServer()
+	c := &Channel{conn: stubConnection{}, server: s}
+	c.init()
+	room := "a" + tenantSeparator + "lobby"
+
+	if err := c.Join(room); err != ErrorRoomName {
+		t.Fatal("join of the room of another tenant:", err)
+	}
+	if len(s.Tenant("a").List("lobby")) != 0 {
+		t.Fatal("global channel is joined to the tenant room")
+	}
+	if _, err := s.RoomState(room); err != ErrorRoomName {
+		t.Fatal("room state of another tenant:", err)
+	}
+	if _, err := s.RoomSet(room, "online"); err != ErrorRoomName {
+		t.Fatal("room set of another tenant:", err)
+	}
+	if _, err := s.RoomCounter(room, "likes"); err != ErrorRoomName {
+		t.Fatal("room counter of another tenant:", err)
+	}
+
+	tenant := &Channel{conn: stubConnection{}, server: s, tenant: "a"}
+	tenant.init()
+	if err := tenant.Join("lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Amount(room) != 0 || len(s.List(room)) != 0 {
+		t.Fatal("tenant room is reached by the global name")
+	}
+}
+
+func TestTenantResumeOfAnotherTenant(t *testing.T) {
+	store := NewMemorySessionStore()
+	s := NewServer()
+	s.SetResumption([]byte("secret"), time.Minute, store)
+	s.SetTenantResolver(func(c *Channel) (string, error) { return c.RequestHeader().Get("X-Tenant"), nil })
+	connected := make(chan *Channel, 2)
+	s.On(OnConnection, func(c *Channel) { connected <- c })
+	host, port, stop := serve(t, s)
+	defer stop()
+	addr := AddrWebsocket(host, port, false)
+
+	// dial connects the client of the tenant
+	dial := func(addr, tenant string) *Client {
+		tr := transport.DefaultWebsocketTransport()
+		tr.Headers = http.Header{"X-Tenant": []string{tenant}}
+		c, err := Dial(addr, tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	first := dial(addr, "a")
+	c := <-connected
+	if err := c.Join("lobby"); err != nil {
+		t.Fatal(err)
+	}
+	token := c.ResumeToken()
+	first.Close()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if session, _ := store.Load(c.SessionID()); session != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session is not suspended")
+		}
+	}
+
+	second := dial(AddrResume(addr, token), "b")
+	defer second.Close()
+	resumed := <-connected
+	if resumed.SessionID() == c.SessionID() || len(resumed.Rooms()) != 0 {
+		t.Fatalf("session of another tenant is resumed: %s, rooms %v", resumed.SessionID(), resumed.Rooms())
+	}
+	if session, _ := store.Load(c.SessionID()); session == nil {
+		t.Fatal("session refused to another tenant is deleted")
+	}
+}
@@ -0,0 +1,122 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// TimeEvent is an ack request answered by the server with ServerTime
+const TimeEvent = "sio:time"
+
+var ErrorNoTimeSamples = errors.New("no time samples")
+
+// ServerTime is a response to the TimeEvent request, times are in milliseconds to be precise for JS clients
+type ServerTime struct {
+	Wall      float64 `json:"wall"`      // unix time
+	Monotonic float64 `json:"monotonic"` // time since the server start, it's not affected by the wall clock changes
+}
+
+// TimeSync is an estimation of the server clock relative to the local one
+type TimeSync struct {
+	Offset  time.Duration // add it to the local time to get the server time
+	RTT     time.Duration // round trip time of the sample the offset is taken from
+	Skew    float64       // drift of the server clock relative to the local one, seconds per second
+	Samples int           // amount of samples answered
+}
+
+// milliseconds returns d in milliseconds
+func milliseconds(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// fromMilliseconds returns a duration of ms milliseconds
+func fromMilliseconds(ms float64) time.Duration { return time.Duration(ms * float64(time.Millisecond)) }
+
+// serveTime registers the TimeEvent handler of the server started at the given moment of the server clock,
+// the monotonic time counts from the moment the clock is set if it's set later
+func (s *Server) serveTime(started time.Time) {
+	s.On(TimeEvent, func(c *Channel) ServerTime {
+		now, since := s.event.now(), s.event.clockSetAt()
+		if since.IsZero() {
+			since = started
+		}
+		return ServerTime{
+			Wall:      milliseconds(time.Duration(now.UnixNano())),
+			Monotonic: milliseconds(now.Sub(since)),
+		}
+	})
+}
+
+// timeSample is a single server time measurement
+type timeSample struct {
+	local  time.Time     // local time when the response arrived
+	offset time.Duration // server time minus local time at the moment of the response
+	rtt    time.Duration
+}
+
+// SyncTime estimates the server clock offset and skew from the given amount of TimeEvent requests sent
+// with interval between them. The offset is taken from the sample with the minimal round trip time,
+// the skew is a slope of the offsets, it needs samples spread in time to be meaningful
+func (c *Channel) SyncTime(samples int, interval, timeout time.Duration) (TimeSync, error) {
+	var measured []timeSample
+	var lastErr error
+	for i := 0; i < samples; i++ {
+		if i > 0 {
+			c.events.sleep(interval)
+		}
+
+		sent := c.events.now()
+		response, err := c.Ack(TimeEvent, nil, timeout)
+		received := c.events.now()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var t ServerTime
+		if err := json.Unmarshal([]byte(response), &t); err != nil {
+			lastErr = err
+			continue
+		}
+
+		rtt := received.Sub(sent)
+		server := time.Unix(0, int64(fromMilliseconds(t.Wall))).Add(rtt / 2)
+		measured = append(measured, timeSample{local: received, offset: server.Sub(received), rtt: rtt})
+	}
+
+	if len(measured) == 0 {
+		if lastErr == nil {
+			lastErr = ErrorNoTimeSamples
+		}
+		return TimeSync{}, lastErr
+	}
+
+	result := TimeSync{Offset: measured[0].offset, RTT: measured[0].rtt, Samples: len(measured)}
+	for _, sample := range measured[1:] {
+		if sample.rtt < result.RTT {
+			result.Offset, result.RTT = sample.offset, sample.rtt
+		}
+	}
+	result.Skew = timeSkew(measured)
+	return result, nil
+}
+
+// timeSkew returns a least squares slope of the sample offsets by the local time
+func timeSkew(samples []timeSample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	var sumX, sumY, sumXX, sumXY float64
+	for _, sample := range samples {
+		x := sample.local.Sub(samples[0].local).Seconds()
+		y := sample.offset.Seconds()
+		sumX, sumY, sumXX, sumXY = sumX+x, sumY+y, sumXX+x*x, sumXY+x*y
+	}
+
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
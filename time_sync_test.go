@@ -0,0 +1,37 @@
+package gosocketio
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/transport"
+)
+
+func TestServerTimeUsesClock(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := NewManualClock(start)
+	s := NewServer()
+	s.SetClock(clock)
+	clock.Advance(5 * time.Second)
+	host, port, stop := serve(t, s)
+	defer stop()
+
+	c, err := Dial(AddrWebsocket(host, port, false), transport.DefaultWebsocketTransport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	response, err := c.Ack(TimeEvent, nil, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st ServerTime
+	if err := json.Unmarshal([]byte(response), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Monotonic != 5000 || fromMilliseconds(st.Wall) != time.Duration(start.Add(5*time.Second).UnixNano()) {
+		t.Fatalf("server time by the manual clock: %+v", st)
+	}
+}
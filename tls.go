@@ -0,0 +1,109 @@
+package gosocketio
+
+import (
+	"crypto/tls"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+)
+
+// TLSReloadInterval is an interval of checking the certificate files for changes
+var TLSReloadInterval = 10 * time.Second
+
+// certReloader keeps the certificate loaded from the files and reloads it when files change
+type certReloader struct {
+	certFile, keyFile string
+
+	cert    *tls.Certificate
+	modTime time.Time
+	mu      sync.RWMutex
+}
+
+// filesModTime returns the latest modification time of the certificate files
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload the certificate if the files were changed since the last load
+func (r *certReloader) reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+	logging.Log().Debug("certReloader.reload() loaded certificate from:", r.certFile)
+	return nil
+}
+
+// getCertificate implements tls.Config GetCertificate
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// watch the certificate files until stopC is closed
+func (r *certReloader) watch(stopC chan struct{}) {
+	ticker := time.NewTicker(TLSReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopC:
+			return
+		case <-ticker.C:
+			if err := r.reload(); err != nil { // keep serving the previous certificate
+				logging.Log().Warn("certReloader.watch() failed to reload certificate:", err)
+			}
+		}
+	}
+}
+
+// ListenAndServeTLSReload listens on the TCP network address addr and serves the socket.io server over TLS.
+// The certificate files are checked every TLSReloadInterval and reloaded when changed,
+// already established connections keep working
+func (s *Server) ListenAndServeTLSReload(addr, certFile, keyFile string) error {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return err
+	}
+
+	stopC := make(chan struct{})
+	defer close(stopC)
+	go r.watch(stopC)
+
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   s,
+		TLSConfig: &tls.Config{GetCertificate: r.getCertificate},
+	}
+	return srv.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,75 @@
+package gosocketio
+
+import (
+	"sync/atomic"
+)
+
+// TrafficStats are cumulative amounts of the packets and their bytes received and sent
+type TrafficStats struct {
+	PacketsIn  int64
+	PacketsOut int64
+	BytesIn    int64
+	BytesOut   int64
+}
+
+// TrafficMetrics is implemented by the Metrics metering the packets of each channel,
+// it's methods are called concurrently for every packet including heartbeats
+type TrafficMetrics interface {
+	PacketReceived(c *Channel, size int) // packet of the given size was received by c
+	PacketSent(c *Channel, size int)     // packet of the given size was written by c
+}
+
+// traffic counts the packets and bytes, it's updated atomically
+type traffic struct {
+	packetsIn, packetsOut, bytesIn, bytesOut int64
+}
+
+var totalTraffic traffic
+
+// add the packet of the given size in the direction d
+func (t *traffic) add(d Direction, size int) {
+	if d == DirectionInbound {
+		atomic.AddInt64(&t.packetsIn, 1)
+		atomic.AddInt64(&t.bytesIn, int64(size))
+		return
+	}
+	atomic.AddInt64(&t.packetsOut, 1)
+	atomic.AddInt64(&t.bytesOut, int64(size))
+}
+
+// stats returns a snapshot of the counters
+func (t *traffic) stats() TrafficStats {
+	return TrafficStats{
+		PacketsIn:  atomic.LoadInt64(&t.packetsIn),
+		PacketsOut: atomic.LoadInt64(&t.packetsOut),
+		BytesIn:    atomic.LoadInt64(&t.bytesIn),
+		BytesOut:   atomic.LoadInt64(&t.bytesOut),
+	}
+}
+
+// TotalTraffic returns the packets and bytes received and sent by all the channels
+func TotalTraffic() TrafficStats { return totalTraffic.stats() }
+
+// Stats returns the packets and bytes received and sent by the Channel, they survive the transport upgrade
+func (c *Channel) Stats() TrafficStats { return c.traffic.stats() }
+
+// account the packet received or sent by the Channel
+func (c *Channel) account(d Direction, packet string) {
+	c.traffic.add(d, len(packet))
+	totalTraffic.add(d, len(packet))
+	c.hooks.packet(c, d, packet)
+	c.server.pluginsPacket(c, d, packet)
+
+	if c.events == nil {
+		return
+	}
+	m, ok := c.events.metricsOf(c).(TrafficMetrics)
+	if !ok {
+		return
+	}
+	if d == DirectionInbound {
+		m.PacketReceived(c, len(packet))
+	} else {
+		m.PacketSent(c, len(packet))
+	}
+}
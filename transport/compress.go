@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptsGzip returns true if the request r allows gzip encoded response
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(v, ";")
+		if strings.TrimSpace(params[0]) != "gzip" {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// writeCompressed writes payload into w compressing it with gzip
+func writeCompressed(w http.ResponseWriter, payload string) error {
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write([]byte(payload)); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,192 @@
+package transport
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FaultDirection selects the frames the faults are injected into
+type FaultDirection int
+
+const (
+	FaultOutbound FaultDirection = 1 << iota // frames written into the connection
+	FaultInbound                             // frames read from the connection
+
+	FaultBoth = FaultOutbound | FaultInbound
+)
+
+// FaultPolicy defines the probabilities of the faults injected into each frame, the random source
+// is seeded with Seed so the same policy injects the same faults into the same sequence of frames
+type FaultPolicy struct {
+	Seed       int64
+	Directions FaultDirection // FaultBoth if zero
+
+	Drop      float64 // frame is lost
+	Duplicate float64 // frame is passed twice
+	Corrupt   float64 // random byte of the frame is replaced
+
+	Delay    float64       // frame is passed after the random delay up to MaxDelay
+	MaxDelay time.Duration // frames are delayed in order, so it delays the following frames too
+}
+
+// FaultCounts are amounts of the injected faults
+type FaultCounts struct {
+	Dropped    int
+	Duplicated int
+	Corrupted  int
+	Delayed    int
+}
+
+// FaultyConnection wraps the connection injecting faults into its frames according to the policy,
+// it's intended for testing application resilience to bad networks
+type FaultyConnection struct {
+	Connection
+	policy FaultPolicy
+
+	rand      *rand.Rand
+	counts    FaultCounts
+	duplicate *string // inbound frame to pass once more
+	mu        sync.Mutex
+}
+
+// NewFaultyConnection wraps conn with the fault injecting policy
+func NewFaultyConnection(conn Connection, policy FaultPolicy) *FaultyConnection {
+	if policy.Directions == 0 {
+		policy.Directions = FaultBoth
+	}
+	return &FaultyConnection{Connection: conn, policy: policy, rand: rand.New(rand.NewSource(policy.Seed))}
+}
+
+// fault is an action decided for a frame
+type fault struct {
+	drop      bool
+	duplicate bool
+	delay     time.Duration
+	message   string
+}
+
+// decide the faults for the frame m in the direction d
+func (f *FaultyConnection) decide(d FaultDirection, m string) fault {
+	result := fault{message: m}
+	if f.policy.Directions&d == 0 {
+		return result
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.rand.Float64() < f.policy.Drop {
+		f.counts.Dropped++
+		result.drop = true
+		return result
+	}
+	if f.rand.Float64() < f.policy.Corrupt && len(m) > 0 {
+		f.counts.Corrupted++
+		b := []byte(m)
+		b[f.rand.Intn(len(b))] = byte(' ' + f.rand.Intn('~'-' '+1))
+		result.message = string(b)
+	}
+	if f.rand.Float64() < f.policy.Duplicate {
+		f.counts.Duplicated++
+		result.duplicate = true
+	}
+	if f.policy.MaxDelay > 0 && f.rand.Float64() < f.policy.Delay {
+		f.counts.Delayed++
+		result.delay = time.Duration(f.rand.Int63n(int64(f.policy.MaxDelay)) + 1)
+	}
+	return result
+}
+
+// GetMessage reads the frame from the wrapped connection injecting inbound faults
+func (f *FaultyConnection) GetMessage() (string, error) {
+	f.mu.Lock()
+	if duplicate := f.duplicate; duplicate != nil {
+		f.duplicate = nil
+		f.mu.Unlock()
+		return *duplicate, nil
+	}
+	f.mu.Unlock()
+
+	for {
+		message, err := f.Connection.GetMessage()
+		if err != nil {
+			return message, err
+		}
+
+		result := f.decide(FaultInbound, message)
+		if result.drop {
+			continue
+		}
+		time.Sleep(result.delay)
+		if result.duplicate {
+			f.mu.Lock()
+			f.duplicate = &result.message
+			f.mu.Unlock()
+		}
+		return result.message, nil
+	}
+}
+
+// WriteMessage into the wrapped connection injecting outbound faults
+func (f *FaultyConnection) WriteMessage(message string) error {
+	result := f.decide(FaultOutbound, message)
+	if result.drop {
+		return nil
+	}
+	time.Sleep(result.delay)
+	if err := f.Connection.WriteMessage(result.message); err != nil {
+		return err
+	}
+	if result.duplicate {
+		return f.Connection.WriteMessage(result.message)
+	}
+	return nil
+}
+
+// Faults returns amounts of the faults injected so far
+func (f *FaultyConnection) Faults() FaultCounts {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts
+}
+
+// FaultyTransport wraps the connections of the transport with FaultyConnection, the policy seed is incremented
+// for each next connection. Client transports with the type specific behaviour (e.g. polling) should not be wrapped
+type FaultyTransport struct {
+	Transport
+	Policy FaultPolicy
+
+	connections int
+	mu          sync.Mutex
+}
+
+// wrap the connection with the policy for the next connection
+func (t *FaultyTransport) wrap(conn Connection) Connection {
+	t.mu.Lock()
+	policy := t.Policy
+	policy.Seed += int64(t.connections)
+	t.connections++
+	t.mu.Unlock()
+
+	return NewFaultyConnection(conn, policy)
+}
+
+// Connect to the given url with the wrapped transport
+func (t *FaultyTransport) Connect(url string) (Connection, error) {
+	conn, err := t.Transport.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+	return t.wrap(conn), nil
+}
+
+// HandleConnection with the wrapped transport
+func (t *FaultyTransport) HandleConnection(w http.ResponseWriter, r *http.Request) (Connection, error) {
+	conn, err := t.Transport.HandleConnection(w, r)
+	if err != nil {
+		return nil, err
+	}
+	return t.wrap(conn), nil
+}
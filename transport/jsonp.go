@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// jsonpIndex returns the JSONP callback index from the "j" query parameter of the request r
+// and false if it's not a JSONP polling request
+func jsonpIndex(r *http.Request) (string, bool) {
+	query := r.URL.Query()
+	if _, ok := query["j"]; !ok {
+		return "", false
+	}
+
+	return strings.Map(func(c rune) rune {
+		if c < '0' || c > '9' {
+			return -1
+		}
+		return c
+	}, query.Get("j")), true
+}
+
+// wrapJSONP wraps the payload into the script calling the JSONP callback with the given index
+func wrapJSONP(index, payload string) string {
+	b, _ := json.Marshal(payload) // marshaling a string never fails
+	return "___eio[" + index + "](" + string(b) + ");"
+}
+
+// unwrapJSONP extracts the payload from the form-encoded JSONP POST body, escaped newlines are restored
+func unwrapJSONP(body string) (string, error) {
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		return "", err
+	}
+
+	d := form.Get("d")
+	var b strings.Builder
+	for i := 0; i < len(d); i++ {
+		switch {
+		case strings.HasPrefix(d[i:], `\\n`): // escaped slash followed by n, kept as is
+			b.WriteString(`\\n`)
+			i += 2
+		case strings.HasPrefix(d[i:], `\n`):
+			b.WriteByte('\n')
+			i++
+		default:
+			b.WriteByte(d[i])
+		}
+	}
+	return b.String(), nil
+}
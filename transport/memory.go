@@ -0,0 +1,184 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	memDefaultPingInterval = 30 * time.Second
+	memDefaultPingTimeout  = 60 * time.Second
+)
+
+var (
+	errMemoryClosed       = errors.New("memory connection is closed")
+	errMemoryBroken       = errors.New("memory connection is broken")
+	errMemoryNotListening = errors.New("memory transport is not listening")
+	errMemoryNotHTTP      = errors.New("memory transport does not serve HTTP requests")
+)
+
+// Acceptor is a transport which connections are accepted without HTTP, like the in-memory one
+type Acceptor interface {
+	Accept() (Connection, error) // blocks until the new connection arrives, fails if the acceptor is closed
+}
+
+// memoryInbox is an unbounded queue of messages received by the memory connection end
+type memoryInbox struct {
+	messages []string
+	err      error // returned when the messages are read out, nil while the connection is open
+	mu       sync.Mutex
+	cond     *sync.Cond
+}
+
+// newMemoryInbox returns an empty inbox
+func newMemoryInbox() *memoryInbox {
+	inbox := &memoryInbox{}
+	inbox.cond = sync.NewCond(&inbox.mu)
+	return inbox
+}
+
+// put the message into the inbox
+func (inbox *memoryInbox) put(message string) error {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+
+	if inbox.err != nil {
+		return errMemoryClosed
+	}
+	inbox.messages = append(inbox.messages, message)
+	inbox.cond.Signal()
+	return nil
+}
+
+// get waits for the message
+func (inbox *memoryInbox) get() (string, error) {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+
+	for len(inbox.messages) == 0 && inbox.err == nil {
+		inbox.cond.Wait()
+	}
+	if len(inbox.messages) == 0 {
+		return "", inbox.err
+	}
+
+	message := inbox.messages[0]
+	inbox.messages = inbox.messages[1:]
+	return message, nil
+}
+
+// close the inbox with the err, the first error is kept
+func (inbox *memoryInbox) close(err error) {
+	inbox.mu.Lock()
+	defer inbox.mu.Unlock()
+
+	if inbox.err == nil {
+		inbox.err = err
+	}
+	inbox.cond.Broadcast()
+}
+
+// MemoryConnection is an end of the in-memory connection, messages written into it are received by the peer end
+type MemoryConnection struct {
+	in, out      *memoryInbox
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+}
+
+// MemoryPipe returns both ends of the new in-memory connection with the given ping params
+func MemoryPipe(pingInterval, pingTimeout time.Duration) (*MemoryConnection, *MemoryConnection) {
+	a, b := newMemoryInbox(), newMemoryInbox()
+	return &MemoryConnection{in: a, out: b, pingInterval: pingInterval, pingTimeout: pingTimeout},
+		&MemoryConnection{in: b, out: a, pingInterval: pingInterval, pingTimeout: pingTimeout}
+}
+
+// GetMessage waits for the message written by the peer end
+func (mem *MemoryConnection) GetMessage() (string, error) { return mem.in.get() }
+
+// WriteMessage to the peer end
+func (mem *MemoryConnection) WriteMessage(message string) error { return mem.out.put(message) }
+
+// Close the connection, the peer end receives the messages written before and then the connection close
+func (mem *MemoryConnection) Close() error {
+	mem.in.close(errMemoryClosed)
+	mem.out.close(errReceivedConnectionClose)
+	return nil
+}
+
+// Break the connection as if the network failed, both ends fail to read and write immediately
+func (mem *MemoryConnection) Break() {
+	for _, inbox := range []*memoryInbox{mem.in, mem.out} {
+		inbox.mu.Lock()
+		inbox.messages = nil
+		inbox.mu.Unlock()
+		inbox.close(errMemoryBroken)
+	}
+}
+
+// PingParams returns ping params
+func (mem *MemoryConnection) PingParams() (time.Duration, time.Duration) {
+	return mem.pingInterval, mem.pingTimeout
+}
+
+// MemoryTransport connects clients and the server of the same process without network,
+// the server accepts the connections with Server.Serve(). It's intended for tests
+type MemoryTransport struct {
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+
+	acceptC chan Connection
+	closedC chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryTransport returns the in-memory transport with default params
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{
+		PingInterval: memDefaultPingInterval,
+		PingTimeout:  memDefaultPingTimeout,
+		acceptC:      make(chan Connection),
+		closedC:      make(chan struct{}),
+	}
+}
+
+// Connect returns the client end of the new connection, waits for the server to accept the other end.
+// The url is ignored
+func (t *MemoryTransport) Connect(url string) (Connection, error) {
+	client, server := MemoryPipe(t.PingInterval, t.PingTimeout)
+	select {
+	case t.acceptC <- server:
+		return client, nil
+	case <-t.closedC:
+		return nil, errMemoryNotListening
+	}
+}
+
+// Accept waits for the client to connect and returns the server end of the connection
+func (t *MemoryTransport) Accept() (Connection, error) {
+	select {
+	case conn := <-t.acceptC:
+		return conn, nil
+	case <-t.closedC:
+		return nil, errMemoryNotListening
+	}
+}
+
+// Close stops accepting connections, the connections established before stay open
+func (t *MemoryTransport) Close() error {
+	t.once.Do(func() { close(t.closedC) })
+	return nil
+}
+
+// HandleConnection fails, the memory transport does not serve HTTP requests
+func (t *MemoryTransport) HandleConnection(w http.ResponseWriter, r *http.Request) (Connection, error) {
+	http.Error(w, errMemoryNotHTTP.Error(), http.StatusNotImplemented)
+	return nil, errMemoryNotHTTP
+}
+
+// Serve does nothing, the memory transport does not serve HTTP requests
+func (t *MemoryTransport) Serve(w http.ResponseWriter, r *http.Request) {}
+
+// SetSid does nothing for the memory transport
+func (t *MemoryTransport) SetSid(string, Connection) {}
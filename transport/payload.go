@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var errWrongPayload = errors.New("wrong payload")
+
+// BatchWriter is implemented by connections able to write several messages at once
+type BatchWriter interface {
+	WriteMessages(messages []string) error
+}
+
+// messageLength returns a length of the message m in characters as it's defined by engine.io protocol
+func messageLength(m string) int { return len(utf16.Encode([]rune(m))) }
+
+// withLength returns s as a message with length
+func withLength(m string) string { return strconv.Itoa(messageLength(m)) + ":" + m }
+
+// encodePayload of several messages for the polling transport
+func encodePayload(messages []string) string {
+	var payload strings.Builder
+	for _, m := range messages {
+		payload.WriteString(withLength(m))
+	}
+	return payload.String()
+}
+
+// decodePayload of the polling transport into messages
+func decodePayload(payload string) ([]string, error) {
+	messages := []string{}
+	for len(payload) > 0 {
+		colon := strings.IndexByte(payload, ':')
+		if colon < 1 {
+			return nil, errWrongPayload
+		}
+
+		length, err := strconv.Atoi(payload[:colon])
+		if err != nil || length < 0 {
+			return nil, errWrongPayload
+		}
+		payload = payload[colon+1:]
+
+		// skip length characters counting UTF-16 code units like the protocol does
+		end := 0
+		for length > 0 && end < len(payload) {
+			r, size := utf8.DecodeRuneInString(payload[end:])
+			end += size
+			length -= len(utf16.Encode([]rune{r}))
+		}
+		if length != 0 {
+			return nil, errWrongPayload
+		}
+
+		messages = append(messages, payload[:end])
+		payload = payload[end:]
+	}
+	return messages, nil
+}
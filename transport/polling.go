@@ -4,11 +4,11 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"fmt"
 	"github.com/mtfelian/golang-socketio/logging"
 	"github.com/mtfelian/golang-socketio/protocol"
 )
@@ -18,6 +18,7 @@ const (
 	PlDefaultPingTimeout    = 60 * time.Second
 	PlDefaultReceiveTimeout = 60 * time.Second
 	PlDefaultSendTimeout    = 60 * time.Second
+	PlDefaultPollTimeout    = 25 * time.Second
 
 	StopMessage     = "stop"
 	UpgradedMessage = "upgrade"
@@ -32,8 +33,8 @@ var (
 	errWriteMessageTimeout     = errors.New("timeout waiting for write")
 )
 
-// withLength returns s as a message with length
-func withLength(m string) string { return fmt.Sprintf("%d:%s", len(m), m) }
+// IsReceivedClose returns true if err means the connection close packet was received from the peer
+func IsReceivedClose(err error) bool { return err == errReceivedConnectionClose }
 
 // PollingTransportParams represents XHR polling transport params
 type PollingTransportParams struct {
@@ -47,12 +48,19 @@ type PollingConnection struct {
 	eventsOutC chan string
 	errors     chan string
 	sessionID  string
+
+	requests   int  // requests of the session being served
+	polling    bool // true while the GET request is held
+	requestsMu sync.Mutex
+
+	receiveTimeout int64 // overrides the transport ReceiveTimeout if not zero, accessed atomically
+	sendTimeout    int64 // overrides the transport SendTimeout if not zero, accessed atomically
 }
 
 // GetMessage waits for incoming message from the connection
 func (polling *PollingConnection) GetMessage() (string, error) {
 	select {
-	case <-time.After(polling.Transport.ReceiveTimeout):
+	case <-time.After(polling.getReceiveTimeout()):
 		logging.Log().Debug("PollingConnection.GetMessage() timed out")
 		return "", errGetMessageTimeout
 	case m := <-polling.eventsInC:
@@ -65,17 +73,61 @@ func (polling *PollingConnection) GetMessage() (string, error) {
 	}
 }
 
+// SetReceiveTimeout overrides the transport ReceiveTimeout of the connection, zero restores it.
+// It applies from the next message wait
+func (polling *PollingConnection) SetReceiveTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&polling.receiveTimeout, int64(timeout))
+}
+
+// getReceiveTimeout returns the timeout of the message wait
+func (polling *PollingConnection) getReceiveTimeout() time.Duration {
+	if timeout := atomic.LoadInt64(&polling.receiveTimeout); timeout != 0 {
+		return time.Duration(timeout)
+	}
+	return polling.Transport.ReceiveTimeout
+}
+
+// SetSendTimeout overrides the transport SendTimeout of the connection, zero restores it.
+// It applies from the next message write
+func (polling *PollingConnection) SetSendTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&polling.sendTimeout, int64(timeout))
+}
+
+// getSendTimeout returns the timeout of the message write
+func (polling *PollingConnection) getSendTimeout() time.Duration {
+	if timeout := atomic.LoadInt64(&polling.sendTimeout); timeout != 0 {
+		return time.Duration(timeout)
+	}
+	return polling.Transport.SendTimeout
+}
+
 // WriteMessage to the connection
 func (polling *PollingConnection) WriteMessage(message string) error {
 	logging.Log().Debug("PollingConnection.WriteMessage() fired with:", message)
-	polling.eventsOutC <- message
-	logging.Log().Debug("PollingConnection.WriteMessage() written to eventsOutC:", message)
+	return polling.writePayload(withLength(message))
+}
+
+// WriteMessages to the connection as a single payload
+func (polling *PollingConnection) WriteMessages(messages []string) error {
+	logging.Log().Debug("PollingConnection.WriteMessages() fired with:", messages)
+	return polling.writePayload(encodePayload(messages))
+}
+
+// writePayload waits for the polling request to write the encoded payload
+func (polling *PollingConnection) writePayload(payload string) error {
+	timeout := time.After(polling.getSendTimeout())
 	select {
-	case <-time.After(polling.Transport.SendTimeout):
+	case polling.eventsOutC <- payload:
+	case <-timeout: // the peer doesn't poll
+		return errWriteMessageTimeout
+	}
+	logging.Log().Debug("PollingConnection.writePayload() written to eventsOutC:", payload)
+	select {
+	case <-timeout:
 		return errWriteMessageTimeout
 	case errString := <-polling.errors:
 		if errString != noError {
-			logging.Log().Debug("PollingConnection.WriteMessage() failed to write with err:", errString)
+			logging.Log().Debug("PollingConnection.writePayload() failed to write with err:", errString)
 			return errors.New(errString)
 		}
 	}
@@ -131,6 +183,19 @@ type PollingTransport struct {
 	ReceiveTimeout time.Duration
 	SendTimeout    time.Duration
 
+	// PollTimeout is a duration to hold the GET request waiting for the messages,
+	// after it the request is answered with the NOOP packet. SendTimeout is used if it's zero
+	PollTimeout time.Duration
+
+	// CompressionThreshold is a minimal size of the GET response payload to compress it with gzip
+	// if the client accepts it, zero disables compression
+	CompressionThreshold int
+
+	// MaxSessionRequests limits the concurrent requests per session, overlapping GET requests are rejected anyway.
+	// PlDefaultMaxSessionRequests is used if it's zero, negative value disables the limit.
+	// See also LimitSlowClients
+	MaxSessionRequests int
+
 	Headers  http.Header
 	sessions sessions
 }
@@ -169,6 +234,13 @@ func (t *PollingTransport) Serve(w http.ResponseWriter, r *http.Request) {
 		logging.Log().Debug("PollingTransport.Serve() is serving GET request")
 		conn.PollingWriter(w, r)
 	case http.MethodPost:
+		if err := conn.enter(false); err != nil {
+			logging.Log().Debug("PollingTransport.Serve() rejected POST request:", err)
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		defer conn.leave(false)
+
 		bodyBytes, err := ioutil.ReadAll(r.Body)
 		r.Body.Close()
 		if err != nil {
@@ -177,16 +249,33 @@ func (t *PollingTransport) Serve(w http.ResponseWriter, r *http.Request) {
 		}
 
 		bodyString := string(bodyBytes)
+		_, jsonp := jsonpIndex(r)
+		if jsonp {
+			if bodyString, err = unwrapJSONP(bodyString); err != nil {
+				logging.Log().Debug("PollingTransport.Serve() error unwrapJSONP():", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 		logging.Log().Debug("PollingTransport.Serve() POST bodyString before split:", bodyString)
-		index := strings.Index(bodyString, ":")
-		body := bodyString[index+1:]
+		messages, err := decodePayload(bodyString)
+		if err != nil {
+			logging.Log().Debug("PollingTransport.Serve() error decodePayload():", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		setHeaders(w)
+		if jsonp {
+			w.Header().Set("Content-Type", "text/html")
+		}
 
-		logging.Log().Debug("PollingTransport.Serve() POST body:", body)
+		logging.Log().Debug("PollingTransport.Serve() POST messages:", messages)
 		w.Write([]byte("ok"))
 		logging.Log().Debug("PollingTransport.Serve() written POST response")
-		conn.eventsInC <- body
+		for _, message := range messages {
+			conn.eventsInC <- message
+		}
 		logging.Log().Debug("PollingTransport.Serve() sent to eventsInC")
 	}
 }
@@ -198,6 +287,7 @@ func DefaultPollingTransport() *PollingTransport {
 		PingTimeout:    PlDefaultPingTimeout,
 		ReceiveTimeout: PlDefaultReceiveTimeout,
 		SendTimeout:    PlDefaultSendTimeout,
+		PollTimeout:    PlDefaultPollTimeout,
 		sessions: sessions{
 			Mutex: sync.Mutex{},
 			m:     map[string]*PollingConnection{},
@@ -208,14 +298,32 @@ func DefaultPollingTransport() *PollingTransport {
 
 // PollingWriter for writing polling answer
 func (polling *PollingConnection) PollingWriter(w http.ResponseWriter, r *http.Request) {
+	if err := polling.enter(true); err != nil {
+		logging.Log().Debug("PollingTransport.PollingWriter() rejected GET request:", err)
+		status := http.StatusBadRequest
+		if err == errTooManyPollRequests {
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer polling.leave(true)
+
 	setHeaders(w)
+
+	pollTimeout := polling.Transport.PollTimeout
+	if pollTimeout == 0 {
+		pollTimeout = polling.Transport.SendTimeout
+	}
+
 	select {
-	case <-time.After(polling.Transport.SendTimeout):
-		logging.Log().Debug("PollingTransport.PollingWriter() timed out")
-		polling.errors <- noError
-	case message := <-polling.eventsOutC:
+	case <-time.After(pollTimeout):
+		logging.Log().Debug("PollingTransport.PollingWriter() timed out, writing noop")
+		if _, err := w.Write([]byte(polling.frame(w, r, withLength(protocol.MessageBlank)))); err != nil {
+			logging.Log().Debug("PollingTransport.PollingWriter() failed to write noop with err:", err)
+		}
+	case message := <-polling.eventsOutC: // encoded payload
 		logging.Log().Debug("PollingTransport.PollingWriter() prepares to write message:", message)
-		message = withLength(message)
 		if message == withLength(protocol.MessageBlank) {
 			logging.Log().Debug("PollingTransport.PollingWriter() writing 1:6")
 
@@ -233,17 +341,26 @@ func (polling *PollingConnection) PollingWriter(w http.ResponseWriter, r *http.R
 
 			defer conn.Close()
 
+			body := polling.frame(w, r, message)
 			buffer.WriteString("HTTP/1.1 200 OK\r\n" +
 				"Cache-Control: no-cache, private\r\n" +
-				"Content-Length: 3\r\n" +
+				"Content-Length: " + strconv.Itoa(len(body)) + "\r\n" +
 				"Date: Mon, 24 Nov 2016 10:21:21 GMT\r\n\r\n")
-			buffer.WriteString(withLength(protocol.MessageBlank))
+			buffer.WriteString(body)
 			buffer.Flush()
 			logging.Log().Debug("PollingTransport.PollingWriter() hijack returns")
 			polling.errors <- noError
 			polling.eventsInC <- StopMessage
 		} else {
-			_, err := w.Write([]byte(message))
+			message = polling.frame(w, r, message)
+
+			var err error
+			if threshold := polling.Transport.CompressionThreshold; threshold > 0 && len(message) >= threshold &&
+				acceptsGzip(r) {
+				err = writeCompressed(w, message)
+			} else {
+				_, err = w.Write([]byte(message))
+			}
 			logging.Log().Debug("PollingTransport.PollingWriter() written message:", message)
 			if err != nil {
 				logging.Log().Debug("PollingTransport.PollingWriter() failed to write message with err:", err)
@@ -255,6 +372,17 @@ func (polling *PollingConnection) PollingWriter(w http.ResponseWriter, r *http.R
 	}
 }
 
+// frame the payload for the request r, JSONP requests get it wrapped into the callback script
+func (polling *PollingConnection) frame(w http.ResponseWriter, r *http.Request, payload string) string {
+	index, ok := jsonpIndex(r)
+	if !ok {
+		return payload
+	}
+
+	w.Header().Set("Content-Type", "text/javascript; charset=UTF-8")
+	return wrapJSONP(index, payload)
+}
+
 // setHeaders into w
 func setHeaders(w http.ResponseWriter) {
 	// We are going to return JSON no matter what:
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mtfelian/golang-socketio/logging"
@@ -17,19 +19,83 @@ var (
 	errResponseIsNotOK       = errors.New("response body is not OK")
 	errAnswerNotOpenSequence = errors.New("not opensequence answer")
 	errAnswerNotOpenMessage  = errors.New("not openmessage answer")
+	errConnectionReleased    = errors.New("connection released")
 )
 
+// IsReleased returns true if err means the connection was released after the transport upgrade
+func IsReleased(err error) bool { return err == errConnectionReleased }
+
 // PollingClientConnection represents XHR polling client connection
 type PollingClientConnection struct {
 	transport *PollingClientTransport
 	client    *http.Client
 	url       string
 	sid       string
+	token     string
+	codec     string // name of the payloads codec
+	upgrades  []string
+	received  []string // messages received within the last payload and not yet returned
+
+	releasedC   chan struct{} // closed when the connection is released after the transport upgrade
+	releaseOnce sync.Once
+
+	connectData string // data of the connect packet, empty if there is no data
+}
+
+// Sid returns a session id received from the server in the open sequence
+func (polling *PollingClientConnection) Sid() string { return polling.sid }
+
+// Token returns a session resumption token received from the server in the open sequence
+func (polling *PollingClientConnection) Token() string { return polling.token }
+
+// Compression returns a name of the payloads codec picked by the server in the open sequence, empty if none
+func (polling *PollingClientConnection) Compression() string { return polling.codec }
+
+// ConnectData returns JSON data of the connect packet received in the open sequence, empty if there is no data
+func (polling *PollingClientConnection) ConnectData() string { return polling.connectData }
+
+// CanUpgrade returns true if the server allows to upgrade the connection to the given transport
+func (polling *PollingClientConnection) CanUpgrade(transportName string) bool {
+	for _, upgrade := range polling.upgrades {
+		if upgrade == transportName {
+			return true
+		}
+	}
+	return false
+}
+
+// WebsocketURL returns an url for upgrading the current session to websocket transport
+func (polling *PollingClientConnection) WebsocketURL() (string, error) {
+	u, err := url.Parse(polling.url)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+
+	q := u.Query()
+	q.Set("transport", "websocket")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
 }
 
 // GetMessage performs a GET request to wait for the following message
 func (polling *PollingClientConnection) GetMessage() (string, error) {
 	logging.Log().Debug("PollingConnection.GetMessage() fired")
+	if len(polling.received) > 0 {
+		message := polling.received[0]
+		polling.received = polling.received[1:]
+		return message, nil
+	}
+
+	if polling.released() {
+		return "", errConnectionReleased
+	}
 
 	resp, err := polling.client.Get(polling.url)
 	if err != nil {
@@ -38,6 +104,10 @@ func (polling *PollingClientConnection) GetMessage() (string, error) {
 	}
 
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if polling.released() { // the poll pending during the upgrade was the last one
+		polling.client.CloseIdleConnections()
+	}
 	if err != nil {
 		logging.Log().Debug("PollingConnection.GetMessage() error ioutil.ReadAll():", err)
 		return "", err
@@ -45,17 +115,37 @@ func (polling *PollingClientConnection) GetMessage() (string, error) {
 
 	bodyString := string(bodyBytes)
 	logging.Log().Debug("PollingConnection.GetMessage() bodyString:", bodyString)
-	index := strings.Index(bodyString, ":")
+	messages, err := decodePayload(bodyString)
+	if err != nil {
+		logging.Log().Debug("PollingConnection.GetMessage() error decodePayload():", err)
+		return "", err
+	}
 
-	body := bodyString[index+1:]
-	return body, nil
+	if len(messages) == 0 { // empty response at the polling timeout
+		return protocol.MessageBlank, nil
+	}
+
+	polling.received = messages[1:]
+	return messages[0], nil
 }
 
 // WriteMessage performs a POST request to send a message to server
 func (polling *PollingClientConnection) WriteMessage(m string) error {
-	mWrite := withLength(m)
+	return polling.writePayload(withLength(m))
+}
+
+// WriteMessages performs a POST request to send several messages to server as a single payload
+func (polling *PollingClientConnection) WriteMessages(messages []string) error {
+	return polling.writePayload(encodePayload(messages))
+}
+
+// writePayload performs a POST request to send the encoded payload to server
+func (polling *PollingClientConnection) writePayload(mWrite string) error {
 	logging.Log().Debug("PollingConnection.WriteMessage() fired, msgToWrite:", mWrite)
 	mJSON := []byte(mWrite)
+	if polling.released() {
+		return errConnectionReleased
+	}
 
 	resp, err := polling.client.Post(polling.url, "application/json", bytes.NewBuffer(mJSON))
 	if err != nil {
@@ -83,6 +173,23 @@ func (polling *PollingClientConnection) Close() error {
 	return polling.WriteMessage(protocol.MessageClose)
 }
 
+// Release the connection after the session moved to another transport: the following requests fail
+// and the idle HTTP connections are closed, the session itself is kept open
+func (polling *PollingClientConnection) Release() {
+	polling.releaseOnce.Do(func() { close(polling.releasedC) })
+	polling.client.CloseIdleConnections()
+}
+
+// released returns true if the connection was released
+func (polling *PollingClientConnection) released() bool {
+	select {
+	case <-polling.releasedC:
+		return true
+	default:
+		return false
+	}
+}
+
 // PingParams returns PingInterval and PingTimeout params
 func (polling *PollingClientConnection) PingParams() (time.Duration, time.Duration) {
 	return polling.transport.PingInterval, polling.transport.PingTimeout
@@ -96,7 +203,11 @@ type PollingClientTransport struct {
 	SendTimeout    time.Duration
 
 	Headers  http.Header
+	Jar      http.CookieJar // persists handshake cookies across requests and connections, may be nil
 	sessions sessions
+
+	// Upgrade is a transport to upgrade the connection to after connecting, no upgrade is performed if nil
+	Upgrade *WebsocketTransport
 }
 
 // HandleConnection for the polling client is a placeholder
@@ -116,11 +227,14 @@ type openSequence struct {
 	Upgrades     []string      `json:"upgrades"`
 	PingInterval time.Duration `json:"pingInterval"`
 	PingTimeout  time.Duration `json:"pingTimeout"`
+	Token        string        `json:"token"`
+	Compression  string        `json:"compression"`
 }
 
 // Connect to server, perform 3 HTTP requests in connecting sequence
-func (t *PollingClientTransport) Connect(url string) (Connection, error) {
-	polling := &PollingClientConnection{transport: t, client: &http.Client{}, url: url}
+func (t *PollingClientTransport) Connect(addr string) (Connection, error) {
+	polling := &PollingClientConnection{transport: t, client: &http.Client{Jar: t.Jar}, url: addr}
+	polling.releasedC = make(chan struct{})
 
 	resp, err := polling.client.Get(polling.url)
 	if err != nil {
@@ -151,6 +265,8 @@ func (t *PollingClientTransport) Connect(url string) (Connection, error) {
 		return nil, err
 	}
 
+	polling.sid, polling.token, polling.upgrades = openSequence.Sid, openSequence.Token, openSequence.Upgrades
+	polling.codec = openSequence.Compression
 	polling.url += "&sid=" + openSequence.Sid
 	logging.Log().Debug("PollingConnection.Connect() polling.url 1:", polling.url)
 
@@ -171,9 +287,10 @@ func (t *PollingClientTransport) Connect(url string) (Connection, error) {
 	logging.Log().Debug("PollingConnection.Connect() bodyString 2:", bodyString)
 	body = bodyString[strings.Index(bodyString, ":")+1:]
 
-	if body != protocol.MessageEmpty {
+	if !strings.HasPrefix(body, protocol.MessageEmpty) || (len(body) > 2 && body[2] != '{') {
 		return nil, errAnswerNotOpenMessage
 	}
+	polling.connectData = body[2:]
 
 	return polling, nil
 }
@@ -187,3 +304,10 @@ func DefaultPollingClientTransport() *PollingClientTransport {
 		SendTimeout:    PlDefaultSendTimeout,
 	}
 }
+
+// NewPollingClientTransport returns client polling transport with given params
+func NewPollingClientTransport(params PollingTransportParams) *PollingClientTransport {
+	tr := DefaultPollingClientTransport()
+	tr.Headers = params.Headers
+	return tr
+}
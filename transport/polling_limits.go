@@ -0,0 +1,57 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// PlDefaultMaxSessionRequests is a default limit of the concurrent requests per polling session
+const PlDefaultMaxSessionRequests = 4
+
+var (
+	errOverlappingPoll     = errors.New("overlapping poll request")
+	errTooManyPollRequests = errors.New("too many concurrent requests for the session")
+)
+
+// LimitSlowClients sets the srv timeouts so the clients sending the request headers or the body slowly
+// can't pin the server goroutines: headerTimeout to read the headers and readTimeout to read the whole request.
+// The long polling is not affected since the requests are read before being held
+func LimitSlowClients(srv *http.Server, headerTimeout, readTimeout time.Duration) {
+	srv.ReadHeaderTimeout = headerTimeout
+	srv.ReadTimeout = readTimeout
+}
+
+// enter registers the request of the session, it returns an error if the request exceeds the session limits:
+// only one GET may be held at once and up to MaxSessionRequests requests of any method
+func (polling *PollingConnection) enter(get bool) error {
+	polling.requestsMu.Lock()
+	defer polling.requestsMu.Unlock()
+
+	limit := polling.Transport.MaxSessionRequests
+	if limit == 0 {
+		limit = PlDefaultMaxSessionRequests
+	}
+	switch {
+	case get && polling.polling:
+		return errOverlappingPoll
+	case limit > 0 && polling.requests >= limit:
+		return errTooManyPollRequests
+	}
+
+	polling.requests++
+	if get {
+		polling.polling = true
+	}
+	return nil
+}
+
+// leave unregisters the finished request of the session
+func (polling *PollingConnection) leave(get bool) {
+	polling.requestsMu.Lock()
+	polling.requests--
+	if get {
+		polling.polling = false
+	}
+	polling.requestsMu.Unlock()
+}
@@ -0,0 +1,53 @@
+package transport
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// TransportFactory creates the server side transport, each server serving it gets its own instance
+type TransportFactory func() Transport
+
+// HandshakeWriter is implemented by the transports answering the handshake request themselves once the session
+// is set up, e.g. writing the open packet to the response or streaming the packets, it may block until
+// the connection is closed
+type HandshakeWriter interface {
+	WriteHandshake(conn Connection, w http.ResponseWriter, r *http.Request)
+}
+
+var registry = struct {
+	factories map[string]TransportFactory
+	sync.RWMutex
+}{factories: make(map[string]TransportFactory)}
+
+// Register the transport factory with the name matched against the transport query parameter of the requests,
+// registering the name again replaces the factory. The "websocket" and "polling" names are always served
+// by the built-in transports. The transport gets the handshake request without sid by HandleConnection,
+// then SetSid is called and the open packet is written to the connection, then WriteHandshake is called
+// if it's implemented. Later requests with sid are passed to Serve
+func Register(name string, factory TransportFactory) {
+	registry.Lock()
+	registry.factories[name] = factory
+	registry.Unlock()
+}
+
+// Lookup returns the transport factory registered with the name
+func Lookup(name string) (TransportFactory, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	f, ok := registry.factories[name]
+	return f, ok
+}
+
+// Registered returns the sorted names of the registered transports
+func Registered() []string {
+	registry.RLock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	registry.RUnlock()
+	sort.Strings(names)
+	return names
+}
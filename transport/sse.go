@@ -0,0 +1,296 @@
+package transport
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// SSETransportName is the transport query parameter value of the SSE transport, it's registered by default
+const SSETransportName = "sse"
+
+const (
+	SSEDefaultPingInterval   = 30 * time.Second
+	SSEDefaultPingTimeout    = 60 * time.Second
+	SSEDefaultReceiveTimeout = 60 * time.Second
+	SSEDefaultSendTimeout    = 60 * time.Second
+)
+
+var (
+	errConnectionClosed      = errors.New("connection closed")
+	errStreamingNotSupported = errors.New("webserver doesn't support streaming")
+)
+
+func init() {
+	Register(SSETransportName, func() Transport { return DefaultSSETransport() })
+}
+
+// SSEConnection represents a server side Server-Sent Events connection. The packets are streamed
+// to the client in the response to the handshake request as the events with the packet in data field,
+// the client sends the packets with POST requests encoded like the XHR polling payload
+type SSEConnection struct {
+	transport  *SSETransport
+	sessionID  string
+	eventsInC  chan string
+	eventsOutC chan sseWrite
+	closed     chan struct{}
+	closeOnce  sync.Once
+
+	receiveTimeout int64 // overrides the transport ReceiveTimeout if not zero, accessed atomically
+	sendTimeout    int64 // overrides the transport SendTimeout if not zero, accessed atomically
+}
+
+// GetMessage waits for incoming message from the connection
+func (sse *SSEConnection) GetMessage() (string, error) {
+	select {
+	case <-time.After(sse.getReceiveTimeout()):
+		logging.Log().Debug("SSEConnection.GetMessage() timed out")
+		return "", errGetMessageTimeout
+	case <-sse.closed:
+		return "", errConnectionClosed
+	case m := <-sse.eventsInC:
+		logging.Log().Debug("SSEConnection.GetMessage() received:", m)
+		if m == protocol.MessageClose {
+			return "", errReceivedConnectionClose
+		}
+		return m, nil
+	}
+}
+
+// SetReceiveTimeout overrides the transport ReceiveTimeout of the connection, zero restores it.
+// It applies from the next message wait
+func (sse *SSEConnection) SetReceiveTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&sse.receiveTimeout, int64(timeout))
+}
+
+// getReceiveTimeout returns the timeout of the message wait
+func (sse *SSEConnection) getReceiveTimeout() time.Duration {
+	if timeout := atomic.LoadInt64(&sse.receiveTimeout); timeout != 0 {
+		return time.Duration(timeout)
+	}
+	return sse.transport.ReceiveTimeout
+}
+
+// SetSendTimeout overrides the transport SendTimeout of the connection, zero restores it.
+// It applies from the next message write
+func (sse *SSEConnection) SetSendTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&sse.sendTimeout, int64(timeout))
+}
+
+// getSendTimeout returns the timeout of the message write
+func (sse *SSEConnection) getSendTimeout() time.Duration {
+	if timeout := atomic.LoadInt64(&sse.sendTimeout); timeout != 0 {
+		return time.Duration(timeout)
+	}
+	return sse.transport.SendTimeout
+}
+
+// sseWrite is the message passed to the stream with the channel getting the write error
+type sseWrite struct {
+	message string
+	done    chan error // buffered, so the stream doesn't wait for the writer gone by timeout
+}
+
+// WriteMessage passes the message to the stream of the handshake response and waits for it to be written
+func (sse *SSEConnection) WriteMessage(message string) error {
+	timeout := time.After(sse.getSendTimeout())
+	write := sseWrite{message: message, done: make(chan error, 1)}
+	select {
+	case sse.eventsOutC <- write:
+	case <-sse.closed:
+		return errConnectionClosed
+	case <-timeout:
+		return errWriteMessageTimeout
+	}
+
+	select {
+	case err := <-write.done:
+		return err
+	case <-timeout:
+		return errWriteMessageTimeout
+	}
+}
+
+// Close the connection ending the stream and delete session
+func (sse *SSEConnection) Close() error {
+	sse.closeOnce.Do(func() {
+		logging.Log().Debug("SSEConnection.Close() fired for session:", sse.sessionID)
+		close(sse.closed)
+		sse.transport.sessions.delete(sse.sessionID)
+	})
+	return nil
+}
+
+// PingParams returns a connection ping params
+func (sse *SSEConnection) PingParams() (time.Duration, time.Duration) {
+	return sse.transport.PingInterval, sse.transport.PingTimeout
+}
+
+// writeEvent writes the message as an event to w, each line of it goes to the separate data field
+func writeEvent(w http.ResponseWriter, message string) error {
+	var event strings.Builder
+	for _, line := range strings.Split(message, "\n") {
+		event.WriteString("data: ")
+		event.WriteString(line)
+		event.WriteString("\n")
+	}
+	event.WriteString("\n")
+	_, err := w.Write([]byte(event.String()))
+	return err
+}
+
+// sseSessions maps the session ids to the SSE connections
+type sseSessions struct {
+	m  map[string]*SSEConnection
+	mu sync.Mutex
+}
+
+// set sessionID to the given connection
+func (s *sseSessions) set(sessionID string, conn *SSEConnection) {
+	s.mu.Lock()
+	s.m[sessionID] = conn
+	s.mu.Unlock()
+}
+
+// delete the sessionID
+func (s *sseSessions) delete(sessionID string) {
+	s.mu.Lock()
+	delete(s.m, sessionID)
+	s.mu.Unlock()
+}
+
+// get returns the connection of the sessionID, nil if there are none
+func (s *sseSessions) get(sessionID string) *SSEConnection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m[sessionID]
+}
+
+// SSETransport is the server side Server-Sent Events transport for the environments where websockets are blocked
+// but the long-lived HTTP responses work. It's selected by the "sse" transport query parameter, see Register
+type SSETransport struct {
+	PingInterval   time.Duration
+	PingTimeout    time.Duration
+	ReceiveTimeout time.Duration
+	SendTimeout    time.Duration
+
+	Headers  http.Header // added to the stream response
+	sessions sseSessions
+}
+
+// DefaultSSETransport returns SSETransport with default params
+func DefaultSSETransport() *SSETransport {
+	return &SSETransport{
+		PingInterval:   SSEDefaultPingInterval,
+		PingTimeout:    SSEDefaultPingTimeout,
+		ReceiveTimeout: SSEDefaultReceiveTimeout,
+		SendTimeout:    SSEDefaultSendTimeout,
+		sessions:       sseSessions{m: make(map[string]*SSEConnection)},
+	}
+}
+
+// Connect for the server side SSE transport is a placeholder, see SSEClientTransport
+func (t *SSETransport) Connect(url string) (Connection, error) { return nil, nil }
+
+// HandleConnection returns a new connection for the handshake GET request, the stream is written by WriteHandshake
+func (t *SSETransport) HandleConnection(w http.ResponseWriter, r *http.Request) (Connection, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, errMethodNotAllowed.Error(), http.StatusMethodNotAllowed)
+		return nil, errMethodNotAllowed
+	}
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, errStreamingNotSupported.Error(), http.StatusInternalServerError)
+		return nil, errStreamingNotSupported
+	}
+
+	return &SSEConnection{
+		transport:  t,
+		eventsInC:  make(chan string),
+		eventsOutC: make(chan sseWrite),
+		closed:     make(chan struct{}),
+	}, nil
+}
+
+// SetSid to the given sessionID and connection
+func (t *SSETransport) SetSid(sessionID string, conn Connection) {
+	t.sessions.set(sessionID, conn.(*SSEConnection))
+	conn.(*SSEConnection).sessionID = sessionID
+}
+
+// WriteHandshake streams the packets of conn in the response to the handshake request until it's closed
+// or the client goes away
+func (t *SSETransport) WriteHandshake(conn Connection, w http.ResponseWriter, r *http.Request) {
+	sse := conn.(*SSEConnection)
+	for k, v := range t.Headers {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // disables the nginx proxy buffering
+	w.WriteHeader(http.StatusOK)
+	flusher := w.(http.Flusher)
+	flusher.Flush()
+
+	gone := r.Context().Done()
+	for {
+		select {
+		case <-sse.closed:
+			return
+		case <-gone:
+			logging.Log().Debug("SSETransport.WriteHandshake() the client went away")
+			sse.Close()
+			return
+		case write := <-sse.eventsOutC:
+			if err := writeEvent(w, write.message); err != nil {
+				logging.Log().Debug("SSETransport.WriteHandshake() failed to write with err:", err)
+				write.done <- err
+				sse.Close()
+				return
+			}
+			flusher.Flush()
+			write.done <- nil
+		}
+	}
+}
+
+// Serve the POST requests with the packets of the client
+func (t *SSETransport) Serve(w http.ResponseWriter, r *http.Request) {
+	conn := t.sessions.get(r.URL.Query().Get("sid"))
+	if conn == nil {
+		http.Error(w, "unknown session", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, errMethodNotAllowed.Error(), http.StatusMethodNotAllowed)
+		return
+	}
+
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		logging.Log().Debug("SSETransport.Serve() error ioutil.ReadAll():", err)
+		return
+	}
+	messages, err := decodePayload(string(bodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setHeaders(w)
+	w.Write([]byte("ok"))
+	for _, message := range messages {
+		select {
+		case conn.eventsInC <- message:
+		case <-conn.closed:
+			return
+		}
+	}
+}
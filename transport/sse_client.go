@@ -0,0 +1,224 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/logging"
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// maxSSEEventSize limits the line size of the stream
+const maxSSEEventSize = 16 * 1024 * 1024
+
+var errNotEventStream = errors.New("response is not an event stream")
+
+// SSEClientConnection represents a client side Server-Sent Events connection
+type SSEClientConnection struct {
+	transport *SSEClientTransport
+	client    *http.Client // performs the POST requests
+	url       string       // url of the session
+	sid       string
+	stream    io.ReadCloser
+	pending   []string // messages read while connecting and not yet returned
+	eventsC   chan string
+	errC      chan error    // gets the stream read error once
+	done      chan struct{} // closed by Close
+	closeOnce sync.Once
+}
+
+// Sid returns a session id received from the server in the open packet
+func (sse *SSEClientConnection) Sid() string { return sse.sid }
+
+// GetMessage waits for the next event of the stream
+func (sse *SSEClientConnection) GetMessage() (string, error) {
+	if len(sse.pending) > 0 {
+		message := sse.pending[0]
+		sse.pending = sse.pending[1:]
+		return message, nil
+	}
+
+	select {
+	case message := <-sse.eventsC:
+		return message, nil
+	case err := <-sse.errC:
+		sse.errC <- err // following calls fail too
+		return "", err
+	case <-time.After(sse.transport.ReceiveTimeout):
+		return "", errGetMessageTimeout
+	}
+}
+
+// WriteMessage performs a POST request to send a message to server
+func (sse *SSEClientConnection) WriteMessage(message string) error {
+	return sse.writePayload(withLength(message))
+}
+
+// WriteMessages performs a POST request to send several messages to server as a single payload
+func (sse *SSEClientConnection) WriteMessages(messages []string) error {
+	return sse.writePayload(encodePayload(messages))
+}
+
+// writePayload performs a POST request to send the encoded payload to server
+func (sse *SSEClientConnection) writePayload(payload string) error {
+	req, err := http.NewRequest(http.MethodPost, sse.url, bytes.NewBufferString(payload))
+	if err != nil {
+		return err
+	}
+	for k, v := range sse.transport.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=UTF-8")
+
+	resp, err := sse.client.Do(req)
+	if err != nil {
+		logging.Log().Debug("SSEClientConnection.writePayload() error sse.client.Do():", err)
+		return err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if string(body) != "ok" {
+		return errResponseIsNotOK
+	}
+	return nil
+}
+
+// Close the connection sending the close packet and closing the stream
+func (sse *SSEClientConnection) Close() error {
+	var err error
+	sse.closeOnce.Do(func() {
+		err = sse.WriteMessage(protocol.MessageClose)
+		close(sse.done)
+		sse.stream.Close()
+	})
+	return err
+}
+
+// PingParams returns PingInterval and PingTimeout params
+func (sse *SSEClientConnection) PingParams() (time.Duration, time.Duration) {
+	return sse.transport.PingInterval, sse.transport.PingTimeout
+}
+
+// readStream passes the events of the stream to eventsC until it fails
+func (sse *SSEClientConnection) readStream() {
+	scanner := bufio.NewScanner(sse.stream)
+	scanner.Buffer(nil, maxSSEEventSize)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				select {
+				case sse.eventsC <- strings.Join(data, "\n"):
+				case <-sse.done:
+					return
+				}
+				data = data[:0]
+			}
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(line[len("data:"):], " "))
+		}
+		// the comments and other fields are skipped
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	logging.Log().Debug("SSEClientConnection.readStream() stream ended with err:", err)
+	sse.errC <- err
+}
+
+// SSEClientTransport is the client side Server-Sent Events transport, see SSETransport
+type SSEClientTransport struct {
+	PingInterval   time.Duration
+	PingTimeout    time.Duration
+	ReceiveTimeout time.Duration
+	SendTimeout    time.Duration // timeout of the POST requests
+
+	Headers http.Header
+	Jar     http.CookieJar // persists handshake cookies across requests and connections, may be nil
+}
+
+// DefaultSSEClientTransport returns client SSE transport with default params
+func DefaultSSEClientTransport() *SSEClientTransport {
+	return &SSEClientTransport{
+		PingInterval:   SSEDefaultPingInterval,
+		PingTimeout:    SSEDefaultPingTimeout,
+		ReceiveTimeout: SSEDefaultReceiveTimeout,
+		SendTimeout:    SSEDefaultSendTimeout,
+	}
+}
+
+// Connect to the server opening the event stream, addr must have the "sse" transport query parameter
+func (t *SSEClientTransport) Connect(addr string) (Connection, error) {
+	req, err := http.NewRequest(http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := (&http.Client{Jar: t.Jar}).Do(req)
+	if err != nil {
+		logging.Log().Debug("SSEClientTransport.Connect() error Do():", err)
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body.Close()
+		return nil, errNotEventStream
+	}
+
+	sse := &SSEClientConnection{
+		transport: t,
+		client:    &http.Client{Jar: t.Jar, Timeout: t.SendTimeout},
+		stream:    resp.Body,
+		eventsC:   make(chan string),
+		errC:      make(chan error, 1),
+		done:      make(chan struct{}),
+	}
+	go sse.readStream()
+
+	open, err := sse.GetMessage()
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(open, protocol.MessageOpen) {
+		resp.Body.Close()
+		return nil, errAnswerNotOpenSequence
+	}
+	var openSequence openSequence
+	if err := json.Unmarshal([]byte(open[1:]), &openSequence); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	sse.sid, sse.url, sse.pending = openSequence.Sid, addr+"&sid="+openSequence.Sid, []string{open}
+	return sse, nil
+}
+
+// HandleConnection for the SSE client is a placeholder
+func (t *SSEClientTransport) HandleConnection(w http.ResponseWriter, r *http.Request) (Connection, error) {
+	return nil, nil
+}
+
+// Serve for the SSE client is a placeholder
+func (t *SSEClientTransport) Serve(w http.ResponseWriter, r *http.Request) {}
+
+// SetSid for the SSE client is a placeholder
+func (t *SSEClientTransport) SetSid(sid string, conn Connection) {}
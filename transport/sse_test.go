@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// brokenWriter is the streaming response writer failing to write the body
+type brokenWriter struct {
+	header http.Header
+	err    error
+}
+
+func (w *brokenWriter) Header() http.Header        { return w.header }
+func (w *brokenWriter) Write([]byte) (int, error)  { return 0, w.err }
+func (w *brokenWriter) WriteHeader(statusCode int) {}
+func (w *brokenWriter) Flush()                     {}
+
+func TestSSEWriteMessageError(t *testing.T) {
+	tr := DefaultSSETransport()
+	w := &brokenWriter{header: make(http.Header), err: errors.New("broken pipe")}
+	r := httptest.NewRequest(http.MethodGet, "/socket.io/?EIO=3&transport=sse", nil)
+	conn, err := tr.HandleConnection(w, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetSid("sid", conn)
+	go tr.WriteHandshake(conn, w, r)
+
+	if err := conn.WriteMessage(`42["m"]`); err != w.err {
+		t.Fatal("write to the broken stream:", err)
+	}
+	if err := conn.WriteMessage(`42["m"]`); err != errConnectionClosed {
+		t.Fatal("write after the stream is broken:", err)
+	}
+}
@@ -0,0 +1,155 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StreamDefaultPingInterval = 30 * time.Second
+	StreamDefaultPingTimeout  = 60 * time.Second
+)
+
+var (
+	errStreamNotHTTP     = errors.New("stream transport does not serve HTTP requests")
+	errStreamNotDialable = errors.New("stream transport has no Dial function")
+	errStreamClosed      = errors.New("stream acceptor is closed")
+)
+
+// PacketStream is a bidirectional message stream carrying the engine.io packets, e.g. the gRPC stream
+// of the service with the message of a single string field, wrapped to send and receive the packets.
+// Send isn't called concurrently
+type PacketStream interface {
+	Send(packet string) error
+	Recv() (packet string, err error)
+}
+
+// StreamConnection is the engine.io connection over the PacketStream
+type StreamConnection struct {
+	stream       PacketStream
+	close        func() error // ends the stream, may be nil
+	pingInterval time.Duration
+	pingTimeout  time.Duration
+	closed       chan struct{}
+	sendMu       sync.Mutex
+	closeOnce    sync.Once
+}
+
+// NewStreamConnection returns the connection over the stream with the given ping params,
+// close ends the stream, e.g. cancels the gRPC stream context, it may be nil
+func NewStreamConnection(stream PacketStream, close func() error,
+	pingInterval, pingTimeout time.Duration) *StreamConnection {
+	return &StreamConnection{stream: stream, close: close, pingInterval: pingInterval, pingTimeout: pingTimeout,
+		closed: make(chan struct{})}
+}
+
+// GetMessage receives the next packet of the stream
+func (sc *StreamConnection) GetMessage() (string, error) { return sc.stream.Recv() }
+
+// WriteMessage sends the packet to the stream
+func (sc *StreamConnection) WriteMessage(message string) error {
+	sc.sendMu.Lock()
+	defer sc.sendMu.Unlock()
+	return sc.stream.Send(message)
+}
+
+// Close the connection ending the stream
+func (sc *StreamConnection) Close() error {
+	var err error
+	sc.closeOnce.Do(func() {
+		close(sc.closed)
+		if sc.close != nil {
+			err = sc.close()
+		}
+	})
+	return err
+}
+
+// Done returns a channel closed when the connection is closed
+func (sc *StreamConnection) Done() <-chan struct{} { return sc.closed }
+
+// PingParams returns ping params
+func (sc *StreamConnection) PingParams() (time.Duration, time.Duration) {
+	return sc.pingInterval, sc.pingTimeout
+}
+
+// StreamTransport carries the engine.io packets over the PacketStream, so the infrastructure terminating
+// gRPC can route the socket.io traffic. The server passes the streams opened by the clients to Handle
+// and serves the transport with Server.Serve(), the client opens the stream with Dial
+type StreamTransport struct {
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+
+	// Dial opens the client stream to the url, the returned function ends it
+	Dial func(url string) (stream PacketStream, close func() error, err error)
+
+	acceptC chan Connection
+	closedC chan struct{}
+	once    sync.Once
+}
+
+// NewStreamTransport returns the stream transport with default params
+func NewStreamTransport() *StreamTransport {
+	return &StreamTransport{
+		PingInterval: StreamDefaultPingInterval,
+		PingTimeout:  StreamDefaultPingTimeout,
+		acceptC:      make(chan Connection),
+		closedC:      make(chan struct{}),
+	}
+}
+
+// Connect opens the stream with Dial
+func (t *StreamTransport) Connect(url string) (Connection, error) {
+	if t.Dial == nil {
+		return nil, errStreamNotDialable
+	}
+	stream, close, err := t.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return NewStreamConnection(stream, close, t.PingInterval, t.PingTimeout), nil
+}
+
+// Handle passes the stream opened by the client to the server accepting the connections and blocks
+// until the connection is closed, so it's called from the gRPC stream handler returning afterwards.
+// It fails if the transport is closed
+func (t *StreamTransport) Handle(stream PacketStream) error {
+	conn := NewStreamConnection(stream, nil, t.PingInterval, t.PingTimeout)
+	select {
+	case t.acceptC <- conn:
+	case <-t.closedC:
+		return errStreamClosed
+	}
+	<-conn.Done()
+	return nil
+}
+
+// Accept waits for the stream passed to Handle
+func (t *StreamTransport) Accept() (Connection, error) {
+	select {
+	case conn := <-t.acceptC:
+		return conn, nil
+	case <-t.closedC:
+		return nil, errStreamClosed
+	}
+}
+
+// Close stops accepting the streams, the connections established before stay open
+func (t *StreamTransport) Close() error {
+	t.once.Do(func() { close(t.closedC) })
+	return nil
+}
+
+// HandleConnection fails, the stream transport does not serve HTTP requests
+func (t *StreamTransport) HandleConnection(w http.ResponseWriter, r *http.Request) (Connection, error) {
+	http.Error(w, errStreamNotHTTP.Error(), http.StatusNotImplemented)
+	return nil, errStreamNotHTTP
+}
+
+// Serve does nothing, the stream transport does not serve HTTP requests
+func (t *StreamTransport) Serve(w http.ResponseWriter, r *http.Request) {}
+
+// SetSid does nothing for the stream transport
+func (t *StreamTransport) SetSid(string, Connection) {}
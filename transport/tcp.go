@@ -0,0 +1,220 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	TCPDefaultPingInterval   = 30 * time.Second
+	TCPDefaultPingTimeout    = 60 * time.Second
+	TCPDefaultReceiveTimeout = 60 * time.Second
+	TCPDefaultSendTimeout    = 60 * time.Second
+	TCPDefaultMaxFrameSize   = 4 * 1024 * 1024
+
+	tcpScheme = "tcp://"
+	tlsScheme = "tls://"
+)
+
+var (
+	errFrameTooLarge = errors.New("frame is too large")
+	errTCPNotHTTP    = errors.New("tcp transport does not serve HTTP requests")
+)
+
+// TCPConnection is the engine.io connection over TCP or TLS, each packet is framed with its length
+// in bytes as 4 bytes big-endian unsigned integer
+type TCPConnection struct {
+	transport *TCPTransport
+	conn      net.Conn
+	r         *bufio.Reader
+	writeMu   sync.Mutex
+
+	receiveTimeout int64 // overrides the transport ReceiveTimeout if not zero, accessed atomically
+	sendTimeout    int64 // overrides the transport SendTimeout if not zero, accessed atomically
+}
+
+// newTCPConnection wraps conn of the transport t
+func newTCPConnection(t *TCPTransport, conn net.Conn) *TCPConnection {
+	return &TCPConnection{transport: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// GetMessage reads the next frame
+func (tcp *TCPConnection) GetMessage() (string, error) {
+	if err := tcp.conn.SetReadDeadline(readDeadline(tcp.getReceiveTimeout())); err != nil {
+		return "", err
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(tcp.r, header[:]); err != nil {
+		return "", err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if max := tcp.transport.MaxFrameSize; max > 0 && int64(size) > int64(max) {
+		return "", errFrameTooLarge
+	}
+
+	message := make([]byte, size)
+	if _, err := io.ReadFull(tcp.r, message); err != nil {
+		return "", err
+	}
+	return string(message), nil
+}
+
+// SetReceiveTimeout overrides the transport ReceiveTimeout of the connection, zero restores it.
+// It applies from the next message wait
+func (tcp *TCPConnection) SetReceiveTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&tcp.receiveTimeout, int64(timeout))
+}
+
+// getReceiveTimeout returns the timeout of the message wait
+func (tcp *TCPConnection) getReceiveTimeout() time.Duration {
+	if timeout := atomic.LoadInt64(&tcp.receiveTimeout); timeout != 0 {
+		return time.Duration(timeout)
+	}
+	return tcp.transport.ReceiveTimeout
+}
+
+// SetSendTimeout overrides the transport SendTimeout of the connection, zero restores it.
+// It applies from the next message write
+func (tcp *TCPConnection) SetSendTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&tcp.sendTimeout, int64(timeout))
+}
+
+// getSendTimeout returns the timeout of the message write
+func (tcp *TCPConnection) getSendTimeout() time.Duration {
+	if timeout := atomic.LoadInt64(&tcp.sendTimeout); timeout != 0 {
+		return time.Duration(timeout)
+	}
+	return tcp.transport.SendTimeout
+}
+
+// WriteMessage writes the message as a frame
+func (tcp *TCPConnection) WriteMessage(message string) error {
+	if max := tcp.transport.MaxFrameSize; max > 0 && len(message) > max {
+		return errFrameTooLarge
+	}
+	frame := make([]byte, 4+len(message))
+	binary.BigEndian.PutUint32(frame, uint32(len(message)))
+	copy(frame[4:], message)
+
+	tcp.writeMu.Lock()
+	defer tcp.writeMu.Unlock()
+	if err := tcp.conn.SetWriteDeadline(time.Now().Add(tcp.getSendTimeout())); err != nil {
+		return err
+	}
+	_, err := tcp.conn.Write(frame)
+	return err
+}
+
+// Close the connection
+func (tcp *TCPConnection) Close() error { return tcp.conn.Close() }
+
+// PingParams returns ping params
+func (tcp *TCPConnection) PingParams() (time.Duration, time.Duration) {
+	return tcp.transport.PingInterval, tcp.transport.PingTimeout
+}
+
+// RemoteAddr returns the address of the peer
+func (tcp *TCPConnection) RemoteAddr() string { return tcp.conn.RemoteAddr().String() }
+
+// TCPTransport connects the clients of this package to the server over TCP or TLS without HTTP,
+// for the backend-to-backend links. The client connects to "tcp://host:port" or "tls://host:port" url,
+// the server accepts the connections from TCPListener with Server.Serve()
+type TCPTransport struct {
+	PingInterval   time.Duration
+	PingTimeout    time.Duration
+	ReceiveTimeout time.Duration // per-read deadline, zero disables it, see Server.SetHeartbeatTimeout
+	SendTimeout    time.Duration // also used as the dial timeout
+	MaxFrameSize   int           // maximum size of the packet in bytes, zero means unlimited
+
+	TLSClientConfig *tls.Config // used to connect to tls:// urls, nil means the default config
+	TLSServerConfig *tls.Config // if set, Listen accepts TLS connections
+}
+
+// DefaultTCPTransport returns TCPTransport with default params
+func DefaultTCPTransport() *TCPTransport {
+	return &TCPTransport{
+		PingInterval:   TCPDefaultPingInterval,
+		PingTimeout:    TCPDefaultPingTimeout,
+		ReceiveTimeout: TCPDefaultReceiveTimeout,
+		SendTimeout:    TCPDefaultSendTimeout,
+		MaxFrameSize:   TCPDefaultMaxFrameSize,
+	}
+}
+
+// Connect to the "tcp://host:port" or "tls://host:port" url, the url without scheme is connected over TCP
+func (t *TCPTransport) Connect(url string) (Connection, error) {
+	dialer := &net.Dialer{Timeout: t.SendTimeout}
+	if strings.HasPrefix(url, tlsScheme) {
+		conn, err := tls.DialWithDialer(dialer, "tcp", strings.TrimPrefix(url, tlsScheme), t.TLSClientConfig)
+		if err != nil {
+			return nil, err
+		}
+		return newTCPConnection(t, conn), nil
+	}
+
+	conn, err := dialer.Dial("tcp", strings.TrimPrefix(url, tcpScheme))
+	if err != nil {
+		return nil, err
+	}
+	return newTCPConnection(t, conn), nil
+}
+
+// HandleConnection fails, the tcp transport does not serve HTTP requests
+func (t *TCPTransport) HandleConnection(w http.ResponseWriter, r *http.Request) (Connection, error) {
+	http.Error(w, errTCPNotHTTP.Error(), http.StatusNotImplemented)
+	return nil, errTCPNotHTTP
+}
+
+// Serve does nothing, the tcp transport does not serve HTTP requests
+func (t *TCPTransport) Serve(w http.ResponseWriter, r *http.Request) {}
+
+// SetSid does nothing for the tcp transport
+func (t *TCPTransport) SetSid(string, Connection) {}
+
+// Listen on the TCP address, the connections are TLS ones if TLSServerConfig is set
+func (t *TCPTransport) Listen(addr string) (*TCPListener, error) {
+	var (
+		l   net.Listener
+		err error
+	)
+	if t.TLSServerConfig != nil {
+		l, err = tls.Listen("tcp", addr, t.TLSServerConfig)
+	} else {
+		l, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &TCPListener{transport: t, listener: l}, nil
+}
+
+// TCPListener is the Acceptor of the TCPTransport connections
+type TCPListener struct {
+	transport *TCPTransport
+	listener  net.Listener
+}
+
+// Accept waits for the client to connect
+func (l *TCPListener) Accept() (Connection, error) {
+	conn, err := l.listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newTCPConnection(l.transport, conn), nil
+}
+
+// Addr returns the listening address
+func (l *TCPListener) Addr() net.Addr { return l.listener.Addr() }
+
+// Close stops listening, the connections established before stay open
+func (l *TCPListener) Close() error { return l.listener.Close() }
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"net"
 	"net/http"
 	"time"
 )
@@ -13,6 +14,36 @@ type Connection interface {
 	PingParams() (interval, timeout time.Duration)
 }
 
+// ReceiveTimeoutSetter is implemented by the connections allowing to override the transport ReceiveTimeout,
+// zero timeout restores it
+type ReceiveTimeoutSetter interface {
+	SetReceiveTimeout(timeout time.Duration)
+}
+
+// SendTimeoutSetter is implemented by the connections allowing to override the transport SendTimeout,
+// zero timeout restores it
+type SendTimeoutSetter interface {
+	SetSendTimeout(timeout time.Duration)
+}
+
+// IsWriteTimeout returns true if err means the message wasn't written within the send timeout
+func IsWriteTimeout(err error) bool {
+	if err == errWriteMessageTimeout {
+		return true
+	}
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// readDeadline returns the deadline of the read starting now with the given timeout,
+// the zero time which means no deadline if the timeout isn't positive
+func readDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
 // Transport represents a connection transport
 type Transport interface {
 	Connect(url string) (conn Connection, err error)
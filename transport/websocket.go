@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -25,6 +26,12 @@ const (
 type WebsocketTransportParams struct {
 	Headers         http.Header
 	TLSClientConfig *tls.Config
+	Jar             http.CookieJar
+	Subprotocols    []string
+
+	ReadBufferSize  int                  // default is used if zero
+	WriteBufferSize int                  // default is used if zero
+	WriteBufferPool websocket.BufferPool // shares write buffers between connections, may be nil
 }
 
 var (
@@ -37,14 +44,16 @@ var (
 
 // WebsocketConnection represents websocket connection
 type WebsocketConnection struct {
-	socket    *websocket.Conn
-	transport *WebsocketTransport
+	socket         *websocket.Conn
+	transport      *WebsocketTransport
+	receiveTimeout int64 // overrides the transport ReceiveTimeout if not zero, accessed atomically
+	sendTimeout    int64 // overrides the transport SendTimeout if not zero, accessed atomically
 }
 
 // GetMessage from the connection
 func (ws *WebsocketConnection) GetMessage() (string, error) {
 	logging.Log().Debug("WebsocketConnection.GetMessage() fired")
-	ws.socket.SetReadDeadline(time.Now().Add(ws.transport.ReceiveTimeout))
+	ws.socket.SetReadDeadline(readDeadline(ws.getReceiveTimeout()))
 
 	msgType, reader, err := ws.socket.NextReader()
 	if err != nil {
@@ -82,7 +91,7 @@ func (t *WebsocketTransport) SetSid(string, Connection) {}
 // WriteMessage message m into a connection
 func (ws *WebsocketConnection) WriteMessage(m string) error {
 	logging.Log().Debug("WebsocketConnection.WriteMessage() fired with:", m)
-	ws.socket.SetWriteDeadline(time.Now().Add(ws.transport.SendTimeout))
+	ws.socket.SetWriteDeadline(time.Now().Add(ws.getSendTimeout()))
 
 	writer, err := ws.socket.NextWriter(websocket.TextMessage)
 	if err != nil {
@@ -96,12 +105,64 @@ func (ws *WebsocketConnection) WriteMessage(m string) error {
 	return writer.Close()
 }
 
+// WriteMessages into a connection one by one, websocket frame carries a single message
+func (ws *WebsocketConnection) WriteMessages(messages []string) error {
+	for _, m := range messages {
+		if err := ws.WriteMessage(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close the connection
 func (ws *WebsocketConnection) Close() error {
 	logging.Log().Debug("WebsocketConnection.Close() fired")
 	return ws.socket.Close()
 }
 
+// WriteClose sends the close frame with the given code and text, the connection should be closed after it
+func (ws *WebsocketConnection) WriteClose(code int, text string) error {
+	message := websocket.FormatCloseMessage(code, text)
+	return ws.socket.WriteControl(websocket.CloseMessage, message, time.Now().Add(ws.getSendTimeout()))
+}
+
+// Subprotocol returns the negotiated websocket subprotocol, empty if none was negotiated
+func (ws *WebsocketConnection) Subprotocol() string { return ws.socket.Subprotocol() }
+
+// Underlying returns the underlying gorilla websocket connection.
+// It allows to set options (read limit, pong handler etc) not exposed by the transport,
+// reading or writing messages directly would break the socket.io session
+func (ws *WebsocketConnection) Underlying() *websocket.Conn { return ws.socket }
+
+// SetReceiveTimeout overrides the transport ReceiveTimeout of the connection, zero restores it.
+// It applies from the next message read
+func (ws *WebsocketConnection) SetReceiveTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&ws.receiveTimeout, int64(timeout))
+}
+
+// getReceiveTimeout returns the timeout of the message read
+func (ws *WebsocketConnection) getReceiveTimeout() time.Duration {
+	if timeout := atomic.LoadInt64(&ws.receiveTimeout); timeout != 0 {
+		return time.Duration(timeout)
+	}
+	return ws.transport.ReceiveTimeout
+}
+
+// SetSendTimeout overrides the transport SendTimeout of the connection, zero restores it.
+// It applies from the next message write
+func (ws *WebsocketConnection) SetSendTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&ws.sendTimeout, int64(timeout))
+}
+
+// getSendTimeout returns the timeout of the message write
+func (ws *WebsocketConnection) getSendTimeout() time.Duration {
+	if timeout := atomic.LoadInt64(&ws.sendTimeout); timeout != 0 {
+		return time.Duration(timeout)
+	}
+	return ws.transport.SendTimeout
+}
+
 // PingParams returns ping params
 func (ws *WebsocketConnection) PingParams() (time.Duration, time.Duration) {
 	return ws.transport.PingInterval, ws.transport.PingTimeout
@@ -111,22 +172,33 @@ func (ws *WebsocketConnection) PingParams() (time.Duration, time.Duration) {
 type WebsocketTransport struct {
 	PingInterval   time.Duration
 	PingTimeout    time.Duration
-	ReceiveTimeout time.Duration
+	ReceiveTimeout time.Duration // per-read deadline, zero disables it, see Server.SetHeartbeatTimeout
 	SendTimeout    time.Duration
 
-	BufferSize      int
+	ReadBufferSize  int
+	WriteBufferSize int
+	WriteBufferPool websocket.BufferPool // if set, write buffers are taken from it only while writing, may be nil
 	Headers         http.Header
 	TLSClientConfig *tls.Config
+	Jar             http.CookieJar // persists handshake cookies across connections, may be nil
+	Subprotocols    []string       // subprotocols requested by the client or supported by the server, in order of preference
 }
 
 // Connect to the given url
 func (t *WebsocketTransport) Connect(url string) (Connection, error) {
-	dialer := websocket.Dialer{TLSClientConfig: t.TLSClientConfig}
+	dialer := websocket.Dialer{
+		TLSClientConfig: t.TLSClientConfig,
+		Jar:             t.Jar,
+		Subprotocols:    t.Subprotocols,
+		ReadBufferSize:  t.ReadBufferSize,
+		WriteBufferSize: t.WriteBufferSize,
+		WriteBufferPool: t.WriteBufferPool,
+	}
 	socket, _, err := dialer.Dial(url, t.Headers)
 	if err != nil {
 		return nil, err
 	}
-	return &WebsocketConnection{socket, t}, nil
+	return &WebsocketConnection{socket: socket, transport: t}, nil
 }
 
 // HandleConnection
@@ -137,15 +209,17 @@ func (t *WebsocketTransport) HandleConnection(w http.ResponseWriter, r *http.Req
 	}
 
 	socket, err := (&websocket.Upgrader{
-		ReadBufferSize:  t.BufferSize,
-		WriteBufferSize: t.BufferSize,
+		ReadBufferSize:  t.ReadBufferSize,
+		WriteBufferSize: t.WriteBufferSize,
+		WriteBufferPool: t.WriteBufferPool,
+		Subprotocols:    t.Subprotocols,
 	}).Upgrade(w, r, nil)
 	if err != nil {
 		http.Error(w, upgradeFailed+err.Error(), http.StatusServiceUnavailable)
 		return nil, errHttpUpgradeFailed
 	}
 
-	return &WebsocketConnection{socket, t}, nil
+	return &WebsocketConnection{socket: socket, transport: t}, nil
 }
 
 // Serve does nothing here. Websocket connection does not require any additional processing
@@ -154,11 +228,12 @@ func (t *WebsocketTransport) Serve(w http.ResponseWriter, r *http.Request) {}
 // DefaultWebsocketTransport returns websocket connection with default params
 func DefaultWebsocketTransport() *WebsocketTransport {
 	return &WebsocketTransport{
-		PingInterval:   wsDefaultPingInterval,
-		PingTimeout:    wsDefaultPingTimeout,
-		ReceiveTimeout: wsDefaultReceiveTimeout,
-		SendTimeout:    wsDefaultSendTimeout,
-		BufferSize:     wsDefaultBufferSize,
+		PingInterval:    wsDefaultPingInterval,
+		PingTimeout:     wsDefaultPingTimeout,
+		ReceiveTimeout:  wsDefaultReceiveTimeout,
+		SendTimeout:     wsDefaultSendTimeout,
+		ReadBufferSize:  wsDefaultBufferSize,
+		WriteBufferSize: wsDefaultBufferSize,
 	}
 }
 
@@ -167,5 +242,14 @@ func NewWebsocketTransport(params WebsocketTransportParams) *WebsocketTransport
 	tr := DefaultWebsocketTransport()
 	tr.Headers = params.Headers
 	tr.TLSClientConfig = params.TLSClientConfig
+	tr.Jar = params.Jar
+	tr.Subprotocols = params.Subprotocols
+	if params.ReadBufferSize > 0 {
+		tr.ReadBufferSize = params.ReadBufferSize
+	}
+	if params.WriteBufferSize > 0 {
+		tr.WriteBufferSize = params.WriteBufferSize
+	}
+	tr.WriteBufferPool = params.WriteBufferPool
 	return tr
 }
@@ -0,0 +1,27 @@
+package gosocketio
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+	"github.com/mtfelian/synced"
+)
+
+var (
+	ErrorPacketExpired = errors.New("packet expired before it was written")
+
+	expiredPackets synced.Counter
+)
+
+// expired returns true if the packet p has a TTL and it's elapsed at the moment now
+func (p *packet) expired(now time.Time) bool { return !p.expires.IsZero() && now.After(p.expires) }
+
+// EmitWithTTL acts like Emit but the message is dropped if it's still queued after ttl elapsed
+func (c *Channel) EmitWithTTL(name string, payload interface{}, ttl time.Duration) error {
+	message := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: name}
+	return c.sendWith(message, payload, &packet{expires: c.events.now().Add(ttl)}, true)
+}
+
+// CountExpiredPackets returns an amount of packets dropped because their TTL elapsed
+func CountExpiredPackets() int { return expiredPackets.Get() }
@@ -0,0 +1,78 @@
+package gosocketio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mtfelian/golang-socketio/protocol"
+)
+
+// WriteTimeoutError is the error of the packet not written within the send timeout, the connection
+// is closed after it as the transport may have written the packet partially
+type WriteTimeoutError struct {
+	Packet  string        // encoded packet, the first one of the batch
+	Timeout time.Duration // send timeout of the packet, zero if the transport one was used
+	Err     error         // transport error
+}
+
+// Error implements error
+func (e *WriteTimeoutError) Error() string {
+	return fmt.Sprintf("packet write timed out: %v", e.Err)
+}
+
+// SetSendTimeout overrides the transport SendTimeout for the packets written to the Channel,
+// zero restores it. EmitWithDeadline overrides it for a single message
+func (c *Channel) SetSendTimeout(timeout time.Duration) {
+	c.sendTimeoutMu.Lock()
+	c.sendTimeout = timeout
+	c.sendTimeoutMu.Unlock()
+}
+
+// getSendTimeout returns the send timeout of the Channel, zero if the transport one is used
+func (c *Channel) getSendTimeout() time.Duration {
+	c.sendTimeoutMu.Lock()
+	defer c.sendTimeoutMu.Unlock()
+	return c.sendTimeout
+}
+
+// sendTimeoutOf returns the send timeout of the packet p, zero if the transport one is used
+func (c *Channel) sendTimeoutOf(p *packet) time.Duration {
+	if p.sendTimeout != 0 {
+		return p.sendTimeout
+	}
+	return c.getSendTimeout()
+}
+
+// EmitWithDeadline acts like Emit but the message should be written within the given timeout
+// instead of the send timeout of the Channel, the timeout starts when the message leaves the queue.
+// On timeout the OnWriteTimeout handler is called and the connection is closed
+func (c *Channel) EmitWithDeadline(name string, payload interface{}, timeout time.Duration) error {
+	message := &protocol.Message{Type: protocol.MessageTypeEmit, EventName: name}
+	return c.sendWith(message, payload, &packet{sendTimeout: timeout}, true)
+}
+
+// OnWriteTimeout registers a handler called when the packet isn't written within the send timeout,
+// before the connection is closed
+func (e *event) OnWriteTimeout(f func(c *Channel, err *WriteTimeoutError)) {
+	e.handlersMu.Lock()
+	e.onWriteTimeout = f
+	e.handlersMu.Unlock()
+}
+
+// writeTimedOut returns the WriteTimeoutError of the packet p failed with the transport error err
+// and calls the OnWriteTimeout handler if it's registered
+func (c *Channel) writeTimedOut(e *event, p *packet, err error) error {
+	timeoutErr := &WriteTimeoutError{Packet: p.message, Timeout: c.sendTimeoutOf(p), Err: err}
+	if p.batch != nil {
+		timeoutErr.Packet = p.batch[0]
+	}
+
+	e.handlersMu.RLock()
+	f := e.onWriteTimeout
+	e.handlersMu.RUnlock()
+
+	if f != nil {
+		f(c, timeoutErr)
+	}
+	return timeoutErr
+}